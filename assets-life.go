@@ -16,7 +16,8 @@
 //
 //     import (
 //         "net/http"
-//         "./public" // TODO: Replace with the absolute import path
+//
+//         "example.com/your/project/public"
 //     )
 //
 //     func main() {
@@ -26,455 +27,1850 @@
 //
 // Visit http://localhost:8080/path/to/file to see your file.
 //
+// The import path of the generated package is detected from the enclosing go.mod,
+// and it is documented in the generated doc.go.
+// The -internal option generates the package into the internal directory next to OUTPUT_DIR,
+// so it cannot be imported from outside of the module.
+//
+//     assets-life -internal /path/to/your/project/public public # generates internal/public
+//
+// The -own-module option generates go.mod for the generated package.
+// The heavy package can be a separate module, so the consumers of your module don't download it.
+//
+//     assets-life -own-module example.com/your/project/public /path/to/your/project/public public
+//
+// SeedT of the generated package extracts the embedded files into a temporary directory of the test for the fixtures.
+// OpenContext of the generated package opens the files with the context, for the future backends that honor the cancellation.
+// NewOverlay of the generated package returns a writable in-memory file system over the embedded files for tests.
+// SetOpenHook of the generated package intercepts opening the embedded files to shadow some of them at runtime.
+// LoadBundle of the generated package loads the files in a signed bundle at runtime, falling back to the embedded files.
+// RootWithFallback of the generated package opens the files in a directory on the disk if they are not embedded.
+// Stats of the generated package reports the memory used by the embedded files.
+// APIVersion of the generated package is the version of its layout, and APIVersionOf detects it from a file system.
+// The -sign-key option signs the manifest of the files with an Ed25519 key, and VerifySignature of the generated package verifies it.
+// ByHash of the generated package opens the files by the SHA-256 hashes of their contents, and ContentAddressable serves them under /_cas/.
+// Sys of the fs.FileInfo of the embedded files returns *FileMeta, the hash, the content type, the compressed size and the source name of the file.
+//
+// Readdir of the generated package lists the entries sorted by name.
+// The -dirs-first option lists the directories before the files.
+// Readdir(n) returns io.EOF only when no entries remain, as os.File does, and ReadDir returns the entries as fs.DirEntry.
+//
+// The -normalize-eol option normalizes the line endings of the text files to lf or crlf,
+// and the -strip-bom option removes the UTF-8 byte order marks, so the embedded bytes do not depend on the checkout.
+// The -html-base, -html-meta and -html-inject options set the base element, the meta elements and a snippet, e.g. of the analytics,
+// into the head elements of the HTML files, so one tree is specialized for each deployment.
+// The -convert-charset option converts the text files in the legacy charsets to UTF-8 with iconv,
+// and OriginalCharset of the generated package returns their original charsets.
+// The -gzip-sources option serves the pre-compressed files, e.g. app.js.gz, to the clients that accept gzip, or decompresses them.
+// The -compression gzip option adds the gzip variants of the text files, which are served in the same way.
+// The -source-maps option removes the source maps and the sourceMappingURL comments, or embeds them only with the debug build tag.
+// The -notices option embeds NOTICES, the license files and the banner comments of JavaScript and CSS,
+// and Licenses of the generated package returns them.
+//
+// The -bench option generates filesystem_bench_test.go, the benchmarks of the generated package.
+//
+// The -unsafe-bytes option generates Bytes, which returns the content of a file as []byte without copying it.
+// The returned slice shares the memory with the embedded string, so it must not be modified.
+//
+// The -no-net option generates the package that implements fs.FS without net/http, e.g. for TinyGo,
+// and Root, the wrapper for net/http, into the separate file guarded by the build tags.
+//
+// The generated package has only the table of the files and the thin wiring by default,
+// and the runtime is imported from github.com/shogo82148/assets-life/assetsfs,
+// so the fixes of the runtime are delivered by updating the module without regenerating the package.
+// The -standalone option writes the copy of the runtime into filesystem-runtime.go instead,
+// so the package depends only on the standard library.
+//
+// The -backend option selects how the contents are stored in the generated code.
+// The default, string, writes them as the string literals. base64 writes them in base64,
+// zip embeds a zip file compressed by deflate with go:embed, and embed embeds a file per content with go:embed.
+// The files embedded by go:embed are written into the assets-life-data directory of the output directory,
+// so commit them with the generated files.
+//
+// The generated package also has Handler and Mount, which serve the files with http.ServeMux under a prefix.
+//
+//     public.Mount(http.DefaultServeMux, "/static/")
+//
+// Composite serves the file systems of several generated packages under the prefixes with the same options.
+//
+// The CleanURLs option serves the HTML files without the extension, e.g. /about serves /about.html.
+// The TrailingSlash option redirects the URLs to add or strip the trailing slashes consistently.
+// The Languages option serves the per-language subtrees, e.g. /en/ and /ja/, with the content negotiation.
+// The Metrics option reports the metrics of each request, e.g. to the collector for Prometheus generated by -adapters prometheus.
+// The AccessLog option writes the access logs in Common Log Format.
+// The Throttle option limits the bandwidth and the rate of the requests.
+// The Authorize and BasicAuth options protect the files that match the pattern.
+// The CORS option adds the CORS headers to the responses of the files that match the pattern.
+// The CSPNonce option injects a new nonce of Content-Security-Policy into the HTML files for each request.
+// The JSONListing and ListingTemplate options customize the listings of the directories.
+//
+// The -fingerprint option adds the hashes of the contents to the names of the files except HTML,
+// e.g. /css/app.1a2b3c4d.css, and rewrites the references in HTML and CSS.
+// Use Fingerprint of the generated package to find the new names.
+// The -hash option selects the hash algorithm of the fingerprints and the other digests, crc32, sha1, sha256 or blake3.
+//
+// With the -preload option, the critical CSS and JavaScript of the HTML files are found,
+// and the PreloadHeaders and EarlyHints options send the Link headers that preload them.
+//
+// The -precache option embeds precache-manifest.json, which lists the files and their revisions in the format of Workbox.
+// The -service-worker option also embeds sw.js, the service worker that precaches the files for offline use.
+//
+// The -adapters option generates the adapters for the web frameworks,
+// ChiMount for chi, EchoHandler for echo, FiberHandler for fiber and GinHandler for gin.
+// -adapters prometheus generates NewPrometheusCollector, which collects the metrics of the Metrics option,
+// -adapters expvar publishes assets.hits, assets.bytes and assets.source_hash of all handlers to expvar without any options,
+// -adapters otel generates the OTelTracing option, which starts a span of OpenTelemetry for each request,
+// -adapters slog generates the SlogAccessLog option, which writes the access logs with log/slog,
+// -adapters afero generates AferoFs, which returns the read-only afero.Fs of the files, and ExtractToAfero, which writes the files into an afero.Fs,
+// -adapters billy generates BillyFilesystem, which returns the read-only billy.Filesystem of the files for go-git,
+// and -adapters js generates ReadJS and ExposeJS, which expose the files to JavaScript on GOOS=js.
+// The generated package depends on the frameworks, so add them to your module.
+//
+//     assets-life -adapters chi,echo /path/to/your/project/public public
+//
+// The serve subcommand is the development server that serves the files in the directory from the disk.
+// It serves TLS and HTTP/2 with -tls-cert and -tls-key, or with a new self-signed certificate by -tls-self-signed.
+// With -live, it reloads the HTML pages in the browsers when the files change.
+//
+//     assets-life serve -live -tls-self-signed /path/to/your/project/public
+//
+// The sync subcommand uploads the files to the bucket of S3, of Cloud Storage with gs://,
+// or to the container of Azure Blob Storage with az://, skipping the unchanged objects.
+//
+//     assets-life sync -fingerprint /path/to/your/project/public s3://bucket/prefix
+//
 // The assets-life command also embed go:generate directive into generated code, and assets-life itself.
 // It allows you to re-generate the package using go generate.
 //
 //     go generate ./public
 //
+// The upgrade subcommand regenerates the packages with the current assets-life,
+// with the input and the options in their go:generate directives,
+// and reports the layout versions of the packages before and after the upgrade.
+//
+//     assets-life upgrade ./public ./internal/docs
+//
+// The diff subcommand lists the files added, deleted and modified in the input directory since the package was generated,
+// with the size deltas, and -u shows the unified diffs of the text files.
+//
+//     assets-life diff -u /path/to/your/project/public ./public
+//
+// The diff-pkg subcommand lists the files added, deleted and modified between two generated packages or bundles,
+// with the sizes and the SHA-256 hashes, e.g. to review the changes between the releases.
+//
+//     assets-life diff-pkg ./public_v1 ./public_v2
+//
+// The bundle subcommand writes the files into a bundle signed with -sign-key,
+// which LoadBundle of the generated package loads at runtime.
+//
+//     assets-life bundle -sign-key assets.key /path/to/your/project/public assets.alb
+//
+// The ls and extract subcommands list the files in a bundle or a generated package, and write them into a directory.
+//
+//     assets-life extract assets.alb ./public
+//
 // The assets-life command is no longer needed because it is embedded into the generated package.
+//
+// The -max-memory option generates the package of the tree larger than the memory.
+// The contents are streamed into the files embedded by go:embed, as -backend embed does,
+// so at most the given megabytes of them are held in memory. The options that read the contents are not available.
+//
+// The -check-compile option checks the generated package with go build or go vet before writing it,
+// so the package that doesn't compile never replaces the previous one.
+//
+// The progress, warnings and errors are reported to stderr with their levels.
+// Use -log-format json to report them as JSON objects, one per line.
+// The -q option suppresses the progress and the info messages.
+//
+// The exit status tells the class of the failure: 1 for the unclassified errors, 2 for the invalid command line,
+// 3 for the warnings with -strict, 4 for the failures to read or write the files,
+// 5 for the invalid inputs, e.g. the configuration file or the package that fails -check-compile, and 6 for the differences found by the diff and diff-pkg subcommands.
+//
+// The Go programs, e.g. the other code generators, can generate the package without running the command
+// with github.com/shogo82148/assets-life/assetslife, which is the copy of assets-life.go as a library.
+//
+//     err := assetslife.New("web/dist", "public", assetslife.WithExclude("*.map")).Build(ctx)
+//
+// The generated code is rendered from a text/template template.
+// Use the -template option to customize it.
+//
+//     assets-life -template custom.tmpl /path/to/your/project/public public
+//
+// The data passed to the template is described by the templateData type.
+//
+// By default, all files in INPUT_DIR are embedded except hidden files.
+// The files that look like the build artifacts, e.g. node_modules, *.log and core dumps, are warned of,
+// and the -allow-artifacts option allows the patterns of them.
+// The -max-files and -max-depth options fail the generation of the runaway trees, e.g. the loops of the bind mounts.
+// Use the -files-from option to embed exactly the listed files, e.g. the files tracked by git.
+// The listed paths are relative to INPUT_DIR, or the current directory if INPUT_DIR is omitted.
+//
+//     git ls-files | assets-life -files-from - public
+//
+// Use the -git-ref option to embed the files at the git revision instead of the working tree.
+// It guarantees that the embedded files match the release even if the working tree is dirty.
+//
+//     assets-life -git-ref v1.2.3 /path/to/your/project/public public
+//
+// With the -export-ignore option, the files with the export-ignore attribute of .gitattributes are excluded,
+// as git archive does.
+//
+// INPUT_DIR can also be a zip or tar archive (.zip, .tar, .tar.gz or .tgz), or a bundle (.alb).
+// The directory structure in the archive is preserved.
+//
+//     assets-life dist.zip public
+//
+// Use the -remote option to download and embed third-party files.
+// Each line of the manifest is "URL NAME [sha256:HEX]".
+// The files pinned by the checksums are cached, and they are not downloaded again.
+//
+//     assets-life -remote remote.txt /path/to/your/project/public public
+//
+// The -config option reads the configuration file in JSON.
+// It can define variants, which are asset sets selected by build tags.
+// Each variant is generated into a file guarded by the build tag of its name,
+// e.g. "go build -tags staging" embeds the staging variant.
+// The -variant option selects the variant embedded when no variant tags are set.
+//
+//     {
+//         "variants": {
+//             "staging": {"input": "dist/staging"},
+//             "prod": {"input": "dist/prod", "exclude": ["*.map"]}
+//         }
+//     }
+//
+// It can also define platform rules, which embed files only on specific platforms.
+//
+//     {
+//         "platforms": [
+//             {"include": ["/bin/windows"], "only": ["windows"]},
+//             {"include": ["/bin/linux-amd64"], "only": ["linux/amd64"]}
+//         ]
+//     }
+//
+// And image rules, which add the resized variants and the WebP or AVIF alternates of the images.
+// The alternates are converted by cwebp and avifenc, and NegotiateImages serves them by the Accept header.
+//
+//     {
+//         "images": [
+//             {"include": ["/img/**/*.jpg"], "widths": [320, 640], "formats": ["webp", "avif"]}
+//         ]
+//     }
+//
+// The -strip-metadata option removes the metadata, e.g. EXIF, from the JPEG and PNG images.
+//
+// And the markdown rule, which renders the Markdown files to HTML with the wrapper template.
+//
+//     {
+//         "markdown": {"include": ["/docs"], "template": "layout.html"}
+//     }
+//
+// And the templates rule, which executes the text files as text/template with the variables given by the -var options.
+//
+//     {
+//         "templates": {"include": ["/config.js"], "vars": {"API_BASE": "/api"}}
+//     }
+//
+// And the budgets, which fail the generation with the breakdown of the largest files
+// if the total size of the files that match the pattern exceeds the size, in KB, MB or GB of 1024.
+//
+//     {
+//         "budgets": ["/js/** <= 500KB", "/img/** <= 2MB"]
+//     }
+//
+// And the robots and security rules, which generate /robots.txt and /.well-known/security.txt of RFC 9116,
+// so they don't have to be in the input directory. They are not fingerprinted.
+//
+//     {
+//         "robots": {"disallow": ["/admin/"], "sitemaps": ["https://example.com/sitemap.xml"]},
+//         "security": {"contact": ["mailto:security@example.com"], "expires": "2027-01-01T00:00:00Z"}
+//     }
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/shogo82148/assets-life/assetslife"
 )
 
-func main() {
-	if len(os.Args) <= 2 {
-		log.Println("Usage:")
-		log.Println(os.Args[0] + " INPUT_DIR OUTPUT_DIR [PACKAGE_NAME]")
-		os.Exit(2)
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if args[0] == "generate" {
+			args = args[1:]
+		} else if run, ok := subcommands[args[0]]; ok && !isDir(args[0]) {
+			run(args[1:])
+			return
+		}
+	}
+	runGenerate(args)
+}
+
+// subcommands are the subcommands of the command.
+// The first argument that is an existing directory is the input directory, not a subcommand,
+// so "assets-life sync out" embeds the directory sync as it did before the subcommands.
+var subcommands = map[string]func(args []string){
+	"serve":    runServe,
+	"sync":     runSync,
+	"upgrade":  runUpgrade,
+	"diff":     runDiff,
+	"diff-pkg": runDiffPkg,
+	"bundle":   runBundle,
+	"ls":       runLs,
+	"extract":  runExtract,
+}
+
+// isDir reports whether name is an existing directory.
+func isDir(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.IsDir()
+}
+
+// runGenerate runs the generator, the subcommand by default, which is the Builder of the assetslife package.
+func runGenerate(args []string) {
+	log := assetslife.NewLogger(os.Stderr)
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage:")
+		fmt.Fprintln(w, os.Args[0]+" [generate] [OPTIONS] INPUT_DIR|INPUT_ARCHIVE OUTPUT_DIR [PACKAGE_NAME]")
+		fmt.Fprintln(w, os.Args[0]+" [generate] [OPTIONS] -files-from FILE [INPUT_DIR] OUTPUT_DIR [PACKAGE_NAME]")
+		fmt.Fprintln(w, os.Args[0]+" serve [OPTIONS] INPUT_DIR")
+		fmt.Fprintln(w, os.Args[0]+" sync [OPTIONS] INPUT_DIR s3://BUCKET/PREFIX|gs://BUCKET/PREFIX|az://CONTAINER/PREFIX")
+		fmt.Fprintln(w, os.Args[0]+" upgrade [OPTIONS] PACKAGE_DIR...")
+		fmt.Fprintln(w, os.Args[0]+" diff [OPTIONS] INPUT_DIR PACKAGE_DIR")
+		fmt.Fprintln(w, os.Args[0]+" diff-pkg [OPTIONS] OLD_PACKAGE_DIR|OLD_BUNDLE NEW_PACKAGE_DIR|NEW_BUNDLE")
+		fmt.Fprintln(w, os.Args[0]+" bundle [OPTIONS] INPUT_DIR|INPUT_ARCHIVE OUTPUT.alb")
+		fmt.Fprintln(w, os.Args[0]+" ls [OPTIONS] PACKAGE_DIR|BUNDLE")
+		fmt.Fprintln(w, os.Args[0]+" extract [OPTIONS] PACKAGE_DIR|BUNDLE OUTPUT_DIR")
+		fs.PrintDefaults()
+	}
+	b, err := assetslife.ParseArgs(fs, args, assetslife.WithLogger(log))
+	if err == nil {
+		err = b.Build(context.Background())
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runServe runs the serve subcommand, the development server that serves the files in the directory.
+func runServe(args []string) {
+	log := assetslife.NewLogger(os.Stderr)
+	var addr, certFile, keyFile string
+	var selfSigned, live bool
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.StringVar(&addr, "addr", "localhost:8080", "the `address` to listen on")
+	fs.StringVar(&certFile, "tls-cert", "", "path to the certificate `file` of TLS, with -tls-key")
+	fs.StringVar(&keyFile, "tls-key", "", "path to the private key `file` of TLS, with -tls-cert")
+	fs.BoolVar(&selfSigned, "tls-self-signed", false, "serve TLS with a new self-signed certificate for localhost")
+	fs.BoolVar(&live, "live", false, "reload the HTML pages in the browsers when the files change")
+	log.AddFlags(fs, "suppress the info messages")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage:")
+		fmt.Fprintln(w, os.Args[0]+" serve [OPTIONS] INPUT_DIR")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	log.Setup()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(assetslife.ExitUsage)
+	}
+	if (certFile == "") != (keyFile == "") {
+		log.Usagef("-tls-cert and -tls-key must be used together")
+	}
+	if selfSigned && certFile != "" {
+		log.Usagef("-tls-self-signed and -tls-cert cannot be used together")
+	}
+
+	dir := fs.Arg(0)
+	var handler http.Handler = http.FileServer(hiddenFilter{http.Dir(dir)})
+	if live {
+		lr := newLiveReload(log, dir, handler)
+		go lr.watch(liveReloadInterval)
+		handler = lr
+	}
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+	var err error
+	switch {
+	case selfSigned:
+		var cert tls.Certificate
+		cert, err = selfSignedCertificate(time.Now())
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		sum := sha256.Sum256(cert.Certificate[0])
+		log.Infof("the SHA-256 fingerprint of the self-signed certificate: %s", hex.EncodeToString(sum[:]))
+		log.Infof("serving %s on https://%s/", fs.Arg(0), addr)
+		err = srv.ListenAndServeTLS("", "")
+	case certFile != "":
+		log.Infof("serving %s on https://%s/", fs.Arg(0), addr)
+		err = srv.ListenAndServeTLS(certFile, keyFile)
+	default:
+		log.Infof("serving %s on http://%s/", fs.Arg(0), addr)
+		err = srv.ListenAndServe()
+	}
+	log.Fatalf("%v", err)
+}
+
+// hiddenFilter is the http.FileSystem that hides the hidden files, e.g. .git and .env, as the generator ignores them.
+type hiddenFilter struct {
+	fs http.FileSystem
+}
+
+func (h hiddenFilter) Open(name string) (http.File, error) {
+	for _, elem := range strings.Split(name, "/") {
+		if strings.HasPrefix(elem, ".") {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	f, err := h.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return hiddenFilterFile{f}, nil
+}
+
+// hiddenFilterFile is the file of hiddenFilter, which lists the directory without the hidden files.
+type hiddenFilterFile struct {
+	http.File
+}
+
+func (f hiddenFilterFile) Readdir(count int) ([]fs.FileInfo, error) {
+	for {
+		infos, err := f.File.Readdir(count)
+		visible := infos[:0]
+		for _, info := range infos {
+			if !strings.HasPrefix(info.Name(), ".") {
+				visible = append(visible, info)
+			}
+		}
+		// read the next entries if all of them are hidden, so only the end of the directory returns no entries.
+		if count <= 0 || len(visible) > 0 || len(infos) == 0 || err != nil {
+			return visible, err
+		}
+	}
+}
+
+// selfSignedCertificate creates a new self-signed certificate for localhost, which is valid for a day from now.
+func selfSignedCertificate(now time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"assets-life"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
+
+// liveReloadPath is the path of the WebSocket that notifies the browsers of the changes.
+const liveReloadPath = "/__assets-life/live"
+
+// liveReloadInterval is the interval to check the changes of the files.
+const liveReloadInterval = 500 * time.Millisecond
+
+// liveReloadScript is the client of the live reload injected into the HTML pages.
+const liveReloadScript = `<script>(function(){` +
+	`var u=(location.protocol==="https:"?"wss://":"ws://")+location.host+"` + liveReloadPath + `";` +
+	`function connect(){var ws=new WebSocket(u);ws.onmessage=function(){location.reload()};ws.onclose=function(){setTimeout(connect,1000)}}` +
+	`connect()})();</script>`
+
+// liveReload serves the files with the live reload.
+type liveReload struct {
+	dir  string
+	next http.Handler
+	log  *assetslife.Logger
+
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func newLiveReload(log *assetslife.Logger, dir string, next http.Handler) *liveReload {
+	return &liveReload{
+		log:     log,
+		dir:     dir,
+		next:    next,
+		clients: map[chan struct{}]struct{}{},
+	}
+}
+
+func (lr *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == liveReloadPath {
+		lr.serveWebSocket(w, r)
+		return
+	}
+
+	name := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	} else if path.Base(name) == "index.html" {
+		// http.FileServer redirects it to the directory.
+		lr.next.ServeHTTP(w, r)
+		return
+	}
+	if ext := path.Ext(name); ext != ".html" && ext != ".htm" {
+		lr.next.ServeHTTP(w, r)
+		return
+	}
+	f, err := http.Dir(lr.dir).Open(name)
+	if err != nil {
+		lr.next.ServeHTTP(w, r)
+		return
+	}
+	defer f.Close()
+	if stat, err := f.Stat(); err != nil || stat.IsDir() {
+		lr.next.ServeHTTP(w, r)
+		return
+	}
+	content, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(injectLiveReload(content)))
+}
+
+// injectLiveReload inserts the client of the live reload before </body>, or at the end of the HTML.
+func injectLiveReload(html []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(html), []byte("</body>"))
+	if i < 0 {
+		i = len(html)
+	}
+	ret := make([]byte, 0, len(html)+len(liveReloadScript))
+	ret = append(ret, html[:i]...)
+	ret = append(ret, liveReloadScript...)
+	ret = append(ret, html[i:]...)
+	return ret
+}
+
+// serveWebSocket accepts the WebSocket, and sends a message when the files change.
+// It implements only the server-to-client text messages of RFC 6455, that the live reload needs.
+func (lr *liveReload) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "400 bad request", http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "501 not implemented", http.StatusNotImplemented)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	fmt.Fprint(rw, "HTTP/1.1 101 Switching Protocols\r\n")
+	fmt.Fprint(rw, "Upgrade: websocket\r\n")
+	fmt.Fprint(rw, "Connection: Upgrade\r\n")
+	fmt.Fprint(rw, "Sec-WebSocket-Accept: "+base64.StdEncoding.EncodeToString(sum[:])+"\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	// the messages from the client are discarded, and they are read only to detect closing.
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, rw.Reader)
+		close(closed)
+	}()
+
+	ch := make(chan struct{}, 1)
+	lr.mu.Lock()
+	lr.clients[ch] = struct{}{}
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, ch)
+		lr.mu.Unlock()
+	}()
+
+	select {
+	case <-ch:
+		// a text frame of "reload", and a close frame.
+		conn.Write([]byte{0x81, 6, 'r', 'e', 'l', 'o', 'a', 'd', 0x88, 0})
+	case <-closed:
+	}
+}
+
+// reload notifies the browsers of the changes.
+func (lr *liveReload) reload() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for ch := range lr.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch checks the changes of the files at the interval, and notifies the browsers of them.
+func (lr *liveReload) watch(interval time.Duration) {
+	last := snapshotDir(lr.dir)
+	for range time.Tick(interval) {
+		current := snapshotDir(lr.dir)
+		if current != last {
+			last = current
+			lr.log.Infof("reloading: %s changed", lr.dir)
+			lr.reload()
+		}
+	}
+}
+
+// snapshotDir returns the digest of the names, the sizes and the modification times of the files in the directory.
+func snapshotDir(dir string) string {
+	h := sha256.New()
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// runSync runs the sync subcommand, which uploads the files to the bucket of S3 or the S3-compatible storage.
+func runSync(args []string) {
+	log := assetslife.NewLogger(os.Stderr)
+	var filesFrom, hash, endpoint, region, cacheControl, immutableCacheControl string
+	var exportIgnore, fingerprint, dryRun bool
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	fs.StringVar(&filesFrom, "files-from", "", "read the list of files to upload from the file instead of walking INPUT_DIR, \"-\" for stdin")
+	fs.BoolVar(&exportIgnore, "export-ignore", false, "exclude the files with the export-ignore attribute of .gitattributes")
+	fs.BoolVar(&fingerprint, "fingerprint", false, "add the hashes of the contents to the names of the files as the generator does")
+	fs.StringVar(&hash, "hash", "sha256", "the hash `algorithm` of the fingerprints, crc32, sha1, sha256 or blake3, the same as the generator")
+	fs.StringVar(&endpoint, "endpoint", "", "the `URL` of the S3-compatible storage with the path-style requests, or of the Azure Blob Storage account (default: AWS_ENDPOINT_URL for S3)")
+	fs.StringVar(&region, "region", "", "the `region` of the bucket (default: AWS_REGION, AWS_DEFAULT_REGION or us-east-1)")
+	fs.StringVar(&cacheControl, "cache-control", "public, max-age=300", "the `value` of Cache-Control of the files")
+	fs.StringVar(&immutableCacheControl, "immutable-cache-control", "public, max-age=31536000, immutable", "the `value` of Cache-Control of the fingerprinted files")
+	fs.BoolVar(&dryRun, "dry-run", false, "show the files to upload without uploading them")
+	log.AddFlags(fs, "suppress the info messages")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage:")
+		fmt.Fprintln(w, os.Args[0]+" sync [OPTIONS] INPUT_DIR s3://BUCKET/PREFIX")
+		fmt.Fprintln(w, os.Args[0]+" sync [OPTIONS] INPUT_DIR gs://BUCKET/PREFIX")
+		fmt.Fprintln(w, os.Args[0]+" sync [OPTIONS] INPUT_DIR az://CONTAINER/PREFIX")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	log.Setup()
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(assetslife.ExitUsage)
+	}
+
+	client, prefix, err := newObjectStore(fs.Arg(1), endpoint, region)
+	if err != nil {
+		log.Usagef("%v", err)
+	}
+	files, err := inputSource(log, fs.Arg(0), filesFrom, "", exportIgnore).Files()
+	if err != nil {
+		log.Fatal(err)
+	}
+	immutable := map[string]bool{}
+	if fingerprint {
+		files, immutable, err = fingerprintFiles(files, hash)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	uploaded, skipped, err := syncAssets(client, files, &syncOptions{
+		prefix:                prefix,
+		cacheControl:          cacheControl,
+		immutableCacheControl: immutableCacheControl,
+		immutable:             immutable,
+		dryRun:                dryRun,
+		log:                   log,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("%d files uploaded, %d files unchanged", uploaded, skipped)
+}
+
+// inputSource returns the Source of the input directory or archive in as the generator reads it
+// with -files-from, -git-ref and -export-ignore.
+func inputSource(log *assetslife.Logger, in, filesFrom, gitRef string, exportIgnore bool) assetslife.Source {
+	opts := []assetslife.Option{assetslife.WithLogger(log), assetslife.WithFilesFrom(filesFrom), assetslife.WithGitRef(gitRef)}
+	if exportIgnore {
+		opts = append(opts, assetslife.WithExportIgnore())
+	}
+	return assetslife.InputSource(in, opts...)
+}
+
+// fileList is the Source of the files that are already read.
+type fileList []*assetslife.File
+
+func (l fileList) Files() ([]*assetslife.File, error) {
+	return l, nil
+}
+
+// fingerprintFiles adds the hashes of the contents to the names of files as the generator does with -fingerprint,
+// and returns the fingerprinted files and the set of the renamed ones.
+func fingerprintFiles(files []*assetslife.File, hash string) ([]*assetslife.File, map[string]bool, error) {
+	fsys, err := assetslife.BuildFS(fileList(files), assetslife.WithFingerprint(), assetslife.WithHash(hash))
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make(map[string]bool, len(files))
+	for _, f := range files {
+		names[f.Name] = true
+	}
+	var ret []*assetslife.File
+	renamed := map[string]bool{}
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		f := &assetslife.File{Name: "/" + name, Mode: 0644, Content: content}
+		if !names[f.Name] {
+			renamed[f.Name] = true
+		}
+		ret = append(ret, f)
+		return nil
+	})
+	return ret, renamed, err
+}
+
+// syncOptions is the options of the sync subcommand.
+type syncOptions struct {
+	// prefix is the prefix of the keys of the objects, without the leading and trailing slashes.
+	prefix string
+
+	// cacheControl is Cache-Control of the files, and immutableCacheControl is the one of the files in immutable.
+	cacheControl          string
+	immutableCacheControl string
+	immutable             map[string]bool
+
+	// dryRun only reports the files to upload.
+	dryRun bool
+
+	// log reports the uploaded files.
+	log *assetslife.Logger
+}
+
+// objectStore is the bucket of the storage that the sync subcommand uploads the files to.
+type objectStore interface {
+	// headObject returns the SHA-256 of the object key stored by putObject,
+	// or empty if the object doesn't exist or is not uploaded by putObject.
+	headObject(key string) (string, error)
+
+	// putObject uploads the object.
+	putObject(obj *s3Object) error
+}
+
+// newObjectStore returns the client of the bucket in the destination dest,
+// e.g. s3://bucket/prefix, gs://bucket/prefix or az://container/prefix, and the prefix.
+func newObjectStore(dest, endpoint, region string) (objectStore, string, error) {
+	if strings.HasPrefix(dest, "az://") {
+		return newAzureClient(dest, endpoint)
+	}
+	return newS3Client(dest, endpoint, region)
+}
+
+// syncAssets uploads the regular files into store, skipping the objects that have the same contents.
+// It returns the numbers of the uploaded files and the skipped files.
+func syncAssets(store objectStore, files []*assetslife.File, opts *syncOptions) (uploaded, skipped int, err error) {
+	for _, f := range files {
+		if f.Mode.IsDir() {
+			continue
+		}
+		key := path.Join(opts.prefix, f.Name[1:])
+		sum := sha256Hex(f.Content)
+		remote, err := store.headObject(key)
+		if err != nil {
+			return uploaded, skipped, err
+		}
+		if remote == sum {
+			skipped++
+			continue
+		}
+		obj := &s3Object{
+			key:          key,
+			content:      f.Content,
+			contentType:  assetslife.ContentType(f.Name, f.Content),
+			cacheControl: opts.cacheControl,
+			sha256:       sum,
+		}
+		if opts.immutable[f.Name] {
+			obj.cacheControl = opts.immutableCacheControl
+		}
+		if opts.dryRun {
+			opts.log.Infof("would upload %s (%s, %d bytes)", key, obj.contentType, len(f.Content))
+			uploaded++
+			continue
+		}
+		if err := store.putObject(obj); err != nil {
+			return uploaded, skipped, err
+		}
+		opts.log.Infof("uploaded %s (%s, %d bytes)", key, obj.contentType, len(f.Content))
+		uploaded++
+	}
+	return uploaded, skipped, nil
+}
+
+// s3Client is the minimal client of S3 that signs the requests with AWS Signature Version 4.
+type s3Client struct {
+	endpoint  *url.URL
+	pathStyle bool
+	bucket    string
+	region    string
+	cred      s3Credentials
+	client    *http.Client
+	now       func() time.Time
+}
+
+// s3Credentials is the access key of S3, or the HMAC key of Google Cloud Storage.
+type s3Credentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// s3Object is the object to upload.
+type s3Object struct {
+	key          string
+	content      []byte
+	contentType  string
+	cacheControl string
+	sha256       string // the hex-encoded SHA-256 of content, stored in the metadata to skip unchanged objects
+}
+
+// newS3Client returns the client of the bucket in the destination dest, e.g. s3://bucket/prefix, and the prefix.
+// The credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN,
+// and the endpoint is read from AWS_ENDPOINT_URL if it is empty.
+func newS3Client(dest, endpoint, region string) (*s3Client, string, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("no bucket in %q", dest)
+	}
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	c := &s3Client{
+		bucket: u.Host,
+		region: region,
+		cred: s3Credentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		client: http.DefaultClient,
+		now:    time.Now,
+	}
+	switch u.Scheme {
+	case "s3":
+		if c.region == "" {
+			c.region = "us-east-1"
+		}
+		if endpoint == "" {
+			endpoint = "https://s3." + c.region + ".amazonaws.com"
+		} else {
+			c.pathStyle = true
+		}
+	case "gs":
+		// Cloud Storage accepts the requests of S3 signed with the HMAC keys.
+		if c.region == "" {
+			c.region = "auto"
+		}
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+		c.pathStyle = true
+	default:
+		return nil, "", fmt.Errorf("unsupported destination %q, use s3://BUCKET/PREFIX, gs://BUCKET/PREFIX or az://CONTAINER/PREFIX", dest)
+	}
+	if c.cred.accessKey == "" || c.cred.secretKey == "" {
+		return nil, "", errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	c.endpoint, err = url.Parse(endpoint)
+	if err != nil {
+		return nil, "", err
+	}
+	return c, strings.Trim(u.Path, "/"), nil
+}
+
+// objectURL returns the URL of the object key.
+func (c *s3Client) objectURL(key string) *url.URL {
+	u := *c.endpoint
+	if c.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket + "/" + key
+	} else {
+		u.Host = c.bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return &u
+}
+
+// headObject returns the SHA-256 of the object key stored by putObject,
+// or empty if the object doesn't exist or is not uploaded by putObject.
+func (c *s3Client) headObject(key string) (string, error) {
+	req, err := http.NewRequest(http.MethodHead, c.objectURL(key).String(), nil)
+	if err != nil {
+		return "", err
+	}
+	c.sign(req, sha256Hex(nil))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("X-Amz-Meta-Sha256"), nil
+	case http.StatusNotFound:
+		return "", nil
+	}
+	return "", fmt.Errorf("HEAD %s: %s", key, resp.Status)
+}
+
+// putObject uploads the object.
+func (c *s3Client) putObject(obj *s3Object) error {
+	req, err := http.NewRequest(http.MethodPut, c.objectURL(obj.key).String(), bytes.NewReader(obj.content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", obj.contentType)
+	if obj.cacheControl != "" {
+		req.Header.Set("Cache-Control", obj.cacheControl)
+	}
+	req.Header.Set("X-Amz-Meta-Sha256", obj.sha256)
+	c.sign(req, obj.sha256)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("PUT %s: %s: %s", obj.key, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// sign signs the request to S3.
+func (c *s3Client) sign(req *http.Request, payloadHash string) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.cred.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.cred.sessionToken)
+	}
+	signV4(req, c.cred, c.region, "s3", payloadHash, c.now())
+}
+
+// signV4 signs the request with AWS Signature Version 4.
+// All headers of the request and Host are signed, see https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-create-signed-request.html.
+func signV4(req *http.Request, cred s3Credentials, region, service, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	segments := strings.Split(req.URL.Path, "/")
+	for i, seg := range segments {
+		segments[i] = awsEscape(seg)
+	}
+	uri := strings.Join(segments, "/")
+	if uri == "" {
+		uri = "/"
+	}
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		vs := query[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			params = append(params, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := []byte("AWS4" + cred.secretKey)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+cred.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+// azureClient is the minimal client of Azure Blob Storage that signs the requests with the shared key.
+type azureClient struct {
+	endpoint  *url.URL
+	account   string
+	key       []byte
+	container string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// azureVersion is the version of the REST API of Azure Blob Storage.
+const azureVersion = "2021-08-06"
+
+// newAzureClient returns the client of the container in the destination dest, e.g. az://container/prefix, and the prefix.
+// The account and its key in base64 are read from AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY,
+// and the endpoint is https://ACCOUNT.blob.core.windows.net if it is empty.
+func newAzureClient(dest, endpoint string) (*azureClient, string, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, "", err
 	}
-	in, err := filepath.Abs(os.Args[1])
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("no container in %q", dest)
+	}
+	account, key := os.Getenv("AZURE_STORAGE_ACCOUNT"), os.Getenv("AZURE_STORAGE_KEY")
+	if account == "" || key == "" {
+		return nil, "", errors.New("AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY must be set")
+	}
+	c := &azureClient{
+		account:   account,
+		container: u.Host,
+		client:    http.DefaultClient,
+		now:       time.Now,
+	}
+	c.key, err = base64.StdEncoding.DecodeString(key)
 	if err != nil {
-		log.Fatal(err)
+		return nil, "", fmt.Errorf("AZURE_STORAGE_KEY: %w", err)
 	}
-	out, err := filepath.Abs(os.Args[2])
+	if endpoint == "" {
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+	c.endpoint, err = url.Parse(endpoint)
 	if err != nil {
-		log.Fatal(err)
+		return nil, "", err
 	}
-	var name string
-	if len(os.Args) > 3 {
-		name = os.Args[3]
+	return c, strings.Trim(u.Path, "/"), nil
+}
+
+// blobURL returns the URL of the blob key.
+func (c *azureClient) blobURL(key string) *url.URL {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.container + "/" + key
+	return &u
+}
+
+func (c *azureClient) headObject(key string) (string, error) {
+	req, err := http.NewRequest(http.MethodHead, c.blobURL(key).String(), nil)
+	if err != nil {
+		return "", err
 	}
-	if name == "" {
-		name = filepath.Base(out)
+	c.sign(req)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
 	}
-	if err := build(in, out, name); err != nil {
-		log.Fatal(err)
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("X-Ms-Meta-Sha256"), nil
+	case http.StatusNotFound:
+		return "", nil
 	}
+	return "", fmt.Errorf("HEAD %s: %s", key, resp.Status)
 }
 
-func build(in, out, name string) error {
-	filename := "assets-life.go"
-	rel, err := filepath.Rel(out, in)
+func (c *azureClient) putObject(obj *s3Object) error {
+	req, err := http.NewRequest(http.MethodPut, c.blobURL(obj.key).String(), bytes.NewReader(obj.content))
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(out, 0755); err != nil {
-		return err
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Blob-Content-Type", obj.contentType)
+	if obj.cacheControl != "" {
+		req.Header.Set("X-Ms-Blob-Cache-Control", obj.cacheControl)
 	}
-	f, err := os.OpenFile(filepath.Join(out, "filesystem.go"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	req.Header.Set("X-Ms-Meta-Sha256", obj.sha256)
+	c.sign(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return err
 	}
-	header := `// Code generated by go run %s. DO NOT EDIT.
-
-//%s
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("PUT %s: %s: %s", obj.key, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
 
-package %s
+// sign signs the request to Azure Blob Storage.
+func (c *azureClient) sign(req *http.Request) {
+	req.Header.Set("X-Ms-Date", c.now().UTC().Format(http.TimeFormat))
+	req.Header.Set("X-Ms-Version", azureVersion)
+	signSharedKey(req, c.account, c.key)
+}
 
-import (
-	"io"
-	"net/http"
-	"os"
-	"path"
-	"sort"
-	"strings"
-	"time"
-)
+// signSharedKey signs the request with the shared key of the storage account.
+// The headers x-ms-* and the resource are signed, see https://learn.microsoft.com/rest/api/storageservices/authorize-with-shared-key.
+func signSharedKey(req *http.Request, account string, key []byte) {
+	var length string
+	if req.ContentLength > 0 {
+		length = strconv.FormatInt(req.ContentLength, 10)
+	}
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	for _, name := range []string{"Content-Encoding", "Content-Language"} {
+		b.WriteString(req.Header.Get(name) + "\n")
+	}
+	b.WriteString(length + "\n")
+	for _, name := range []string{"Content-Md5", "Content-Type", "Date", "If-Modified-Since", "If-Match", "If-None-Match", "If-Unmodified-Since", "Range"} {
+		b.WriteString(req.Header.Get(name) + "\n")
+	}
 
-// Root is the root of the file system.
-var Root http.FileSystem = fileSystem{
-`
-	fmt.Fprintf(f, header, filename, "go:generate go run "+filename+" \""+rel+"\" . "+name, name)
+	var names []string
+	for name := range req.Header {
+		if name := strings.ToLower(name); strings.HasPrefix(name, "x-ms-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.Join(strings.Fields(req.Header.Get(name)), " ") + "\n")
+	}
 
-	type file struct {
-		path     string
-		mode     os.FileMode
-		children []int
-		next     int
+	b.WriteString("/" + account + req.URL.EscapedPath())
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		vs := query[k]
+		sort.Strings(vs)
+		b.WriteString("\n" + strings.ToLower(k) + ":" + strings.Join(vs, ","))
 	}
-	index := map[string]int{}
-	files := []file{}
 
-	var i int
-	err = filepath.Walk(in, func(path string, info os.FileInfo, err error) error {
-		// ignore hidden files
-		if strings.HasPrefix(info.Name(), ".") {
-			return nil
-		}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(b.String()))
+	req.Header.Set("Authorization", "SharedKey "+account+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
 
-		if (info.Mode()&os.ModeType)|os.ModeDir != os.ModeDir {
-			return fmt.Errorf("unsupported file type: %s, mode %s", path, info.Mode())
+// awsEscape escapes s as URI encoding of AWS, which escapes all characters except the unreserved characters of RFC 3986.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
 		}
+	}
+	return b.String()
+}
 
-		index[path] = i
-		files = append(files, file{
-			path: path,
-			mode: info.Mode(),
-		})
-		parent := filepath.Dir(path)
-		if idx, ok := index[parent]; ok {
-			files[idx].children = append(files[idx].children, i)
-		}
-		i++
-		return nil
-	})
+// apiVersion is APIVersion of the packages generated by the built-in templates.
+const apiVersion = 1
+
+// runUpgrade runs the upgrade subcommand, which regenerates the packages with the current generator,
+// using the input and the options recorded in their go:generate directives.
+func runUpgrade(args []string) {
+	log := assetslife.NewLogger(os.Stderr)
+	var dryRun bool
+	fs := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "show the commands that regenerate the packages without running them")
+	log.AddFlags(fs, "suppress the progress and the info messages")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage:")
+		fmt.Fprintln(w, os.Args[0]+" upgrade [OPTIONS] PACKAGE_DIR...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	log.Setup()
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(assetslife.ExitUsage)
+	}
+
+	exe, err := os.Executable()
 	if err != nil {
-		return err
+		log.Fatalf("%v", err)
 	}
+	for _, dir := range fs.Args() {
+		pkg, err := readGeneratedPackage(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("upgrading %s from the layout version %d to %d", dir, pkg.apiVersion, apiVersion)
+		if dryRun {
+			log.Infof("assets-life %s", strings.Join(pkg.args, " "))
+			continue
+		}
 
-	for _, ff := range files {
-		// search neighborhood
-		for i := range ff.children {
-			next := -1
-			if i+1 < len(ff.children) {
-				next = ff.children[i+1]
+		// the child reports in the same format as this command.
+		childArgs := []string{"-log-format", log.Format()}
+		if log.Quiet() {
+			childArgs = append(childArgs, "-q")
+		}
+		cmd := exec.Command(exe, append(childArgs, pkg.args...)...)
+		cmd.Dir = dir
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				// the generator has already reported the error.
+				os.Exit(exitErr.ExitCode())
 			}
-			files[ff.children[i]].next = next
+			log.Fatalf("%s: %v", dir, err)
 		}
+	}
+}
+
+// generatedPackage is the package generated by assets-life, found by readGeneratedPackage.
+type generatedPackage struct {
+	// args is the arguments of assets-life in the go:generate directive.
+	// The paths are relative to the directory of the package.
+	args []string
 
-		fmt.Fprintf(f, "\tfile{\n")
-		rel, err := filepath.Rel(in, ff.path)
+	// apiVersion is APIVersion of the package, or 0 if it is generated before APIVersion was added.
+	apiVersion int
+}
+
+// apiVersionPattern matches the declaration of APIVersion in the generated code.
+var apiVersionPattern = regexp.MustCompile(`(?m)^const APIVersion = (\d+|assetsfs\.APIVersion)$`)
+
+// readGeneratedPackage reads the go:generate directive and APIVersion of the generated package in dir.
+func readGeneratedPackage(dir string) (*generatedPackage, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if filepath.Base(name) == "assets-life.go" {
+			// the copy of the generator has the directives in its templates.
+			continue
+		}
+		b, err := os.ReadFile(name)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		fmt.Fprintf(f, "\t\tname:    %q,\n", path.Clean("/"+filepath.ToSlash(rel)))
-		if ff.mode.IsDir() {
-			fmt.Fprintln(f, "\t\tcontent: \"\",")
-		} else {
-			b, err := ioutil.ReadFile(ff.path)
-			if err != nil {
-				return err
-			}
-			fmt.Fprintf(f, "\t\tcontent: %q,\n", string(b))
+		args, ok, err := findDirective(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
 		}
-		switch {
-		case ff.mode.IsDir(): // directory
-			fmt.Fprintln(f, "\t\tmode:    0755 | os.ModeDir,")
-		case ff.mode&0100 != 0: // executable file
-			fmt.Fprintln(f, "\t\tmode:    0755,")
-		default:
-			fmt.Fprintln(f, "\t\tmode:    0644,")
+		if !ok {
+			continue
 		}
-		fmt.Fprintf(f, "\t\tnext:    %d,\n", ff.next)
-		if len(ff.children) > 0 {
-			fmt.Fprintf(f, "\t\tchild:   %d,\n", ff.children[0])
-		} else {
-			fmt.Fprint(f, "\t\tchild:   -1,\n")
+		pkg := &generatedPackage{args: args}
+		if m := apiVersionPattern.FindStringSubmatch(string(b)); m != nil {
+			if m[1] == "assetsfs.APIVersion" {
+				// the runtime is imported, so it is always the current version of the module.
+				pkg.apiVersion = apiVersion
+			} else {
+				pkg.apiVersion, _ = strconv.Atoi(m[1])
+			}
 		}
-		fmt.Fprint(f, "\t},\n")
+		return pkg, nil
 	}
-	footer := `}
-
-type fileSystem []file
+	return nil, fmt.Errorf("%s: no go:generate directive of assets-life is found", dir)
+}
 
-func (fs fileSystem) Open(name string) (http.File, error) {
-	i := sort.Search(len(fs), func(i int) bool { return fs[i].name >= name })
-	if i >= len(fs) || fs[i].name != name {
-		return nil, &os.PathError{
-			Op:   "open",
-			Path: name,
-			Err:  os.ErrNotExist,
+// findDirective finds the go:generate directive that runs assets-life.go in src,
+// and returns the arguments of assets-life.
+func findDirective(src string) ([]string, bool, error) {
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if !strings.HasPrefix(line, "//go:generate ") {
+			continue
 		}
+		words, err := splitDirective(strings.TrimPrefix(line, "//go:generate "))
+		if err != nil {
+			return nil, false, err
+		}
+		if len(words) < 3 || words[0] != "go" || words[1] != "run" || filepath.Base(words[2]) != "assets-life.go" {
+			continue
+		}
+		return words[3:], true, nil
 	}
-	f := &fs[i]
-	return &httpFile{
-		Reader: strings.NewReader(f.content),
-		file:   f,
-		fs:     fs,
-		idx:    i,
-		dirIdx: f.child,
-	}, nil
+	return nil, false, nil
 }
 
-type file struct {
-	name    string
-	content string
-	mode    os.FileMode
-	child   int
-	next    int
-}
-
-var _ os.FileInfo = (*file)(nil)
+// splitDirective splits the command of the go:generate directive into the words, as go generate does.
+// The words are separated by spaces, and the double-quoted words are unquoted as Go strings.
+func splitDirective(line string) ([]string, error) {
+	var words []string
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return words, nil
+		}
+		if line[0] != '"' {
+			i := strings.IndexAny(line, " \t")
+			if i < 0 {
+				i = len(line)
+			}
+			words = append(words, line[:i])
+			line = line[i:]
+			continue
+		}
 
-func (f *file) Name() string {
-	return path.Base(f.name)
+		// find the closing quote, skipping the escaped characters.
+		i := 1
+		for i < len(line) && line[i] != '"' {
+			if line[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(line) {
+			return nil, fmt.Errorf("unterminated quoted string in the directive: %s", line)
+		}
+		word, err := strconv.Unquote(line[:i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string in the directive: %s", line[:i+1])
+		}
+		words = append(words, word)
+		line = line[i+1:]
+	}
 }
 
-func (f *file) Size() int64 {
-	return int64(len(f.content))
-}
+// runDiff runs the diff subcommand, which compares the files in the input directory with the generated package.
+// It exits with ExitDrift if they differ.
+func runDiff(args []string) {
+	log := assetslife.NewLogger(os.Stderr)
+	var filesFrom string
+	var exportIgnore, unified bool
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.StringVar(&filesFrom, "files-from", "", "read the list of files to compare from the file instead of walking INPUT_DIR, \"-\" for stdin")
+	fs.BoolVar(&exportIgnore, "export-ignore", false, "exclude the files with the export-ignore attribute of .gitattributes")
+	fs.BoolVar(&unified, "u", false, "show the unified diffs of the changed text files")
+	log.AddFlags(fs, "suppress the info messages")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage:")
+		fmt.Fprintln(w, os.Args[0]+" diff [OPTIONS] INPUT_DIR|INPUT_ARCHIVE PACKAGE_DIR")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	log.Setup()
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(assetslife.ExitUsage)
+	}
 
-func (f *file) Mode() os.FileMode {
-	return f.mode
+	files, err := inputSource(log, fs.Arg(0), filesFrom, "", exportIgnore).Files()
+	if err != nil {
+		log.Fatal(err)
+	}
+	generated, err := readPackageFiles(fs.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	changes := diffAssets(generated, files)
+	printChanges(changes, unified, assetChange.String)
+	if len(changes) > 0 {
+		log.Infof("%d files differ", len(changes))
+		os.Exit(assetslife.ExitDrift)
+	}
 }
 
-var zeroTime time.Time
+// runDiffPkg runs the diff-pkg subcommand, which compares the files of two generated packages or bundles,
+// e.g. to review the changes between the releases.
+func runDiffPkg(args []string) {
+	log := assetslife.NewLogger(os.Stderr)
+	var unified bool
+	fs := flag.NewFlagSet("diff-pkg", flag.ExitOnError)
+	fs.BoolVar(&unified, "u", false, "show the unified diffs of the changed text files")
+	log.AddFlags(fs, "suppress the info messages")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage:")
+		fmt.Fprintln(w, os.Args[0]+" diff-pkg [OPTIONS] OLD_PACKAGE_DIR|OLD_BUNDLE NEW_PACKAGE_DIR|NEW_BUNDLE")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	log.Setup()
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(assetslife.ExitUsage)
+	}
 
-func (f *file) ModTime() time.Time {
-	return zeroTime
+	old, err := readPackageFiles(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	new, err := assetslife.PackageSource(fs.Arg(1)).Files()
+	if err != nil {
+		log.Fatal(err)
+	}
+	changes := diffAssets(old, new)
+	printChanges(changes, unified, assetChange.hashString)
+	if len(changes) > 0 {
+		log.Infof("%d files differ", len(changes))
+		os.Exit(assetslife.ExitDrift)
+	}
 }
 
-func (f *file) IsDir() bool {
-	return f.Mode().IsDir()
+// readPackageFiles returns the contents of the regular files in the generated package in dir, or in the bundle,
+// by their names.
+func readPackageFiles(filename string) (map[string]string, error) {
+	files, err := assetslife.PackageSource(filename).Files()
+	if err != nil {
+		return nil, err
+	}
+	contents := make(map[string]string, len(files))
+	for _, f := range files {
+		contents[f.Name] = string(f.Content)
+	}
+	return contents, nil
 }
 
-func (f *file) Sys() interface{} {
-	return nil
+// printChanges prints the changes formatted by format, with the unified diffs of the modified text files if unified is true.
+func printChanges(changes []assetChange, unified bool, format func(assetChange) string) {
+	for _, c := range changes {
+		fmt.Println(format(c))
+		if !unified || c.kind != 'M' {
+			continue
+		}
+		if !isText(c.old) || !isText(c.new) {
+			fmt.Println("Binary files differ")
+			continue
+		}
+		diff, ok := unifiedDiff(c.name, c.old, c.new)
+		if !ok {
+			fmt.Println("Text files are too large to compare")
+			continue
+		}
+		fmt.Print(diff)
+	}
 }
 
-type httpFile struct {
-	*strings.Reader
-	file   *file
-	fs     fileSystem
-	idx    int
-	dirIdx int
+// isText reports whether content is a text, which the unified diffs show.
+func isText(content string) bool {
+	return strings.HasPrefix(http.DetectContentType([]byte(content)), "text/")
 }
 
-var _ http.File = (*httpFile)(nil)
+// runBundle runs the bundle subcommand, which writes the files in the input directory into a bundle
+// that LoadBundle of the generated packages loads.
+func runBundle(args []string) {
+	log := assetslife.NewLogger(os.Stderr)
+	var filesFrom, gitRef, signKey string
+	var exportIgnore bool
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	fs.StringVar(&filesFrom, "files-from", "", "read the list of files to bundle from the file instead of walking INPUT_DIR, \"-\" for stdin")
+	fs.StringVar(&gitRef, "git-ref", "", "read the files from the git `revision` instead of the working tree")
+	fs.BoolVar(&exportIgnore, "export-ignore", false, "exclude the files with the export-ignore attribute of .gitattributes")
+	fs.StringVar(&signKey, "sign-key", "", "`path` to the Ed25519 private key in PEM that signs the bundle, verified by LoadBundle")
+	log.AddFlags(fs, "suppress the info messages")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage:")
+		fmt.Fprintln(w, os.Args[0]+" bundle [OPTIONS] INPUT_DIR|INPUT_ARCHIVE OUTPUT.alb")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	log.Setup()
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(assetslife.ExitUsage)
+	}
 
-func (f *httpFile) Stat() (os.FileInfo, error) {
-	return f.file, nil
+	if signKey == "" {
+		log.Warnf("the bundle is not signed, so LoadBundle rejects it")
+	}
+	files, err := inputSource(log, fs.Arg(0), filesFrom, gitRef, exportIgnore).Files()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := assetslife.WriteBundle(fs.Arg(1), files, signKey); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("wrote %d files into %s", countFiles(files), fs.Arg(1))
 }
 
-func (f *httpFile) Readdir(count int) ([]os.FileInfo, error) {
-	ret := []os.FileInfo{}
-	if !f.file.IsDir() {
-		return ret, nil
+// runLs runs the ls subcommand, which lists the files in the generated package or the bundle.
+func runLs(args []string) {
+	log := assetslife.NewLogger(os.Stderr)
+	fs := flag.NewFlagSet("ls", flag.ExitOnError)
+	log.AddFlags(fs, "suppress the info messages")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage:")
+		fmt.Fprintln(w, os.Args[0]+" ls [OPTIONS] PACKAGE_DIR|BUNDLE")
+		fs.PrintDefaults()
 	}
-
-	if count <= 0 {
-		for f.dirIdx >= 0 {
-			entry := &f.fs[f.dirIdx]
-			ret = append(ret, entry)
-			f.dirIdx = entry.next
-		}
-		return ret, nil
+	fs.Parse(args)
+	log.Setup()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(assetslife.ExitUsage)
 	}
 
-	ret = make([]os.FileInfo, 0, count)
-	for f.dirIdx >= 0 {
-		entry := &f.fs[f.dirIdx]
-		ret = append(ret, entry)
-		f.dirIdx = entry.next
-		if len(ret) == count {
-			return ret, nil
-		}
+	files, err := assetslife.PackageSource(fs.Arg(0)).Files()
+	if err != nil {
+		log.Fatal(err)
 	}
-	return ret, io.EOF
+	listAssets(os.Stdout, files)
 }
 
-func (f *httpFile) Close() error {
-	return nil
-}`
-	fmt.Fprintln(f, footer)
-	if err := f.Close(); err != nil {
-		return err
+// listAssets writes the modes, the sizes, the SHA-256 hashes abbreviated to 12 digits and the names of files into w,
+// a line per file.
+func listAssets(w io.Writer, files []*assetslife.File) {
+	for _, f := range files {
+		fmt.Fprintf(w, "%s %10d sha256:%s %s\n", f.Mode, len(f.Content), sha256Hex(f.Content)[:12], f.Name)
 	}
+}
 
-	f, err = os.OpenFile(filepath.Join(out, filename), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	format := `// Copyright (C) 2019 Ichinose Shogo All rights reserved.
-// Use of this source code is governed by a MIT-style
-// license that can be found in https://github.com/shogo82148/assets-life/blob/master/LICENSE
-
-// +build ignore
-
-// assets-life is a very simple embedding asset generator.
-// It generates an embed small in-memory file system that is served from an http.FileSystem.
-// Install the command line tool first.
-//
-//     go get github.com/shogo82148/assets-life
-//
-// The assets-life command generates a package that have embed small in-memory file system.
-//
-//     assets-life /path/to/your/project/public public
-//
-// You can access the file system by accessing a public variable Root of the generated package.
-//
-//     import (
-//         "net/http"
-//         "./public" // TODO: Replace with the absolute import path
-//     )
-//
-//     func main() {
-//         http.Handle("/", http.FileServer(public.Root))
-//         http.ListenAndServe(":8080", nil)
-//     }
-//
-// Visit http://localhost:8080/path/to/file to see your file.
-//
-// The assets-life command also embed go:generate directive into generated code, and assets-life itself.
-// It allows you to re-generate the package using go generate.
-//
-//     go generate ./public
-//
-// The assets-life command is no longer needed because it is embedded into the generated package.
-package main
-
-import (
-	"fmt"
-	"io/ioutil"
-	"log"
-	"os"
-	"path"
-	"path/filepath"
-	"strings"
-)
-
-func main() {
-	if len(os.Args) <= 2 {
-		log.Println("Usage:")
-		log.Println(os.Args[0] + " INPUT_DIR OUTPUT_DIR [PACKAGE_NAME]")
-		os.Exit(2)
+// runExtract runs the extract subcommand, which writes the files in the generated package or the bundle
+// into the output directory.
+func runExtract(args []string) {
+	log := assetslife.NewLogger(os.Stderr)
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	log.AddFlags(fs, "suppress the info messages")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage:")
+		fmt.Fprintln(w, os.Args[0]+" extract [OPTIONS] PACKAGE_DIR|BUNDLE OUTPUT_DIR")
+		fs.PrintDefaults()
 	}
-	in, err := filepath.Abs(os.Args[1])
-	if err != nil {
-		log.Fatal(err)
+	fs.Parse(args)
+	log.Setup()
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(assetslife.ExitUsage)
 	}
-	out, err := filepath.Abs(os.Args[2])
+
+	files, err := assetslife.PackageSource(fs.Arg(0)).Files()
 	if err != nil {
 		log.Fatal(err)
 	}
-	var name string
-	if len(os.Args) > 3 {
-		name = os.Args[3]
-	}
-	if name == "" {
-		name = filepath.Base(out)
-	}
-	if err := build(in, out, name); err != nil {
+	if err := extractAssets(files, fs.Arg(1)); err != nil {
 		log.Fatal(err)
 	}
+	log.Infof("extracted %d files into %s", len(files), fs.Arg(1))
 }
 
-func build(in, out, name string) error {
-	filename := "assets-life.go"
-	rel, err := filepath.Rel(out, in)
-	if err != nil {
-		return err
-	}
-	if err := os.MkdirAll(out, 0755); err != nil {
-		return err
+// extractAssets writes the regular files into the directory dir with their modes, creating the parent directories.
+// The names are checked by PackageSource, and it refuses writing through symbolic links.
+func extractAssets(files []*assetslife.File, dir string) error {
+	for _, f := range files {
+		target := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		info, err := os.Lstat(target)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if info != nil && info.Mode()&fs.ModeSymlink != 0 {
+			return &fs.PathError{Op: "extract", Path: target, Err: errors.New("refusing to follow symbolic link")}
+		}
+		if err := os.WriteFile(target, f.Content, 0600); err != nil {
+			return err
+		}
+		if err := os.Chmod(target, f.Mode.Perm()); err != nil {
+			return err
+		}
 	}
-	f, err := os.OpenFile(filepath.Join(out, "filesystem.go"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return err
+	return nil
+}
+
+// assetChange is a file that differs between the generated package and the input, or between two packages.
+type assetChange struct {
+	// kind is 'A' if the file is added to the input, 'D' if it is deleted, or 'M' if its content is modified.
+	kind byte
+
+	// name is the name of the file, e.g. "/index.html".
+	name string
+
+	// old is the content in the generated package, and new is the content in the input.
+	old, new string
+}
+
+func (c assetChange) String() string {
+	switch c.kind {
+	case 'A':
+		return fmt.Sprintf("A %s (+%d bytes)", c.name, len(c.new))
+	case 'D':
+		return fmt.Sprintf("D %s (-%d bytes)", c.name, len(c.old))
 	}
-	header := %c%s%c
-	fmt.Fprintf(f, header, filename, "go:generate go run "+filename+" \""+rel+"\" . "+name, name)
+	return fmt.Sprintf("M %s (%d -> %d bytes, %+d)", c.name, len(c.old), len(c.new), len(c.new)-len(c.old))
+}
 
-	type file struct {
-		path     string
-		mode     os.FileMode
-		children []int
-		next     int
+// hashString returns String with the SHA-256 hashes of the contents, abbreviated to 12 digits.
+func (c assetChange) hashString() string {
+	short := func(content string) string {
+		return sha256Hex([]byte(content))[:12]
 	}
-	index := map[string]int{}
-	files := []file{}
+	switch c.kind {
+	case 'A':
+		return fmt.Sprintf("A %s (+%d bytes, sha256:%s)", c.name, len(c.new), short(c.new))
+	case 'D':
+		return fmt.Sprintf("D %s (-%d bytes, sha256:%s)", c.name, len(c.old), short(c.old))
+	}
+	return fmt.Sprintf("M %s (%d -> %d bytes, %+d, sha256:%s -> sha256:%s)",
+		c.name, len(c.old), len(c.new), len(c.new)-len(c.old), short(c.old), short(c.new))
+}
 
-	var i int
-	err = filepath.Walk(in, func(path string, info os.FileInfo, err error) error {
-		// ignore hidden files
-		if strings.HasPrefix(info.Name(), ".") {
-			return nil
+// diffAssets compares the contents of the regular files in the generated package with files,
+// and returns the changes sorted by name.
+func diffAssets(generated map[string]string, files []*assetslife.File) []assetChange {
+	var changes []assetChange
+	seen := map[string]bool{}
+	for _, f := range files {
+		if f.Mode.IsDir() {
+			continue
 		}
-
-		if (info.Mode()&os.ModeType)|os.ModeDir != os.ModeDir {
-			return fmt.Errorf("unsupported file type: %%s, mode %%s", path, info.Mode())
+		seen[f.Name] = true
+		old, ok := generated[f.Name]
+		switch {
+		case !ok:
+			changes = append(changes, assetChange{kind: 'A', name: f.Name, new: string(f.Content)})
+		case old != string(f.Content):
+			changes = append(changes, assetChange{kind: 'M', name: f.Name, old: old, new: string(f.Content)})
 		}
-
-		index[path] = i
-		files = append(files, file{
-			path: path,
-			mode: info.Mode(),
-		})
-		parent := filepath.Dir(path)
-		if idx, ok := index[parent]; ok {
-			files[idx].children = append(files[idx].children, i)
+	}
+	for name, old := range generated {
+		if !seen[name] {
+			changes = append(changes, assetChange{kind: 'D', name: name, old: old})
 		}
-		i++
-		return nil
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].name < changes[j].name
 	})
-	if err != nil {
-		return err
+	return changes
+}
+
+// maxDiffLines is the maximum product of the numbers of the lines that unifiedDiff compares.
+const maxDiffLines = 10000000
+
+// unifiedDiff returns the unified diff of the lines of a and b with 3 lines of the context,
+// or false if they are too large to compare.
+func unifiedDiff(name, a, b string) (string, bool) {
+	x, y := splitLines(a), splitLines(b)
+	if len(x)*len(y) > maxDiffLines {
+		return "", false
 	}
 
-	for _, ff := range files {
-		// search neighborhood
-		for i := range ff.children {
-			next := -1
-			if i+1 < len(ff.children) {
-				next = ff.children[i+1]
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
 			}
-			files[ff.children[i]].next = next
 		}
+	}
 
-		fmt.Fprintf(f, "\tfile{\n")
-		rel, err := filepath.Rel(in, ff.path)
-		if err != nil {
-			return err
+	// edit is a line of the diff, and i and j are the indexes of the line in x and y.
+	type edit struct {
+		op   byte
+		line string
+		i, j int
+	}
+	var edits []edit
+	var changed []int
+	for i, j := 0, 0; i < len(x) || j < len(y); {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			edits = append(edits, edit{' ', x[i], i, j})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			changed = append(changed, len(edits))
+			edits = append(edits, edit{'-', x[i], i, j})
+			i++
+		default:
+			changed = append(changed, len(edits))
+			edits = append(edits, edit{'+', y[j], i, j})
+			j++
 		}
-		fmt.Fprintf(f, "\t\tname:    %%q,\n", path.Clean("/"+filepath.ToSlash(rel)))
-		if ff.mode.IsDir() {
-			fmt.Fprintln(f, "\t\tcontent: \"\",")
-		} else {
-			b, err := ioutil.ReadFile(ff.path)
-			if err != nil {
-				return err
+	}
+	if len(changed) == 0 {
+		return "", true
+	}
+
+	const context = 3
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- a%s\n+++ b%s\n", name, name)
+	for k := 0; k < len(changed); {
+		// merge the changes whose contexts overlap into a hunk.
+		last := k
+		for last+1 < len(changed) && changed[last+1]-changed[last] <= 2*context {
+			last++
+		}
+		start := changed[k] - context
+		if start < 0 {
+			start = 0
+		}
+		end := changed[last] + context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+		var oldLines, newLines int
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				oldLines++
+			}
+			if e.op != '-' {
+				newLines++
 			}
-			fmt.Fprintf(f, "\t\tcontent: %%q,\n", string(b))
 		}
-		switch {
-		case ff.mode.IsDir(): // directory
-			fmt.Fprintln(f, "\t\tmode:    0755 | os.ModeDir,")
-		case ff.mode&0100 != 0: // executable file
-			fmt.Fprintln(f, "\t\tmode:    0755,")
-		default:
-			fmt.Fprintln(f, "\t\tmode:    0644,")
+		oldStart, newStart := edits[start].i+1, edits[start].j+1
+		if oldLines == 0 {
+			oldStart--
 		}
-		fmt.Fprintf(f, "\t\tnext:    %%d,\n", ff.next)
-		if len(ff.children) > 0 {
-			fmt.Fprintf(f, "\t\tchild:   %%d,\n", ff.children[0])
-		} else {
-			fmt.Fprint(f, "\t\tchild:   -1,\n")
+		if newLines == 0 {
+			newStart--
 		}
-		fmt.Fprint(f, "\t},\n")
-	}
-	footer := %c%s%c
-	fmt.Fprintln(f, footer)
-	if err := f.Close(); err != nil {
-		return err
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLines, newStart, newLines)
+		for _, e := range edits[start:end] {
+			buf.WriteByte(e.op)
+			buf.WriteString(e.line)
+			buf.WriteByte('\n')
+		}
+		k = last + 1
 	}
+	return buf.String(), true
+}
 
-	f, err = os.OpenFile(filepath.Join(out, filename), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	format := %c%s%c
-	fmt.Fprintf(f, format, 96, header, 96, 96, footer, 96, 96, format, 96)
-	if err := f.Close(); err != nil {
-		return err
+// splitLines splits s into the lines without the line feeds.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
 	}
-	return nil
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
 }
-`
-	fmt.Fprintf(f, format, 96, header, 96, 96, footer, 96, 96, format, 96)
-	if err := f.Close(); err != nil {
-		return err
+
+// countFiles returns the number of the regular files in files.
+func countFiles(files []*assetslife.File) int {
+	var n int
+	for _, f := range files {
+		if !f.Mode.IsDir() {
+			n++
+		}
 	}
-	return nil
+	return n
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
 }
+
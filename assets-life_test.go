@@ -1,29 +1,639 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
-	"io/ioutil"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/shogo82148/assets-life/assetslife"
 )
 
-func Test(t *testing.T) {
-	a, err := ioutil.ReadFile("assets-life.go")
+func TestSelfSignedCertificate(t *testing.T) {
+	now := time.Now()
+	cert, err := selfSignedCertificate(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	for _, name := range []string{"localhost", "127.0.0.1", "::1"} {
+		_, err := leaf.Verify(x509.VerifyOptions{
+			DNSName:     name,
+			Roots:       roots,
+			CurrentTime: now,
+		})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:     "localhost",
+		Roots:       roots,
+		CurrentTime: now.Add(25 * time.Hour),
+	}); err == nil {
+		t.Error("the certificate must expire in a day")
+	}
+}
+
+func TestInjectLiveReload(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"<body>hello</body>", "<body>hello" + liveReloadScript + "</body>"},
+		{"<BODY>hello</BODY>\n", "<BODY>hello" + liveReloadScript + "</BODY>\n"},
+		{"hello", "hello" + liveReloadScript},
+	}
+	for _, c := range cases {
+		if got := string(injectLiveReload([]byte(c.in))); got != c.want {
+			t.Errorf("%q: want %q, got %q", c.in, c.want, got)
+		}
+	}
+}
+
+func TestLiveReload(t *testing.T) {
+	lr := newLiveReload(nil, "testdata/index", http.FileServer(http.Dir("testdata/index")))
+	ts := httptest.NewServer(lr)
+	defer ts.Close()
+
+	// the HTML pages have the client.
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(body, []byte(liveReloadScript)) {
+		t.Errorf("the client is not injected: %s", body)
+	}
+
+	// the WebSocket receives the message.
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET "+liveReloadPath+" HTTP/1.1\r\n")
+	fmt.Fprint(conn, "Host: "+ts.Listener.Addr().String()+"\r\n")
+	fmt.Fprint(conn, "Upgrade: websocket\r\n")
+	fmt.Fprint(conn, "Connection: Upgrade\r\n")
+	fmt.Fprint(conn, "Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n")
+	fmt.Fprint(conn, "Sec-WebSocket-Version: 13\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err = http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+	// the example of RFC 6455
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("unexpected Sec-WebSocket-Accept: want %q, got %q", want, got)
+	}
+
+	// wait for the client to be registered.
+	for i := 0; ; i++ {
+		lr.mu.Lock()
+		n := len(lr.clients)
+		lr.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if i > 100 {
+			t.Fatal("the client is not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	lr.reload()
+	frame := make([]byte, 8)
+	if _, err := io.ReadFull(br, frame); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\x81\x06reload"; string(frame) != want {
+		t.Errorf("unexpected frame: want %q, got %q", want, frame)
+	}
+}
+
+func TestHiddenFilter(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"index.txt":       "index",
+		".env":            "SECRET=1",
+		".git/config":     "[core]",
+		"sub/.htpasswd":   "user:pass",
+		"sub/visible.txt": "visible",
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := httptest.NewServer(http.FileServer(hiddenFilter{http.Dir(dir)}))
+	defer ts.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+	for _, path := range []string{"/.env", "/.git/config", "/.git/", "/sub/.htpasswd"} {
+		if code, _ := get(path); code != http.StatusNotFound {
+			t.Errorf("%s: want %d, got %d", path, http.StatusNotFound, code)
+		}
+	}
+	if code, body := get("/sub/visible.txt"); code != http.StatusOK || body != "visible" {
+		t.Errorf("unexpected response: %d %q", code, body)
+	}
+
+	// the listings don't have the hidden files.
+	_, body := get("/")
+	if strings.Contains(body, ".env") || strings.Contains(body, ".git") || !strings.Contains(body, "index.txt") {
+		t.Errorf("unexpected listing: %s", body)
+	}
+	_, body = get("/sub/")
+	if strings.Contains(body, ".htpasswd") || !strings.Contains(body, "visible.txt") {
+		t.Errorf("unexpected listing: %s", body)
+	}
+}
+
+func TestSignV4(t *testing.T) {
+	// get-vanilla of the test suite of AWS Signature Version 4.
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := s3Credentials{
+		accessKey: "AKIDEXAMPLE",
+		secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signV4(req, cred, "us-east-1", "service", sha256Hex(nil), time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+// setenv sets the environment variable and restores it at the end of the test.
+// It is t.Setenv of Go 1.17.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestSync(t *testing.T) {
+	objects := map[string]http.Header{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodHead:
+			header, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("X-Amz-Meta-Sha256", header.Get("X-Amz-Meta-Sha256"))
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			if sha256Hex(body) != r.Header.Get("X-Amz-Content-Sha256") {
+				http.Error(w, "bad digest", http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = r.Header
+		}
+	}))
+	defer ts.Close()
+
+	setenv(t, "AWS_ACCESS_KEY_ID", "AKID")
+	setenv(t, "AWS_SECRET_ACCESS_KEY", "SECRET")
+	c, prefix, err := newS3Client("s3://bucket/assets/", ts.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "assets" {
+		t.Errorf("unexpected prefix: %q", prefix)
+	}
+	assets := []*assetslife.File{
+		{Name: "/", Mode: 0755 | os.ModeDir},
+		{Name: "/index.html", Mode: 0644, Content: []byte("<html></html>")},
+		{Name: "/app.1a2b3c4d.css", Mode: 0644, Content: []byte("body {}")},
+	}
+	opts := &syncOptions{
+		prefix:                prefix,
+		cacheControl:          "no-cache",
+		immutableCacheControl: "immutable",
+		immutable:             map[string]bool{"/app.1a2b3c4d.css": true},
+		log:                   assetslife.NewLogger(io.Discard),
+	}
+	uploaded, skipped, err := syncAssets(c, assets, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != 2 || skipped != 0 {
+		t.Errorf("unexpected result: %d uploaded, %d skipped", uploaded, skipped)
+	}
+	html := objects["/bucket/assets/index.html"]
+	if html == nil || html.Get("Content-Type") != "text/html; charset=utf-8" || html.Get("Cache-Control") != "no-cache" {
+		t.Errorf("unexpected headers of index.html: %v", html)
+	}
+	css := objects["/bucket/assets/app.1a2b3c4d.css"]
+	if css == nil || css.Get("Cache-Control") != "immutable" {
+		t.Errorf("unexpected headers of app.css: %v", css)
+	}
+
+	// the unchanged files are skipped.
+	assets[1].Content = []byte("<html>changed</html>")
+	uploaded, skipped, err = syncAssets(c, assets, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != 1 || skipped != 1 {
+		t.Errorf("unexpected result: %d uploaded, %d skipped", uploaded, skipped)
+	}
+}
+
+func TestSignSharedKey(t *testing.T) {
+	// the well-known account and key of Azurite, the emulator of Azure Storage.
+	key, err := base64.StdEncoding.DecodeString("Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPut, "http://127.0.0.1:10000/devstoreaccount1/assets/index.html", strings.NewReader("<html></html>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Date", "Sun, 30 Aug 2015 12:36:00 GMT")
+	req.Header.Set("X-Ms-Version", azureVersion)
+	signSharedKey(req, "devstoreaccount1", key)
+	want := "SharedKey devstoreaccount1:KFq9LOEqxl+NowFXwbgBjtRq+Jy4KVfOoCLCiwdA9u0="
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestSyncAzure(t *testing.T) {
+	blobs := map[string]http.Header{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey account:") || r.Header.Get("X-Ms-Version") == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodHead:
+			header, ok := blobs[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("X-Ms-Meta-Sha256", header.Get("X-Ms-Meta-Sha256"))
+		case http.MethodPut:
+			if r.Header.Get("X-Ms-Blob-Type") != "BlockBlob" {
+				http.Error(w, "bad blob type", http.StatusBadRequest)
+				return
+			}
+			blobs[r.URL.Path] = r.Header
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer ts.Close()
+
+	setenv(t, "AZURE_STORAGE_ACCOUNT", "account")
+	setenv(t, "AZURE_STORAGE_KEY", base64.StdEncoding.EncodeToString([]byte("secret")))
+	c, prefix, err := newObjectStore("az://container/assets/", ts.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "assets" {
+		t.Errorf("unexpected prefix: %q", prefix)
+	}
+	assets := []*assetslife.File{
+		{Name: "/", Mode: 0755 | os.ModeDir},
+		{Name: "/index.html", Mode: 0644, Content: []byte("<html></html>")},
+	}
+	opts := &syncOptions{prefix: prefix, cacheControl: "no-cache", log: assetslife.NewLogger(io.Discard)}
+	uploaded, skipped, err := syncAssets(c, assets, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != 1 || skipped != 0 {
+		t.Errorf("unexpected result: %d uploaded, %d skipped", uploaded, skipped)
+	}
+	html := blobs["/container/assets/index.html"]
+	if html == nil || html.Get("X-Ms-Blob-Content-Type") != "text/html; charset=utf-8" || html.Get("X-Ms-Blob-Cache-Control") != "no-cache" {
+		t.Errorf("unexpected headers of index.html: %v", html)
+	}
+
+	// the unchanged files are skipped.
+	uploaded, skipped, err = syncAssets(c, assets, opts)
 	if err != nil {
 		t.Fatal(err)
 	}
-	b, err := ioutil.ReadFile("test/file/assets-life.go")
+	if uploaded != 0 || skipped != 1 {
+		t.Errorf("unexpected result: %d uploaded, %d skipped", uploaded, skipped)
+	}
+}
+
+func TestSplitDirective(t *testing.T) {
+	words, err := splitDirective(`go run assets-life.go -var "KEY=a b\"c" "../../testdata/deep" . deep`)
 	if err != nil {
 		t.Fatal(err)
 	}
+	want := []string{"go", "run", "assets-life.go", "-var", `KEY=a b"c`, "../../testdata/deep", ".", "deep"}
+	if fmt.Sprint(words) != fmt.Sprint(want) {
+		t.Errorf("want %q, got %q", want, words)
+	}
+	if _, err := splitDirective(`go run "assets-life.go`); err == nil {
+		t.Error("want an error, got nil")
+	}
+}
+
+func TestReadGeneratedPackage(t *testing.T) {
+	pkg, err := readGeneratedPackage("test/nonet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"-no-net", "../../testdata/deep", ".", "nonet"}
+	if fmt.Sprint(pkg.args) != fmt.Sprint(want) {
+		t.Errorf("want %q, got %q", want, pkg.args)
+	}
+	if pkg.apiVersion != apiVersion {
+		t.Errorf("want %d, got %d", apiVersion, pkg.apiVersion)
+	}
+
+	// the packages generated before APIVersion was added.
+	dir := t.TempDir()
+	src := "// Code generated by go run assets-life.go. DO NOT EDIT.\n\n//go:generate go run assets-life.go \"../in\" . old\n\npackage old\n"
+	if err := os.WriteFile(dir+"/filesystem.go", []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err = readGeneratedPackage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.apiVersion != 0 || fmt.Sprint(pkg.args) != "[../in . old]" {
+		t.Errorf("unexpected package: %+v", pkg)
+	}
+
+	if _, err := readGeneratedPackage(t.TempDir()); err == nil {
+		t.Error("want an error, got nil")
+	}
+}
+
+func TestDiffAssets(t *testing.T) {
+	generated := map[string]string{
+		"/same.txt":    "same",
+		"/deleted.txt": "deleted",
+		"/changed.txt": "old",
+	}
+	assets := []*assetslife.File{
+		{Name: "/", Mode: 0755 | os.ModeDir},
+		{Name: "/same.txt", Mode: 0644, Content: []byte("same")},
+		{Name: "/changed.txt", Mode: 0644, Content: []byte("changed")},
+		{Name: "/added.txt", Mode: 0644, Content: []byte("added")},
+	}
+	var got []string
+	for _, c := range diffAssets(generated, assets) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"A /added.txt (+5 bytes)",
+		"M /changed.txt (3 -> 7 bytes, +4)",
+		"D /deleted.txt (-7 bytes)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want %q, got %q", want, got)
+	}
 
-	// normalize line feed marks
-	a = bytes.Replace(a, []byte("\r\n"), []byte("\n"), -1)
-	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+	got = got[:0]
+	for _, c := range diffAssets(generated, assets) {
+		got = append(got, c.hashString())
+	}
+	want = []string{
+		"A /added.txt (+5 bytes, sha256:279b8a60f444)",
+		"M /changed.txt (3 -> 7 bytes, +4, sha256:cba06b5736fa -> sha256:d67e2e944994)",
+		"D /deleted.txt (-7 bytes, sha256:1185f37d33b0)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
 
-	// the first generation does not have the Build Constraints for "go get"
-	b = bytes.Replace(b, []byte("\n// +build ignore\n"), []byte(""), 1)
+func TestReadPackageFiles(t *testing.T) {
+	files, err := assetslife.InputSource("testdata/intern").Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "intern.alb")
+	if err := assetslife.WriteBundle(filename, files, ""); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := readPackageFiles("test/intern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := readPackageFiles(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pkg, bundle) {
+		t.Errorf("the package and the bundle differ: %v, %v", pkg, bundle)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	b := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n"
+	got, ok := unifiedDiff("/n.txt", a, b)
+	if !ok {
+		t.Fatal("want ok, got not ok")
+	}
+	want := "--- a/n.txt\n+++ b/n.txt\n" +
+		"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n" +
+		"@@ -13,3 +13,4 @@\n 13\n 14\n 15\n+16\n"
+	if got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
 
-	if string(a) != string(b) {
-		t.Error("do not match", string(b))
+	if got, _ := unifiedDiff("/n.txt", "", "new\n"); got != "--- a/n.txt\n+++ b/n.txt\n@@ -0,0 +1,1 @@\n+new\n" {
+		t.Errorf("unexpected diff of the new file:\n%s", got)
+	}
+}
+
+// runCommandProcess runs the command with args in a subprocess of the test binary,
+// and returns its stderr and its exit status.
+func runCommandProcess(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), "ASSETS_LIFE_HELPER_ARGS="+strings.Join(args, "\n"))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stderr.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stderr.String(), 0
+}
+
+// TestHelperProcess is the command run by runCommandProcess. It is skipped in the tests.
+func TestHelperProcess(t *testing.T) {
+	args := os.Getenv("ASSETS_LIFE_HELPER_ARGS")
+	if args == "" {
+		t.Skip("the helper process of runCommandProcess")
+	}
+	os.Args = append([]string{"assets-life"}, strings.Split(args, "\n")...)
+	main()
+	os.Exit(0)
+}
+
+func TestSubcommandDirectory(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.Mkdir("sync", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("sync", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the existing directory is the input, not the sync subcommand.
+	stderr, code := runCommandProcess(t, "sync", "public")
+	if code != 0 {
+		t.Fatalf("want the exit status 0, got %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join("public", "filesystem.go")); err != nil {
+		t.Error(err)
+	}
+
+	// generate is the generator even if the input is not a directory.
+	stderr, code = runCommandProcess(t, "generate", "serve", "public")
+	if code != assetslife.ExitIO || strings.Contains(stderr, "Usage") {
+		t.Errorf("want the exit status %d of the missing input, got %d: %s", assetslife.ExitIO, code, stderr)
+	}
+
+	// serve is the subcommand without the directory.
+	stderr, code = runCommandProcess(t, "serve", "-addr", "bad address", "public")
+	if code == 0 || !strings.Contains(stderr, "bad address") {
+		t.Errorf("want the error of serve, got %d: %s", code, stderr)
+	}
+}
+
+func TestExtractAssets(t *testing.T) {
+	files, err := assetslife.InputSource("testdata/preservemode", assetslife.WithPreserveMode()).Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "assets.alb")
+	if err := assetslife.WriteBundle(filename, files, ""); err != nil {
+		t.Fatal(err)
+	}
+	bundled, err := assetslife.PackageSource(filename).Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ls lists the regular files of the bundle.
+	var buf bytes.Buffer
+	listAssets(&buf, bundled)
+	for _, f := range files {
+		if f.Mode.IsDir() {
+			continue
+		}
+		line := fmt.Sprintf("%s %10d sha256:%s %s\n", f.Mode, len(f.Content), sha256Hex(f.Content)[:12], f.Name)
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("want %q in %q", line, buf.String())
+		}
+	}
+
+	// extract writes the files with their modes.
+	dir := t.TempDir()
+	if err := extractAssets(bundled, dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if f.Mode.IsDir() {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(f.Name))
+		content, err := os.ReadFile(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, f.Content) {
+			t.Errorf("%s: want %q, got %q", f.Name, f.Content, content)
+		}
+		info, err := os.Stat(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != f.Mode.Perm() {
+			t.Errorf("%s: want %s, got %s", f.Name, f.Mode.Perm(), info.Mode().Perm())
+		}
+	}
+
+	// the generated packages are also extracted.
+	pkg, err := assetslife.PackageSource("test/intern").Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := extractAssets(pkg, t.TempDir()); err != nil {
+		t.Fatal(err)
 	}
 }
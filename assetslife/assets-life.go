@@ -0,0 +1,11487 @@
+// Copyright (C) 2019 Ichinose Shogo All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in https://github.com/shogo82148/assets-life/blob/master/LICENSE
+
+// Package assetslife generates the packages of the embedded files, as the assets-life command does.
+// It is the generator of the command, and github.com/shogo82148/assets-life is a thin wrapper of it.
+//
+//     b := assetslife.New("web/dist", "public", assetslife.WithPackageName("public"))
+//     if err := b.Build(ctx); err != nil {
+//         log.Fatal(err)
+//     }
+//
+// ParseArgs parses the command line of the generator into the Builder,
+// e.g. the arguments of the go:generate directive of the generated package.
+// The generated package embeds the copy of this file as package main with the main function,
+// which go generate runs to regenerate it without installing the command.
+package assetslife
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"hash"
+	"hash/crc32"
+	"html"
+	htmltemplate "html/template"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"math"
+	"math/bits"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Logger writes the log messages and the status line of a generation or a subcommand of the command.
+// Each Builder has its own Logger unless WithLogger shares one, so the concurrent generations in the library share no state.
+// The nil Logger writes to stderr in the text format.
+type Logger struct {
+	out io.Writer
+
+	// format is the format of the log messages, "text" or "json".
+	format string
+
+	// quiet suppresses the progress and the info messages.
+	quiet bool
+
+	// color colors the levels of the log messages in the text format.
+	color bool
+
+	// showStatus shows the status line, such as the progress, on the terminal.
+	showStatus bool
+
+	mu sync.Mutex
+
+	// statusShown reports whether the status line is on the terminal now.
+	statusShown bool
+
+	// warnings is the number of the warnings reported by Warnf.
+	warnings int
+}
+
+// NewLogger returns the Logger that writes to w in the text format.
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{out: w, format: "text"}
+}
+
+// AddFlags adds -q and -log-format to fs. help is the usage of -q.
+func (l *Logger) AddFlags(fs *flag.FlagSet, help string) {
+	fs.BoolVar(&l.quiet, "q", false, help)
+	fs.Var((*logFormat)(&l.format), "log-format", "the `format` of the log messages, \"text\" or \"json\"")
+}
+
+// logFormat is the value of -log-format.
+type logFormat string
+
+func (f *logFormat) String() string {
+	return string(*f)
+}
+
+func (f *logFormat) Set(s string) error {
+	if s != "text" && s != "json" {
+		return fmt.Errorf("unknown log format: %q", s)
+	}
+	*f = logFormat(s)
+	return nil
+}
+
+// Setup configures the log messages for the terminal after the flags of AddFlags are parsed.
+func (l *Logger) Setup() {
+	if l.format != "text" {
+		return
+	}
+	// respect the NO_COLOR environment value, see https://no-color.org/.
+	term := isTerminal(os.Stderr)
+	l.color = term && os.Getenv("NO_COLOR") == ""
+	l.showStatus = term && !l.quiet
+}
+
+// Format returns the format of the log messages, "text" or "json", e.g. to pass -log-format to the child processes.
+func (l *Logger) Format() string {
+	return l.format
+}
+
+// Quiet reports whether -q suppresses the progress and the info messages.
+func (l *Logger) Quiet() bool {
+	return l.quiet
+}
+
+// logLevel is the level of a log message.
+type logLevel string
+
+const (
+	levelInfo  logLevel = "info"
+	levelWarn  logLevel = "warn"
+	levelError logLevel = "error"
+)
+
+// color returns the ANSI escape sequence that colors the level.
+func (l logLevel) color() string {
+	switch l {
+	case levelWarn:
+		return "\x1b[33m" // yellow
+	case levelError:
+		return "\x1b[31m" // red
+	default:
+		return "\x1b[36m" // cyan
+	}
+}
+
+// logf writes a log message of the level in the format of -log-format.
+func (l *Logger) logf(level logLevel, format string, args ...interface{}) {
+	if l == nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", level, fmt.Sprintf(format, args...))
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clearStatusLocked()
+	msg := fmt.Sprintf(format, args...)
+	if l.format == "json" {
+		b, err := json.Marshal(struct {
+			Time    string   `json:"time"`
+			Level   logLevel `json:"level"`
+			Message string   `json:"msg"`
+		}{
+			Time:    time.Now().Format(time.RFC3339),
+			Level:   level,
+			Message: msg,
+		})
+		if err != nil {
+			panic(err)
+		}
+		l.out.Write(append(b, '\n'))
+		return
+	}
+	label := string(level)
+	if l.color {
+		label = level.color() + label + "\x1b[0m"
+	}
+	fmt.Fprintf(l.out, "%s: %s\n", label, msg)
+}
+
+// Infof reports the progress of the generation.
+// It is suppressed by -q.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	if l != nil && l.quiet {
+		return
+	}
+	l.logf(levelInfo, format, args...)
+}
+
+// Warnf reports a warning that doesn't stop the generation.
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	if l != nil {
+		l.mu.Lock()
+		l.warnings++
+		l.mu.Unlock()
+	}
+	l.logf(levelWarn, format, args...)
+}
+
+// Warnings returns the number of the warnings reported by Warnf.
+func (l *Logger) Warnings() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.warnings
+}
+
+// Fatalf reports an error and exits with ExitFailure.
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.logf(levelError, format, args...)
+	os.Exit(ExitFailure)
+}
+
+// Usagef reports an error of the command line and exits with ExitUsage.
+func (l *Logger) Usagef(format string, args ...interface{}) {
+	l.logf(levelError, format, args...)
+	os.Exit(ExitUsage)
+}
+
+// Fatal reports err and exits with the status of its class, see ExitCode.
+func (l *Logger) Fatal(err error) {
+	l.logf(levelError, "%v", err)
+	os.Exit(ExitCode(err))
+}
+
+// The exit statuses of assets-life, so the wrapper scripts and CI can branch on the class of the failure.
+const (
+	// ExitFailure is the status of the errors that are not classified, e.g. the bugs of the generator.
+	ExitFailure = 1
+
+	// ExitUsage is the status of the invalid command line, e.g. the unknown options or the options that conflict.
+	ExitUsage = 2
+
+	// ExitWarnings is the status when any warnings occurred with -strict.
+	ExitWarnings = 3
+
+	// ExitIO is the status of the failures to read or write the files.
+	ExitIO = 4
+
+	// ExitValidation is the status of the invalid inputs, e.g. the configuration file or the files beyond the limits.
+	ExitValidation = 5
+
+	// ExitDrift is the status of the diff and diff-pkg subcommands when the files differ.
+	ExitDrift = 6
+)
+
+// UsageError is the error of the invalid options, e.g. the options that cannot be used together.
+type UsageError struct {
+	err error
+}
+
+func (e *UsageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf returns a UsageError formatted as fmt.Errorf.
+func usageErrorf(format string, args ...interface{}) error {
+	return &UsageError{err: fmt.Errorf(format, args...)}
+}
+
+// ValidationError is the error of the invalid inputs, e.g. the configuration file or the files beyond the limits.
+type ValidationError struct {
+	err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+// validationErrorf returns a ValidationError formatted as fmt.Errorf.
+func validationErrorf(format string, args ...interface{}) error {
+	return &ValidationError{err: fmt.Errorf(format, args...)}
+}
+
+// WarningsError is the error of Build with WithStrict when any warnings occurred.
+type WarningsError struct {
+	// Warnings is the number of the warnings.
+	Warnings int
+}
+
+func (e *WarningsError) Error() string {
+	return fmt.Sprintf("%d warning(s) occurred", e.Warnings)
+}
+
+// ExitCode returns the exit status of the command for the class of err.
+func ExitCode(err error) int {
+	var usageErr *UsageError
+	var validationErr *ValidationError
+	var warningsErr *WarningsError
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &usageErr):
+		return ExitUsage
+	case errors.As(err, &warningsErr):
+		return ExitWarnings
+	case errors.As(err, &validationErr):
+		return ExitValidation
+	case errors.As(err, &pathErr), errors.As(err, &linkErr):
+		return ExitIO
+	}
+	return ExitFailure
+}
+
+// status shows the message on the status line.
+// It is suppressed by -q, and when the log is not on the terminal, e.g. in the library.
+func (l *Logger) status(format string, args ...interface{}) {
+	if l == nil || l.quiet || !l.showStatus {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.out, "\r\x1b[K"+format, args...)
+	l.statusShown = true
+}
+
+// clearStatus clears the status line.
+func (l *Logger) clearStatus() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clearStatusLocked()
+}
+
+func (l *Logger) clearStatusLocked() {
+	if !l.statusShown {
+		return
+	}
+	fmt.Fprint(l.out, "\r\x1b[K")
+	l.statusShown = false
+}
+
+// progress reports the progress of reading files on the status line.
+type progress struct {
+	log   *Logger
+	label string
+
+	// total and totalBytes are the number and the size of the files.
+	// They are zero if unknown.
+	total      int
+	totalBytes int64
+
+	files int
+	bytes int64
+	start time.Time
+	last  time.Time // when the progress is shown last
+}
+
+// progressInterval is the interval of updating the progress.
+const progressInterval = 100 * time.Millisecond
+
+func newProgress(log *Logger, label string, total int, totalBytes int64) *progress {
+	now := time.Now()
+	return &progress{
+		log:        log,
+		label:      label,
+		total:      total,
+		totalBytes: totalBytes,
+		start:      now,
+		last:       now,
+	}
+}
+
+// add records that a file of the size is processed.
+func (p *progress) add(size int64) {
+	p.files++
+	p.bytes += size
+	now := time.Now()
+	if now.Sub(p.last) < progressInterval {
+		return
+	}
+	p.last = now
+
+	if p.total == 0 {
+		p.log.status("%s: %d files, %s", p.label, p.files, formatBytes(p.bytes))
+		return
+	}
+	var ratio float64
+	if p.totalBytes > 0 {
+		ratio = float64(p.bytes) / float64(p.totalBytes)
+	} else {
+		ratio = float64(p.files) / float64(p.total)
+	}
+	eta := "-"
+	if ratio > 0 {
+		elapsed := now.Sub(p.start)
+		eta = time.Duration(float64(elapsed) * (1 - ratio) / ratio).Round(time.Second).String()
+	}
+	p.log.status("%s: %d/%d files, %s/%s, ETA %s", p.label, p.files, p.total, formatBytes(p.bytes), formatBytes(p.totalBytes), eta)
+}
+
+// done clears the progress.
+func (p *progress) done() {
+	p.log.clearStatus()
+}
+
+// formatBytes formats the size in bytes for humans.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// isTerminal reports whether f is a terminal that supports the escape sequences.
+func isTerminal(f *os.File) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// options is the options of the generator.
+// All paths are absolute.
+type options struct {
+	// in is the input directory or archive.
+	in string
+
+	// out is the output directory.
+	out string
+
+	// name is the name of the generated package.
+	name string
+
+	// template is the path to the custom template, or empty to use the built-in template.
+	template string
+
+	// filesFrom is the path to the list of the files to embed, or "-" to read the list from stdin.
+	// If it is empty, all files in the input directory are embedded.
+	filesFrom string
+
+	// gitRef is the git revision to read the files from.
+	// If it is empty, the files are read from the working tree.
+	gitRef string
+
+	// exportIgnore excludes the files with the export-ignore attribute.
+	exportIgnore bool
+
+	// remote is the path to the manifest of the remote files, or empty if there are no remote files.
+	remote string
+
+	// cacheDir is the cache directory of the remote files.
+	// If it is empty, assets-life in the user cache directory is used.
+	cacheDir string
+
+	// preserveMode embeds the exact permission bits of the files.
+	preserveMode bool
+
+	// preserveMTime embeds the modification times of the files.
+	preserveMTime bool
+
+	// skipUnreadable skips the files that cannot be read because of the permissions.
+	skipUnreadable bool
+
+	// strict makes the warnings fail the generation.
+	strict bool
+
+	// maxFiles is the maximum number of the files to embed, or 0 for no limit.
+	maxFiles int
+
+	// maxDepth is the maximum depth of the files to embed, or 0 for no limit.
+	maxDepth int
+
+	// exclude is the glob patterns of the files not to embed.
+	exclude []string
+
+	// hooks is the hooks of the files given by the library, applied in order.
+	hooks []hook
+
+	// allowArtifacts is the patterns of the files that look like the build artifacts but are embedded without warnings.
+	allowArtifacts []string
+
+	// log reports the progress and the warnings of the generation, or nil to write them to stderr.
+	log *Logger
+
+	// config is the path to the configuration file, or empty if there is no configuration file.
+	config string
+
+	// variant is the name of the variant embedded when no variant build tags are set.
+	// If it is empty, the input directory is embedded.
+	variant string
+
+	// ownModule is the module path of the generated package.
+	// If it is not empty, go.mod is generated.
+	ownModule string
+
+	// adapters is the names of the libraries to generate the adapters for.
+	adapters []string
+
+	// preload finds the critical resources of the HTML files to preload them.
+	preload bool
+
+	// fingerprint adds the hashes of the contents to the names of the files.
+	fingerprint bool
+
+	// precache embeds the precache manifest.
+	precache bool
+
+	// serviceWorker embeds the service worker that precaches the files.
+	serviceWorker bool
+
+	// noNet generates the package that implements fs.FS without net/http,
+	// and the wrapper for net/http into the separate file.
+	noNet bool
+
+	// bench generates the benchmarks of the package.
+	bench bool
+
+	// standalone generates the copy of the runtime into the package instead of importing it from the assetsfs package.
+	standalone bool
+
+	// backend is the storage strategy of the contents in the generated code.
+	backend Backend
+
+	// source is the source of the files set by WithSource, which replaces the input directory.
+	source Source
+
+	// maxMemory is the maximum size of the contents in memory in MB, which enables the memory-bounded mode,
+	// or 0 to read all contents into memory.
+	maxMemory int
+
+	// checkCompile is the go command that checks the generated package before it is written, "build" or "vet",
+	// or empty not to check it.
+	checkCompile string
+
+	// htmlBase is the href of the base element set into the HTML files, or empty.
+	htmlBase string
+
+	// htmlMeta maps the names of the meta elements set into the HTML files to their contents.
+	htmlMeta map[string]string
+
+	// htmlInject is the path to the file inserted before </head> of the HTML files, e.g. the analytics snippet, or empty.
+	htmlInject string
+
+	// unsafeBytes generates Bytes, which returns the zero-copy view of the content.
+	unsafeBytes bool
+
+	// dirsFirst lists the directories before the files in Readdir.
+	dirsFirst bool
+
+	// stripMetadata removes the metadata from the JPEG and PNG images.
+	stripMetadata bool
+
+	// vars is the variables of the templates in the configuration file, given by the -var options.
+	vars map[string]string
+
+	// normalizeEOL is the line ending of the text files, "lf" or "crlf", or empty to keep them as they are.
+	normalizeEOL string
+
+	// stripBOM removes the UTF-8 byte order marks from the text files.
+	stripBOM bool
+
+	// sourceMaps is the policy of the source maps, "keep", "strip" or "debug".
+	sourceMaps string
+
+	// hash is the hash algorithm of the fingerprints, the precache manifest and the hashes of the generated package,
+	// "crc32", "sha1", "sha256" or "blake3".
+	hash string
+
+	// signKey is the path to the Ed25519 private key that signs the manifest of the files.
+	signKey string
+
+	// gzipSources is the policy of the pre-compressed files, "keep", "encoded" or "decompress".
+	gzipSources string
+
+	// compression is the algorithm of the pre-compressed variants added for the compressible files.
+	compression Compression
+
+	// notices adds /NOTICES, the licenses of the third-party files.
+	notices bool
+
+	// charsets is the candidate charsets of the text files that are not valid UTF-8, converted to UTF-8.
+	charsets []string
+}
+
+// Builder generates the package of the embedded files, as the assets-life command does.
+// It is the library API for the code generators that embed assets-life without running the command.
+// Create it with New, or with ParseArgs from the command line.
+//
+// The options are those of the command, which builds the package by Builder too.
+// The warnings and the progress are reported to stderr, or to the writer of WithLogOutput,
+// and each Builder counts its own warnings, so the Builders may run concurrently.
+type Builder struct {
+	opts *options
+}
+
+// Option is an option of Builder.
+type Option func(*options)
+
+// Compression is the algorithm of the pre-compressed variants of the files, as -compression selects.
+type Compression string
+
+const (
+	// NoCompression adds no variants. It is the default.
+	NoCompression Compression = "none"
+
+	// Gzip adds the gzip variants, e.g. app.js.gz, which the handler serves with Content-Encoding: gzip.
+	Gzip Compression = "gzip"
+)
+
+// New returns the Builder that generates the package from the input directory or archive in into the directory out.
+func New(in, out string, opts ...Option) *Builder {
+	o := &options{
+		in:  in,
+		out: out,
+		log: NewLogger(os.Stderr),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Builder{opts: o}
+}
+
+// ParseArgs parses the command line of the generator, the options and INPUT_DIR OUTPUT_DIR [PACKAGE_NAME]
+// as in the go:generate directive of the generated package, with fs, and returns the Builder of New.
+// The flags of the options, e.g. -exclude and -fingerprint, and of the Logger are added to fs,
+// so the errors of the flags are handled by fs, and fs.Usage is called if the arguments are missing.
+// opts are applied before the flags, e.g. WithLogger to report in the format of -log-format.
+func ParseArgs(fs *flag.FlagSet, args []string, opts ...Option) (*Builder, error) {
+	o := &options{log: NewLogger(os.Stderr)}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var internal bool
+	fs.StringVar(&o.template, "template", "", "path to a custom template of the generated filesystem.go")
+	fs.StringVar(&o.filesFrom, "files-from", "", "read the list of files to embed from the file instead of walking INPUT_DIR, \"-\" for stdin")
+	fs.StringVar(&o.gitRef, "git-ref", "", "read the files from the git `revision` instead of the working tree")
+	fs.BoolVar(&o.exportIgnore, "export-ignore", false, "exclude the files with the export-ignore attribute of .gitattributes, as git archive does")
+	fs.StringVar(&o.remote, "remote", "", "path to the manifest of the remote files to download and embed")
+	fs.BoolVar(&o.preserveMode, "preserve-mode", false, "embed the exact permission bits of the files instead of 0644 and 0755")
+	fs.BoolVar(&o.preserveMTime, "preserve-mtime", false, "embed the modification times of the files, which ExtractTo restores and the handlers serve as Last-Modified")
+	fs.BoolVar(&o.skipUnreadable, "skip-unreadable", false, "skip the files that cannot be read because of the permissions, with warnings")
+	fs.BoolVar(&o.strict, "strict", false, "exit with status 3 if any warnings occurred")
+	fs.StringVar(&o.checkCompile, "check-compile", "", "check the generated package with go `build` or vet before writing it, and exit with status 5 if it fails")
+	fs.IntVar(&o.maxFiles, "max-files", 0, "fail if the number of the files exceeds `n`, 0 for no limit")
+	fs.IntVar(&o.maxMemory, "max-memory", 0, "generate in the memory-bounded mode, which streams the contents into the files embedded by go:embed and holds at most `MB` megabytes of them in memory, for the trees larger than the memory")
+	fs.IntVar(&o.maxDepth, "max-depth", 0, "fail if the files are deeper than `n` directories, e.g. /a/b/c is at the depth 3, 0 for no limit")
+	fs.Var((*listFlag)(&o.exclude), "exclude", "comma-separated glob `patterns` of the files not to embed, e.g. *.map,/drafts")
+	fs.Var((*listFlag)(&o.allowArtifacts), "allow-artifacts", "comma-separated glob `patterns` of the files that look like the build artifacts, e.g. *.log, but are meant to be embedded")
+	fs.StringVar(&o.config, "config", "", "path to the configuration file")
+	fs.StringVar(&o.variant, "variant", "", "the `name` of the variant in the configuration file embedded when no variant build tags are set")
+	fs.BoolVar(&internal, "internal", false, "generate the package into the internal directory, i.e. OUTPUT_DIR/../internal/PACKAGE_NAME")
+	fs.StringVar(&o.ownModule, "own-module", "", "write go.mod of the module `path` for the generated package, to make it a separate module")
+	fs.Var((*listFlag)(&o.adapters), "adapters", "comma-separated `names` of the libraries to generate the adapters for: afero, billy, chi, echo, expvar, fiber, gin, js, otel, prometheus and slog")
+	fs.BoolVar(&o.preload, "preload", false, "find the critical CSS and JavaScript of the HTML files to preload them")
+	fs.BoolVar(&o.fingerprint, "fingerprint", false, "add the hashes of the contents to the names of the files except HTML, and rewrite the references in HTML and CSS")
+	fs.BoolVar(&o.precache, "precache", false, "embed precache-manifest.json, which lists the files and their revisions for service workers")
+	fs.BoolVar(&o.serviceWorker, "service-worker", false, "embed sw.js, the service worker that precaches the files, and precache-manifest.json")
+	fs.BoolVar(&o.noNet, "no-net", false, "generate the package that implements fs.FS without net/http, e.g. for TinyGo, and Root into filesystem-http.go")
+	fs.BoolVar(&o.standalone, "standalone", false, "generate the copy of the runtime into filesystem-runtime.go instead of importing github.com/shogo82148/assets-life/assetsfs, so the package depends only on the standard library")
+	fs.Var(backendFlag{&o.backend}, "backend", "the `name` of the storage of the contents: string, which writes the string literals, base64, zip, which embeds a deflated zip file, or embed, which embeds a file per content (default string)")
+	fs.BoolVar(&o.bench, "bench", false, "generate filesystem_bench_test.go, the benchmarks of Open, Read, Readdir and Handler")
+	fs.BoolVar(&o.stripMetadata, "strip-metadata", false, "remove the metadata, e.g. EXIF with the GPS location, from the JPEG and PNG images")
+	fs.Var((*varFlag)(&o.vars), "var", "set the variable of the templates in the configuration file in the form of `KEY=VALUE`, can be given multiple times")
+	fs.StringVar(&o.normalizeEOL, "normalize-eol", "", "normalize the line endings of the text files to `lf` or crlf")
+	fs.StringVar(&o.htmlBase, "html-base", "", "set the `href` of the <base> element of the HTML files, e.g. /app/")
+	fs.Var((*varFlag)(&o.htmlMeta), "html-meta", "set the <meta> element of the HTML files in the form of `NAME=CONTENT`, e.g. version=1.2.3, can be given multiple times")
+	fs.StringVar(&o.htmlInject, "html-inject", "", "`path` to the file inserted before </head> of the HTML files, e.g. the analytics snippet")
+	fs.Var((*listFlag)(&o.charsets), "convert-charset", "comma-separated `charsets` tried in order to convert the text files that are not valid UTF-8 to UTF-8 with iconv, e.g. shift_jis,euc-jp")
+	fs.StringVar(&o.sourceMaps, "source-maps", "keep", "the `policy` of the source maps: keep, strip, which removes the .map files and the sourceMappingURL comments, or debug, which embeds them only with the debug build tag")
+	fs.StringVar(&o.hash, "hash", "sha256", "the hash `algorithm` of the fingerprints, the precache manifest, the ETags, Hash and ByHash: crc32, sha1, sha256 or blake3")
+	fs.StringVar(&o.signKey, "sign-key", "", "`path` to the Ed25519 private key in PEM that signs the manifest of the files, verified by VerifySignature")
+	fs.StringVar(&o.gzipSources, "gzip-sources", "keep", "the `policy` of the pre-compressed files, e.g. app.js.gz: keep, encoded, which serves them to the clients that accept gzip, or decompress")
+	fs.StringVar((*string)(&o.compression), "compression", "none", "the `algorithm` of the pre-compressed variants added for the compressible files and served to the clients that accept them: none or gzip")
+	fs.BoolVar(&o.notices, "notices", false, "embed NOTICES, the licenses and the banner comments found in the files, and generate Licenses")
+	fs.BoolVar(&o.stripBOM, "strip-bom", false, "remove the UTF-8 byte order marks from the text files")
+	fs.BoolVar(&o.dirsFirst, "dirs-first", false, "list the directories before the files in Readdir, instead of sorting all entries by name")
+	fs.BoolVar(&o.unsafeBytes, "unsafe-bytes", false, "generate Bytes into filesystem-bytes.go, which returns the content without copying it via unsafe")
+	fs.StringVar(&o.cacheDir, "cache-dir", "", "path to the cache directory of the remote files (default: assets-life in the user cache directory)")
+	o.log.AddFlags(fs, "suppress the progress and the info messages")
+	if err := fs.Parse(args); err != nil {
+		return nil, &UsageError{err}
+	}
+	o.log.Setup()
+	args = fs.Args()
+	if o.filesFrom != "" && len(args) == 1 {
+		// the listed files are relative to the current directory.
+		args = append([]string{"."}, args...)
+	}
+	if len(args) < 2 {
+		fs.Usage()
+		return nil, usageErrorf("INPUT_DIR and OUTPUT_DIR are required")
+	}
+	o.in = args[0]
+	o.out = args[1]
+	if len(args) > 2 {
+		o.name = args[2]
+	}
+	var err error
+	o.out, err = filepath.Abs(o.out)
+	if err != nil {
+		return nil, err
+	}
+	if internal && o.ownModule != "" {
+		return nil, usageErrorf("-internal and -own-module cannot be used together")
+	}
+	if internal && filepath.Base(filepath.Dir(o.out)) != "internal" {
+		// the directive is relative to the output directory, so it doesn't need -internal.
+		o.out = filepath.Join(filepath.Dir(o.out), "internal", filepath.Base(o.out))
+	}
+	return &Builder{opts: o}, nil
+}
+
+// Warnings returns the number of the warnings reported by Build, which fails -strict of the command.
+func (b *Builder) Warnings() int {
+	return b.opts.log.Warnings()
+}
+
+// Build generates the package.
+// It returns *UsageError if the options are invalid, *ValidationError if the inputs are invalid,
+// and *WarningsError with WithStrict if any warnings occurred.
+func (b *Builder) Build(ctx context.Context) error {
+	if err := b.opts.validate(); err != nil {
+		return err
+	}
+	if err := build(ctx, b.opts); err != nil {
+		return err
+	}
+	if n := b.opts.log.Warnings(); b.opts.strict && n > 0 {
+		return &WarningsError{Warnings: n}
+	}
+	return nil
+}
+
+// Generate generates the package of the files of source in memory as Build does,
+// and returns the generated files by their slash-separated names, e.g. "filesystem.go",
+// e.g. for the golden tests of the generated code with the combinations of the options.
+// The package is generated as if it were in the current directory,
+// which is the base of the relative paths in the go:generate directive and the default package name.
+func Generate(ctx context.Context, source Source, opts ...Option) (map[string][]byte, error) {
+	o := &options{
+		source: source,
+		out:    ".",
+		log:    NewLogger(os.Stderr),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	files := memOutput{}
+	if err := generate(ctx, o, files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// BuildFS builds the files of source in memory as Build does, without generating the package,
+// e.g. for the tests of the transforms and of the helpers that take the generated file systems.
+// The files are those of the variant of WithVariant on the current platform,
+// and the options of the generated code, e.g. WithBackend, have no effect.
+func BuildFS(source Source, opts ...Option) (fs.FS, error) {
+	o := &options{
+		source: source,
+		// nothing is written into the output, but the options are validated against it.
+		out: ".",
+		log: NewLogger(os.Stderr),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	var cfg *config
+	if o.config != "" {
+		var err error
+		cfg, err = readConfig(o.config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	shards, err := o.shards(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, sh := range shards {
+		ok, err := sh.selected(o.variant)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if _, err := o.process(sh, cfg); err != nil {
+			return nil, err
+		}
+		fsys := fstest.MapFS{}
+		for _, f := range newFileTable(sh.assets, o.preserveMode, o.preserveMTime, o.dirsFirst) {
+			if f.Name == "/" {
+				continue
+			}
+			fsys[strings.TrimPrefix(f.Name, "/")] = &fstest.MapFile{
+				Data: []byte(f.Content),
+				Mode: f.Mode,
+			}
+			if f.ModTime != 0 {
+				fsys[strings.TrimPrefix(f.Name, "/")].ModTime = time.Unix(0, f.ModTime)
+			}
+		}
+		return fsys, nil
+	}
+	return nil, validationErrorf("no files are built on %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// WithLogOutput writes the warnings and the progress to w instead of stderr.
+func WithLogOutput(w io.Writer) Option {
+	return func(opts *options) {
+		if opts.log == nil {
+			opts.log = NewLogger(w)
+		}
+		opts.log.out = w
+	}
+}
+
+// WithLogger reports the warnings and the progress to l, e.g. the Logger of the command configured by its flags.
+// The Builders that share l count their warnings together.
+func WithLogger(l *Logger) Option {
+	return func(opts *options) {
+		opts.log = l
+	}
+}
+
+// WithStrict fails Build with *WarningsError if any warnings occurred, as -strict does.
+func WithStrict() Option {
+	return func(opts *options) {
+		opts.strict = true
+	}
+}
+
+// WithQuiet suppresses the progress, as -q does. The warnings are still reported.
+func WithQuiet() Option {
+	return func(opts *options) {
+		if opts.log == nil {
+			opts.log = NewLogger(os.Stderr)
+		}
+		opts.log.quiet = true
+	}
+}
+
+// WithPackageName sets the name of the generated package.
+// The default is the base name of the output directory.
+func WithPackageName(name string) Option {
+	return func(opts *options) {
+		opts.name = name
+	}
+}
+
+// WithExclude excludes the files that match the glob patterns, as -exclude does.
+func WithExclude(patterns ...string) Option {
+	return func(opts *options) {
+		opts.exclude = append(opts.exclude, patterns...)
+	}
+}
+
+// WithTemplate renders filesystem.go with the custom template at path, as -template does.
+func WithTemplate(path string) Option {
+	return func(opts *options) {
+		opts.template = path
+	}
+}
+
+// WithConfig reads the configuration file at path, as -config does.
+func WithConfig(path string) Option {
+	return func(opts *options) {
+		opts.config = path
+	}
+}
+
+// WithVariant selects the variant in the configuration file embedded when no variant build tags are set, as -variant does.
+func WithVariant(name string) Option {
+	return func(opts *options) {
+		opts.variant = name
+	}
+}
+
+// WithGitRef reads the files from the git revision ref instead of the working tree, as -git-ref does.
+func WithGitRef(ref string) Option {
+	return func(opts *options) {
+		opts.gitRef = ref
+	}
+}
+
+// WithFilesFrom reads the files listed in the file list instead of walking the input directory, as -files-from does.
+// The list is newline-separated, e.g. the output of git ls-files, and "-" is stdin.
+func WithFilesFrom(list string) Option {
+	return func(opts *options) {
+		opts.filesFrom = list
+	}
+}
+
+// WithExportIgnore excludes the files with the export-ignore attribute of .gitattributes, as -export-ignore does.
+func WithExportIgnore() Option {
+	return func(opts *options) {
+		opts.exportIgnore = true
+	}
+}
+
+// WithFingerprint adds the hashes of the contents to the names of the files, as -fingerprint does.
+// The hash algorithm is that of WithHash.
+func WithFingerprint() Option {
+	return func(opts *options) {
+		opts.fingerprint = true
+	}
+}
+
+// WithHash selects the hash algorithm alg of the fingerprints and the digests except the signed manifest,
+// crc32, sha1, sha256 or blake3, as -hash does. The default is sha256.
+func WithHash(alg string) Option {
+	return func(opts *options) {
+		opts.hash = alg
+	}
+}
+
+// WithPreserveMode embeds the exact permission bits of the files, as -preserve-mode does.
+func WithPreserveMode() Option {
+	return func(opts *options) {
+		opts.preserveMode = true
+	}
+}
+
+// WithPreserveMTime embeds the modification times of the files, as -preserve-mtime does.
+func WithPreserveMTime() Option {
+	return func(opts *options) {
+		opts.preserveMTime = true
+	}
+}
+
+// WithDirsFirst lists the directories before the files in Readdir, as -dirs-first does.
+func WithDirsFirst() Option {
+	return func(opts *options) {
+		opts.dirsFirst = true
+	}
+}
+
+// WithNoNet generates the package that implements fs.FS without net/http, as -no-net does.
+func WithNoNet() Option {
+	return func(opts *options) {
+		opts.noNet = true
+	}
+}
+
+// WithCompression adds the pre-compressed variants of the compressible files by the algorithm c
+// and serves them to the clients that accept them, as -compression does.
+func WithCompression(c Compression) Option {
+	return func(opts *options) {
+		opts.compression = c
+	}
+}
+
+// WithStandalone generates the copy of the runtime into the package instead of importing assetsfs, as -standalone does.
+func WithStandalone() Option {
+	return func(opts *options) {
+		opts.standalone = true
+	}
+}
+
+// WithBackend stores the contents by the backend b, as -backend does.
+// The backends other than the built-in ones are not recorded in the go:generate directive.
+func WithBackend(b Backend) Option {
+	return func(opts *options) {
+		opts.backend = b
+	}
+}
+
+// WithCheckCompile checks the generated package with the go command tool, "build" or "vet", before writing it, as -check-compile does.
+// Build returns *ValidationError if the package fails the check.
+func WithCheckCompile(tool string) Option {
+	return func(opts *options) {
+		opts.checkCompile = tool
+	}
+}
+
+// WithOwnModule writes go.mod of the module path for the generated package, as -own-module does.
+func WithOwnModule(path string) Option {
+	return func(opts *options) {
+		opts.ownModule = path
+	}
+}
+
+// WithAdapters generates the adapters for the libraries, e.g. "chi" and "echo", as -adapters does.
+func WithAdapters(names ...string) Option {
+	return func(opts *options) {
+		opts.adapters = append(opts.adapters, names...)
+	}
+}
+
+// File is a file passed to the hooks of Builder.
+type File struct {
+	// Name is the slash-separated absolute path in the generated file system, e.g. "/index.html".
+	// The transforms can rename the file by changing it.
+	Name string
+
+	// Mode is the mode of the source file.
+	Mode fs.FileMode
+
+	// ModTime is the modification time of the source file, or zero if it is unknown.
+	// It is embedded only with WithPreserveMTime.
+	ModTime time.Time
+
+	// Content is the content of the file.
+	// The transforms can replace it, but must not modify the original slice.
+	Content []byte
+}
+
+// hook is the callbacks applied to each file, one of them is set.
+type hook struct {
+	filter    func(f *File) bool
+	transform func(f *File) error
+	onFile    func(f *File)
+}
+
+// WithFilter embeds only the files for which filter returns true.
+// The hooks are called in the order of the options, after the built-in transforms of the contents,
+// e.g. -normalize-eol and the markdown rule, and before fingerprinting.
+func WithFilter(filter func(f *File) bool) Option {
+	return func(opts *options) {
+		opts.hooks = append(opts.hooks, hook{filter: filter})
+	}
+}
+
+// WithTransform rewrites the name or the content of each file by transform.
+// An error of transform stops the generation.
+func WithTransform(transform func(f *File) error) Option {
+	return func(opts *options) {
+		opts.hooks = append(opts.hooks, hook{transform: transform})
+	}
+}
+
+// WithOnFile calls onFile for each file passed to it, e.g. to report the files.
+func WithOnFile(onFile func(f *File)) Option {
+	return func(opts *options) {
+		opts.hooks = append(opts.hooks, hook{onFile: onFile})
+	}
+}
+
+// applyHooks applies the hooks to the regular files in assets.
+// The directories are kept, and the parent directories of the renamed files are added by newFileTable.
+func applyHooks(assets []*asset, hooks []hook) ([]*asset, error) {
+	if len(hooks) == 0 {
+		return assets, nil
+	}
+	ret := make([]*asset, 0, len(assets))
+	names := map[string]bool{}
+assets:
+	for _, a := range assets {
+		if a.mode.IsDir() {
+			ret = append(ret, a)
+			continue
+		}
+		f := &File{
+			Name:    a.name,
+			Mode:    a.mode,
+			ModTime: a.modTime,
+			Content: a.content,
+		}
+		for _, h := range hooks {
+			switch {
+			case h.filter != nil:
+				if !h.filter(f) {
+					continue assets
+				}
+			case h.transform != nil:
+				if err := h.transform(f); err != nil {
+					return nil, fmt.Errorf("%s: %w", a.name, err)
+				}
+			case h.onFile != nil:
+				h.onFile(f)
+			}
+		}
+		if f.Name == "/" || path.Clean(f.Name) != f.Name || !strings.HasPrefix(f.Name, "/") {
+			return nil, validationErrorf("%s: invalid name %q, it must be a clean absolute path", a.name, f.Name)
+		}
+		if names[f.Name] {
+			return nil, validationErrorf("%s: %s is duplicated", a.name, f.Name)
+		}
+		names[f.Name] = true
+
+		// the assets are shared by the shards of the platforms, so the changed files are copied.
+		if f.Name == a.name && f.Mode == a.mode && f.ModTime.Equal(a.modTime) && bytes.Equal(f.Content, a.content) {
+			ret = append(ret, a)
+			continue
+		}
+		ret = append(ret, &asset{
+			name:    f.Name,
+			mode:    f.Mode,
+			modTime: f.ModTime,
+			content: f.Content,
+		})
+	}
+	return ret, nil
+}
+
+// Backend is the storage strategy of the contents of the files in the generated code,
+// selected by -backend or WithBackend.
+// The built-in backends are "string", "base64", "zip" and "embed",
+// and the other strategies are added by implementing it.
+type Backend interface {
+	// Name is the name of the backend, e.g. "zip".
+	Name() string
+
+	// Store stores the contents of the files of a shard.
+	// filename is the name of the generated file of the shard, e.g. "filesystem-staging.go",
+	// and contents is the contents of the files in the order of the table, empty for the directories.
+	Store(filename string, contents []string) (*Storage, error)
+}
+
+// Storage is the contents of a shard stored by Backend.
+type Storage struct {
+	// Exprs is the Go expressions of type string that evaluate to the contents, in the order of the contents.
+	Exprs []string
+
+	// Imports is the import specs that Decls needs, e.g. `_ "embed"`.
+	// They are added to the imports of the generated file, so name them not to conflict with the imports of the templates.
+	Imports []string
+
+	// Decls is the Go declarations that Exprs refer to.
+	Decls string
+
+	// Lazy reports whether Exprs decode the contents, e.g. from base64,
+	// so the generated package evaluates them on first access of the files instead of on its initialization.
+	Lazy bool
+
+	// Files maps the slash-separated names of the data files to their contents, e.g. the files embedded by go:embed.
+	// They are written into the directory dataDir of the output directory.
+	Files map[string][]byte
+}
+
+// dataDir is the directory of the data files of the backends in the output directory.
+// It is owned by the generator, and removed on each generation.
+const dataDir = "assets-life-data"
+
+// backends is the built-in backends by name.
+var backends = map[string]Backend{
+	"string": stringBackend{},
+	"base64": base64Backend{},
+	"zip":    zipBackend{},
+	"embed":  embedBackend{},
+}
+
+// stringBackend writes the contents as the string literals, and the shared contents once as the constants.
+// It is the default backend.
+type stringBackend struct{}
+
+func (stringBackend) Name() string {
+	return "string"
+}
+
+func (stringBackend) Store(filename string, contents []string) (*Storage, error) {
+	names, shared := sharedContents(contents)
+	s := &Storage{
+		Exprs: make([]string, len(contents)),
+	}
+	for i, content := range contents {
+		if names[i] != "" {
+			s.Exprs[i] = names[i]
+		} else {
+			s.Exprs[i] = fmt.Sprintf("%q", content)
+		}
+	}
+	if len(shared) > 0 {
+		var buf strings.Builder
+		buf.WriteString("// the contents of the files that have the same content, embedded once.\nconst (\n")
+		for _, c := range shared {
+			fmt.Fprintf(&buf, "\t%s = %q\n", c.Name, c.Content)
+		}
+		buf.WriteString(")")
+		s.Decls = buf.String()
+	}
+	return s, nil
+}
+
+// base64Backend writes the contents in base64, which are decoded on first access of the files.
+// The generated code has only the printable ASCII, e.g. for the tools that choke on the long escaped literals.
+type base64Backend struct{}
+
+func (base64Backend) Name() string {
+	return "base64"
+}
+
+func (base64Backend) Store(filename string, contents []string) (*Storage, error) {
+	s := &Storage{
+		Exprs:   make([]string, len(contents)),
+		Lazy:    true,
+		Imports: []string{`storagebase64 "encoding/base64"`},
+		Decls: `// storageBase64 decodes the content written by the base64 backend.
+func storageBase64(s string) string {
+	b, err := storagebase64.StdEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}`,
+	}
+	for i, content := range contents {
+		if content == "" {
+			s.Exprs[i] = `""`
+			continue
+		}
+		s.Exprs[i] = fmt.Sprintf("storageBase64(%q)", base64.StdEncoding.EncodeToString([]byte(content)))
+	}
+	return s, nil
+}
+
+// zipBackend writes the contents into a zip file compressed by deflate, which is embedded by go:embed
+// and decompressed on first access of the files.
+// The binary is smaller for the compressible contents, but the contents accessed are on the heap at run time.
+type zipBackend struct{}
+
+func (zipBackend) Name() string {
+	return "zip"
+}
+
+func (zipBackend) Store(filename string, contents []string) (*Storage, error) {
+	name := strings.TrimSuffix(filename, ".go") + ".zip"
+	s := &Storage{
+		Exprs:   make([]string, len(contents)),
+		Lazy:    true,
+		Imports: []string{`_ "embed"`, `storagezip "archive/zip"`, `storageio "io"`, `storagestrings "strings"`, `storagesync "sync"`},
+		Decls: `//go:embed ` + dataDir + `/` + name + `
+var storageZipData string
+
+// storageZipReader reads the contents written by the zip backend, which is opened on first access.
+var (
+	storageZipOnce   storagesync.Once
+	storageZipReader *storagezip.Reader
+)
+
+// storageZip decompresses the content of name written by the zip backend.
+func storageZip(name string) string {
+	storageZipOnce.Do(func() {
+		r, err := storagezip.NewReader(storagestrings.NewReader(storageZipData), int64(len(storageZipData)))
+		if err != nil {
+			panic(err)
+		}
+		storageZipReader = r
+	})
+	f, err := storageZipReader.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	b, err := storageio.ReadAll(f)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}`,
+	}
+
+	// the same contents are written once, and named by their indexes in the zip file.
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	entries := map[string]string{}
+	for i, content := range contents {
+		if content == "" {
+			s.Exprs[i] = `""`
+			continue
+		}
+		entry, ok := entries[content]
+		if !ok {
+			entry = strconv.Itoa(len(entries))
+			entries[content] = entry
+			w, err := zw.CreateHeader(&zip.FileHeader{
+				Name:   entry,
+				Method: zip.Deflate,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.WriteString(w, content); err != nil {
+				return nil, err
+			}
+		}
+		s.Exprs[i] = fmt.Sprintf("storageZip(%q)", entry)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	s.Files = map[string][]byte{name: buf.Bytes()}
+	return s, nil
+}
+
+// embedBackend writes each content into a data file named by its SHA-256 hash, which is embedded by go:embed.
+// The contents are not in the generated code, so it stays small and readable.
+type embedBackend struct{}
+
+func (embedBackend) Name() string {
+	return "embed"
+}
+
+func (embedBackend) Store(filename string, contents []string) (*Storage, error) {
+	s := &Storage{
+		Exprs:   make([]string, len(contents)),
+		Imports: []string{`_ "embed"`},
+		Files:   map[string][]byte{},
+	}
+	var decls strings.Builder
+	vars := map[string]string{}
+	for i, content := range contents {
+		if content == "" {
+			s.Exprs[i] = `""`
+			continue
+		}
+		v, ok := vars[content]
+		if !ok {
+			sum := sha256.Sum256([]byte(content))
+			name := hex.EncodeToString(sum[:])
+			v = fmt.Sprintf("storage%d", len(vars))
+			vars[content] = v
+			s.Files[name] = []byte(content)
+			if decls.Len() > 0 {
+				decls.WriteString("\n\n")
+			}
+			fmt.Fprintf(&decls, "//go:embed %s/%s\nvar %s string", dataDir, name, v)
+		}
+		s.Exprs[i] = v
+	}
+	s.Decls = decls.String()
+	return s, nil
+}
+
+// Source is the input of the files, e.g. a directory or an archive, passed by WithSource.
+// The built-in sources are DirSource, ArchiveSource, GitSource, ListSource and MapSource,
+// and the tests and the tools feed the files programmatically by implementing it.
+type Source interface {
+	// Files returns the files with the clean absolute names, e.g. "/css/app.css".
+	// The parent directories are added if they are missing, so only the empty directories need to be returned.
+	Files() ([]*File, error)
+}
+
+// WithSource reads the files from src instead of the input of New, which can be empty.
+// The variants without their own inputs read src too.
+// The go:generate directive records the input of New, so the package generated from src can't be regenerated by go generate.
+func WithSource(src Source) Option {
+	return func(opts *options) {
+		opts.source = src
+	}
+}
+
+// InputSource returns the Source of the input directory or archive in as Build reads it with the options,
+// e.g. WithFilesFrom, WithGitRef and WithExportIgnore, so the tools read the same files as the generator.
+// The files are not transformed; BuildFS builds them.
+func InputSource(in string, opts ...Option) Source {
+	o := &options{in: in, log: NewLogger(os.Stderr)}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &inputSource{opts: o}
+}
+
+// inputSource reads the input of the options.
+type inputSource struct {
+	opts *options
+}
+
+func (s *inputSource) Files() ([]*File, error) {
+	assets, err := s.opts.readAssets(s.opts.in)
+	if err != nil {
+		return nil, err
+	}
+	return filesOf(assets), nil
+}
+
+// DirSource returns the Source of the files in the directory dir, excluding the hidden files.
+func DirSource(dir string) Source {
+	return &dirSource{dir: dir}
+}
+
+// dirSource walks the directory.
+type dirSource struct {
+	dir            string
+	skipUnreadable bool
+	limits         limits
+	log            *Logger
+}
+
+func (s *dirSource) Files() ([]*File, error) {
+	assets, err := walkDir(s.log, s.dir, s.skipUnreadable, s.limits)
+	if err != nil {
+		return nil, err
+	}
+	return filesOf(assets), nil
+}
+
+// ArchiveSource returns the Source of the files in the zip or tar archive, e.g. "dist.tar.gz".
+func ArchiveSource(filename string) Source {
+	return &archiveSource{filename: filename}
+}
+
+// archiveSource reads the archive.
+type archiveSource struct {
+	filename string
+	log      *Logger
+}
+
+func (s *archiveSource) Files() ([]*File, error) {
+	assets, err := readArchive(s.log, s.filename)
+	if err != nil {
+		return nil, err
+	}
+	return filesOf(assets), nil
+}
+
+// GitSource returns the Source of the files in the directory dir of a git repository at the revision ref.
+func GitSource(dir, ref string) Source {
+	return &gitSource{dir: dir, ref: ref}
+}
+
+// gitSource reads the git tree.
+type gitSource struct {
+	dir string
+	ref string
+}
+
+func (s *gitSource) Files() ([]*File, error) {
+	assets, err := readGitTree(s.dir, s.ref)
+	if err != nil {
+		return nil, err
+	}
+	return filesOf(assets), nil
+}
+
+// ListSource returns the Source of the files listed in the file list, relative to the directory dir.
+// The list is newline-separated, e.g. the output of git ls-files, and "-" is stdin.
+func ListSource(list, dir string) Source {
+	return &listSource{list: list, dir: dir}
+}
+
+// listSource reads the listed files.
+type listSource struct {
+	list           string
+	dir            string
+	skipUnreadable bool
+	log            *Logger
+}
+
+func (s *listSource) Files() ([]*File, error) {
+	r := io.Reader(os.Stdin)
+	if s.list != "-" {
+		f, err := os.Open(s.list)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	assets, err := readFileList(s.log, r, s.dir, s.skipUnreadable)
+	if err != nil {
+		return nil, err
+	}
+	return filesOf(assets), nil
+}
+
+// MapSource returns the Source of the files in the map from their names to their contents, e.g. {"/index.html": "..."}.
+// The leading slashes of the names are optional, and the files have the mode 0644.
+func MapSource(files map[string]string) Source {
+	return mapSource(files)
+}
+
+// mapSource is the in-memory files.
+type mapSource map[string]string
+
+func (s mapSource) Files() ([]*File, error) {
+	files := make([]*File, 0, len(s))
+	for name, content := range s {
+		if !strings.HasPrefix(name, "/") {
+			name = "/" + name
+		}
+		files = append(files, &File{
+			Name:    name,
+			Mode:    0644,
+			Content: []byte(content),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// PackageSource returns the Source of the regular files embedded in the generated package in the directory dir,
+// read from the table of the files in its filesystem.go, or in the bundle of the bundle subcommand of the command.
+// The files of the generated packages have the mode 0644, because only the contents are in the table.
+func PackageSource(dir string) Source {
+	return packageSource(dir)
+}
+
+// packageSource reads the generated package or the bundle.
+type packageSource string
+
+func (s packageSource) Files() ([]*File, error) {
+	var assets []*asset
+	if isArchive(string(s)) {
+		all, err := readArchive(nil, string(s))
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range all {
+			if !a.mode.IsDir() {
+				assets = append(assets, a)
+			}
+		}
+	} else {
+		files, err := readGeneratedFiles(string(s))
+		if err != nil {
+			return nil, err
+		}
+		for name, content := range files {
+			assets = append(assets, &asset{name: name, mode: 0644, content: []byte(content)})
+		}
+	}
+	// the bundles may be written by the other versions or tools, so the names are checked before they are used as paths.
+	if err := checkNames(assets); err != nil {
+		return nil, err
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].name < assets[j].name })
+	return filesOf(assets), nil
+}
+
+// readGeneratedFiles reads the table of the files in filesystem.go of the generated package in dir,
+// and returns the contents of the regular files by their names.
+// It supports the packages generated by the built-in templates, including those of the older generators.
+func readGeneratedFiles(dir string) (map[string]string, error) {
+	filename := filepath.Join(dir, "filesystem.go")
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect the shared contents and the table.
+	consts := map[string]string{}
+	var table *ast.CompositeLit
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs, ok := spec.(*ast.ValueSpec)
+			if !ok || len(vs.Names) != 1 || len(vs.Values) != 1 {
+				continue
+			}
+			switch v := vs.Values[0].(type) {
+			case *ast.BasicLit:
+				if gen.Tok == token.CONST && v.Kind == token.STRING {
+					if s, err := strconv.Unquote(v.Value); err == nil {
+						consts[vs.Names[0].Name] = s
+					}
+				}
+			case *ast.CompositeLit:
+				if gen.Tok == token.VAR && vs.Names[0].Name == "files" {
+					table = v
+				}
+			}
+		}
+	}
+	if table == nil {
+		return nil, fmt.Errorf("%s: the table of the files is not found", filename)
+	}
+
+	files := map[string]string{}
+	for _, elt := range table.Elts {
+		entry, ok := elt.(*ast.CompositeLit)
+		if !ok {
+			return nil, fmt.Errorf("%s: unexpected entry of the table", fset.Position(elt.Pos()))
+		}
+		var name, content string
+		var isDir bool
+		for _, field := range entry.Elts {
+			kv, ok := field.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			key, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			switch key.Name {
+			case "name", "Path":
+				if lit, ok := kv.Value.(*ast.BasicLit); ok {
+					name, _ = strconv.Unquote(lit.Value)
+				}
+			case "content", "Content":
+				switch v := kv.Value.(type) {
+				case *ast.BasicLit:
+					content, _ = strconv.Unquote(v.Value)
+				case *ast.Ident:
+					c, ok := consts[v.Name]
+					if !ok {
+						return nil, fmt.Errorf("%s: %s is not a constant, only the contents of -backend string are supported", fset.Position(v.Pos()), v.Name)
+					}
+					content = c
+				default:
+					return nil, fmt.Errorf("%s: the content is not a literal, only the contents of -backend string are supported", fset.Position(v.Pos()))
+				}
+			case "mode", "FileMode":
+				ast.Inspect(kv.Value, func(n ast.Node) bool {
+					if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "ModeDir" {
+						isDir = true
+					}
+					return true
+				})
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("%s: the entry has no name", fset.Position(entry.Pos()))
+		}
+		if !isDir {
+			files[name] = content
+		}
+	}
+	return files, nil
+}
+
+// filesOf converts the assets to the files of Source.
+func filesOf(assets []*asset) []*File {
+	files := make([]*File, len(assets))
+	for i, a := range assets {
+		files[i] = &File{
+			Name:    a.name,
+			Mode:    a.mode,
+			ModTime: a.modTime,
+			Content: a.content,
+		}
+	}
+	return files
+}
+
+// assetsOf converts the files of Source to the assets.
+// It returns *ValidationError if a name is not a clean absolute path.
+func assetsOf(files []*File) ([]*asset, error) {
+	assets := make([]*asset, len(files))
+	for i, f := range files {
+		if path.Clean(f.Name) != f.Name || !strings.HasPrefix(f.Name, "/") || (f.Name == "/" && !f.Mode.IsDir()) {
+			return nil, validationErrorf("invalid name %q, it must be a clean absolute path", f.Name)
+		}
+		assets[i] = &asset{
+			name:    f.Name,
+			mode:    f.Mode,
+			modTime: f.ModTime,
+			content: f.Content,
+		}
+	}
+	return assets, nil
+}
+
+// validate checks the options, makes the paths absolute and fills the defaults.
+// It returns *UsageError if the options are invalid.
+func (opts *options) validate() error {
+	if (opts.in == "" && opts.source == nil) || opts.out == "" {
+		return usageErrorf("the input and the output are required")
+	}
+	if opts.filesFrom != "" && opts.gitRef != "" {
+		return usageErrorf("-files-from and -git-ref cannot be used together")
+	}
+	if opts.source != nil && (opts.filesFrom != "" || opts.gitRef != "" || opts.exportIgnore) {
+		return usageErrorf("the source cannot be used with -files-from, -git-ref or -export-ignore")
+	}
+	var err error
+	if opts.in != "" {
+		opts.in, err = filepath.Abs(opts.in)
+		if err != nil {
+			return err
+		}
+	}
+	opts.out, err = filepath.Abs(opts.out)
+	if err != nil {
+		return err
+	}
+	if opts.name == "" {
+		opts.name = filepath.Base(opts.out)
+	}
+	if opts.hash == "" {
+		opts.hash = "sha256"
+	}
+	if opts.gzipSources == "" {
+		opts.gzipSources = "keep"
+	}
+	if opts.compression == "" {
+		opts.compression = NoCompression
+	}
+	if opts.sourceMaps == "" {
+		opts.sourceMaps = "keep"
+	}
+	if opts.backend == nil {
+		opts.backend = stringBackend{}
+		if opts.maxMemory > 0 {
+			opts.backend = embedBackend{}
+		}
+	}
+	if opts.maxMemory < 0 {
+		return usageErrorf("-max-memory must not be negative")
+	}
+	if opts.maxMemory > 0 {
+		if opts.backend.Name() != "embed" {
+			return usageErrorf("-max-memory stores the contents only by -backend embed")
+		}
+		if opts.template != "" || opts.filesFrom != "" || opts.gitRef != "" || opts.remote != "" || opts.source != nil || len(opts.hooks) > 0 || opts.checkCompile != "" {
+			return usageErrorf("-max-memory cannot be used with -template, -files-from, -git-ref, -remote, -check-compile, the sources or the hooks")
+		}
+		if opts.fingerprint || opts.notices || opts.precache || opts.serviceWorker || opts.preload || opts.signKey != "" ||
+			opts.normalizeEOL != "" || opts.stripBOM || len(opts.charsets) > 0 || opts.stripMetadata || opts.sourceMaps != "keep" || opts.gzipSources != "keep" || opts.compression != NoCompression ||
+			opts.htmlBase != "" || len(opts.htmlMeta) > 0 || opts.htmlInject != "" {
+			return usageErrorf("-max-memory cannot be used with the options that read the contents, e.g. -fingerprint, -compression and -normalize-eol")
+		}
+	}
+	switch opts.checkCompile {
+	case "", "build", "vet":
+	default:
+		return usageErrorf("unknown go command of -check-compile: %q, use build or vet", opts.checkCompile)
+	}
+	for _, name := range opts.adapters {
+		if _, ok := adapterTemplates[name]; !ok {
+			return usageErrorf("unknown adapter: %q", name)
+		}
+	}
+	if opts.noNet && opts.template != "" {
+		return usageErrorf("-no-net and -template cannot be used together")
+	}
+	if opts.noNet && (len(opts.adapters) > 0 || opts.preload || opts.bench) {
+		// they use the handler, which requires net/http.
+		return usageErrorf("-no-net cannot be used with -adapters, -preload or -bench")
+	}
+	if opts.bench && opts.template != "" {
+		return usageErrorf("-bench and -template cannot be used together")
+	}
+	if opts.noNet && opts.signKey != "" {
+		return usageErrorf("-no-net and -sign-key cannot be used together")
+	}
+	if opts.unsafeBytes && opts.template != "" {
+		// Bytes depends on the file table of the built-in templates.
+		return usageErrorf("-unsafe-bytes and -template cannot be used together")
+	}
+	if strings.ContainsAny(opts.ownModule, " \t\r\n\"'`") {
+		return usageErrorf("invalid module path: %q", opts.ownModule)
+	}
+	if opts.template != "" {
+		opts.template, err = filepath.Abs(opts.template)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.signKey != "" {
+		opts.signKey, err = filepath.Abs(opts.signKey)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.htmlInject != "" {
+		opts.htmlInject, err = filepath.Abs(opts.htmlInject)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.filesFrom != "" && opts.filesFrom != "-" {
+		opts.filesFrom, err = filepath.Abs(opts.filesFrom)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.remote != "" {
+		opts.remote, err = filepath.Abs(opts.remote)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.cacheDir != "" {
+		opts.cacheDir, err = filepath.Abs(opts.cacheDir)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.config != "" {
+		opts.config, err = filepath.Abs(opts.config)
+		if err != nil {
+			return err
+		}
+	}
+	if err := checkHash(opts.hash); err != nil {
+		return &UsageError{err: err}
+	}
+	switch opts.gzipSources {
+	case "keep", "encoded", "decompress":
+	default:
+		return usageErrorf("unknown gzip source policy: %q, use keep, encoded or decompress", opts.gzipSources)
+	}
+	switch opts.compression {
+	case NoCompression, Gzip:
+	default:
+		return usageErrorf("unknown compression: %q, use none or gzip", opts.compression)
+	}
+	switch opts.sourceMaps {
+	case "keep", "strip", "debug":
+	default:
+		return usageErrorf("unknown source map policy: %q, use keep, strip or debug", opts.sourceMaps)
+	}
+	if opts.normalizeEOL != "" && opts.normalizeEOL != "lf" && opts.normalizeEOL != "crlf" {
+		return usageErrorf("unknown line ending: %q, use lf or crlf", opts.normalizeEOL)
+	}
+	if len(opts.vars) > 0 && opts.config == "" {
+		return usageErrorf("-var requires -config")
+	}
+	if opts.variant != "" && opts.config == "" {
+		return usageErrorf("-variant requires -config")
+	}
+	if opts.maxFiles < 0 || opts.maxDepth < 0 {
+		return usageErrorf("-max-files and -max-depth must not be negative")
+	}
+	return nil
+}
+
+// listFlag is a comma-separated list flag.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// backendFlag is the flag of the name of the built-in backend.
+type backendFlag struct {
+	b *Backend
+}
+
+func (f backendFlag) String() string {
+	if f.b == nil || *f.b == nil {
+		return ""
+	}
+	return (*f.b).Name()
+}
+
+func (f backendFlag) Set(s string) error {
+	b, ok := backends[s]
+	if !ok {
+		return fmt.Errorf("unknown backend %q, use string, base64, zip or embed", s)
+	}
+	*f.b = b
+	return nil
+}
+
+// varFlag is a KEY=VALUE flag that can be given multiple times.
+type varFlag map[string]string
+
+func (v *varFlag) String() string {
+	var pairs []string
+	for k, value := range *v {
+		pairs = append(pairs, k+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v *varFlag) Set(s string) error {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return fmt.Errorf("%q is not in the form of KEY=VALUE", s)
+	}
+	if *v == nil {
+		*v = map[string]string{}
+	}
+	(*v)[s[:i]] = s[i+1:]
+	return nil
+}
+
+// assetsfsPath is the import path of the runtime that the packages import unless they are standalone.
+const assetsfsPath = "github.com/shogo82148/assets-life/assetsfs"
+
+// standaloneRuntime reports whether the generated package has its own runtime instead of importing assetsfsPath.
+// The packages of -no-net have the runtime without net/http, and go.mod of -own-module cannot require assetsfs,
+// because the version of the runtime that the generator matches is unknown, so they are standalone too.
+func (opts *options) standaloneRuntime() bool {
+	return opts.standalone || opts.noNet || opts.ownModule != ""
+}
+
+// directive returns the go:generate directive that re-generates the package with the same options.
+// The paths in the directive are relative to the output directory.
+func (opts *options) directive(generator string) (string, error) {
+	args := []string{"go:generate", "go", "run", generator}
+	rel := func(path string) (string, error) {
+		rel, err := filepath.Rel(opts.out, path)
+		if err != nil {
+			return "", err
+		}
+		return `"` + rel + `"`, nil
+	}
+	if opts.template != "" {
+		tmpl, err := rel(opts.template)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "-template", tmpl)
+	}
+	switch opts.filesFrom {
+	case "":
+	case "-":
+		args = append(args, "-files-from", "-")
+	default:
+		list, err := rel(opts.filesFrom)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "-files-from", list)
+	}
+	if opts.gitRef != "" {
+		args = append(args, "-git-ref", opts.gitRef)
+	}
+	if opts.exportIgnore {
+		args = append(args, "-export-ignore")
+	}
+	if opts.remote != "" {
+		remote, err := rel(opts.remote)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "-remote", remote)
+	}
+	if opts.cacheDir != "" {
+		cacheDir, err := rel(opts.cacheDir)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "-cache-dir", cacheDir)
+	}
+	if opts.preserveMode {
+		args = append(args, "-preserve-mode")
+	}
+	if opts.preserveMTime {
+		args = append(args, "-preserve-mtime")
+	}
+	if opts.skipUnreadable {
+		args = append(args, "-skip-unreadable")
+	}
+	if opts.strict {
+		args = append(args, "-strict")
+	}
+	if opts.checkCompile != "" {
+		args = append(args, "-check-compile", opts.checkCompile)
+	}
+	if len(opts.exclude) > 0 {
+		args = append(args, "-exclude", strings.Join(opts.exclude, ","))
+	}
+	if len(opts.allowArtifacts) > 0 {
+		args = append(args, "-allow-artifacts", strings.Join(opts.allowArtifacts, ","))
+	}
+	if opts.maxFiles > 0 {
+		args = append(args, "-max-files", strconv.Itoa(opts.maxFiles))
+	}
+	if opts.maxDepth > 0 {
+		args = append(args, "-max-depth", strconv.Itoa(opts.maxDepth))
+	}
+	if opts.maxMemory > 0 {
+		args = append(args, "-max-memory", strconv.Itoa(opts.maxMemory))
+	}
+	if opts.config != "" {
+		config, err := rel(opts.config)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "-config", config)
+	}
+	if opts.variant != "" {
+		args = append(args, "-variant", opts.variant)
+	}
+	if opts.ownModule != "" {
+		args = append(args, "-own-module", opts.ownModule)
+	}
+	if len(opts.adapters) > 0 {
+		args = append(args, "-adapters", strings.Join(opts.adapters, ","))
+	}
+	if opts.noNet {
+		args = append(args, "-no-net")
+	}
+	if opts.bench {
+		args = append(args, "-bench")
+	}
+	if opts.standalone {
+		args = append(args, "-standalone")
+	}
+	if opts.backend != nil && opts.backend.Name() != "string" && backends[opts.backend.Name()] != nil {
+		args = append(args, "-backend", opts.backend.Name())
+	}
+	if opts.unsafeBytes {
+		args = append(args, "-unsafe-bytes")
+	}
+	if opts.dirsFirst {
+		args = append(args, "-dirs-first")
+	}
+	if opts.stripMetadata {
+		args = append(args, "-strip-metadata")
+	}
+	if opts.normalizeEOL != "" {
+		args = append(args, "-normalize-eol", opts.normalizeEOL)
+	}
+	if opts.stripBOM {
+		args = append(args, "-strip-bom")
+	}
+	if opts.hash != "" && opts.hash != "sha256" {
+		args = append(args, "-hash", opts.hash)
+	}
+	if opts.signKey != "" {
+		key, err := rel(opts.signKey)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "-sign-key", key)
+	}
+	if opts.gzipSources != "" && opts.gzipSources != "keep" {
+		args = append(args, "-gzip-sources", opts.gzipSources)
+	}
+	if opts.compression != "" && opts.compression != NoCompression {
+		args = append(args, "-compression", string(opts.compression))
+	}
+	if opts.sourceMaps != "" && opts.sourceMaps != "keep" {
+		args = append(args, "-source-maps", opts.sourceMaps)
+	}
+	if opts.notices {
+		args = append(args, "-notices")
+	}
+	if len(opts.charsets) > 0 {
+		args = append(args, "-convert-charset", strings.Join(opts.charsets, ","))
+	}
+	keys := make([]string, 0, len(opts.vars))
+	for k := range opts.vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "-var", strconv.Quote(k+"="+opts.vars[k]))
+	}
+	if opts.htmlBase != "" {
+		args = append(args, "-html-base", strconv.Quote(opts.htmlBase))
+	}
+	names := make([]string, 0, len(opts.htmlMeta))
+	for name := range opts.htmlMeta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-html-meta", strconv.Quote(name+"="+opts.htmlMeta[name]))
+	}
+	if opts.htmlInject != "" {
+		inject, err := rel(opts.htmlInject)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "-html-inject", inject)
+	}
+	if opts.preload {
+		args = append(args, "-preload")
+	}
+	if opts.fingerprint {
+		args = append(args, "-fingerprint")
+	}
+	if opts.serviceWorker {
+		args = append(args, "-service-worker")
+	} else if opts.precache {
+		args = append(args, "-precache")
+	}
+	// the input is empty if the files are read from the source of WithSource.
+	in := `""`
+	if opts.in != "" {
+		var err error
+		in, err = rel(opts.in)
+		if err != nil {
+			return "", err
+		}
+	}
+	args = append(args, in, ".", opts.name)
+	return strings.Join(args, " "), nil
+}
+
+// templateData is the data passed to the template of filesystem.go.
+type templateData struct {
+	// Generator is the file name of the generator.
+	Generator string
+
+	// Directive is the go:generate directive without the leading "//".
+	Directive string
+
+	// Package is the name of the generated package.
+	Package string
+
+	// ImportPath is the import path of the generated package, or empty if it is not in a module.
+	ImportPath string
+
+	// Variant is the name of the variant, or empty for the input directory.
+	Variant string
+
+	// BuildConstraint is the build constraint lines of the file, or empty if the file has no constraints.
+	BuildConstraint string
+
+	// Files is the table of the files, sorted by Name.
+	Files []templateFile
+
+	// Fingerprints maps the names of the fingerprinted files to their names with the hashes.
+	// It is empty unless the -fingerprint option is set.
+	Fingerprints map[string]string
+
+	// Contents is the contents shared by the files in Files, sorted by Name.
+	Contents []templateContent
+
+	// Charsets maps the names of the files converted to UTF-8 to their original charsets.
+	// It is empty unless the -convert-charset option is set.
+	Charsets map[string]string
+
+	// Licenses is the licenses of the third-party files, sorted by Name.
+	// It is empty unless the -notices option is set.
+	Licenses []templateLicense
+
+	// DirsFirst reports whether the directories are linked before the files in each directory.
+	DirsFirst bool
+
+	// GzipEncoded reports whether the handler serves the pre-compressed gzip variants of the files.
+	GzipEncoded bool
+
+	// HashAlgorithm is the hash algorithm of Hashes and HashNames, selected by the -hash option.
+	HashAlgorithm string
+
+	// Hashes maps the names of the files to the hashes of their contents in hex.
+	Hashes map[string]string
+
+	// HashNames maps the hashes of the contents to the names of the files that have them.
+	HashNames map[string]string
+
+	// Signature is the Ed25519 signature of the manifest of Files in base64.
+	// It is empty unless the -sign-key option is set.
+	Signature string
+
+	// Standalone reports whether the runtime is copied into the package, instead of imported from the assetsfs package.
+	Standalone bool
+
+	// Imports is the import specs that Decls needs, e.g. `_ "embed"`.
+	Imports []string
+
+	// Decls is the declarations of the backend that the Expr of Files refer to.
+	Decls string
+}
+
+// templateContent is the content shared by the files with the same content.
+type templateContent struct {
+	// Name is the name of the constant of the content, e.g. "content0".
+	Name string
+
+	// Content is the content.
+	Content string
+}
+
+// InternalRoot returns the import path of the tree that can import the generated package,
+// or empty if the package is not internal.
+func (d *templateData) InternalRoot() string {
+	elems := strings.Split(d.ImportPath, "/")
+	for i := len(elems) - 1; i >= 0; i-- {
+		if elems[i] == "internal" {
+			return strings.Join(elems[:i], "/")
+		}
+	}
+	return ""
+}
+
+// ImportSpec returns the import declaration of the generated package,
+// with the package name if it differs from the last element of the import path.
+func (d *templateData) ImportSpec() string {
+	spec := strconv.Quote(d.ImportPath)
+	if path.Base(d.ImportPath) != d.Package {
+		spec = d.Package + " " + spec
+	}
+	return spec
+}
+
+// templateFile is an entry of the file table.
+type templateFile struct {
+	// Name is the slash-separated absolute path of the file, e.g. "/index.html".
+	Name string
+
+	// Content is the content of the file. It is empty for directories.
+	Content string
+
+	// Mode is the mode of the file.
+	// It is normalized to 0755 | os.ModeDir, 0755 or 0644 unless the -preserve-mode option is set.
+	Mode os.FileMode
+
+	// ModTime is the modification time of the file in Unix nanoseconds.
+	// It is 0 unless the -preserve-mtime option is set.
+	ModTime int64
+
+	// Next is the index of the next sibling, or -1 if the file is the last child.
+	Next int
+
+	// Child is the index of the first child, or -1 if the file has no children.
+	Child int
+
+	// Preload is the values of the Link headers that preload the critical resources of the HTML file.
+	// It is empty unless the -preload option is set.
+	Preload []string
+
+	// Alias is the name of the constant in Contents if the content is shared with other files, or empty.
+	Alias string
+
+	// Expr is the Go expression of the content written by the backend, e.g. a string literal.
+	Expr string
+
+	// Lazy reports whether Expr is evaluated on first access of the file instead of on the initialization of the package,
+	// because it decodes the content, e.g. of the base64 backend.
+	Lazy bool
+
+	// path is the path of the file whose content is not read yet, in the memory-bounded mode of -max-memory.
+	path string
+
+	// hash is the hash of the content by the -hash option in hex, and sum is its SHA-256 hash in hex,
+	// if they are computed on writing the content.
+	hash string
+	sum  string
+}
+
+// GoMode returns the Go expression of the mode, e.g. "0755 | os.ModeDir".
+func (f templateFile) GoMode() string {
+	expr := fmt.Sprintf("%#o", f.Mode.Perm())
+	for _, m := range []struct {
+		mode os.FileMode
+		name string
+	}{
+		{os.ModeDir, "os.ModeDir"},
+		{os.ModeSetuid, "os.ModeSetuid"},
+		{os.ModeSetgid, "os.ModeSetgid"},
+		{os.ModeSticky, "os.ModeSticky"},
+	} {
+		if f.Mode&m.mode != 0 {
+			expr += " | " + m.name
+		}
+	}
+	return expr
+}
+
+// serviceWorkerTemplate is the template of the service worker sw.js, which precaches the files in the manifest.
+const serviceWorkerTemplate = `// Code generated by assets-life. DO NOT EDIT.
+"use strict";
+
+const CACHE_PREFIX = "assets-life-";
+const CACHE = CACHE_PREFIX + "{{.Version}}";
+const MANIFEST = {{.Manifest}};
+
+self.addEventListener("install", (event) => {
+  event.waitUntil(
+    caches.open(CACHE).then((cache) => cache.addAll(MANIFEST.map((entry) => entry.url)))
+  );
+  self.skipWaiting();
+});
+
+self.addEventListener("activate", (event) => {
+  event.waitUntil(
+    caches.keys().then((keys) =>
+      Promise.all(keys.filter((key) => key.startsWith(CACHE_PREFIX) && key !== CACHE).map((key) => caches.delete(key)))
+    )
+  );
+  self.clients.claim();
+});
+
+self.addEventListener("fetch", (event) => {
+  if (event.request.method !== "GET") {
+    return;
+  }
+  event.respondWith(
+    caches.open(CACHE).then((cache) =>
+      cache.match(event.request).then((response) => response || fetch(event.request))
+    )
+  );
+});
+`
+
+// adapterTemplates are the templates of the adapters for the web frameworks and the libraries, keyed by the name.
+// The adapters use the API of the built-in template.
+var adapterTemplates = map[string]string{
+	"billy": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// BillyFilesystem returns the read-only billy.Filesystem of the files in Root, e.g. for go-git.
+// The methods that modify the files return billy.ErrReadOnly.
+func BillyFilesystem() billy.Filesystem {
+	return billyFs{root: "/"}
+}
+
+type billyFs struct {
+	root string
+}
+
+// abs returns the name in Root. The name cannot be out of the root of fs.
+func (fs billyFs) abs(name string) string {
+	return path.Join(fs.root, path.Clean("/"+filepath.ToSlash(name)))
+}
+
+func (fs billyFs) Open(name string) (billy.File, error) {
+	f, err := Root.Open(fs.abs(name))
+	if err != nil {
+		return nil, err
+	}
+	return &billyFile{File: f, name: name}, nil
+}
+
+func (fs billyFs) OpenFile(name string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, billy.ErrReadOnly
+	}
+	return fs.Open(name)
+}
+
+func (fs billyFs) Stat(name string) (os.FileInfo, error) {
+	f, err := Root.Open(fs.abs(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+func (fs billyFs) Lstat(name string) (os.FileInfo, error) {
+	return fs.Stat(name)
+}
+
+func (fs billyFs) ReadDir(name string) ([]os.FileInfo, error) {
+	f, err := Root.Open(fs.abs(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return f.Readdir(-1)
+}
+
+func (fs billyFs) Readlink(name string) (string, error) {
+	return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrInvalid}
+}
+
+func (fs billyFs) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (fs billyFs) Chroot(name string) (billy.Filesystem, error) {
+	return billyFs{root: fs.abs(name)}, nil
+}
+
+func (fs billyFs) Root() string {
+	return fs.root
+}
+
+func (fs billyFs) Capabilities() billy.Capability {
+	return billy.ReadCapability | billy.SeekCapability
+}
+
+func (billyFs) Create(name string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (billyFs) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (billyFs) Rename(oldpath, newpath string) error {
+	return billy.ErrReadOnly
+}
+
+func (billyFs) Remove(name string) error {
+	return billy.ErrReadOnly
+}
+
+func (billyFs) MkdirAll(name string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (billyFs) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+type billyFile struct {
+	http.File
+	name string
+}
+
+func (f *billyFile) Name() string {
+	return f.name
+}
+
+func (f *billyFile) ReadAt(p []byte, off int64) (int, error) {
+	if r, ok := f.File.(io.ReaderAt); ok {
+		return r.ReadAt(p, off)
+	}
+	return 0, billy.ErrNotSupported
+}
+
+func (f *billyFile) Write(p []byte) (int, error) {
+	return 0, billy.ErrReadOnly
+}
+
+func (f *billyFile) Truncate(size int64) error {
+	return billy.ErrReadOnly
+}
+
+func (f *billyFile) Lock() error {
+	return nil
+}
+
+func (f *billyFile) Unlock() error {
+	return nil
+}
+`,
+	"chi": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ChiMount mounts the file server of Root at prefix of r.
+// The request to prefix without the trailing slash is redirected to prefix + "/".
+func ChiMount(r chi.Router, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	h := http.StripPrefix(prefix, http.FileServer(Root))
+	if prefix != "" {
+		r.Get(prefix, http.RedirectHandler(prefix+"/", http.StatusMovedPermanently).ServeHTTP)
+	}
+	r.Get(prefix+"/*", h.ServeHTTP)
+	r.Head(prefix+"/*", h.ServeHTTP)
+}
+`,
+
+	"echo": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// EchoHandler returns the echo handler that serves Root.
+// If it is registered with a wildcard, e.g. e.GET("/static/*", EchoHandler()),
+// the file at the wildcard path is served.
+func EchoHandler() echo.HandlerFunc {
+	h := http.FileServer(Root)
+	return func(c echo.Context) error {
+		r := c.Request()
+		for _, name := range c.ParamNames() {
+			if name == "*" {
+				r = r.Clone(r.Context())
+				r.URL.Path = "/" + c.Param("*")
+				r.URL.RawPath = ""
+				break
+			}
+		}
+		h.ServeHTTP(c.Response(), r)
+		return nil
+	}
+}
+`,
+
+	"fiber": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+)
+
+// FiberHandler returns the fiber handler that serves Root.
+// Register it with a prefix, e.g. app.Use("/static", FiberHandler()).
+func FiberHandler() fiber.Handler {
+	return filesystem.New(filesystem.Config{
+		Root: Root,
+	})
+}
+`,
+
+	"expvar": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"expvar"
+	"net/http"
+	"sync"
+)
+
+// the counters are shared by the packages generated with -adapters expvar in the binary.
+var (
+	expvarHits  = expvarInt("assets.hits")
+	expvarBytes = expvarInt("assets.bytes")
+)
+
+func init() {
+	var once sync.Once
+	var hash string
+	expvarMap("assets.source_hash").Set({{printf "%q" .Package}}, expvar.Func(func() interface{} {
+		once.Do(func() { hash = SourceHash() })
+		return hash
+	}))
+	assets.Use(Middleware(expvarCounters))
+}
+
+// expvarCounters counts the requests served by the handlers in assets.hits and the bytes of the responses in assets.bytes.
+// The hits are the responses with the status codes less than 400, e.g. 200 and 304.
+func expvarCounters(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew := &expvarWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		if ew.status < 400 {
+			expvarHits.Add(1)
+		}
+		expvarBytes.Add(ew.bytes)
+	})
+}
+
+// expvarWriter is the http.ResponseWriter that records the status code and the number of the bytes for the counters.
+type expvarWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *expvarWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		// ignore the informational responses, e.g. 103 Early Hints.
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *expvarWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// expvarInt returns the published *expvar.Int name, or publishes a new one.
+func expvarInt(name string) *expvar.Int {
+	if v, ok := expvar.Get(name).(*expvar.Int); ok {
+		return v
+	}
+	return expvar.NewInt(name)
+}
+
+// expvarMap returns the published *expvar.Map name, or publishes a new one.
+func expvarMap(name string) *expvar.Map {
+	if v, ok := expvar.Get(name).(*expvar.Map); ok {
+		return v
+	}
+	return expvar.NewMap(name)
+}
+`,
+
+	"prometheus": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusCollector is the collector of Prometheus for the metrics of the requests.
+// Pass it to the Metrics option, and register it to a registry.
+//
+//	collector := NewPrometheusCollector("public")
+//	prometheus.MustRegister(collector)
+//	http.Handle("/", Handler(Metrics(collector)))
+type PrometheusCollector struct {
+	requests *prometheus.CounterVec
+	bytes    *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+var _ MetricsRecorder = (*PrometheusCollector)(nil)
+var _ prometheus.Collector = (*PrometheusCollector)(nil)
+
+// NewPrometheusCollector returns a new collector with the namespace of the metrics.
+func NewPrometheusCollector(namespace string) *PrometheusCollector {
+	return &PrometheusCollector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "The number of the requests to the embedded files.",
+		}, []string{"path", "code"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "response_bytes_total",
+			Help:      "The number of the bytes of the embedded files served.",
+		}, []string{"path"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "The latency of the requests to the embedded files.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"code"}),
+	}
+}
+
+// RecordRequest implements MetricsRecorder.
+// The path label is the name of the embedded file, or empty if the request is not served by a file.
+func (c *PrometheusCollector) RecordRequest(name string, status int, bytes int64, latency time.Duration) {
+	code := strconv.Itoa(status)
+	c.requests.WithLabelValues(name, code).Inc()
+	c.bytes.WithLabelValues(name).Add(float64(bytes))
+	c.latency.WithLabelValues(code).Observe(latency.Seconds())
+}
+
+// Describe implements prometheus.Collector.
+func (c *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.bytes.Describe(ch)
+	c.latency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.bytes.Collect(ch)
+	c.latency.Collect(ch)
+}
+`,
+
+	"afero": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// ExtractToAfero writes the embedded files into the directory dir of fs, e.g. afero.NewMemMapFs() for the test fixtures.
+// The files are written with their modes, and the existing files are overwritten.
+func ExtractToAfero(fs afero.Fs, dir string) error {
+	for i := range files {
+		f := &files[i]
+		target := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if f.IsDir() {
+			if err := fs.MkdirAll(target, f.Mode().Perm()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := afero.WriteFile(fs, target, []byte(f.Data()), f.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AferoFs returns the read-only afero.Fs of the files in Root.
+// The methods that modify the files return os.ErrPermission.
+func AferoFs() afero.Fs {
+	return aferoFs{}
+}
+
+type aferoFs struct{}
+
+func (aferoFs) Name() string {
+	return {{printf "%q" .Package}}
+}
+
+func (aferoFs) Open(name string) (afero.File, error) {
+	f, err := Root.Open(path.Clean("/" + filepath.ToSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	return &aferoFile{File: f, name: name}, nil
+}
+
+func (fs aferoFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, aferoErr("open", name)
+	}
+	return fs.Open(name)
+}
+
+func (fs aferoFs) Stat(name string) (os.FileInfo, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+func (aferoFs) Create(name string) (afero.File, error) {
+	return nil, aferoErr("create", name)
+}
+
+func (aferoFs) Mkdir(name string, perm os.FileMode) error {
+	return aferoErr("mkdir", name)
+}
+
+func (aferoFs) MkdirAll(name string, perm os.FileMode) error {
+	return aferoErr("mkdir", name)
+}
+
+func (aferoFs) Remove(name string) error {
+	return aferoErr("remove", name)
+}
+
+func (aferoFs) RemoveAll(name string) error {
+	return aferoErr("remove", name)
+}
+
+func (aferoFs) Rename(oldname, newname string) error {
+	return aferoErr("rename", oldname)
+}
+
+func (aferoFs) Chmod(name string, mode os.FileMode) error {
+	return aferoErr("chmod", name)
+}
+
+func (aferoFs) Chown(name string, uid, gid int) error {
+	return aferoErr("chown", name)
+}
+
+func (aferoFs) Chtimes(name string, atime, mtime time.Time) error {
+	return aferoErr("chtimes", name)
+}
+
+func aferoErr(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+}
+
+type aferoFile struct {
+	http.File
+	name string
+}
+
+func (f *aferoFile) Name() string {
+	return f.name
+}
+
+func (f *aferoFile) ReadAt(p []byte, off int64) (int, error) {
+	if r, ok := f.File.(io.ReaderAt); ok {
+		return r.ReadAt(p, off)
+	}
+	return 0, aferoErr("read", f.name)
+}
+
+func (f *aferoFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names, err
+}
+
+func (f *aferoFile) Sync() error {
+	return nil
+}
+
+func (f *aferoFile) Truncate(size int64) error {
+	return aferoErr("truncate", f.name)
+}
+
+func (f *aferoFile) Write(p []byte) (int, error) {
+	return 0, aferoErr("write", f.name)
+}
+
+func (f *aferoFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, aferoErr("write", f.name)
+}
+
+func (f *aferoFile) WriteString(s string) (int, error) {
+	return 0, aferoErr("write", f.name)
+}
+`,
+	"slog": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+//go:build go1.21
+// +build go1.21
+
+package {{.Package}}
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogAccessLog writes the access logs to logger at the info level.
+func SlogAccessLog(logger *slog.Logger) Option {
+	return AccessLogFunc(func(e *AccessLogEntry) {
+		logger.LogAttrs(context.Background(), slog.LevelInfo, "access",
+			slog.String("remote_addr", e.RemoteAddr),
+			slog.String("user", e.User),
+			slog.String("method", e.Method),
+			slog.String("uri", e.RequestURI),
+			slog.String("path", e.Path),
+			slog.String("proto", e.Proto),
+			slog.Int("status", e.Status),
+			slog.Int64("bytes", e.Bytes),
+			slog.Duration("duration", e.Duration),
+			slog.String("referer", e.Referer),
+			slog.String("user_agent", e.UserAgent),
+		)
+	})
+}
+`,
+
+	"js": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+//go:build js && wasm
+// +build js,wasm
+
+package {{.Package}}
+
+import (
+	"io"
+	"mime"
+	"os"
+	"path"
+	"syscall/js"
+)
+
+// ReadJS returns the content of the file name as a new Uint8Array of JavaScript.
+func ReadJS(name string) (js.Value, error) {
+	f, err := Root.Open(name)
+	if err != nil {
+		return js.Null(), err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return js.Null(), err
+	}
+	if fi.IsDir() {
+		return js.Null(), &os.PathError{Op: "read", Path: name, Err: os.ErrInvalid}
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return js.Null(), err
+	}
+	arr := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(arr, b)
+	return arr, nil
+}
+
+// ExposeJS defines the object globalThis[name] for JavaScript, which has the methods:
+//
+//	read(name): returns the content of the file as Uint8Array, or null if it is not found.
+//	response(name): returns the Response of the Fetch API, or null if it is not found,
+//	    e.g. for event.respondWith in the fetch event handler of the service worker.
+func ExposeJS(name string) {
+	// load the MIME types now.
+	// the file system is not available in the callbacks, because they block the event loop of JavaScript.
+	mime.TypeByExtension(".html")
+
+	obj := js.Global().Get("Object").New()
+	obj.Set("read", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) < 1 {
+			return js.Null()
+		}
+		arr, err := ReadJS(args[0].String())
+		if err != nil {
+			return js.Null()
+		}
+		return arr
+	}))
+	obj.Set("response", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) < 1 {
+			return js.Null()
+		}
+		name := args[0].String()
+		arr, err := ReadJS(name)
+		if err != nil {
+			return js.Null()
+		}
+		headers := js.Global().Get("Object").New()
+		if typ := mime.TypeByExtension(path.Ext(name)); typ != "" {
+			headers.Set("Content-Type", typ)
+		}
+		init := js.Global().Get("Object").New()
+		init.Set("headers", headers)
+		return js.Global().Get("Response").New(arr, init)
+	}))
+	js.Global().Set(name, obj)
+}
+`,
+	"otel": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"net/http"
+	"path"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTelTracing starts a span of OpenTelemetry with tracer for each request.
+// The span is a child of the span in the context of the request, e.g. the span of otelhttp.
+func OTelTracing(tracer trace.Tracer) Option {
+	return Middleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := path.Clean("/" + r.URL.Path)
+			ctx, span := tracer.Start(r.Context(), "assets "+name, trace.WithSpanKind(trace.SpanKindInternal))
+			defer span.End()
+
+			ow := &otelWriter{ResponseWriter: w}
+			next.ServeHTTP(ow, r.WithContext(ctx))
+
+			status := ow.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			encoding := w.Header().Get("Content-Encoding")
+			if encoding == "" {
+				encoding = "identity"
+			}
+			span.SetAttributes(
+				attribute.String("assets.path", name),
+				attribute.Int("http.response.status_code", status),
+				attribute.Int64("assets.size", ow.bytes),
+				attribute.Bool("assets.cache_hit", status == http.StatusNotModified),
+				attribute.String("assets.encoding", encoding),
+			)
+			if status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	})
+}
+
+// otelWriter is the http.ResponseWriter that records the status code and the number of the bytes for the span.
+type otelWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *otelWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		// ignore the informational responses, e.g. 103 Early Hints.
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *otelWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+`,
+
+	"gin": `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GinHandler returns the gin handler that serves Root.
+// If it is registered with the catch-all parameter filepath, e.g. r.GET("/static/*filepath", GinHandler()),
+// the file at the parameter is served.
+func GinHandler() gin.HandlerFunc {
+	h := http.FileServer(Root)
+	return func(c *gin.Context) {
+		r := c.Request
+		if p, ok := c.Params.Get("filepath"); ok {
+			r = r.Clone(r.Context())
+			r.URL.Path = p
+			r.URL.RawPath = ""
+		}
+		h.ServeHTTP(c.Writer, r)
+	}
+}
+`,
+}
+
+// noNetTemplate is the built-in template of filesystem.go for -no-net.
+// It implements only fs.FS, and doesn't import net/http.
+const noNetTemplate = `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+{{with .BuildConstraint}}{{.}}
+
+{{end}}//{{.Directive}}
+
+package {{.Package}}
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+
+// Variant is the name of the embedded variant, or empty if no variant is selected.
+const Variant = {{printf "%q" .Variant}}
+
+// APIVersion is the version of the layout of the generated package.
+// It is incremented when the layout changes, so the helper packages can adapt to the packages generated by the older generators.
+//
+//   - 1: FS implements fs.FS and Versioned.
+const APIVersion = 1
+
+// Versioned is implemented by the file systems of the generated packages.
+type Versioned interface {
+	APIVersion() int
+}
+
+// APIVersionOf returns APIVersion of the generated package of the file system fsys,
+// or 0 if fsys is not of the generated packages or of the package generated before APIVersion was added.
+func APIVersionOf(fsys interface{}) int {
+	if v, ok := fsys.(Versioned); ok {
+		return v.APIVersion()
+	}
+	return 0
+}
+
+// FS is the root of the file system.
+var FS fs.FS = files
+
+{{- with .Decls}}
+
+{{.}}
+{{- end}}
+
+// files is the table of the embedded files, sorted by name.
+var files = fileSystem{
+{{- range .Files}}
+	file{
+		name:    {{printf "%q" .Name}},
+		{{- if .Lazy}}
+		lazy:    &lazyContent{size: {{len .Content}}, decode: func() string { return {{.Expr}} }},
+		{{- else}}
+		content: {{.Expr}},
+		{{- end}}
+		mode:    {{.GoMode}},
+		next:    {{.Next}},
+		child:   {{.Child}},
+	},
+{{- end}}
+}
+
+// fingerprints maps the names of the fingerprinted files to their names with the hashes.
+var fingerprints = map[string]string{
+{{- range $name, $fingerprinted := .Fingerprints}}
+	{{printf "%q" $name}}: {{printf "%q" $fingerprinted}},
+{{- end}}
+}
+
+// charsets maps the names of the files converted to UTF-8 to their original charsets.
+var charsets = map[string]string{
+{{- range $name, $charset := .Charsets}}
+	{{printf "%q" $name}}: {{printf "%q" $charset}},
+{{- end}}
+}
+
+// OriginalCharset returns the charset of the file before it was converted to UTF-8, e.g. "shift_jis".
+// It returns an empty string if the file is not converted. name is the name before fingerprinting.
+func OriginalCharset(name string) string {
+	return charsets[name]
+}
+
+// License is a license or a notice of the third-party files.
+type License struct {
+	// Name is the name of the license file or the file that has the banner comment, before fingerprinting.
+	Name string
+
+	// Text is the text of the license.
+	Text string
+}
+
+// licenses is the licenses found in the embedded files.
+var licenses = []License{
+{{- range .Licenses}}
+	{Name: {{printf "%q" .Name}}, Text: {{printf "%q" .Text}}},
+{{- end}}
+}
+
+// Licenses returns the licenses and the notices of the third-party files, sorted by name.
+// They are also embedded as /NOTICES.
+func Licenses() []License {
+	return append([]License(nil), licenses...)
+}
+
+// Fingerprint returns the name of the file with the hash, e.g. "/css/app.1a2b3c4d.css" for "/css/app.css".
+// It returns name itself if the file is not fingerprinted.
+func Fingerprint(name string) string {
+	if fingerprinted, ok := fingerprints[name]; ok {
+		return fingerprinted
+	}
+	return name
+}
+
+// BaseURL is the base URL of the files that URL returns, without the trailing slash.
+// Set it to the URL of the CDN in production, e.g. "https://cdn.example.com/3f9a2c",
+// or to the prefix of the handler locally, e.g. "/static".
+// It must be set before serving, because it is not guarded by a lock.
+var BaseURL string
+
+// URL returns the URL of the file name, e.g. "https://cdn.example.com/3f9a2c/css/app.1a2b3c4d.css" for "/css/app.css".
+// The name is fingerprinted if the package is generated with the -fingerprint option.
+func URL(name string) string {
+	return strings.TrimSuffix(BaseURL, "/") + Fingerprint(name)
+}
+
+// TemplateFuncs returns the functions for html/template and text/template:
+// "asset" returns the URL of the file, e.g. {{"{{"}}asset "/css/app.css"{{"}}"}}.
+func TemplateFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"asset": URL,
+	}
+}
+
+// AssetStats is the report of the memory used by the embedded files.
+type AssetStats struct {
+	// Files is the number of the files, excluding the directories.
+	Files int
+
+	// Dirs is the number of the directories, including the root.
+	Dirs int
+
+	// TotalBytes is the total size of the files.
+	TotalBytes int64
+
+	// EmbeddedBytes is the size of the contents embedded in the binary.
+	// The contents are not compressed, but the files with the same content share it,
+	// so it may be less than TotalBytes.
+	EmbeddedBytes int64
+
+	// Largest is the largest files, in descending order of the size.
+	// It has 10 files at most.
+	Largest []FileStat
+}
+
+// FileStat is the size of an embedded file.
+type FileStat struct {
+	Name string
+	Size int64
+}
+
+// Stats returns the report of the memory used by the embedded files,
+// e.g. to log it at startup or to alert when it exceeds the budget.
+func Stats() AssetStats {
+	var stats AssetStats
+	embedded := map[string]bool{}
+	for i := range files {
+		f := &files[i]
+		if f.mode.IsDir() {
+			stats.Dirs++
+			continue
+		}
+		stats.Files++
+		stats.TotalBytes += f.Size()
+		// the contents decoded on first access are not decoded for the report, and counted for each file.
+		if f.lazy != nil || !embedded[f.content] {
+			if f.lazy == nil {
+				embedded[f.content] = true
+			}
+			stats.EmbeddedBytes += f.Size()
+		}
+		stats.Largest = append(stats.Largest, FileStat{Name: f.name, Size: f.Size()})
+	}
+	sort.SliceStable(stats.Largest, func(i, j int) bool {
+		return stats.Largest[i].Size > stats.Largest[j].Size
+	})
+	if len(stats.Largest) > 10 {
+		stats.Largest = stats.Largest[:10]
+	}
+	return stats
+}
+
+// Preload decodes the contents of the files paths, e.g. "/index.html", and of the files in the directories of paths,
+// or of all the files if paths is empty, so their first accesses, e.g. the first requests after startup, don't decode them.
+// Only the backends that encode the contents, e.g. base64 and zip, decode them on first access,
+// so Preload does nothing for the other backends.
+// It returns an error if any of paths doesn't exist.
+func Preload(paths ...string) error {
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	for _, name := range paths {
+		i := sort.Search(len(files), func(i int) bool { return files[i].name >= name })
+		if i >= len(files) || files[i].name != name {
+			return &fs.PathError{Op: "preload", Path: name, Err: fs.ErrNotExist}
+		}
+		files.preload(i)
+	}
+	return nil
+}
+
+// preload decodes the content of the file i, or of the files in the directory i.
+func (fsys fileSystem) preload(i int) {
+	f := &fsys[i]
+	if !f.mode.IsDir() {
+		f.data()
+		return
+	}
+	for c := f.child; c >= 0; c = fsys[c].next {
+		fsys.preload(c)
+	}
+}
+
+type fileSystem []file
+
+// APIVersion implements Versioned.
+func (fsys fileSystem) APIVersion() int {
+	return APIVersion
+}
+
+func (fsys fileSystem) Open(name string) (fs.File, error) {
+	// the backslashes and the NUL bytes are rejected too, because other file systems may interpret them differently.
+	// all invalid names are reported as not exist, as the names of no files.
+	if !fs.ValidPath(name) || strings.ContainsAny(name, "\\\x00") {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	key := "/" + name
+	if name == "." {
+		key = "/"
+	}
+	i := sort.Search(len(fsys), func(i int) bool { return fsys[i].name >= key })
+	if i >= len(fsys) || fsys[i].name != key {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f := &fsys[i]
+	return &fsFile{
+		Reader: strings.NewReader(f.data()),
+		file:   f,
+		fs:     fsys,
+		dirIdx: f.child,
+	}, nil
+}
+
+type file struct {
+	name    string
+	content string
+	mode    fs.FileMode
+	child   int
+	next    int
+	lazy    *lazyContent // the content decoded on first access, or nil if it is content
+}
+
+// lazyContent is the content of a file that the backend decodes on first access of the file, e.g. the base64 backend,
+// so the package decodes nothing on its initialization.
+type lazyContent struct {
+	once    sync.Once
+	size    int64
+	decode  func() string
+	content string
+}
+
+// data returns the content of the file, decoding it on first access if it is encoded by the backend.
+func (f *file) data() string {
+	if f.lazy == nil {
+		return f.content
+	}
+	f.lazy.once.Do(func() {
+		f.lazy.content = f.lazy.decode()
+	})
+	return f.lazy.content
+}
+
+var _ fs.FileInfo = (*file)(nil)
+var _ fs.DirEntry = (*file)(nil)
+
+func (f *file) Name() string {
+	if f.name == "/" {
+		return "."
+	}
+	return f.name[strings.LastIndexByte(f.name, '/')+1:]
+}
+
+func (f *file) Size() int64 {
+	if f.lazy != nil {
+		return f.lazy.size
+	}
+	return int64(len(f.content))
+}
+
+func (f *file) Mode() fs.FileMode {
+	return f.mode
+}
+
+func (f *file) Type() fs.FileMode {
+	return f.mode.Type()
+}
+
+func (f *file) Info() (fs.FileInfo, error) {
+	return f, nil
+}
+
+var zeroTime time.Time
+
+func (f *file) ModTime() time.Time {
+	return zeroTime
+}
+
+func (f *file) IsDir() bool {
+	return f.mode.IsDir()
+}
+
+func (f *file) Sys() interface{} {
+	return nil
+}
+
+// fsFile is an opened file.
+// Each Open returns a new fsFile that has its own offset and position of ReadDir,
+// and the embedded files are read-only, so the files opened separately can be used concurrently.
+// Like os.File, an fsFile itself is not safe for concurrent use.
+type fsFile struct {
+	*strings.Reader
+	file   *file
+	fs     fileSystem
+	dirIdx int
+}
+
+var _ fs.ReadDirFile = (*fsFile)(nil)
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.file, nil
+}
+
+// Seek implements io.Seeker.
+// Seeking to the start of the directory restarts ReadDir.
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		f.dirIdx = f.file.child
+	}
+	return f.Reader.Seek(offset, whence)
+}
+
+func (f *fsFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !f.file.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.file.name, Err: fs.ErrInvalid}
+	}
+	ret := []fs.DirEntry{}
+	for f.dirIdx >= 0 && (count <= 0 || len(ret) < count) {
+		entry := &f.fs[f.dirIdx]
+		ret = append(ret, entry)
+		f.dirIdx = entry.next
+	}
+	if count > 0 && len(ret) == 0 {
+		return ret, io.EOF
+	}
+	return ret, nil
+}
+
+func (f *fsFile) Close() error {
+	return nil
+}
+`
+
+// noNetHTTPTemplate is the template of filesystem-http.go for -no-net,
+// which wraps the file system for net/http.
+const noNetHTTPTemplate = `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+//go:build !tinygo && !nonet
+// +build !tinygo,!nonet
+
+package {{.Package}}
+
+import "net/http"
+
+// Root is the root of the file system for net/http.
+// It is not available on TinyGo or with the nonet build tag.
+var Root http.FileSystem = http.FS(FS)
+`
+
+// bytesTemplate is the template of filesystem-bytes.go for -unsafe-bytes.
+const bytesTemplate = `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+{{if .NoNet}}
+import (
+	"sort"
+	"unsafe"
+)
+
+// Bytes returns the content of the file name, e.g. "/index.html", without copying it.
+// It returns nil if the file doesn't exist or is a directory.
+//
+// The returned slice shares the memory with the embedded string, which may be in the read-only segment.
+// It must not be modified; writing to it may crash the program or change the content of the file system.
+func Bytes(name string) []byte {
+	i := sort.Search(len(files), func(i int) bool { return files[i].name >= name })
+	if i >= len(files) || files[i].name != name || files[i].mode.IsDir() {
+		return nil
+	}
+	return unsafeBytes(files[i].data())
+}
+
+// unsafeBytes returns the bytes of s without copying it.
+func unsafeBytes(s string) []byte {
+	if s == "" {
+		return []byte{}
+	}
+	// the layout of the slice is the pointer and the length of the string followed by the capacity.
+	return *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		int
+	}{s, len(s)}))
+}
+{{- else}}
+// Bytes returns the content of the file name, e.g. "/index.html", without copying it.
+// It returns nil if the file doesn't exist or is a directory.
+// The file is looked up as Root opens it, so the files of the hook set by SetOpenHook and in the bundle loaded by LoadBundle
+// take precedence over the embedded files. The contents of the files of the hook are copied.
+// Bytes of Overlay looks up the file in the overlay.
+//
+// The returned slice shares the memory with the embedded string, which may be in the read-only segment.
+// It must not be modified; writing to it may crash the program or change the content of the file system.
+func Bytes(name string) []byte {
+	return assets.Bytes(name)
+}
+{{- end}}
+`
+
+// benchFilename is the name of the file of the benchmarks generated by -bench.
+const benchFilename = "filesystem_bench_test.go"
+
+// benchTemplate is the template of the benchmarks of the built-in template.
+const benchTemplate = `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// benchFile returns the largest embedded file.
+func benchFile(b *testing.B) *File {
+	var ret *File
+	for i := range files {
+		f := &files[i]
+		if !f.IsDir() && (ret == nil || f.Size() > ret.Size()) {
+			ret = f
+		}
+	}
+	if ret == nil {
+		b.Skip("no files are embedded")
+	}
+	return ret
+}
+
+func BenchmarkAssetsOpen(b *testing.B) {
+	name := benchFile(b).Path
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := Root.Open(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func BenchmarkAssetsRead(b *testing.B) {
+	var size int64
+	for i := range files {
+		size += files[i].Size()
+	}
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range files {
+			if files[j].IsDir() {
+				continue
+			}
+			f, err := Root.Open(files[j].Path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := io.Copy(io.Discard, f); err != nil {
+				b.Fatal(err)
+			}
+			f.Close()
+		}
+	}
+}
+
+func BenchmarkAssetsReaddir(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := range files {
+			if !files[j].IsDir() {
+				continue
+			}
+			f, err := Root.Open(files[j].Path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := f.Readdir(-1); err != nil {
+				b.Fatal(err)
+			}
+			f.Close()
+		}
+	}
+}
+
+func BenchmarkAssetsServe(b *testing.B) {
+	f := benchFile(b)
+	h := Handler()
+	req := httptest.NewRequest(http.MethodGet, f.Path, nil)
+	b.SetBytes(f.Size())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			b.Fatalf("unexpected status: %d", rec.Code)
+		}
+	}
+}
+`
+
+// docTemplate is the template of the package documentation doc.go.
+const docTemplate = `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+// Package {{.Package}} is an in-memory file system generated by assets-life.
+{{- with .ImportPath}}
+//
+// Serve the files with http.FileServer:
+//
+//	import (
+//		"net/http"
+//
+//		{{$.ImportSpec}}
+//	)
+//
+//	func main() {
+//		http.Handle("/", http.FileServer({{$.Package}}.Root))
+//		http.ListenAndServe(":8080", nil)
+//	}
+{{- end}}
+{{- with .InternalRoot}}
+//
+// The package is internal, so it can be imported only from the packages in {{.}}.
+{{- end}}
+{{- if .NoNet}}
+//
+// The package implements fs.FS as FS without net/http.
+// Root, the wrapper for net/http, is not available on TinyGo or with the nonet build tag.
+{{- end}}
+{{- if not .Standalone}}
+//
+// The package has only the tables of the files, and its runtime is github.com/shogo82148/assets-life/assetsfs,
+// so update the module of assets-life to get the fixes of the runtime without regenerating the package.
+// Generate the package with the -standalone option for the package that depends only on the standard library.
+{{- end}}
+//
+// Run go generate to re-generate the package.
+package {{.Package}}
+`
+
+// defaultTemplate is the built-in template of filesystem.go.
+// It has only the tables of the files and the thin wiring to the runtime,
+// which is imported from the assetsfs package, or is runtimeSource in filesystem-runtime.go with -standalone.
+const defaultTemplate = `// Code generated by go run {{.Generator}}. DO NOT EDIT.
+
+{{with .BuildConstraint}}{{.}}
+
+{{end}}//{{.Directive}}
+
+package {{.Package}}
+
+import (
+	"context"
+	"crypto/ed25519"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+{{- if not .Standalone}}
+
+	"github.com/shogo82148/assets-life/assetsfs"
+{{- end}}
+)
+
+// Variant is the name of the embedded variant, or empty if no variant is selected.
+const Variant = {{printf "%q" .Variant}}
+
+// HashAlgorithm is the hash algorithm of Hash, ByHash, FileMeta and the ETags, selected by the -hash option:
+// "crc32", "sha1", "sha256" or "blake3".
+const HashAlgorithm = {{printf "%q" .HashAlgorithm}}
+{{- if not .Standalone}}
+
+// The types, the options and the errors of the runtime, see github.com/shogo82148/assets-life/assetsfs for their documents.
+const (
+	APIVersion       = assetsfs.APIVersion
+	NoncePlaceholder = assetsfs.NoncePlaceholder
+	SlashDefault     = assetsfs.SlashDefault
+	SlashAdd         = assetsfs.SlashAdd
+	SlashStrip       = assetsfs.SlashStrip
+)
+
+type (
+	AccessLogEntry    = assetsfs.AccessLogEntry
+	AssetStats        = assetsfs.AssetStats
+	Authorizer        = assetsfs.Authorizer
+	CORSConfig        = assetsfs.CORSConfig
+	Config            = assetsfs.Config
+	ContextFileSystem = assetsfs.ContextFileSystem
+	DebugFile         = assetsfs.DebugFile
+	DebugInfo         = assetsfs.DebugInfo
+	File              = assetsfs.File
+	FileMeta          = assetsfs.FileMeta
+	FileStat          = assetsfs.FileStat
+	FileSystem        = assetsfs.FileSystem
+	Lazy              = assetsfs.Lazy
+	License           = assetsfs.License
+	Listing           = assetsfs.Listing
+	ListingEntry      = assetsfs.ListingEntry
+	MetricsRecorder   = assetsfs.MetricsRecorder
+	Option            = assetsfs.Option
+	Overlay           = assetsfs.Overlay
+	SlashPolicy       = assetsfs.SlashPolicy
+	TB                = assetsfs.TB
+	Template          = assetsfs.Template
+	ThrottleConfig    = assetsfs.ThrottleConfig
+	Versioned         = assetsfs.Versioned
+)
+
+var (
+	ErrInsecureHash = assetsfs.ErrInsecureHash
+	ErrNotSigned    = assetsfs.ErrNotSigned
+
+	APIVersionOf    = assetsfs.APIVersionOf
+	New             = assetsfs.New
+	AccessLog       = assetsfs.AccessLog
+	AccessLogFunc   = assetsfs.AccessLogFunc
+	Authorize       = assetsfs.Authorize
+	BasicAuth       = assetsfs.BasicAuth
+	CORS            = assetsfs.CORS
+	CSPNonce        = assetsfs.CSPNonce
+	CleanURLs       = assetsfs.CleanURLs
+	Debug           = assetsfs.Debug
+	EarlyHints      = assetsfs.EarlyHints
+	JSONListing     = assetsfs.JSONListing
+	Languages       = assetsfs.Languages
+	ListingTemplate = assetsfs.ListingTemplate
+	Metrics         = assetsfs.Metrics
+	Middleware      = assetsfs.Middleware
+	NegotiateImages = assetsfs.NegotiateImages
+	PreloadHeaders  = assetsfs.PreloadHeaders
+	Throttle        = assetsfs.Throttle
+	TrailingSlash   = assetsfs.TrailingSlash
+)
+{{- end}}
+
+// Root is the root of the file system.
+var Root http.FileSystem = assets
+
+{{- with .Decls}}
+
+{{.}}
+{{- end}}
+
+// assets is the file system of the tables.
+var assets = New(Config{
+	Variant:       Variant,
+	Files:         files,
+	Fingerprints:  fingerprints,
+	Charsets:      charsets,
+	Licenses:      licenses,
+	HashAlgorithm: HashAlgorithm,
+	Hashes:        contentHashes,
+	HashNames:     hashNames,
+	Signature:     {{printf "%q" .Signature}},
+	DirsFirst:     {{.DirsFirst}},
+	GzipEncoded:   {{.GzipEncoded}},
+})
+
+// files is the table of the embedded files, sorted by name.
+var files = []File{
+{{- range .Files}}
+	{
+		Path:     {{printf "%q" .Name}},
+		{{- if .Lazy}}
+		Lazy:     &Lazy{Size: {{len .Content}}, Decode: func() string { return {{.Expr}} }},
+		{{- else}}
+		Content:  {{.Expr}},
+		{{- end}}
+		FileMode: {{.GoMode}},
+		Next:     {{.Next}},
+		Child:    {{.Child}},
+		{{- with .ModTime}}
+		MTime:    {{.}},
+		{{- end}}
+		{{- with .Preload}}
+		Links:    {{printf "%#v" .}},
+		{{- end}}
+	},
+{{- end}}
+}
+
+// fingerprints maps the names of the fingerprinted files to their names with the hashes.
+var fingerprints = map[string]string{
+{{- range $name, $fingerprinted := .Fingerprints}}
+	{{printf "%q" $name}}: {{printf "%q" $fingerprinted}},
+{{- end}}
+}
+
+// charsets maps the names of the files converted to UTF-8 to their original charsets.
+var charsets = map[string]string{
+{{- range $name, $charset := .Charsets}}
+	{{printf "%q" $name}}: {{printf "%q" $charset}},
+{{- end}}
+}
+
+// licenses is the licenses found in the embedded files.
+var licenses = []License{
+{{- range .Licenses}}
+	{Name: {{printf "%q" .Name}}, Text: {{printf "%q" .Text}}},
+{{- end}}
+}
+
+// contentHashes maps the names of the files to the hashes of their contents by HashAlgorithm in hex.
+var contentHashes = map[string]string{
+{{- range $name, $hash := .Hashes}}
+	{{printf "%q" $name}}: {{printf "%q" $hash}},
+{{- end}}
+}
+
+// hashNames maps the hashes of the contents by HashAlgorithm to the names of the files that have them.
+var hashNames = map[string]string{
+{{- range $hash, $name := .HashNames}}
+	{{printf "%q" $hash}}: {{printf "%q" $name}},
+{{- end}}
+}
+
+// OriginalCharset returns the charset of the file before it was converted to UTF-8, e.g. "shift_jis".
+// It returns an empty string if the file is not converted. name is the name before fingerprinting.
+func OriginalCharset(name string) string {
+	return assets.OriginalCharset(name)
+}
+
+// Licenses returns the licenses and the notices of the third-party files, sorted by name.
+// They are also embedded as /NOTICES.
+func Licenses() []License {
+	return assets.Licenses()
+}
+
+// Fingerprint returns the name of the file with the hash, e.g. "/css/app.1a2b3c4d.css" for "/css/app.css".
+// It returns name itself if the file is not fingerprinted.
+func Fingerprint(name string) string {
+	return assets.Fingerprint(name)
+}
+
+// BaseURL is the base URL of the files that URL returns, without the trailing slash.
+// Set it to the URL of the CDN in production, e.g. "https://cdn.example.com/3f9a2c",
+// or to the prefix of the handler locally, e.g. "/static".
+// It must be set before serving, because it is not guarded by a lock.
+var BaseURL string
+
+// URL returns the URL of the file name, e.g. "https://cdn.example.com/3f9a2c/css/app.1a2b3c4d.css" for "/css/app.css".
+// The name is fingerprinted if the package is generated with the -fingerprint option.
+func URL(name string) string {
+	return strings.TrimSuffix(BaseURL, "/") + Fingerprint(name)
+}
+
+// ContentURL returns the stable URL of the content of the file name, e.g. "https://cdn.example.com/_cas/3f9a...",
+// which the handler with the ContentAddressable option serves.
+// It returns URL(name) if the file is not embedded, or if HashAlgorithm is crc32 or sha1.
+func ContentURL(name string) string {
+	return strings.TrimSuffix(BaseURL, "/") + assets.ContentPath(name)
+}
+
+// TemplateFuncs returns the functions for html/template and text/template:
+// "asset" returns the URL of the file, e.g. {{"{{"}}asset "/css/app.css"{{"}}"}}.
+func TemplateFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"asset": URL,
+	}
+}
+
+// Hash returns the hash of the content of the file name by HashAlgorithm in hex, or false if it is not an embedded file.
+func Hash(name string) (string, bool) {
+	return assets.Hash(name)
+}
+
+// ByHash opens the embedded file whose content has the hash by HashAlgorithm in hex.
+// It returns ErrInsecureHash if HashAlgorithm is crc32 or sha1, whose hashes can be forged for other contents.
+func ByHash(hash string) (http.File, error) {
+	return assets.ByHash(hash)
+}
+
+// Manifest returns the manifest of the embedded files that the -sign-key option signs,
+// the lines of the SHA-256 hashes of the contents in hex and the names sorted by name, in the format of sha256sum.
+func Manifest() string {
+	return assets.Manifest()
+}
+
+// VerifySignature verifies the signature of the manifest with the public key pub,
+// e.g. to trust that the embedded files are generated by the owner of the private key.
+func VerifySignature(pub ed25519.PublicKey) error {
+	return assets.VerifySignature(pub)
+}
+
+// SourceHash returns the hash of Manifest by HashAlgorithm in hex, which identifies the build of the embedded files.
+func SourceHash() string {
+	return assets.SourceHash()
+}
+
+// Stats returns the report of the memory used by the embedded files,
+// e.g. to log it at startup or to alert when it exceeds the budget.
+func Stats() AssetStats {
+	return assets.Stats()
+}
+
+// Preload decodes the contents of the files paths, e.g. "/index.html", and of the files in the directories of paths,
+// or of all the files if paths is empty, so their first accesses, e.g. the first requests after startup, don't decode them.
+// It returns an error if any of paths doesn't exist.
+func Preload(paths ...string) error {
+	return assets.Preload(paths...)
+}
+
+// PreloadLinks returns the values of the Link headers that preload the critical resources of the HTML file name,
+// e.g. "</css/app.css>; rel=preload; as=style", to send them from other handlers, e.g. in 103 Early Hints.
+// The returned slice must not be modified.
+func PreloadLinks(name string) []string {
+	return assets.PreloadLinks(name)
+}
+
+// OpenContext opens the file name in Root.
+// It returns the error of ctx if ctx is done.
+func OpenContext(ctx context.Context, name string) (http.File, error) {
+	return assets.OpenContext(ctx, name)
+}
+
+// RootWithFallback returns the file system that opens the embedded files in Root,
+// and opens the files in the directory dir if they are not embedded.
+// The files can be added in production by putting them into dir without rebuilding,
+// but the directories list only the embedded files.
+func RootWithFallback(dir string) http.FileSystem {
+	return assets.WithFallback(dir)
+}
+
+// NewOverlay returns a new overlay over the embedded files.
+func NewOverlay() *Overlay {
+	return assets.NewOverlay()
+}
+
+// Handler returns the handler that serves the files in Root.
+// It serves GET and HEAD requests, and responds to OPTIONS requests with the Allow header.
+func Handler(opts ...Option) http.Handler {
+	return assets.Handler(opts...)
+}
+
+// Composite returns the handler that serves the file systems under the prefixes,
+// e.g. {"/docs": docs.Root, "/static": static.Root}, with the same options,
+// so the file systems of several generated packages are served with the same headers and 404 responses.
+// The longest prefix that matches the request path is used, and the prefix "/" matches all paths.
+func Composite(roots map[string]http.FileSystem, opts ...Option) http.Handler {
+	return assets.Composite(roots, opts...)
+}
+
+// Mount registers the handler of the files in Root at prefix of mux.
+// The prefix is stripped from the request path,
+// and the request to prefix without the trailing slash is redirected to prefix + "/".
+func Mount(mux *http.ServeMux, prefix string, opts ...Option) {
+	assets.Mount(mux, prefix, opts...)
+}
+
+// ContentAddressable serves the embedded files by the hashes of their contents by HashAlgorithm under /_cas/, e.g. /_cas/3f9a...,
+// with the immutable Cache-Control header. The URLs are returned by ContentURL.
+// It panics if HashAlgorithm is crc32 or sha1, whose hashes can be forged, so a client could cache another content forever.
+func ContentAddressable() Option {
+	return assets.ContentAddressable()
+}
+
+// SetAccessLog enables or disables the access logs of all handlers at runtime.
+// They are enabled by default.
+func SetAccessLog(enabled bool) {
+	assets.SetAccessLog(enabled)
+}
+
+// SetOpenHook sets the hook that intercepts opening the embedded files at runtime,
+// so the apps can shadow some files, e.g. by the feature flags or the A/B tests, without overlaying the whole file system.
+// It is safe to call SetOpenHook concurrently with opening the files. nil removes the hook.
+func SetOpenHook(hook func(name string) (http.File, bool)) {
+	assets.SetOpenHook(hook)
+}
+
+// SetBundleKey sets the Ed25519 public key that verifies the signatures of the bundles loaded by LoadBundle.
+func SetBundleKey(pub ed25519.PublicKey) {
+	assets.SetBundleKey(pub)
+}
+
+// LoadBundle loads the files in the bundle read from r, which the bundle subcommand of assets-life writes,
+// and replaces the files loaded before atomically, so the long-running servers can update the files without a redeploy.
+// The bundle must be signed by the private key of the public key set by SetBundleKey.
+func LoadBundle(r io.Reader) error {
+	return assets.LoadBundle(r)
+}
+
+// UnloadBundle removes the files loaded by LoadBundle, so Root and the handlers open only the embedded files.
+func UnloadBundle() {
+	assets.UnloadBundle()
+}
+
+// SeedT extracts the embedded files into a new temporary directory of the test t, and returns the directory.
+// The directory is removed when the test and all its subtests complete.
+func SeedT(t TB) string {
+	t.Helper()
+	return assets.SeedT(t)
+}
+
+// ExtractTo writes the embedded files into the directory dir, creating it if necessary.
+// The files are written with their modes, and the existing files are overwritten.
+func ExtractTo(dir string) error {
+	return assets.ExtractTo(dir)
+}
+`
+
+// runtimeSource is the runtime of the built-in template after the package clause,
+// which is written into filesystem-runtime.go with -standalone, and is the assetsfs package.
+// Run make golden to update assetsfs after changing it.
+const runtimeSource = `import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"math"
+	"math/bits"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// APIVersion is the version of the layout of the generated packages.
+// It is incremented when the layout changes, so the helper packages can adapt to the packages generated by the older generators.
+//
+//   - 1: Root, RootWithFallback and the overlays implement ContextFileSystem and Versioned,
+//     and their files implement io.Seeker and io.WriterTo.
+const APIVersion = 1
+
+// Versioned is implemented by the file systems of the generated packages.
+type Versioned interface {
+	APIVersion() int
+}
+
+// APIVersionOf returns APIVersion of the generated package of the file system fsys,
+// or 0 if fsys is not of the generated packages or of the package generated before APIVersion was added.
+func APIVersionOf(fsys interface{}) int {
+	if v, ok := fsys.(Versioned); ok {
+		return v.APIVersion()
+	}
+	return 0
+}
+
+// Config is the tables of a generated package.
+type Config struct {
+	// Variant is the name of the embedded variant, or empty if no variant is selected.
+	Variant string
+
+	// Files is the table of the files, sorted by name.
+	Files []File
+
+	// Fingerprints maps the names of the fingerprinted files to their names with the hashes.
+	Fingerprints map[string]string
+
+	// Charsets maps the names of the files converted to UTF-8 to their original charsets.
+	Charsets map[string]string
+
+	// Licenses is the licenses found in the files, sorted by name.
+	Licenses []License
+
+	// HashAlgorithm is the hash algorithm of Hashes, HashNames and Signature:
+	// "crc32", "sha1", "sha256" or "blake3".
+	HashAlgorithm string
+
+	// Hashes maps the names of the files to the hashes of their contents by HashAlgorithm in hex.
+	Hashes map[string]string
+
+	// HashNames maps the hashes of the contents by HashAlgorithm to the names of the files that have them.
+	HashNames map[string]string
+
+	// Signature is the Ed25519 signature of the manifest in base64, or empty if the package is not signed.
+	Signature string
+
+	// DirsFirst reports whether Readdir lists the directories before the files.
+	DirsFirst bool
+
+	// GzipEncoded reports whether the handler serves the pre-compressed gzip variants of the files, e.g. app.js.gz.
+	GzipEncoded bool
+}
+
+// FileSystem is the file system of a generated package.
+// It implements ContextFileSystem and Versioned, and it is safe for concurrent use.
+type FileSystem struct {
+	variant       string
+	files         table
+	fingerprints  map[string]string
+	sourceNames   map[string]string // the names of the fingerprinted files with the hashes to their names
+	charsets      map[string]string
+	licenses      []License
+	hashAlgorithm string
+	contentHashes map[string]string
+	hashNames     map[string]string
+	signature     string
+	dirsFirst     bool
+	gzipEncoded   bool
+
+	defaultOptions    []Option     // applied to all handlers before their options
+	accessLogDisabled int32        // accessed atomically
+	openHook          atomic.Value // of func(name string) (http.File, bool)
+	bundle            atomic.Value // of table
+	bundleKey         atomic.Value // of ed25519.PublicKey
+}
+
+// New returns the file system of the tables c.
+// The entries of c.Files are linked to the file system, so they must not be shared with another file system.
+func New(c Config) *FileSystem {
+	fsys := &FileSystem{
+		variant:       c.Variant,
+		files:         table(c.Files),
+		fingerprints:  c.Fingerprints,
+		sourceNames:   make(map[string]string, len(c.Fingerprints)),
+		charsets:      c.Charsets,
+		licenses:      c.Licenses,
+		hashAlgorithm: c.HashAlgorithm,
+		contentHashes: c.Hashes,
+		hashNames:     c.HashNames,
+		signature:     c.Signature,
+		dirsFirst:     c.DirsFirst,
+		gzipEncoded:   c.GzipEncoded,
+	}
+	for name, fingerprinted := range c.Fingerprints {
+		fsys.sourceNames[fingerprinted] = name
+	}
+	for i := range fsys.files {
+		fsys.files[i].fsys = fsys
+	}
+	return fsys
+}
+
+// OriginalCharset returns the charset of the file before it was converted to UTF-8, e.g. "shift_jis".
+// It returns an empty string if the file is not converted. name is the name before fingerprinting.
+func (fsys *FileSystem) OriginalCharset(name string) string {
+	return fsys.charsets[name]
+}
+
+// License is a license or a notice of the third-party files.
+type License struct {
+	// Name is the name of the license file or the file that has the banner comment, before fingerprinting.
+	Name string
+
+	// Text is the text of the license.
+	Text string
+}
+
+// Licenses returns the licenses and the notices of the third-party files, sorted by name.
+// They are also embedded as /NOTICES.
+func (fsys *FileSystem) Licenses() []License {
+	return append([]License(nil), fsys.licenses...)
+}
+
+// Fingerprint returns the name of the file with the hash, e.g. "/css/app.1a2b3c4d.css" for "/css/app.css".
+// It returns name itself if the file is not fingerprinted.
+func (fsys *FileSystem) Fingerprint(name string) string {
+	if fingerprinted, ok := fsys.fingerprints[name]; ok {
+		return fingerprinted
+	}
+	return name
+}
+
+// Hash returns the hash of the content of the file name by HashAlgorithm in hex, or false if it is not an embedded file.
+func (fsys *FileSystem) Hash(name string) (string, bool) {
+	hash, ok := fsys.contentHashes[name]
+	return hash, ok
+}
+
+// ByHash opens the embedded file whose content has the hash by HashAlgorithm in hex.
+// The files with the same content are the same file, so it opens one of them.
+// It returns ErrInsecureHash if HashAlgorithm is crc32 or sha1, whose hashes can be forged for other contents.
+func (fsys *FileSystem) ByHash(hash string) (http.File, error) {
+	if !fsys.secureHash() {
+		return nil, ErrInsecureHash
+	}
+	name, ok := fsys.hashNames[strings.ToLower(hash)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: hash, Err: fs.ErrNotExist}
+	}
+	// open the embedded file even if a bundle is loaded, because the hash is of its content.
+	f, err := fsys.files.open(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// secureHash reports whether HashAlgorithm is collision-resistant, which ByHash and ContentAddressable require.
+func (fsys *FileSystem) secureHash() bool {
+	return fsys.hashAlgorithm == "sha256" || fsys.hashAlgorithm == "blake3"
+}
+
+// ErrInsecureHash is returned by ByHash if HashAlgorithm is crc32 or sha1.
+var ErrInsecureHash = errors.New("the files are not served by the hashes of crc32 and sha1, use -hash sha256 or blake3")
+
+// hashHex returns the hash of b by HashAlgorithm in hex.
+func (fsys *FileSystem) hashHex(b []byte) string {
+	switch fsys.hashAlgorithm {
+	case "crc32":
+		var sum [crc32.Size]byte
+		binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(b))
+		return hex.EncodeToString(sum[:])
+	case "sha1":
+		sum := sha1.Sum(b)
+		return hex.EncodeToString(sum[:])
+	case "blake3":
+		h := &blake3{cv: blake3IV}
+		h.Write(b)
+		return hex.EncodeToString(h.Sum(nil))
+	default:
+		sum := sha256.Sum256(b)
+		return hex.EncodeToString(sum[:])
+	}
+}
+
+// blake3 is the BLAKE3 hash of HashAlgorithm, which is not in the standard library.
+// It implements only the default mode with the 256-bit output, see https://github.com/BLAKE3-team/BLAKE3-specs.
+type blake3 struct {
+	cv       [8]uint32   // the chaining value of the current chunk
+	chunk    uint64      // the index of the current chunk
+	blocks   int         // the number of the compressed blocks of the current chunk
+	block    [64]byte    // the current block, padded with zeros
+	blockLen int         // the length of the current block
+	stack    [][8]uint32 // the chaining values of the completed subtrees
+}
+
+const (
+	blake3ChunkStart = 1 << 0
+	blake3ChunkEnd   = 1 << 1
+	blake3Parent     = 1 << 2
+	blake3Root       = 1 << 3
+)
+
+var blake3IV = [8]uint32{0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19}
+
+var blake3Permutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+func (h *blake3) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if h.blockLen == len(h.block) {
+			// the block is compressed only when more input follows, because the last block has the end flag.
+			if h.blocks == 15 {
+				h.pushChunk()
+			} else {
+				out := blake3Compress(h.cv, &h.block, h.chunk, uint32(h.blockLen), h.flags())
+				copy(h.cv[:], out[:8])
+				h.blocks++
+			}
+			h.block = [64]byte{}
+			h.blockLen = 0
+		}
+		m := copy(h.block[h.blockLen:], p)
+		h.blockLen += m
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// flags returns the flags of the current block of the chunk.
+func (h *blake3) flags() uint32 {
+	if h.blocks == 0 {
+		return blake3ChunkStart
+	}
+	return 0
+}
+
+// pushChunk completes the current chunk, and merges the completed subtrees of the same size.
+func (h *blake3) pushChunk() {
+	out := blake3Compress(h.cv, &h.block, h.chunk, uint32(h.blockLen), h.flags()|blake3ChunkEnd)
+	var cv [8]uint32
+	copy(cv[:], out[:8])
+	for total := h.chunk + 1; total&1 == 0; total >>= 1 {
+		cv = blake3ParentCV(h.stack[len(h.stack)-1], cv)
+		h.stack = h.stack[:len(h.stack)-1]
+	}
+	h.stack = append(h.stack, cv)
+	h.chunk++
+	h.cv = blake3IV
+	h.blocks = 0
+}
+
+// Sum appends the hash to b. It doesn't change the state of h.
+func (h *blake3) Sum(b []byte) []byte {
+	cv, block, counter, blockLen, flags := h.cv, h.block, h.chunk, uint32(h.blockLen), h.flags()|blake3ChunkEnd
+	for i := len(h.stack) - 1; i >= 0; i-- {
+		// the current node is the right child of the parent.
+		out := blake3Compress(cv, &block, counter, blockLen, flags)
+		var right [8]uint32
+		copy(right[:], out[:8])
+		block = blake3ParentBlock(h.stack[i], right)
+		cv, counter, blockLen, flags = blake3IV, 0, 64, blake3Parent
+	}
+	out := blake3Compress(cv, &block, counter, blockLen, flags|blake3Root)
+	var sum [32]byte
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(sum[4*i:], out[i])
+	}
+	return append(b, sum[:]...)
+}
+
+// blake3ParentBlock returns the block of the parent node of the chaining values of the children.
+func blake3ParentBlock(left, right [8]uint32) [64]byte {
+	var block [64]byte
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(block[4*i:], left[i])
+		binary.LittleEndian.PutUint32(block[32+4*i:], right[i])
+	}
+	return block
+}
+
+// blake3ParentCV returns the chaining value of the parent node of the chaining values of the children.
+func blake3ParentCV(left, right [8]uint32) [8]uint32 {
+	block := blake3ParentBlock(left, right)
+	out := blake3Compress(blake3IV, &block, 0, 64, blake3Parent)
+	var cv [8]uint32
+	copy(cv[:], out[:8])
+	return cv
+}
+
+// blake3Compress is the compression function of BLAKE3.
+func blake3Compress(cv [8]uint32, block *[64]byte, counter uint64, blockLen, flags uint32) [16]uint32 {
+	var m [16]uint32
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint32(block[4*i:])
+	}
+	s := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		blake3IV[0], blake3IV[1], blake3IV[2], blake3IV[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	for r := 0; r < 7; r++ {
+		blake3G(&s, 0, 4, 8, 12, m[0], m[1])
+		blake3G(&s, 1, 5, 9, 13, m[2], m[3])
+		blake3G(&s, 2, 6, 10, 14, m[4], m[5])
+		blake3G(&s, 3, 7, 11, 15, m[6], m[7])
+		blake3G(&s, 0, 5, 10, 15, m[8], m[9])
+		blake3G(&s, 1, 6, 11, 12, m[10], m[11])
+		blake3G(&s, 2, 7, 8, 13, m[12], m[13])
+		blake3G(&s, 3, 4, 9, 14, m[14], m[15])
+		var permuted [16]uint32
+		for i, j := range blake3Permutation {
+			permuted[i] = m[j]
+		}
+		m = permuted
+	}
+	for i := 0; i < 8; i++ {
+		s[i] ^= s[i+8]
+		s[i+8] ^= cv[i]
+	}
+	return s
+}
+
+// blake3G is the quarter-round of BLAKE3.
+func blake3G(s *[16]uint32, a, b, c, d int, x, y uint32) {
+	s[a] += s[b] + x
+	s[d] = bits.RotateLeft32(s[d]^s[a], -16)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -12)
+	s[a] += s[b] + y
+	s[d] = bits.RotateLeft32(s[d]^s[a], -8)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -7)
+}
+
+// ErrNotSigned is returned by VerifySignature if the package is generated without the -sign-key option.
+var ErrNotSigned = errors.New("the embedded files are not signed")
+
+// Manifest returns the manifest of the embedded files that the -sign-key option signs,
+// the lines of the SHA-256 hashes of the contents in hex and the names sorted by name, in the format of sha256sum.
+// It is SHA-256 whatever HashAlgorithm is, so the signature guarantees the integrity of the contents.
+func (fsys *FileSystem) Manifest() string {
+	return fsys.files.manifest()
+}
+
+// manifest returns the manifest of the files in t.
+func (t table) manifest() string {
+	var buf strings.Builder
+	for i := range t {
+		f := &t[i]
+		if f.FileMode.IsDir() {
+			continue
+		}
+		sum := sha256.Sum256([]byte(f.Data()))
+		buf.WriteString(hex.EncodeToString(sum[:]))
+		buf.WriteString("  ")
+		buf.WriteString(f.Path)
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// VerifySignature verifies the signature of the manifest with the public key pub,
+// e.g. to trust that the embedded files are generated by the owner of the private key.
+// The manifest is computed from the embedded files, so the files modified after the generation fail the verification.
+func (fsys *FileSystem) VerifySignature(pub ed25519.PublicKey) error {
+	if fsys.signature == "" {
+		return ErrNotSigned
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid size of the Ed25519 public key: %d", len(pub))
+	}
+	sig, err := base64.StdEncoding.DecodeString(fsys.signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, []byte(fsys.Manifest()), sig) {
+		return errors.New("the signature of the embedded files is invalid")
+	}
+	return nil
+}
+
+// SourceHash returns the hash of Manifest by HashAlgorithm in hex, which identifies the build of the embedded files,
+// e.g. to check which build a running instance serves.
+func (fsys *FileSystem) SourceHash() string {
+	return fsys.hashHex([]byte(fsys.Manifest()))
+}
+
+// ContentPath returns the stable path of the content of the file name, e.g. "/_cas/3f9a...",
+// which the handler with the ContentAddressable option serves.
+// It returns Fingerprint(name) if the file is not embedded, or if HashAlgorithm is crc32 or sha1.
+func (fsys *FileSystem) ContentPath(name string) string {
+	hash, ok := fsys.contentHashes[name]
+	if !ok || !fsys.secureHash() {
+		return fsys.Fingerprint(name)
+	}
+	return "/_cas/" + hash
+}
+
+// AssetStats is the report of the memory used by the embedded files.
+type AssetStats struct {
+	// Files is the number of the files, excluding the directories.
+	Files int
+
+	// Dirs is the number of the directories, including the root.
+	Dirs int
+
+	// TotalBytes is the total size of the files.
+	TotalBytes int64
+
+	// EmbeddedBytes is the size of the contents embedded in the binary.
+	// The contents are not compressed, but the files with the same content share it,
+	// so it may be less than TotalBytes.
+	EmbeddedBytes int64
+
+	// Largest is the largest files, in descending order of the size.
+	// It has 10 files at most.
+	Largest []FileStat
+}
+
+// FileStat is the size of an embedded file.
+type FileStat struct {
+	Name string
+	Size int64
+}
+
+// Stats returns the report of the memory used by the embedded files,
+// e.g. to log it at startup or to alert when it exceeds the budget.
+func (fsys *FileSystem) Stats() AssetStats {
+	var stats AssetStats
+	embedded := map[string]bool{}
+	for i := range fsys.files {
+		f := &fsys.files[i]
+		if f.FileMode.IsDir() {
+			stats.Dirs++
+			continue
+		}
+		stats.Files++
+		stats.TotalBytes += f.Size()
+		// the contents decoded on first access are not decoded for the report, and counted for each file.
+		if f.Lazy != nil || !embedded[f.Content] {
+			if f.Lazy == nil {
+				embedded[f.Content] = true
+			}
+			stats.EmbeddedBytes += f.Size()
+		}
+		stats.Largest = append(stats.Largest, FileStat{Name: f.Path, Size: f.Size()})
+	}
+	sort.SliceStable(stats.Largest, func(i, j int) bool {
+		return stats.Largest[i].Size > stats.Largest[j].Size
+	})
+	if len(stats.Largest) > 10 {
+		stats.Largest = stats.Largest[:10]
+	}
+	return stats
+}
+
+// Preload decodes the contents of the files paths, e.g. "/index.html", and of the files in the directories of paths,
+// or of all the files if paths is empty, so their first accesses, e.g. the first requests after startup, don't decode them.
+// Only the backends that encode the contents, e.g. base64 and zip, decode them on first access,
+// so Preload does nothing for the other backends.
+// It returns an error if any of paths doesn't exist.
+func (fsys *FileSystem) Preload(paths ...string) error {
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	for _, name := range paths {
+		i, ok := fsys.files.lookup(name)
+		if !ok {
+			return &fs.PathError{Op: "preload", Path: name, Err: fs.ErrNotExist}
+		}
+		fsys.files.preload(i)
+	}
+	return nil
+}
+
+// preload decodes the content of the file i, or of the files in the directory i.
+func (t table) preload(i int) {
+	f := &t[i]
+	if !f.FileMode.IsDir() {
+		f.Data()
+		return
+	}
+	for c := f.Child; c >= 0; c = t[c].Next {
+		t.preload(c)
+	}
+}
+
+// ContextFileSystem is the http.FileSystem that honors the cancellation of the context.
+// The file systems of the package implement it, and Handler opens the files with the contexts of the requests.
+type ContextFileSystem interface {
+	http.FileSystem
+	OpenContext(ctx context.Context, name string) (http.File, error)
+}
+
+// openContext opens the file name in fsys with ctx.
+// If fsys doesn't implement ContextFileSystem, ctx is checked only before opening.
+func openContext(ctx context.Context, fsys http.FileSystem, name string) (http.File, error) {
+	if cfs, ok := fsys.(ContextFileSystem); ok {
+		return cfs.OpenContext(ctx, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return fsys.Open(name)
+}
+
+// contextFileSystem is the http.FileSystem that opens the files of the handler with ctx, for http.FileServer.
+type contextFileSystem struct {
+	ctx    context.Context
+	h      *handler
+	header http.Header
+}
+
+func (fsys contextFileSystem) Open(name string) (http.File, error) {
+	f, err := fsys.h.open(fsys.ctx, name)
+	if err == nil {
+		// the file server opens the file to serve last, e.g. index.html after its directory.
+		setServed(fsys.ctx, name)
+		setETag(fsys.header, f)
+	}
+	return f, err
+}
+
+// setETag sets the ETag header to the hash of the content of f by HashAlgorithm,
+// so http.ServeContent answers If-None-Match with 304 Not Modified.
+// The headers are not changed if the hash is unknown, e.g. of the files of the hook set by SetOpenHook.
+func setETag(header http.Header, f http.File) {
+	hf, ok := f.(*httpFile)
+	if !ok {
+		return
+	}
+	if hash := hf.file.hash(); hash != "" {
+		header.Set("ETag", strconv.Quote(hash))
+	}
+}
+
+// servedKey is the key of the context value that records the name of the served file for the metrics.
+type servedKey struct{}
+
+// setServed records name as the file served for the request in ctx.
+func setServed(ctx context.Context, name string) {
+	if served, ok := ctx.Value(servedKey{}).(*string); ok {
+		*served = name
+	}
+}
+
+// WithFallback returns the file system that opens the embedded files in fsys,
+// and opens the files in the directory dir if they are not embedded.
+// The files can be added in production by putting them into dir without rebuilding,
+// but the directories list only the embedded files.
+func (fsys *FileSystem) WithFallback(dir string) http.FileSystem {
+	return fallbackFileSystem{
+		embedded: fsys,
+		disk:     http.Dir(dir),
+	}
+}
+
+type fallbackFileSystem struct {
+	embedded http.FileSystem
+	disk     http.FileSystem
+}
+
+// APIVersion implements Versioned.
+func (fsys fallbackFileSystem) APIVersion() int {
+	return APIVersion
+}
+
+func (fsys fallbackFileSystem) Open(name string) (http.File, error) {
+	return fsys.OpenContext(context.Background(), name)
+}
+
+func (fsys fallbackFileSystem) OpenContext(ctx context.Context, name string) (http.File, error) {
+	if !validName(name) {
+		// don't pass the name to the disk.
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f, err := openContext(ctx, fsys.embedded, name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return f, err
+	}
+	return openContext(ctx, fsys.disk, name)
+}
+
+// compositeFileSystem is the file system of Composite.
+type compositeFileSystem struct {
+	prefixes []string // sorted by length in descending order
+	roots    map[string]http.FileSystem
+}
+
+func (fsys compositeFileSystem) Open(name string) (http.File, error) {
+	return fsys.OpenContext(context.Background(), name)
+}
+
+func (fsys compositeFileSystem) OpenContext(ctx context.Context, name string) (http.File, error) {
+	if !validName(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	for _, prefix := range fsys.prefixes {
+		if prefix == "/" {
+			return openContext(ctx, fsys.roots[prefix], name)
+		}
+		if name == prefix || strings.HasPrefix(name, prefix+"/") {
+			return openContext(ctx, fsys.roots[prefix], "/"+strings.TrimPrefix(name[len(prefix):], "/"))
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Overlay is a writable in-memory file system layered over the embedded files.
+// The changes are visible only through the overlay, and the embedded files are not modified.
+// It is safe for concurrent use.
+type Overlay struct {
+	assets  *FileSystem
+	mu      sync.RWMutex
+	entries map[string]File
+	fs      table
+}
+
+// NewOverlay returns a new overlay over the embedded files of fsys.
+func (fsys *FileSystem) NewOverlay() *Overlay {
+	entries := make(map[string]File, len(fsys.files))
+	for _, f := range fsys.files {
+		entries[f.Path] = f
+	}
+	return &Overlay{
+		assets:  fsys,
+		entries: entries,
+		fs:      fsys.files,
+	}
+}
+
+// APIVersion implements Versioned.
+func (o *Overlay) APIVersion() int {
+	return APIVersion
+}
+
+// Open implements http.FileSystem.
+func (o *Overlay) Open(name string) (http.File, error) {
+	return o.OpenContext(context.Background(), name)
+}
+
+// OpenContext implements ContextFileSystem.
+// The files in the overlay take precedence over the hook set by SetOpenHook and the bundle loaded by LoadBundle,
+// which open only the names that are not in the overlay.
+func (o *Overlay) OpenContext(ctx context.Context, name string) (http.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !validName(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return o.open(name, false)
+}
+
+// open opens the file name in the overlay, with the hook set by SetOpenHook or in the bundle loaded by LoadBundle, in this order.
+// If pooled is true, Close puts the embedded file back to httpFilePool, so it must not be used after Close.
+func (o *Overlay) open(name string, pooled bool) (http.File, error) {
+	o.mu.RLock()
+	fsys := o.fs
+	o.mu.RUnlock()
+	f, err := fsys.open(name, pooled)
+	if err == nil {
+		return f, nil
+	}
+	if f, ok := o.assets.hookOpen(name); ok {
+		return f, nil
+	}
+	if f, ok := o.assets.openBundled(name, pooled); ok {
+		return f, nil
+	}
+	return nil, err
+}
+
+// WriteFile adds or replaces the file name with content, creating its parent directories.
+// The name is slash-separated, e.g. "/css/app.css", and must not have backslashes and NUL bytes.
+func (o *Overlay) WriteFile(name string, content []byte) error {
+	name = path.Clean("/" + name)
+	if !validName(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if f, ok := o.entries[name]; ok && f.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+	}
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		if f, ok := o.entries[dir]; ok {
+			if !f.IsDir() {
+				return &fs.PathError{Op: "write", Path: name, Err: errors.New("not a directory")}
+			}
+			break
+		}
+		o.entries[dir] = File{Path: dir, FileMode: fs.ModeDir | 0755, fsys: o.assets}
+	}
+	// the metadata in the generated tables is of the embedded content, so it is computed from the written content.
+	o.entries[name] = File{Path: name, Content: string(content), FileMode: 0644, fsys: o.assets, meta: &FileMeta{
+		Hash:           o.assets.hashHex(content),
+		ContentType:    contentType(name, string(content)),
+		CompressedSize: -1,
+		Source:         name,
+	}}
+	o.rebuild()
+	return nil
+}
+
+// Remove removes the file name, or the directory name and all files in it.
+func (o *Overlay) Remove(name string) error {
+	name = path.Clean("/" + name)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if name == "/" {
+		return &fs.PathError{Op: "remove", Path: name, Err: errors.New("cannot remove the root")}
+	}
+	if _, ok := o.entries[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	for n := range o.entries {
+		if n == name || strings.HasPrefix(n, name+"/") {
+			delete(o.entries, n)
+		}
+	}
+	o.rebuild()
+	return nil
+}
+
+// rebuild builds the table of the files from the entries. o.mu must be held.
+func (o *Overlay) rebuild() {
+	names := make([]string, 0, len(o.entries))
+	for name := range o.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fsys := make(table, len(names))
+	for i, name := range names {
+		fsys[i] = o.entries[name]
+		fsys[i].Next = -1
+		fsys[i].Child = -1
+	}
+
+	// link the children in the same order as the generator.
+	order := make([]int, len(names))
+	for i := range order {
+		order[i] = i
+	}
+	if o.assets.dirsFirst {
+		sort.SliceStable(order, func(i, j int) bool {
+			return fsys[order[i]].FileMode.IsDir() && !fsys[order[j]].FileMode.IsDir()
+		})
+	}
+	last := map[string]int{} // the index of the last child found, for each directory
+	for _, i := range order {
+		name := names[i]
+		if name == "/" {
+			continue
+		}
+
+		// link to the siblings
+		dir := path.Dir(name)
+		if j, ok := last[dir]; ok {
+			fsys[j].Next = i
+		} else {
+			fsys[sort.SearchStrings(names, dir)].Child = i
+		}
+		last[dir] = i
+	}
+
+	// the written files have the written gzip variants, e.g. app.js.gz.
+	for i := range fsys {
+		if fsys[i].meta == nil {
+			continue
+		}
+		meta := *fsys[i].meta
+		meta.CompressedSize = -1
+		if j, ok := fsys.lookup(fsys[i].Path + ".gz"); o.assets.gzipEncoded && ok && !fsys[j].FileMode.IsDir() {
+			meta.CompressedSize = fsys[j].Size()
+		}
+		fsys[i].meta = &meta
+	}
+	o.fs = fsys
+}
+
+// Option is an option of Handler and Mount.
+type Option func(*handler)
+
+// Handler returns the handler that serves the files in fsys.
+// It serves GET and HEAD requests, and responds to OPTIONS requests with the Allow header.
+func (fsys *FileSystem) Handler(opts ...Option) http.Handler {
+	return fsys.newHandler(fsys, opts)
+}
+
+// Composite returns the handler that serves the file systems under the prefixes,
+// e.g. {"/docs": docs.Root, "/static": static.Root}, with the same options,
+// so the file systems of several generated packages are served with the same headers and 404 responses.
+// The longest prefix that matches the request path is used, and the prefix "/" matches all paths.
+// The handler is of fsys, so the options added by Use and SetAccessLog of fsys apply to it.
+func (fsys *FileSystem) Composite(roots map[string]http.FileSystem, opts ...Option) http.Handler {
+	composite := compositeFileSystem{roots: map[string]http.FileSystem{}}
+	for prefix, root := range roots {
+		prefix = "/" + strings.Trim(prefix, "/")
+		composite.roots[prefix] = root
+		composite.prefixes = append(composite.prefixes, prefix)
+	}
+	sort.Slice(composite.prefixes, func(i, j int) bool {
+		return len(composite.prefixes[i]) > len(composite.prefixes[j])
+	})
+	return fsys.newHandler(composite, opts)
+}
+
+// Use adds the options applied to all handlers of fsys before their options, e.g. the counters of expvar of the adapter.
+// It is not guarded by a lock, so call it before creating the handlers, e.g. in init.
+func (fsys *FileSystem) Use(opts ...Option) {
+	fsys.defaultOptions = append(fsys.defaultOptions, opts...)
+}
+
+// newHandler returns the handler of fsys that serves the files in root.
+func (fsys *FileSystem) newHandler(root http.FileSystem, opts []Option) http.Handler {
+	h := &handler{
+		assets: fsys,
+		fs:     root,
+	}
+	for _, opt := range fsys.defaultOptions {
+		opt(h)
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	var ret http.Handler = h
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		ret = h.middlewares[i](ret)
+	}
+	return ret
+}
+
+// Mount registers the handler of the files in fsys at prefix of mux.
+// The prefix is stripped from the request path,
+// and the request to prefix without the trailing slash is redirected to prefix + "/".
+func (fsys *FileSystem) Mount(mux *http.ServeMux, prefix string, opts ...Option) {
+	h := fsys.Handler(opts...)
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		mux.Handle("/", h)
+		return
+	}
+	prefix = "/" + prefix
+	// ServeMux redirects prefix to prefix + "/", because prefix itself is not registered.
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, h))
+}
+
+// CleanURLs serves the HTML files without the extension, e.g. /about serves /about.html.
+// The requests to the HTML files with the extension are redirected to the clean URLs.
+func CleanURLs() Option {
+	return func(h *handler) {
+		h.cleanURLs = true
+	}
+}
+
+// SlashPolicy is the policy of the trailing slashes of the URLs.
+type SlashPolicy int
+
+const (
+	// SlashDefault is the policy of http.FileServer.
+	// The URLs of the directories end with a slash, and the URLs of the files don't.
+	SlashDefault SlashPolicy = iota
+
+	// SlashAdd redirects the URLs of the files to the URLs with a trailing slash, e.g. /about to /about/.
+	SlashAdd
+
+	// SlashStrip redirects the URLs of the directories to the URLs without a trailing slash, e.g. /docs/ to /docs.
+	// The directories without index.html keep the trailing slash.
+	SlashStrip
+)
+
+// TrailingSlash sets the policy of the trailing slashes of the URLs.
+func TrailingSlash(policy SlashPolicy) Option {
+	return func(h *handler) {
+		h.trailingSlash = policy
+	}
+}
+
+// Languages serves the per-language subtrees, e.g. /en/ and /ja/, with the content negotiation.
+// The request to the path out of the subtrees, e.g. /help.html, is served from the subtree of
+// the best language for the Accept-Language header, e.g. /ja/help.html.
+// If no languages match, the subtree of defaultLang is served.
+func Languages(defaultLang string, langs ...string) Option {
+	return func(h *handler) {
+		h.defaultLang = defaultLang
+		h.langs = append([]string{defaultLang}, langs...)
+	}
+}
+
+// PreloadHeaders adds the Link headers that preload the critical CSS and JavaScript to the responses of the HTML files.
+// The package must be generated with the -preload option to find the critical resources.
+func PreloadHeaders() Option {
+	return func(h *handler) {
+		h.preload = true
+	}
+}
+
+// PreloadLinks returns the values of the Link headers that preload the critical resources of the HTML file name,
+// e.g. "</css/app.css>; rel=preload; as=style", to send them from other handlers, e.g. in 103 Early Hints.
+// The resources are found from the HTML at generation time with the -preload option,
+// so it returns nil if the option is not set or the file has no critical resources.
+// The returned slice must not be modified.
+func (fsys *FileSystem) PreloadLinks(name string) []string {
+	i, ok := fsys.files.lookup(name)
+	if !ok {
+		return nil
+	}
+	return fsys.files[i].Links
+}
+
+// EarlyHints sends the 103 Early Hints responses with the Link headers of PreloadHeaders before the responses.
+// It requires Go 1.19 or later.
+func EarlyHints() Option {
+	return func(h *handler) {
+		h.preload = true
+		h.earlyHints = true
+	}
+}
+
+// CORSConfig is the configuration of CORS, Cross-Origin Resource Sharing.
+type CORSConfig struct {
+	// Origins is the allowed origins, e.g. "https://example.com", or "*" to allow any origins.
+	Origins []string
+
+	// AllowHeaders is the request headers allowed in the preflight responses.
+	AllowHeaders []string
+
+	// ExposeHeaders is the response headers exposed to the clients.
+	ExposeHeaders []string
+
+	// MaxAge is how long the results of the preflight requests can be cached.
+	// If it is zero, the Access-Control-Max-Age header is not sent.
+	MaxAge time.Duration
+}
+
+// CORS adds the CORS headers to the responses of the files that match pattern,
+// and responds to the preflight requests.
+// The pattern is the syntax of path.Match, e.g. "/fonts/*.woff2",
+// and the pattern that ends with "/**" matches all files in the directory, e.g. "/api/**".
+// If more than one pattern matches, the first one is used.
+func CORS(pattern string, config CORSConfig) Option {
+	return func(h *handler) {
+		h.cors = append(h.cors, corsRule{
+			pattern: pattern,
+			config:  config,
+		})
+	}
+}
+
+type corsRule struct {
+	pattern string
+	config  CORSConfig
+}
+
+// match reports whether the rule applies to the file name.
+func (rule *corsRule) match(name string) bool {
+	return matchPattern(rule.pattern, name)
+}
+
+// matchPattern reports whether the file name matches pattern.
+// The pattern is the syntax of path.Match, and the pattern that ends with "/**" matches all files in the directory.
+func matchPattern(pattern, name string) bool {
+	if dir := strings.TrimSuffix(pattern, "/**"); dir != pattern {
+		return dir == "" || name == dir || strings.HasPrefix(name, dir+"/")
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// Authorizer reports whether the request to the file name is allowed.
+type Authorizer func(r *http.Request, name string) bool
+
+// Authorize protects the files that match pattern with authorizer.
+// The requests that are not allowed are responded with 403 Forbidden.
+// The pattern is the same syntax as CORS.
+// If more than one pattern matches, all of them must allow the request.
+// The pattern is matched against the request path, and against the file to serve after the rewrites
+// of CleanURLs, TrailingSlash and Languages, e.g. /about.html of /about and /docs/index.html of /docs/,
+// so the rewritten URLs don't bypass the rules.
+func Authorize(pattern string, authorizer Authorizer) Option {
+	return func(h *handler) {
+		h.auth = append(h.auth, authRule{
+			pattern:    pattern,
+			authorizer: authorizer,
+		})
+	}
+}
+
+// BasicAuth protects the files that match pattern with the HTTP basic authentication.
+// The users is the map from the user names to the passwords.
+// The requests without the valid credentials are responded with 401 Unauthorized.
+func BasicAuth(pattern, realm string, users map[string]string) Option {
+	hashes := make(map[string][sha256.Size]byte, len(users))
+	for user, password := range users {
+		hashes[user] = sha256.Sum256([]byte(password))
+	}
+	authorizer := func(r *http.Request, name string) bool {
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			return false
+		}
+		want, ok := hashes[user]
+		got := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare(want[:], got[:]) == 1 && ok
+	}
+	return func(h *handler) {
+		h.auth = append(h.auth, authRule{
+			pattern:    pattern,
+			authorizer: authorizer,
+			realm:      realm,
+		})
+	}
+}
+
+type authRule struct {
+	pattern    string
+	authorizer Authorizer
+	realm      string // the realm of the basic authentication, or empty
+}
+
+// MetricsRecorder records the metrics of the requests.
+type MetricsRecorder interface {
+	// RecordRequest records a request to the file name with the status code,
+	// the number of the bytes of the response body and the latency.
+	// The name is the embedded file served after the rewrites, e.g. /docs/index.html of /docs/,
+	// or empty if the request is not served by a file, e.g. 404, 401 and the redirects, so the names are bounded.
+	RecordRequest(name string, status int, bytes int64, latency time.Duration)
+}
+
+// Metrics reports the metrics of each request to recorder.
+// Generate the package with -adapters prometheus for the collector of Prometheus.
+func Metrics(recorder MetricsRecorder) Option {
+	return func(h *handler) {
+		h.metrics = recorder
+	}
+}
+
+// Middleware wraps the handler with mw, e.g. to trace the requests in the adapters.
+// The middlewares wrap the handler in the order of the options, so the first one is the outermost.
+func Middleware(mw func(http.Handler) http.Handler) Option {
+	return func(h *handler) {
+		h.middlewares = append(h.middlewares, mw)
+	}
+}
+
+// AccessLogEntry is an entry of the access logs.
+type AccessLogEntry struct {
+	// Time is when the request is received.
+	Time time.Time
+
+	// RemoteAddr is the network address of the client.
+	RemoteAddr string
+
+	// User is the user name of the basic authentication, or empty.
+	User string
+
+	// Method is the HTTP method.
+	Method string
+
+	// RequestURI is the request URI sent by the client.
+	RequestURI string
+
+	// Path is the path of the file, relative to the handler.
+	Path string
+
+	// Proto is the protocol version, e.g. "HTTP/1.1".
+	Proto string
+
+	// Status is the status code of the response.
+	Status int
+
+	// Bytes is the number of the bytes of the response body.
+	Bytes int64
+
+	// Duration is the latency of the response.
+	Duration time.Duration
+
+	// Referer is the Referer header.
+	Referer string
+
+	// UserAgent is the User-Agent header.
+	UserAgent string
+}
+
+// SetAccessLog enables or disables the access logs of all handlers of fsys at runtime.
+// They are enabled by default.
+func (fsys *FileSystem) SetAccessLog(enabled bool) {
+	var v int32
+	if !enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&fsys.accessLogDisabled, v)
+}
+
+// AccessLog writes the access logs in Common Log Format to w.
+func AccessLog(w io.Writer) Option {
+	var mu sync.Mutex
+	return AccessLogFunc(func(e *AccessLogEntry) {
+		host, _, err := net.SplitHostPort(e.RemoteAddr)
+		if err != nil {
+			host = e.RemoteAddr
+		}
+		user := e.User
+		if user == "" {
+			user = "-"
+		}
+		bytes := "-"
+		if e.Bytes > 0 {
+			bytes = strconv.FormatInt(e.Bytes, 10)
+		}
+		line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s\n",
+			host, user, e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.Method, e.RequestURI, e.Proto, e.Status, bytes)
+
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, line)
+	})
+}
+
+// AccessLogFunc calls fn with the access log entry of each request, e.g. to write structured logs.
+func AccessLogFunc(fn func(e *AccessLogEntry)) Option {
+	return func(h *handler) {
+		h.middlewares = append(h.middlewares, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.LoadInt32(&h.assets.accessLogDisabled) != 0 {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				start := time.Now()
+				mw := &metricsWriter{ResponseWriter: w}
+				next.ServeHTTP(mw, r)
+
+				status := mw.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				user, _, _ := r.BasicAuth()
+				fn(&AccessLogEntry{
+					Time:       start,
+					RemoteAddr: r.RemoteAddr,
+					User:       user,
+					Method:     r.Method,
+					RequestURI: r.RequestURI,
+					Path:       path.Clean("/" + r.URL.Path),
+					Proto:      r.Proto,
+					Status:     status,
+					Bytes:      mw.bytes,
+					Duration:   time.Since(start),
+					Referer:    r.Referer(),
+					UserAgent:  r.UserAgent(),
+				})
+			})
+		})
+	}
+}
+
+// ThrottleConfig is the configuration of the throttle.
+// The zero values mean unlimited.
+type ThrottleConfig struct {
+	// BytesPerSecond is the bandwidth shared by all clients.
+	BytesPerSecond int64
+
+	// BytesPerSecondPerIP is the bandwidth of each client IP address.
+	BytesPerSecondPerIP int64
+
+	// RequestsPerSecondPerIP is the rate of the requests of each client IP address.
+	// The requests over the rate are responded with 429 Too Many Requests.
+	RequestsPerSecondPerIP float64
+
+	// RequestBurstPerIP is the number of the requests allowed at once over RequestsPerSecondPerIP.
+	// If it is zero, RequestsPerSecondPerIP rounded up is used.
+	RequestBurstPerIP int
+}
+
+// Throttle limits the bandwidth and the rate of the requests with token buckets,
+// so that large downloads don't starve the other handlers.
+// The client IP address is the host of http.Request.RemoteAddr.
+// The bursts of the bandwidths are the bytes of a second.
+func Throttle(config ThrottleConfig) Option {
+	t := &throttle{
+		config:   config,
+		bytes:    map[string]*tokenBucket{},
+		requests: map[string]*tokenBucket{},
+	}
+	if config.BytesPerSecond > 0 {
+		t.global = newTokenBucket(float64(config.BytesPerSecond), float64(config.BytesPerSecond))
+	}
+	return func(h *handler) {
+		h.middlewares = append(h.middlewares, t.middleware)
+	}
+}
+
+type throttle struct {
+	config ThrottleConfig
+	global *tokenBucket
+
+	mu        sync.Mutex
+	bytes     map[string]*tokenBucket
+	requests  map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func (t *throttle) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		bytes, requests := t.buckets(ip)
+		if requests != nil && !requests.allow() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(1/t.config.RequestsPerSecondPerIP))))
+			http.Error(w, "429 too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		tw := &throttleWriter{
+			ResponseWriter: w,
+			ctx:            r.Context(),
+		}
+		if t.global != nil {
+			tw.buckets = append(tw.buckets, t.global)
+		}
+		if bytes != nil {
+			tw.buckets = append(tw.buckets, bytes)
+		}
+		if len(tw.buckets) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(tw, r)
+	})
+}
+
+// buckets returns the token buckets of the bytes and the requests of ip.
+// They are nil if there are no limits.
+func (t *throttle) buckets(ip string) (bytes, requests *tokenBucket) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// remove the buckets of the inactive clients.
+	// they are full, so removing them doesn't change the limits.
+	now := time.Now()
+	if now.Sub(t.lastSweep) > time.Minute {
+		t.lastSweep = now
+		for ip, b := range t.bytes {
+			if b.full(now) {
+				delete(t.bytes, ip)
+			}
+		}
+		for ip, b := range t.requests {
+			if b.full(now) {
+				delete(t.requests, ip)
+			}
+		}
+	}
+
+	if rate := t.config.BytesPerSecondPerIP; rate > 0 {
+		bytes = t.bytes[ip]
+		if bytes == nil {
+			bytes = newTokenBucket(float64(rate), float64(rate))
+			t.bytes[ip] = bytes
+		}
+	}
+	if rate := t.config.RequestsPerSecondPerIP; rate > 0 {
+		requests = t.requests[ip]
+		if requests == nil {
+			burst := float64(t.config.RequestBurstPerIP)
+			if burst <= 0 {
+				burst = math.Ceil(rate)
+			}
+			requests = newTokenBucket(rate, burst)
+			t.requests[ip] = requests
+		}
+	}
+	return
+}
+
+// tokenBucket is a token bucket.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // the tokens added per second
+	burst  float64 // the capacity of the bucket
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// advance adds the tokens since the last update. b.mu must be held.
+func (b *tokenBucket) advance(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// full reports whether the bucket is full.
+func (b *tokenBucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	return b.tokens >= b.burst
+}
+
+// allow takes a token if available.
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// reserve takes n tokens, and returns how long to wait until they are available.
+func (b *tokenBucket) reserve(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// throttleChunkSize is the maximum size of a write of throttleWriter.
+const throttleChunkSize = 16 * 1024
+
+// throttleWriter is the http.ResponseWriter that limits the bandwidth.
+type throttleWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	buckets []*tokenBucket
+}
+
+func (w *throttleWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		n := len(p)
+		if n > throttleChunkSize {
+			n = throttleChunkSize
+		}
+		var wait time.Duration
+		for _, b := range w.buckets {
+			if d := b.reserve(n); d > wait {
+				wait = d
+			}
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-w.ctx.Done():
+				timer.Stop()
+				return written, w.ctx.Err()
+			}
+		}
+		m, err := w.ResponseWriter.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// metricsWriter is the http.ResponseWriter that records the status code and the number of the bytes.
+type metricsWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *metricsWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		// ignore the informational responses, e.g. 103 Early Hints.
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *metricsWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// NoncePlaceholder is the placeholder of the nonce in the HTML files, e.g. <script nonce="__CSP_NONCE__">.
+// It is replaced with a new nonce for each request if the CSPNonce option is set.
+const NoncePlaceholder = "__CSP_NONCE__"
+
+// ContentAddressable serves the embedded files by the hashes of their contents by HashAlgorithm under /_cas/, e.g. /_cas/3f9a...,
+// with the immutable Cache-Control header, because the content of a hash never changes.
+// The paths are returned by ContentPath.
+// It panics if HashAlgorithm is crc32 or sha1, whose hashes can be forged, so a client could cache another content forever.
+func (fsys *FileSystem) ContentAddressable() Option {
+	if !fsys.secureHash() {
+		panic(ErrInsecureHash)
+	}
+	return func(h *handler) {
+		h.cas = fsys
+	}
+}
+
+// NegotiateImages serves the alternates of the images in the formats that the Accept header lists,
+// e.g. /img/photo.jpg.avif or /img/photo.jpg.webp for /img/photo.jpg.
+// The alternates are generated by the image rules of the configuration file.
+func NegotiateImages() Option {
+	return func(h *handler) {
+		h.negotiateImgs = true
+	}
+}
+
+// ListingEntry is an entry of the directory listing.
+// The struct tags are double-quoted, because the generator keeps the template in a raw string.
+type ListingEntry struct {
+	Name    string    "json:\"name\""
+	Size    int64     "json:\"size\""
+	ModTime time.Time "json:\"mtime\""
+
+	// Type is "file" or "dir".
+	Type string "json:\"type\""
+}
+
+// Listing is the data passed to the template of ListingTemplate.
+type Listing struct {
+	// Path is the path of the directory, e.g. "/docs".
+	Path string
+
+	// Entries is the entries of the directory sorted by name.
+	Entries []ListingEntry
+}
+
+// Template is the template of the directory listings, e.g. *html/template.Template.
+type Template interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// ListingTemplate renders the listings of the directories without index.html with tmpl,
+// instead of the plain listings of http.FileServer.
+// The template receives *Listing.
+func ListingTemplate(tmpl Template) Option {
+	return func(h *handler) {
+		h.listingTmpl = tmpl
+	}
+}
+
+// JSONListing serves the directory listings as JSON arrays of ListingEntry for the requests with "?format=json",
+// e.g. GET /themes/?format=json, so the frontend can enumerate the files.
+// The listing is served even if the directory has index.html.
+func JSONListing() Option {
+	return func(h *handler) {
+		h.jsonListing = true
+	}
+}
+
+// CSPNonce adds the Content-Security-Policy header to the responses of the HTML files.
+// A new nonce is generated for each response, and it replaces "{nonce}" in policy,
+// e.g. "script-src 'nonce-{nonce}'", and NoncePlaceholder in the HTML files.
+func CSPNonce(policy string) Option {
+	return func(h *handler) {
+		h.cspPolicy = policy
+	}
+}
+
+// DebugInfo is the inventory of the embedded files that the handler with the Debug option serves in JSON.
+type DebugInfo struct {
+	// Variant is the name of the embedded variant, or empty.
+	Variant string "json:\"variant\""
+
+	// APIVersion is APIVersion of the package.
+	APIVersion int "json:\"apiVersion\""
+
+	// SourceHash is the hash of the embedded files that SourceHash returns.
+	SourceHash string "json:\"sourceHash\""
+
+	// Signed reports whether the package is generated with the -sign-key option.
+	Signed bool "json:\"signed\""
+
+	// BundleHash is the hash of the manifest of the bundle loaded by LoadBundle by HashAlgorithm in hex, or empty.
+	BundleHash string "json:\"bundleHash,omitempty\""
+
+	// TotalBytes is the total size of the files.
+	TotalBytes int64 "json:\"totalBytes\""
+
+	// Files is the embedded files sorted by name, excluding the directories.
+	Files []DebugFile "json:\"files\""
+}
+
+// DebugFile is an embedded file in DebugInfo.
+type DebugFile struct {
+	Name string "json:\"name\""
+	Size int64  "json:\"size\""
+
+	// Hash is the hash of the content by HashAlgorithm in hex.
+	Hash string "json:\"hash\""
+}
+
+// Debug serves DebugInfo in JSON at urlPath relative to the handler, e.g. "/_assets/debug",
+// to verify which build of the files a running instance serves.
+// The inventory is served only to the requests that allow reports true, e.g. from the internal network,
+// and the other requests are responded with 404 Not Found as if the path did not exist.
+// If urlPath is empty, "/_assets/debug" is used.
+func Debug(urlPath string, allow func(r *http.Request) bool) Option {
+	if urlPath == "" {
+		urlPath = "/_assets/debug"
+	}
+	return func(h *handler) {
+		h.debugPath = path.Clean("/" + urlPath)
+		h.debugAllow = allow
+	}
+}
+
+// debugInfo returns the inventory of the embedded files.
+func (fsys *FileSystem) debugInfo() *DebugInfo {
+	info := &DebugInfo{
+		Variant:    fsys.variant,
+		APIVersion: APIVersion,
+		SourceHash: fsys.SourceHash(),
+		Signed:     fsys.signature != "",
+		Files:      []DebugFile{},
+	}
+	if t := fsys.loadedBundle(); len(t) > 0 {
+		info.BundleHash = fsys.hashHex([]byte(t.manifest()))
+	}
+	for i := range fsys.files {
+		f := &fsys.files[i]
+		if f.FileMode.IsDir() {
+			continue
+		}
+		info.TotalBytes += f.Size()
+		info.Files = append(info.Files, DebugFile{
+			Name: f.Path,
+			Size: f.Size(),
+			Hash: fsys.contentHashes[f.Path],
+		})
+	}
+	return info
+}
+
+// serveDebug serves the inventory of the Debug option, or 404 Not Found if the request is not allowed.
+func (h *handler) serveDebug(w http.ResponseWriter, r *http.Request) {
+	if h.debugAllow == nil || !h.debugAllow(r) {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	b, err := json.Marshal(h.assets.debugInfo())
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(append(b, '\n')))
+}
+
+type handler struct {
+	assets        *FileSystem // the file system that created the handler
+	fs            http.FileSystem
+	cleanURLs     bool
+	trailingSlash SlashPolicy
+	defaultLang   string
+	langs         []string
+	preload       bool
+	earlyHints    bool
+	cspPolicy     string
+	cors          []corsRule
+	auth          []authRule
+	metrics       MetricsRecorder
+	jsonListing   bool
+	listingTmpl   Template
+	negotiateImgs bool
+	cas           *FileSystem
+	debugPath     string
+	debugAllow    func(r *http.Request) bool
+
+	// middlewares wrap the handler, the first one is the outermost.
+	// They are added by Middleware and the options that wrap the handler, e.g. AccessLog.
+	middlewares []func(http.Handler) http.Handler
+}
+
+// allowedMethods is the value of the Allow header of the handler.
+const allowedMethods = "GET, HEAD, OPTIONS"
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.metrics != nil {
+		mw := &metricsWriter{ResponseWriter: w}
+		start := time.Now()
+		served := new(string)
+		r = r.WithContext(context.WithValue(r.Context(), servedKey{}, served))
+		defer func() {
+			status := mw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			name := *served
+			if status >= 300 && status != http.StatusNotModified {
+				// the redirects and the errors, e.g. 404 and 401, are not the responses of the files.
+				name = ""
+			}
+			h.metrics.RecordRequest(name, status, mw.bytes, time.Since(start))
+		}()
+		w = mw
+	}
+	if len(h.cors) > 0 && h.serveCORS(w, r) {
+		return
+	}
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", allowedMethods)
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Method == http.MethodHead {
+		// the responses set Content-Length, and the bodies written by the error pages are discarded.
+		w = headWriter{w}
+	}
+	if !validURLPath(r.URL.Path) {
+		// reject the paths that escape the directory before path.Clean hides them.
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if h.debugPath != "" && path.Clean("/"+r.URL.Path) == h.debugPath {
+		h.serveDebug(w, r)
+		return
+	}
+	if len(h.auth) > 0 && h.serveAuth(w, r, path.Clean("/"+r.URL.Path)) {
+		return
+	}
+	if len(h.langs) > 0 {
+		r = h.localize(w, r)
+	}
+	if h.trailingSlash != SlashDefault && h.serveTrailingSlash(w, r) {
+		return
+	}
+	if h.cleanURLs && h.serveCleanURL(w, r) {
+		return
+	}
+	h.serve(w, r)
+}
+
+// serveAuth responds to the requests to the names that are not authorized.
+// It returns true if the request is handled.
+func (h *handler) serveAuth(w http.ResponseWriter, r *http.Request, names ...string) bool {
+	for _, rule := range h.auth {
+		for _, name := range names {
+			if !matchPattern(rule.pattern, name) || rule.authorizer(r, name) {
+				continue
+			}
+			if rule.realm != "" {
+				w.Header().Set("WWW-Authenticate", "Basic realm="+strconv.Quote(rule.realm)+", charset=\"UTF-8\"")
+				http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+				return true
+			}
+			http.Error(w, "403 forbidden", http.StatusForbidden)
+			return true
+		}
+	}
+	return false
+}
+
+// authNames returns the names that the rules of the authorization match for the request path upath
+// after the rewrites: the clean path and index.html that the file server serves for the directory.
+func (h *handler) authNames(ctx context.Context, upath string) []string {
+	name := path.Clean("/" + upath)
+	if fi, ok := h.stat(ctx, name); ok && fi.IsDir() {
+		if index := path.Join(name, "index.html"); h.exists(ctx, index) {
+			return []string{name, index}
+		}
+	}
+	return []string{name}
+}
+
+// serveCORS adds the CORS headers, and responds to the preflight request.
+// It returns true if the request is handled.
+func (h *handler) serveCORS(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	name := path.Clean("/" + r.URL.Path)
+	var config *CORSConfig
+	for i := range h.cors {
+		if h.cors[i].match(name) {
+			config = &h.cors[i].config
+			break
+		}
+	}
+	if config == nil {
+		return false
+	}
+
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	allowed := ""
+	for _, o := range config.Origins {
+		if o == "*" {
+			allowed = "*"
+			break
+		}
+		if o == origin {
+			allowed = origin
+			break
+		}
+	}
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	if allowed == "" {
+		// the browser blocks the response because it doesn't have Access-Control-Allow-Origin.
+		return false
+	}
+	header.Set("Access-Control-Allow-Origin", allowed)
+	if !preflight {
+		if len(config.ExposeHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(config.ExposeHeaders, ", "))
+		}
+		return false
+	}
+
+	header.Set("Access-Control-Allow-Methods", "GET, HEAD")
+	if len(config.AllowHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(config.AllowHeaders, ", "))
+	}
+	if config.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge/time.Second)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// localize rewrites the request path to the subtree of the best language.
+func (h *handler) localize(w http.ResponseWriter, r *http.Request) *http.Request {
+	upath := r.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+	}
+	elems := strings.SplitN(path.Clean(upath), "/", 3)
+	for _, lang := range h.langs {
+		if strings.EqualFold(elems[1], lang) {
+			// it is already in the subtree.
+			return r
+		}
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	for _, lang := range []string{h.negotiate(r.Header.Get("Accept-Language")), h.defaultLang} {
+		localized := "/" + lang + upath
+		name := path.Clean(localized)
+		if !h.exists(r.Context(), name) && !(h.cleanURLs && h.exists(r.Context(), name+".html")) {
+			continue
+		}
+		w.Header().Set("Content-Language", lang)
+		r = r.Clone(r.Context())
+		r.URL.Path = localized
+		r.URL.RawPath = ""
+		return r
+	}
+	return r
+}
+
+// negotiate returns the best language for the Accept-Language header,
+// or the default language if no languages match.
+func (h *handler) negotiate(header string) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	var tags []tag
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		t := tag{name: v, q: 1}
+		if idx := strings.IndexByte(v, ';'); idx >= 0 {
+			t.name = strings.TrimSpace(v[:idx])
+			param := strings.TrimSpace(v[idx+1:])
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[len("q="):], 64)
+				if err != nil {
+					continue
+				}
+				t.q = q
+			}
+		}
+		if t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if t.name == "*" {
+			return h.defaultLang
+		}
+		// prefer the exact match to the match of the primary language, e.g. "en-US" to "en".
+		for _, lang := range h.langs {
+			if strings.EqualFold(t.name, lang) {
+				return lang
+			}
+		}
+		primary := strings.SplitN(t.name, "-", 2)[0]
+		for _, lang := range h.langs {
+			if strings.EqualFold(primary, strings.SplitN(lang, "-", 2)[0]) {
+				return lang
+			}
+		}
+	}
+	return h.defaultLang
+}
+
+// serveTrailingSlash serves the request with the trailing slash policy.
+// It returns false if the request is left to the file server.
+func (h *handler) serveTrailingSlash(w http.ResponseWriter, r *http.Request) bool {
+	name := path.Clean("/" + r.URL.Path)
+	if name == "/" {
+		return false
+	}
+	slash := strings.HasSuffix(r.URL.Path, "/")
+
+	// find the file to serve.
+	target := name
+	fi, ok := h.stat(r.Context(), target)
+	if !ok && h.cleanURLs {
+		target = name + ".html"
+		fi, ok = h.stat(r.Context(), target)
+	}
+	if !ok {
+		return false
+	}
+	if fi.IsDir() {
+		index := path.Join(target, "index.html")
+		if _, ok := h.stat(r.Context(), index); !ok || h.trailingSlash != SlashStrip {
+			// the file server redirects it to the URL with the trailing slash.
+			return false
+		}
+		target = index
+	}
+
+	switch {
+	case h.trailingSlash == SlashAdd && !slash:
+		localRedirect(w, r, path.Base(name)+"/")
+	case h.trailingSlash == SlashStrip && slash:
+		localRedirect(w, r, "../"+path.Base(name))
+	default:
+		if len(h.auth) > 0 && h.serveAuth(w, r, name, target) {
+			return true
+		}
+		h.serveFile(w, r, target)
+	}
+	return true
+}
+
+// serveCleanURL serves the request in the clean URL mode.
+// It returns false if the request is left to the file server.
+func (h *handler) serveCleanURL(w http.ResponseWriter, r *http.Request) bool {
+	upath := r.URL.Path
+	if strings.HasSuffix(upath, "/") {
+		// the file server serves index.html of the directory.
+		return false
+	}
+	name := path.Clean("/" + upath)
+	if h.exists(r.Context(), name) {
+		clean := strings.TrimSuffix(name, ".html")
+		if clean == name || path.Base(name) == "index.html" || path.Base(clean) == "" || h.exists(r.Context(), clean) {
+			return false
+		}
+		localRedirect(w, r, path.Base(clean))
+		return true
+	}
+	if !h.exists(r.Context(), name+".html") {
+		return false
+	}
+	r = r.Clone(r.Context())
+	r.URL.Path = name + ".html"
+	r.URL.RawPath = ""
+	h.serve(w, r)
+	return true
+}
+
+// exists reports whether the file or the directory name exists.
+func (h *handler) exists(ctx context.Context, name string) bool {
+	_, ok := h.stat(ctx, name)
+	return ok
+}
+
+// stat returns the file info of the file or the directory name.
+func (h *handler) stat(ctx context.Context, name string) (fs.FileInfo, bool) {
+	f, err := h.open(ctx, name)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, false
+	}
+	return fi, true
+}
+
+// serve serves the request with the file server.
+func (h *handler) serve(w http.ResponseWriter, r *http.Request) {
+	if h.cas != nil && strings.HasPrefix(r.URL.Path, "/_cas/") {
+		h.serveCAS(w, r, r.URL.Path[len("/_cas/"):])
+		return
+	}
+	if len(h.auth) > 0 && h.serveAuth(w, r, h.authNames(r.Context(), r.URL.Path)...) {
+		// the rules match the file to serve after the rewrites, e.g. /about.html of /about with CleanURLs.
+		return
+	}
+	if h.jsonListing && strings.HasSuffix(r.URL.Path, "/") && r.URL.Query().Get("format") == "json" {
+		h.serveJSONListing(w, r, path.Clean("/"+r.URL.Path))
+		return
+	}
+	if h.assets.gzipEncoded {
+		if name := h.gzipVariant(w, r); name != "" {
+			h.serveGzip(w, r, name)
+			return
+		}
+	}
+	if h.negotiateImgs {
+		if name, typ := h.imageAlternate(w, r); name != "" {
+			w.Header().Set("Content-Type", typ)
+			h.serveFile(w, r, name)
+			return
+		}
+	}
+	if h.listingTmpl != nil && strings.HasSuffix(r.URL.Path, "/") {
+		name := path.Clean("/" + r.URL.Path)
+		if _, ok := h.stat(r.Context(), path.Join(name, "index.html")); !ok {
+			if entries, ok := h.listing(r.Context(), name); ok {
+				h.serveListing(w, r, name, entries)
+				return
+			}
+		}
+	}
+	if h.preload || h.cspPolicy != "" {
+		if name := h.target(r.Context(), r.URL.Path); name != "" {
+			h.serveFile(w, r, name)
+			return
+		}
+	}
+	http.FileServer(contextFileSystem{ctx: r.Context(), h: h, header: w.Header()}).ServeHTTP(directWriter{w}, r)
+}
+
+// target returns the name of the file that the file server serves for upath without the redirects,
+// or empty if it is not a file.
+func (h *handler) target(ctx context.Context, upath string) string {
+	name := path.Clean("/" + upath)
+	switch {
+	case strings.HasSuffix(upath, "/"):
+		name = path.Join(name, "index.html")
+	case path.Base(name) == "index.html":
+		// the file server redirects it to the directory.
+		return ""
+	}
+	if fi, ok := h.stat(ctx, name); !ok || fi.IsDir() {
+		return ""
+	}
+	return name
+}
+
+// addPreload adds the Link headers of the file name.
+func (h *handler) addPreload(ctx context.Context, w http.ResponseWriter, name string) {
+	f, err := h.open(ctx, name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	hf, ok := f.(*httpFile)
+	if !ok || len(hf.file.Links) == 0 {
+		return
+	}
+	for _, link := range hf.file.Links {
+		w.Header().Add("Link", link)
+	}
+	if h.earlyHints {
+		w.WriteHeader(http.StatusEarlyHints)
+	}
+}
+
+// imageAlternates is the formats of the alternates of the images, in the order of the preference.
+var imageAlternates = []struct {
+	ext string
+	typ string
+}{
+	{".avif", "image/avif"},
+	{".webp", "image/webp"},
+}
+
+// imageAlternate returns the name and the content type of the alternate of the requested image
+// in the format that the Accept header lists, or empty if the client accepts none of them.
+// The response of an image varies by the Accept header even if the alternate is not found.
+func (h *handler) imageAlternate(w http.ResponseWriter, r *http.Request) (string, string) {
+	name := path.Clean("/" + r.URL.Path)
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+	default:
+		return "", ""
+	}
+	w.Header().Add("Vary", "Accept")
+	accept := r.Header.Get("Accept")
+	for _, alt := range imageAlternates {
+		if !acceptsType(accept, alt.typ) {
+			continue
+		}
+		if fi, ok := h.stat(r.Context(), name+alt.ext); ok && !fi.IsDir() {
+			return name + alt.ext, alt.typ
+		}
+	}
+	return "", ""
+}
+
+// serveCAS serves the embedded file whose content has the hash.
+func (h *handler) serveCAS(w http.ResponseWriter, r *http.Request, hash string) {
+	name, ok := h.cas.hashNames[strings.ToLower(hash)]
+	if !ok {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if len(h.auth) > 0 && h.serveAuth(w, r, name) {
+		// the hash doesn't bypass the rules of the file.
+		return
+	}
+	f, err := h.cas.files.open(name, true)
+	if err != nil {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	setServed(r.Context(), name)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", strconv.Quote(strings.ToLower(hash)))
+	http.ServeContent(directWriter{w}, r, fi.Name(), fi.ModTime(), f)
+}
+
+// gzipVariant returns the name of the requested file that has the gzip variant, e.g. "/app.js" of "/app.js.gz",
+// or empty if the client does not accept gzip.
+// The response varies by the Accept-Encoding header if the variant is found.
+// The HTML files are not served pre-compressed with the nonces of CSP, because the nonces are inserted into them.
+func (h *handler) gzipVariant(w http.ResponseWriter, r *http.Request) string {
+	name := h.target(r.Context(), r.URL.Path)
+	if name == "" || h.cspPolicy != "" && (path.Ext(name) == ".html" || path.Ext(name) == ".htm") {
+		return ""
+	}
+	if fi, ok := h.stat(r.Context(), name+".gz"); !ok || fi.IsDir() {
+		return ""
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsType(r.Header.Get("Accept-Encoding"), "gzip") {
+		return ""
+	}
+	return name
+}
+
+// serveGzip serves the gzip variant of the file name with the content type of name.
+func (h *handler) serveGzip(w http.ResponseWriter, r *http.Request, name string) {
+	if h.preload {
+		h.addPreload(r.Context(), w, name)
+	}
+	typ := mime.TypeByExtension(path.Ext(name))
+	if typ == "" {
+		// sniff the content type from the decompressed file.
+		if f, err := h.open(r.Context(), name); err == nil {
+			var buf [512]byte
+			n, _ := io.ReadFull(f, buf[:])
+			typ = http.DetectContentType(buf[:n])
+			f.Close()
+		}
+	}
+	f, err := h.open(r.Context(), name+".gz")
+	if err != nil {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	setServed(r.Context(), name)
+	setETag(w.Header(), f)
+	w.Header().Set("Content-Type", typ)
+	w.Header().Set("Content-Encoding", "gzip")
+	http.ServeContent(directWriter{w}, r, fi.Name(), fi.ModTime(), f)
+}
+
+// acceptsType reports whether the Accept header lists the media type typ explicitly.
+// It also reports whether the Accept-Encoding header lists the encoding typ.
+// The wildcards, e.g. "image/*", are ignored, because the clients may not support the new formats.
+func acceptsType(header, typ string) bool {
+	for _, v := range strings.Split(header, ",") {
+		params := strings.Split(v, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), typ) {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[len("q="):], 64); err != nil || q <= 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// serveJSONListing serves the entries of the directory name as a JSON array of ListingEntry.
+func (h *handler) serveJSONListing(w http.ResponseWriter, r *http.Request, name string) {
+	entries, ok := h.listing(r.Context(), name)
+	if !ok {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	setServed(r.Context(), name)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(append(b, '\n')))
+}
+
+// serveListing renders the listing of the directory name with the template of ListingTemplate.
+func (h *handler) serveListing(w http.ResponseWriter, r *http.Request, name string, entries []ListingEntry) {
+	var buf bytes.Buffer
+	if err := h.listingTmpl.Execute(&buf, &Listing{Path: name, Entries: entries}); err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	setServed(r.Context(), name)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
+
+// listing returns the entries of the directory name sorted by name, or false if it is not a directory.
+func (h *handler) listing(ctx context.Context, name string) ([]ListingEntry, bool) {
+	f, err := h.open(ctx, name)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	fis, err := f.Readdir(-1)
+	if err != nil {
+		return nil, false
+	}
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+	entries := make([]ListingEntry, 0, len(fis))
+	for _, fi := range fis {
+		entry := ListingEntry{
+			Name:    fi.Name(),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+			Type:    "file",
+		}
+		if fi.IsDir() {
+			entry.Size = 0
+			entry.Type = "dir"
+		}
+		entries = append(entries, entry)
+	}
+	return entries, true
+}
+
+// serveFile serves the file name without the redirects of the file server.
+func (h *handler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	if h.preload {
+		h.addPreload(r.Context(), w, name)
+	}
+	f, err := h.open(r.Context(), name)
+	if err != nil {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	setServed(r.Context(), name)
+	if h.cspPolicy != "" && (path.Ext(name) == ".html" || path.Ext(name) == ".htm") {
+		h.serveNonce(w, r, fi.Name(), f)
+		return
+	}
+	setETag(w.Header(), f)
+	http.ServeContent(directWriter{w}, r, fi.Name(), fi.ModTime(), f)
+}
+
+// open opens the file name with ctx.
+// The embedded files are pooled, because the handler and http.FileServer never use them after closing.
+func (h *handler) open(ctx context.Context, name string) (http.File, error) {
+	fsys, ok := h.fs.(*FileSystem)
+	if !ok {
+		return openContext(ctx, h.fs, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !validName(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return fsys.openShadowed(name, true)
+}
+
+// SetOpenHook sets the hook that intercepts opening the embedded files at runtime,
+// so the apps can shadow some files, e.g. by the feature flags or the A/B tests, without overlaying the whole file system.
+// Open and the handlers of fsys call hook with the name of the file before opening it, e.g. "/index.html",
+// and use the file that hook returns instead if it returns true.
+// The content-addressable store of ContentAddressable is not intercepted, because its files are identified by their contents.
+// It is safe to call SetOpenHook concurrently with opening the files. nil removes the hook.
+func (fsys *FileSystem) SetOpenHook(hook func(name string) (http.File, bool)) {
+	fsys.openHook.Store(hook)
+}
+
+// hookOpen opens the file name with the hook set by SetOpenHook.
+func (fsys *FileSystem) hookOpen(name string) (http.File, bool) {
+	hook, _ := fsys.openHook.Load().(func(name string) (http.File, bool))
+	if hook == nil {
+		return nil, false
+	}
+	return hook(name)
+}
+
+// bundleMagic is the header of the bundles, "ALB" and the version of the format.
+// The header is followed by the length of the signature in uvarint, the signature,
+// the length of the payload in uvarint and the payload, which is the gzip stream of the files, each of which is
+// the length of the name in uvarint, the name, the mode in uvarint, the length of the content in uvarint and the content,
+// and the length of the empty name that ends the files.
+// The signature is the Ed25519 signature of the payload, which covers the names, the modes and the contents of the files and the directories,
+// or empty if the bundle is not signed.
+const bundleMagic = "ALB\x02"
+
+// maxBundleSize is the maximum size in bytes of the payload of a bundle, and of the files in the payload after inflating.
+var maxBundleSize int64 = 1 << 30
+
+// SetBundleKey sets the Ed25519 public key that verifies the signatures of the bundles loaded by LoadBundle.
+func (fsys *FileSystem) SetBundleKey(pub ed25519.PublicKey) {
+	fsys.bundleKey.Store(pub)
+}
+
+// LoadBundle loads the files in the bundle read from r, which the bundle subcommand of assets-life writes,
+// and replaces the files loaded before atomically,
+// so the long-running servers can update the files without a redeploy.
+// Open and the handlers of fsys open the files in the bundle, and fall back to the embedded files if they are not in the bundle.
+// The directories in the bundle list only the files in the bundle.
+// The bundle must be signed by the private key of the public key set by SetBundleKey,
+// and the files loaded before are kept if the bundle is broken or its signature is invalid.
+// Hash, ByHash, Manifest and the other functions about the embedded files do not see the bundle.
+func (fsys *FileSystem) LoadBundle(r io.Reader) error {
+	pub, _ := fsys.bundleKey.Load().(ed25519.PublicKey)
+	if len(pub) != ed25519.PublicKeySize {
+		return errors.New("the public key of the bundles is not set by SetBundleKey")
+	}
+	payload, sig, err := readBundle(r)
+	if err != nil {
+		return fmt.Errorf("failed to read the bundle: %w", err)
+	}
+	if len(sig) == 0 {
+		return errors.New("the bundle is not signed")
+	}
+	// verify the signature before inflating the payload, so the unsigned data is never parsed.
+	if !ed25519.Verify(pub, payload, sig) {
+		return errors.New("the signature of the bundle is invalid")
+	}
+	entries, err := inflateBundle(payload)
+	if err != nil {
+		return fmt.Errorf("failed to read the bundle: %w", err)
+	}
+	t, err := fsys.newTable(entries)
+	if err != nil {
+		return fmt.Errorf("failed to read the bundle: %w", err)
+	}
+	fsys.bundle.Store(t)
+	return nil
+}
+
+// UnloadBundle removes the files loaded by LoadBundle, so Open and the handlers of fsys open only the embedded files.
+func (fsys *FileSystem) UnloadBundle() {
+	fsys.bundle.Store(table(nil))
+}
+
+// loadedBundle returns the table of the files loaded by LoadBundle, or nil.
+func (fsys *FileSystem) loadedBundle() table {
+	t, _ := fsys.bundle.Load().(table)
+	return t
+}
+
+// openBundled opens the file name in the bundle loaded by LoadBundle.
+func (fsys *FileSystem) openBundled(name string, pooled bool) (*httpFile, bool) {
+	t := fsys.loadedBundle()
+	if len(t) == 0 {
+		return nil, false
+	}
+	f, err := t.open(name, pooled)
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// readBundle reads the compressed payload and the signature of the bundle from r.
+func readBundle(r io.Reader) ([]byte, []byte, error) {
+	var magic [len(bundleMagic)]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, nil, err
+	}
+	if string(magic[:3]) != bundleMagic[:3] {
+		return nil, nil, errors.New("not a bundle")
+	}
+	if magic[3] != bundleMagic[3] {
+		return nil, nil, fmt.Errorf("unsupported version of the bundle: %d", magic[3])
+	}
+	br := bufio.NewReader(r)
+	sig, err := readBundleString(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n > uint64(maxBundleSize) {
+		return nil, nil, fmt.Errorf("the bundle is larger than %d bytes", maxBundleSize)
+	}
+	var payload bytes.Buffer
+	m, err := io.CopyN(&payload, br, int64(n))
+	if err == io.EOF || err == nil && uint64(m) != n {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		if err == nil {
+			err = errors.New("unexpected data after the payload")
+		}
+		return nil, nil, err
+	}
+	return payload.Bytes(), []byte(sig), nil
+}
+
+// inflateBundle reads the files in the compressed payload of the bundle.
+// The payload is inflated up to maxBundleSize bytes, so a broken bundle does not exhaust the memory.
+func inflateBundle(payload []byte) ([]File, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	lr := &io.LimitedReader{R: zr, N: maxBundleSize + 1}
+	br := bufio.NewReader(lr)
+
+	var entries []File
+	for {
+		name, err := readBundleString(br)
+		if err != nil {
+			return nil, inflateBundleError(lr, err)
+		}
+		if name == "" {
+			break
+		}
+		mode, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, inflateBundleError(lr, err)
+		}
+		content, err := readBundleString(br)
+		if err != nil {
+			return nil, inflateBundleError(lr, err)
+		}
+		entries = append(entries, File{
+			Path:     name,
+			Content:  content,
+			FileMode: fs.FileMode(mode) & (fs.ModeDir | fs.ModePerm),
+			bundled:  true,
+		})
+	}
+	// read to the end, so the gzip reader verifies the checksum.
+	if _, err := br.ReadByte(); err != io.EOF {
+		if err == nil {
+			err = errors.New("unexpected data after the files")
+		}
+		return nil, inflateBundleError(lr, err)
+	}
+	return entries, nil
+}
+
+// inflateBundleError returns the error that reports the bundle exceeding maxBundleSize if lr reached the limit, or err.
+func inflateBundleError(lr *io.LimitedReader, err error) error {
+	if lr.N <= 0 {
+		return fmt.Errorf("the files in the bundle are larger than %d bytes", maxBundleSize)
+	}
+	return err
+}
+
+// readBundleString reads the length in uvarint and the string of the length.
+func readBundleString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	// the buffer grows as the bytes are read, so the broken length does not allocate the memory at once.
+	var buf strings.Builder
+	m, err := io.CopyN(&buf, r, int64(n))
+	if err == io.EOF || err == nil && uint64(m) != n {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// newTable returns the table of the files in entries and their parent directories sorted by name,
+// whose directories link their children in the order of Readdir of fsys.
+func (fsys *FileSystem) newTable(entries []File) (table, error) {
+	byName := make(map[string]File, len(entries))
+	for _, f := range entries {
+		if f.Path != "/" && (!strings.HasPrefix(f.Path, "/") || !fs.ValidPath(f.Path[1:])) {
+			return nil, fmt.Errorf("invalid name: %q", f.Path)
+		}
+		if f.Path == "/" && !f.FileMode.IsDir() {
+			return nil, errors.New("/ is not a directory")
+		}
+		if f.FileMode.IsDir() && f.Content != "" {
+			return nil, fmt.Errorf("%s: the directory has the content", f.Path)
+		}
+		if _, ok := byName[f.Path]; ok {
+			return nil, fmt.Errorf("%s: duplicated name", f.Path)
+		}
+		byName[f.Path] = f
+	}
+	for name := range byName {
+		for dir := name; dir != "/"; {
+			dir = path.Dir(dir)
+			g, ok := byName[dir]
+			if ok && !g.FileMode.IsDir() {
+				return nil, fmt.Errorf("%s: %s is not a directory", name, dir)
+			}
+			if !ok {
+				byName[dir] = File{Path: dir, FileMode: fs.ModeDir | 0755, bundled: true}
+			}
+		}
+	}
+	if _, ok := byName["/"]; !ok {
+		byName["/"] = File{Path: "/", FileMode: fs.ModeDir | 0755, bundled: true}
+	}
+
+	t := make(table, 0, len(byName))
+	for _, f := range byName {
+		f.Child, f.Next = -1, -1
+		f.fsys = fsys
+		t = append(t, f)
+	}
+	sort.Slice(t, func(i, j int) bool { return t[i].Path < t[j].Path })
+	index := make(map[string]int, len(t))
+	children := map[string][]int{}
+	for i := range t {
+		name := t[i].Path
+		index[name] = i
+		if name != "/" {
+			dir := path.Dir(name)
+			children[dir] = append(children[dir], i)
+		}
+	}
+	for dir, list := range children {
+		if fsys.dirsFirst {
+			sort.SliceStable(list, func(i, j int) bool {
+				return t[list[i]].FileMode.IsDir() && !t[list[j]].FileMode.IsDir()
+			})
+		}
+		t[index[dir]].Child = list[0]
+		for i := 1; i < len(list); i++ {
+			t[list[i-1]].Next = list[i]
+		}
+	}
+	return t, nil
+}
+
+// directWriter is the http.ResponseWriter that writes the embedded files directly with io.WriterTo,
+// instead of copying them through the buffer of io.Copy in http.ServeContent.
+type directWriter struct {
+	http.ResponseWriter
+}
+
+func (w directWriter) ReadFrom(r io.Reader) (int64, error) {
+	// io.WriterTo of strings.Reader converts the content into []byte if the writer is not io.StringWriter.
+	if _, ok := w.ResponseWriter.(io.StringWriter); ok {
+		if lr, ok := r.(*io.LimitedReader); ok {
+			if f, ok := lr.R.(*httpFile); ok && int64(f.Len()) <= lr.N {
+				n, err := f.WriteTo(w.ResponseWriter)
+				lr.N -= n
+				return n, err
+			}
+		}
+	}
+	return io.Copy(w.ResponseWriter, r)
+}
+
+// Unwrap returns the original http.ResponseWriter for http.ResponseController.
+func (w directWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// headWriter is the http.ResponseWriter of the HEAD requests that discards the body,
+// because the handler writes the bodies of the error pages and the directory listings of http.FileServer even for HEAD.
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// serveNonce serves the HTML file with a new nonce of CSP.
+func (h *handler) serveNonce(w http.ResponseWriter, r *http.Request, name string, f http.File) {
+	b, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	nonce := base64.StdEncoding.EncodeToString(buf[:])
+	content := strings.ReplaceAll(string(b), NoncePlaceholder, nonce)
+
+	w.Header().Set("Content-Security-Policy", strings.ReplaceAll(h.cspPolicy, "{nonce}", nonce))
+	// the nonce must not be reused.
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, name, time.Time{}, strings.NewReader(content))
+}
+
+// localRedirect redirects the request to newPath relative to the request path, keeping the query.
+func localRedirect(w http.ResponseWriter, r *http.Request, newPath string) {
+	if q := r.URL.RawQuery; q != "" {
+		newPath += "?" + q
+	}
+	w.Header().Set("Location", newPath)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+// APIVersion implements Versioned.
+func (fsys *FileSystem) APIVersion() int {
+	return APIVersion
+}
+
+// Open implements http.FileSystem.
+func (fsys *FileSystem) Open(name string) (http.File, error) {
+	return fsys.OpenContext(context.Background(), name)
+}
+
+// OpenContext implements ContextFileSystem.
+// It returns the error of ctx if ctx is done.
+func (fsys *FileSystem) OpenContext(ctx context.Context, name string) (http.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !validName(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return fsys.openShadowed(name, false)
+}
+
+// openShadowed opens the file name with the hook set by SetOpenHook, in the bundle loaded by LoadBundle or in the embedded files, in this order.
+// If pooled is true, Close puts the embedded file back to httpFilePool, so it must not be used after Close.
+func (fsys *FileSystem) openShadowed(name string, pooled bool) (http.File, error) {
+	if f, ok := fsys.hookOpen(name); ok {
+		return f, nil
+	}
+	if f, ok := fsys.openBundled(name, pooled); ok {
+		return f, nil
+	}
+	f, err := fsys.files.open(name, pooled)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// validName reports whether name is a name that the file systems of the package open:
+// the clean slash-separated absolute path, e.g. "/css/app.css", without the elements "." and "..",
+// the backslashes and the NUL bytes, which other file systems, e.g. http.Dir on Windows, may interpret differently.
+// The other names are reported as not exist, so no name is mapped outside of the files.
+func validName(name string) bool {
+	if name == "/" {
+		return true
+	}
+	return strings.HasPrefix(name, "/") && fs.ValidPath(name[1:]) && !strings.ContainsAny(name, "\\\x00")
+}
+
+// validURLPath reports whether the request path upath has no elements "..", backslashes and NUL bytes,
+// e.g. decoded from "%2e%2e%2f", "%5c" and "%00".
+func validURLPath(upath string) bool {
+	if strings.ContainsAny(upath, "\\\x00") {
+		return false
+	}
+	for _, elem := range strings.Split(upath, "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// httpFilePool is the pool of the files opened by the handler.
+var httpFilePool = sync.Pool{
+	New: func() interface{} {
+		return new(httpFile)
+	},
+}
+
+// table is the table of the files, sorted by name.
+// The directories link their children with Child and Next, in the order that Readdir lists them.
+type table []File
+
+// open opens the file name.
+// If pooled is true, Close puts the file back to httpFilePool, so it must not be used after Close.
+func (t table) open(name string, pooled bool) (*httpFile, error) {
+	i, ok := t.lookup(name)
+	if !ok {
+		return nil, &fs.PathError{
+			Op:   "open",
+			Path: name,
+			Err:  fs.ErrNotExist,
+		}
+	}
+	f := &t[i]
+	var hf *httpFile
+	if pooled {
+		hf = httpFilePool.Get().(*httpFile)
+	} else {
+		hf = new(httpFile)
+	}
+	hf.Reader.Reset(f.Data())
+	hf.file = f
+	hf.fs = t
+	hf.idx = i
+	hf.dirIdx = f.Child
+	hf.pooled = pooled
+	return hf, nil
+}
+
+// lookup returns the index of the file name in the table.
+func (t table) lookup(name string) (int, bool) {
+	i := sort.Search(len(t), func(i int) bool { return t[i].Path >= name })
+	return i, i < len(t) && t[i].Path == name
+}
+
+// File is an entry of the table of the files, which implements fs.FileInfo and fs.DirEntry.
+// The tables are generated by assets-life, so don't build them by hand.
+type File struct {
+	// Path is the slash-separated absolute path of the file, e.g. "/css/app.css".
+	Path string
+
+	// Content is the content of the file, or empty if the file is a directory or has Lazy.
+	Content string
+
+	// FileMode is the mode of the file.
+	FileMode fs.FileMode
+
+	// Child is the index of the first child of the directory, or -1.
+	Child int
+
+	// Next is the index of the next sibling of the file, or -1.
+	Next int
+
+	// MTime is the modification time in Unix nanoseconds, or 0 if it is not embedded.
+	MTime int64
+
+	// Links is the values of the Link headers that preload the critical resources of the HTML file.
+	Links []string
+
+	// Lazy is the content decoded on first access, or nil if it is Content.
+	Lazy *Lazy
+
+	fsys    *FileSystem // the file system of the file, set by New
+	bundled bool        // the file is loaded by LoadBundle
+	meta    *FileMeta   // the metadata of the file written by Overlay, or nil
+}
+
+// Lazy is the content of a file that the backend decodes on first access of the file, e.g. the base64 backend,
+// so the package decodes nothing on its initialization.
+type Lazy struct {
+	// Size is the size of the decoded content.
+	Size int64
+
+	// Decode returns the decoded content. It is called once.
+	Decode func() string
+
+	once    sync.Once
+	content string
+}
+
+// Data returns the content of the file, decoding it on first access if it is encoded by the backend.
+func (f *File) Data() string {
+	if f.Lazy == nil {
+		return f.Content
+	}
+	f.Lazy.once.Do(func() {
+		f.Lazy.content = f.Lazy.Decode()
+	})
+	return f.Lazy.content
+}
+
+// FileMeta is the metadata of an embedded file, which Sys of its fs.FileInfo returns.
+type FileMeta struct {
+	// Hash is the hash of the content by HashAlgorithm in hex. It is empty for directories.
+	Hash string
+
+	// ContentType is the content type that the handler serves the file with,
+	// detected from the extension or sniffed from the content. It is empty for directories.
+	ContentType string
+
+	// CompressedSize is the size of the pre-compressed gzip variant that the handler serves, e.g. app.js.gz,
+	// or -1 if the file has no variant or the handler does not serve them.
+	CompressedSize int64
+
+	// Source is the name of the file in the source tree, which differs from its name if the file is fingerprinted.
+	Source string
+}
+
+var _ fs.FileInfo = (*File)(nil)
+var _ fs.DirEntry = (*File)(nil)
+
+func (f *File) Name() string {
+	return path.Base(f.Path)
+}
+
+func (f *File) Size() int64 {
+	if f.Lazy != nil {
+		return f.Lazy.Size
+	}
+	return int64(len(f.Content))
+}
+
+func (f *File) Mode() fs.FileMode {
+	return f.FileMode
+}
+
+var zeroTime time.Time
+
+func (f *File) ModTime() time.Time {
+	if f.MTime == 0 {
+		return zeroTime
+	}
+	return time.Unix(0, f.MTime)
+}
+
+func (f *File) IsDir() bool {
+	return f.Mode().IsDir()
+}
+
+// Sys returns the *FileMeta of the file.
+func (f *File) Sys() interface{} {
+	if f.meta != nil {
+		meta := *f.meta
+		return &meta
+	}
+	meta := &FileMeta{
+		CompressedSize: -1,
+		Source:         f.Path,
+	}
+	if name, ok := f.fsys.sourceNames[f.Path]; ok && !f.bundled {
+		meta.Source = name
+	}
+	if f.FileMode.IsDir() {
+		return meta
+	}
+	t := f.fsys.files
+	if f.bundled {
+		t = f.fsys.loadedBundle()
+		meta.Hash = f.fsys.hashHex([]byte(f.Content))
+	} else {
+		meta.Hash = f.hash()
+	}
+	meta.ContentType = contentType(f.Path, f.Data())
+	if f.fsys.gzipEncoded {
+		if i, ok := t.lookup(f.Path + ".gz"); ok && !t[i].FileMode.IsDir() {
+			meta.CompressedSize = t[i].Size()
+		}
+	}
+	return meta
+}
+
+// hash returns the hash of the content by HashAlgorithm in hex,
+// or empty if it is not computed in advance, e.g. of the files in the bundles.
+func (f *File) hash() string {
+	if f.meta != nil {
+		return f.meta.Hash
+	}
+	if f.bundled || f.FileMode.IsDir() {
+		return ""
+	}
+	return f.fsys.contentHashes[f.Path]
+}
+
+// contentType returns the content type of the file name, detected from the extension or sniffed from the content.
+func contentType(name, content string) string {
+	if typ := mime.TypeByExtension(path.Ext(name)); typ != "" {
+		return typ
+	}
+	if len(content) > 512 {
+		content = content[:512]
+	}
+	return http.DetectContentType([]byte(content))
+}
+
+func (f *File) Type() fs.FileMode {
+	return f.FileMode.Type()
+}
+
+func (f *File) Info() (fs.FileInfo, error) {
+	return f, nil
+}
+
+// httpFile is an opened file.
+// Each Open returns a new httpFile that has its own offset and position of Readdir,
+// and the embedded files are read-only, so the files opened separately can be used concurrently.
+// Like os.File, an httpFile itself is not safe for concurrent use.
+type httpFile struct {
+	strings.Reader
+	file   *File
+	fs     table
+	idx    int
+	dirIdx int
+	pooled bool
+}
+
+var _ http.File = (*httpFile)(nil)
+
+func (f *httpFile) Stat() (fs.FileInfo, error) {
+	return f.file, nil
+}
+
+// Seek implements io.Seeker.
+// Seeking to the start of the directory restarts Readdir.
+func (f *httpFile) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		f.dirIdx = f.file.Child
+	}
+	return f.Reader.Seek(offset, whence)
+}
+
+// Readdir reads the entries of the directory with the same semantics as os.File:
+// if count <= 0, it reads all the remaining entries and returns no error even if none remain,
+// and if count > 0, it reads at most count entries and returns io.EOF only if none remain.
+func (f *httpFile) Readdir(count int) ([]fs.FileInfo, error) {
+	n, err := f.dirCount(count)
+	if err != nil {
+		return []fs.FileInfo{}, err
+	}
+	ret := make([]fs.FileInfo, n)
+	for i := range ret {
+		ret[i] = f.nextEntry()
+	}
+	return ret, nil
+}
+
+// ReadDir is Readdir that returns fs.DirEntry, as os.File.ReadDir does.
+func (f *httpFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	n, err := f.dirCount(count)
+	if err != nil {
+		return []fs.DirEntry{}, err
+	}
+	ret := make([]fs.DirEntry, n)
+	for i := range ret {
+		ret[i] = f.nextEntry()
+	}
+	return ret, nil
+}
+
+// dirCount returns the number of the entries that Readdir(count) reads.
+func (f *httpFile) dirCount(count int) (int, error) {
+	if !f.file.IsDir() {
+		// same as os.File, it is an error to read a file as a directory.
+		return 0, &fs.PathError{Op: "readdir", Path: f.file.Path, Err: fs.ErrInvalid}
+	}
+	n := 0
+	for i := f.dirIdx; i >= 0 && (count <= 0 || n < count); i = f.fs[i].Next {
+		n++
+	}
+	if count > 0 && n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// nextEntry returns the entry at the position of Readdir, and advances it.
+func (f *httpFile) nextEntry() *File {
+	entry := &f.fs[f.dirIdx]
+	f.dirIdx = entry.Next
+	return entry
+}
+
+func (f *httpFile) Close() error {
+	if f.pooled {
+		*f = httpFile{}
+		httpFilePool.Put(f)
+	}
+	return nil
+}
+
+// Bytes returns the content of the file name, e.g. "/index.html", without copying it.
+// It returns nil if the file doesn't exist or is a directory.
+// The file is looked up as Open opens it, so the files of the hook set by SetOpenHook and in the bundle loaded by LoadBundle
+// take precedence over the embedded files. The contents of the files of the hook are copied.
+//
+// The returned slice shares the memory with the embedded string, which may be in the read-only segment.
+// It must not be modified; writing to it may crash the program or change the content of the file system.
+func (fsys *FileSystem) Bytes(name string) []byte {
+	if !validName(name) {
+		return nil
+	}
+	f, err := fsys.openShadowed(name, true)
+	if err != nil {
+		return nil
+	}
+	return fileBytes(f)
+}
+
+// Bytes returns the content of the file name in the overlay without copying it, as Bytes of FileSystem does.
+// The file is looked up as OpenContext of the overlay opens it.
+func (o *Overlay) Bytes(name string) []byte {
+	if !validName(name) {
+		return nil
+	}
+	f, err := o.open(name, true)
+	if err != nil {
+		return nil
+	}
+	return fileBytes(f)
+}
+
+// fileBytes returns the content of f and closes f.
+// The content of the files of the table is not copied, and the other files are read.
+func fileBytes(f http.File) []byte {
+	defer f.Close()
+	if hf, ok := f.(*httpFile); ok {
+		if hf.file.IsDir() {
+			return nil
+		}
+		return unsafeBytes(hf.file.Data())
+	}
+	if fi, err := f.Stat(); err != nil || fi.IsDir() {
+		return nil
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// unsafeBytes returns the bytes of s without copying it.
+func unsafeBytes(s string) []byte {
+	if s == "" {
+		return []byte{}
+	}
+	// the layout of the slice is the pointer and the length of the string followed by the capacity.
+	return *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		int
+	}{s, len(s)}))
+}
+
+// TB is the subset of testing.TB that SeedT uses.
+type TB interface {
+	Helper()
+	Cleanup(func())
+	TempDir() string
+	Fatal(args ...interface{})
+}
+
+// SeedT extracts the embedded files into a new temporary directory of the test t, and returns the directory.
+// The directory is removed when the test and all its subtests complete.
+func (fsys *FileSystem) SeedT(t TB) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	// make the directories writable to remove them, before the cleanup of TempDir.
+	t.Cleanup(func() {
+		filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+			if err == nil && info.IsDir() {
+				os.Chmod(path, info.Mode().Perm()|0700)
+			}
+			return nil
+		})
+	})
+
+	if err := fsys.ExtractTo(dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// ExtractTo writes the embedded files into the directory dir, creating it if necessary.
+// The files are written with their modes, and the existing files are overwritten.
+// It refuses to write through symbolic links, so no files are written outside of dir.
+func (fsys *FileSystem) ExtractTo(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	type extracted struct {
+		file   *File
+		target string
+	}
+	var dirs []extracted
+	for i := range fsys.files {
+		f := &fsys.files[i]
+		if f.Path == "/" {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(f.Path))
+		rel, err := filepath.Rel(dir, target)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return &fs.PathError{Op: "extract", Path: f.Path, Err: errors.New("outside of the target directory")}
+		}
+		info, err := os.Lstat(target)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if info != nil && info.Mode()&fs.ModeSymlink != 0 {
+			return &fs.PathError{Op: "extract", Path: target, Err: errors.New("refusing to follow symbolic link")}
+		}
+
+		if f.IsDir() {
+			if info == nil {
+				// keep the directory writable until all files are written.
+				if err := os.Mkdir(target, 0700); err != nil {
+					return err
+				}
+			} else if !info.IsDir() {
+				return &fs.PathError{Op: "extract", Path: target, Err: errors.New("not a directory")}
+			}
+			dirs = append(dirs, extracted{file: f, target: target})
+			continue
+		}
+		if err := extractFile(f, target); err != nil {
+			return err
+		}
+	}
+
+	// restore the modes of the directories, children first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := setMetadata(dirs[i].file, dirs[i].target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(f *File, target string) error {
+	w, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, f.Data()); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return setMetadata(f, target)
+}
+
+func setMetadata(f *File, target string) error {
+	if err := os.Chmod(target, f.FileMode); err != nil {
+		return err
+	}
+	if mtime := f.ModTime(); !mtime.IsZero() {
+		if err := os.Chtimes(target, mtime, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+`
+
+// assetsfsDoc is the package documentation of the assetsfs package, which is runtimeSource as a package.
+const assetsfsDoc = `// Package assetsfs is the runtime of the packages generated by assets-life.
+// The generated packages have only the tables of their files and the thin wiring to this package,
+// so the fixes of the runtime are delivered by updating this package without regenerating them.
+// The packages generated with the -standalone option have a copy of the runtime instead,
+// so they depend only on the standard library.
+//
+// The generated packages build the file systems with New, so don't build the tables by hand.
+`
+
+// runtimeFile returns the source of the runtime in the package pkg, e.g. filesystem-runtime.go of -standalone.
+func runtimeFile(generator, pkg string) []byte {
+	return []byte("// Code generated by go run " + generator + ". DO NOT EDIT.\n\npackage " + pkg + "\n\n" + runtimeSource)
+}
+
+// assetsfsSource returns the source of the assetsfs package.
+func assetsfsSource() []byte {
+	return []byte("// Code generated by assets-life. DO NOT EDIT.\n\n" + assetsfsDoc + "package assetsfs\n\n" + runtimeSource)
+}
+
+// build generates the package into the output directory.
+// The package is generated in memory, and the output directory is replaced only if the generation succeeds,
+// and with -check-compile, only if the package passes the check,
+// so a failed generation keeps the package generated previously.
+func build(ctx context.Context, opts *options) error {
+	files := &stagedOutput{memOutput: memOutput{}, out: opts.out}
+	defer files.cleanup()
+	if err := generate(ctx, opts, files); err != nil {
+		return err
+	}
+	if opts.checkCompile != "" {
+		if err := checkCompile(ctx, opts, files); err != nil {
+			return err
+		}
+	}
+	return files.commit()
+}
+
+// importsRuntime reports whether the generated files import the assetsfs package.
+// The custom templates may import it even if the runtime is standalone, and may not even if it is not.
+func (s *stagedOutput) importsRuntime() bool {
+	for name, b := range s.memOutput {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), name, b, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, spec := range f.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil && path == assetsfsPath {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkCompile runs go build or go vet of -check-compile on the generated files.
+// The files are checked in a temporary directory in the output directory,
+// so the imports of the package are resolved with the requirements of the enclosing module.
+func checkCompile(ctx context.Context, opts *options, files *stagedOutput) error {
+	if err := os.MkdirAll(opts.out, 0755); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(opts.out, "assets-life-check-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	for name, b := range files.memOutput {
+		if err := dirOutput(dir).writeFile(name, b); err != nil {
+			return err
+		}
+	}
+	if _, ok := files.memOutput["go.mod"]; !ok {
+		mod, err := moduleImportPath(opts.out)
+		if err != nil {
+			return err
+		}
+		if mod == "" {
+			if files.importsRuntime() {
+				return validationErrorf("the package imports %s, which cannot be resolved outside of a module; use -standalone", assetsfsPath)
+			}
+			// the standalone package uses only the standard library.
+			if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module check\n\ngo 1.16\n"), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	opts.log.status("checking the package with go %s", opts.checkCompile)
+	cmd := exec.CommandContext(ctx, "go", opts.checkCompile, ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	opts.log.clearStatus()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, ok := err.(*exec.ExitError); !ok {
+			return err
+		}
+		// the messages have the paths in the temporary directory, which is removed.
+		msg := strings.Replace(strings.TrimSpace(string(out)), dir, opts.out, -1)
+		return validationErrorf("the generated package doesn't pass go %s:\n%s", opts.checkCompile, msg)
+	}
+	opts.log.Infof("the generated package passes go %s", opts.checkCompile)
+	return nil
+}
+
+// generate generates the package into out.
+func generate(ctx context.Context, opts *options, out output) error {
+	filename := "assets-life.go"
+	directive, err := opts.directive(filename)
+	if err != nil {
+		return err
+	}
+	t := template.New("filesystem.go")
+	if opts.template != "" {
+		b, err := os.ReadFile(opts.template)
+		if err != nil {
+			return err
+		}
+		if _, err := t.Parse(string(b)); err != nil {
+			return err
+		}
+	} else if opts.noNet {
+		if _, err := t.Parse(noNetTemplate); err != nil {
+			return err
+		}
+	} else {
+		if _, err := t.Parse(defaultTemplate); err != nil {
+			return err
+		}
+	}
+
+	var cfg *config
+	if opts.config != "" {
+		cfg, err = readConfig(opts.config)
+		if err != nil {
+			return err
+		}
+	}
+	shards, err := opts.shards(cfg)
+	if err != nil {
+		return err
+	}
+	var signKey ed25519.PrivateKey
+	if opts.signKey != "" {
+		signKey, err = readSignKey(opts.signKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.ownModule != "" {
+		mod := fmt.Sprintf("// Code generated by go run %s. DO NOT EDIT.\n\nmodule %s\n\ngo 1.16\n", filename, opts.ownModule)
+		if err := out.writeFile("go.mod", []byte(mod)); err != nil {
+			return err
+		}
+	}
+	// the package is the root of its own module, which may not be written to the disk.
+	importPath := opts.ownModule
+	if importPath == "" {
+		importPath, err = moduleImportPath(opts.out)
+		if err != nil {
+			return err
+		}
+	}
+	if importPath != "" {
+		opts.log.Infof("import the package as %q", importPath)
+	}
+	if opts.template == "" {
+		// the documentation describes the API of the built-in template.
+		doc := new(bytes.Buffer)
+		if err := template.Must(template.New("doc.go").Parse(docTemplate)).Execute(doc, struct {
+			*templateData
+			NoNet bool
+		}{
+			templateData: &templateData{
+				Generator:  filename,
+				Package:    opts.name,
+				ImportPath: importPath,
+				Standalone: opts.standaloneRuntime(),
+			},
+			NoNet: opts.noNet,
+		}); err != nil {
+			return err
+		}
+		src, err := formatSource("doc.go", doc.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := out.writeFile("doc.go", src); err != nil {
+			return err
+		}
+	}
+
+	for _, sh := range shards {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		constraint, err := buildConstraint(sh.constraint)
+		if err != nil {
+			return err
+		}
+		meta, err := opts.process(sh, cfg)
+		if err != nil {
+			return err
+		}
+		data := &templateData{
+			Generator:       filename,
+			Directive:       directive,
+			Package:         opts.name,
+			ImportPath:      importPath,
+			Variant:         sh.variant,
+			BuildConstraint: constraint,
+			Files:           newFileTable(sh.assets, opts.preserveMode, opts.preserveMTime, opts.dirsFirst),
+			DirsFirst:       opts.dirsFirst,
+			GzipEncoded:     opts.gzipSources == "encoded" || opts.compression == Gzip,
+			Standalone:      opts.standaloneRuntime(),
+			HashAlgorithm:   opts.hash,
+			Fingerprints:    meta.fingerprints,
+			Charsets:        meta.charsets,
+			Licenses:        meta.licenses,
+		}
+		data.Contents = internContents(data.Files)
+		if err := store(opts, out, sh.filename(), data); err != nil {
+			return err
+		}
+		data.Hashes, data.HashNames, err = contentHashes(data.Files, opts.hash)
+		if err != nil {
+			return err
+		}
+		if signKey != nil {
+			data.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(signKey, manifest(data.Files)))
+		}
+		if opts.preload {
+			findPreloads(data.Files)
+		}
+		f := new(bytes.Buffer)
+		if err := t.Execute(f, data); err != nil {
+			return err
+		}
+		opts.log.status("formatting %s (%s)", sh.filename(), formatBytes(int64(f.Len())))
+		src, err := formatSource(sh.filename(), f.Bytes())
+		opts.log.clearStatus()
+		if err != nil {
+			return err
+		}
+		if err := out.writeFile(sh.filename(), src); err != nil {
+			return err
+		}
+		opts.log.Infof("generated %s (%d files)", out.path(sh.filename()), countFiles(data.Files))
+	}
+
+	if opts.noNet {
+		f := new(bytes.Buffer)
+		if err := template.Must(template.New("filesystem-http.go").Parse(noNetHTTPTemplate)).Execute(f, &templateData{
+			Generator: filename,
+			Package:   opts.name,
+		}); err != nil {
+			return err
+		}
+		src, err := formatSource("filesystem-http.go", f.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := out.writeFile("filesystem-http.go", src); err != nil {
+			return err
+		}
+	}
+
+	if opts.template == "" && !opts.noNet && opts.standaloneRuntime() {
+		if err := out.writeFile("filesystem-runtime.go", runtimeFile(filename, opts.name)); err != nil {
+			return err
+		}
+	}
+
+	if opts.unsafeBytes {
+		f := new(bytes.Buffer)
+		if err := template.Must(template.New("filesystem-bytes.go").Parse(bytesTemplate)).Execute(f, struct {
+			*templateData
+			NoNet bool
+		}{
+			templateData: &templateData{
+				Generator: filename,
+				Package:   opts.name,
+			},
+			NoNet: opts.noNet,
+		}); err != nil {
+			return err
+		}
+		src, err := formatSource("filesystem-bytes.go", f.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := out.writeFile("filesystem-bytes.go", src); err != nil {
+			return err
+		}
+	}
+
+	if opts.bench {
+		f := new(bytes.Buffer)
+		if err := template.Must(template.New(benchFilename).Parse(benchTemplate)).Execute(f, &templateData{
+			Generator: filename,
+			Package:   opts.name,
+		}); err != nil {
+			return err
+		}
+		src, err := formatSource(benchFilename, f.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := out.writeFile(benchFilename, src); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range opts.adapters {
+		adapter := "adapter-" + name + ".go"
+		f := new(bytes.Buffer)
+		if err := template.Must(template.New(adapter).Parse(adapterTemplates[name])).Execute(f, &templateData{
+			Generator: filename,
+			Package:   opts.name,
+		}); err != nil {
+			return err
+		}
+		src, err := formatSource(adapter, f.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := out.writeFile(adapter, src); err != nil {
+			return err
+		}
+	}
+
+	self := selfSource()
+	if err := parseSource(filename, self); err != nil {
+		return err
+	}
+	return out.writeFile(filename, self)
+}
+
+// maxStreamBuffer is the maximum size of the buffer that copies the contents in the memory-bounded mode.
+const maxStreamBuffer = 1 << 20
+
+// storeStreaming writes the contents of the files in data into the data files of the embed backend in dir,
+// for the memory-bounded mode of -max-memory.
+// Each content is copied from its file through a buffer of at most -max-memory MB while it is hashed,
+// so the contents are never held in memory at once.
+func storeStreaming(opts *options, dir string, data *templateData) error {
+	size := opts.maxMemory << 20
+	if size > maxStreamBuffer {
+		size = maxStreamBuffer
+	}
+	buf := make([]byte, size)
+
+	var decls strings.Builder
+	vars := map[string]string{}
+	p := newProgress(opts.log, "writing", len(data.Files), 0)
+	defer p.done()
+	for i := range data.Files {
+		f := &data.Files[i]
+		if f.Mode.IsDir() {
+			f.Expr = `""`
+			p.add(0)
+			continue
+		}
+		hash, digest, n, err := copyHashed(dir, f, buf, opts.hash)
+		if err != nil {
+			return err
+		}
+		p.add(n)
+		f.hash, f.sum = digest, hash
+		if n == 0 {
+			f.Expr = `""`
+			continue
+		}
+		v, ok := vars[hash]
+		if !ok {
+			v = fmt.Sprintf("storage%d", len(vars))
+			vars[hash] = v
+			if decls.Len() > 0 {
+				decls.WriteString("\n\n")
+			}
+			fmt.Fprintf(&decls, "//go:embed %s/%s\nvar %s string", dataDir, hash, v)
+		}
+		f.Expr = v
+	}
+	data.Imports = []string{`_ "embed"`}
+	data.Decls = decls.String()
+	return nil
+}
+
+// copyHashed copies the content of f into the data file in dir named by its SHA-256 hash,
+// and returns the hash, the hash by the algorithm alg of -hash and the size of the content.
+// The data files are always named by SHA-256, because the contents with the same name share the file.
+func copyHashed(dir string, f *templateFile, buf []byte, alg string) (string, string, int64, error) {
+	var src io.Reader = strings.NewReader(f.Content)
+	if f.path != "" {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return "", "", 0, err
+		}
+		defer file.Close()
+		src = file
+	}
+	tmp, err := os.CreateTemp(dir, "tmp-")
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	d := newHash(alg)
+	n, err := io.CopyBuffer(io.MultiWriter(tmp, h, d), src, buf)
+	if err != nil {
+		tmp.Close()
+		return "", "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", "", 0, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	digest := hex.EncodeToString(d.Sum(nil))
+	if n == 0 {
+		return hash, digest, 0, nil
+	}
+	// the same contents share the data file.
+	dst := filepath.Join(dir, hash)
+	if _, err := os.Stat(dst); err == nil {
+		return hash, digest, n, nil
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", "", 0, err
+	}
+	return hash, digest, n, nil
+}
+
+// output is the destination of the generated files.
+type output interface {
+	// writeFile writes the generated file of the slash-separated name.
+	writeFile(name string, b []byte) error
+
+	// path returns the path of the generated file of the slash-separated name for the logs.
+	path(name string) string
+
+	// streamDir returns the directory that the memory-bounded mode streams the data files into.
+	streamDir() (string, error)
+}
+
+// dirOutput writes the generated files into the directory.
+type dirOutput string
+
+// reset removes the files generated previously, e.g. the shards of the removed variants.
+func (dir dirOutput) reset() error {
+	if err := os.MkdirAll(string(dir), 0755); err != nil {
+		return err
+	}
+	if err := removeShards(string(dir)); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(string(dir), dataDir))
+}
+
+func (dir dirOutput) path(name string) string {
+	return filepath.Join(string(dir), filepath.FromSlash(name))
+}
+
+func (dir dirOutput) writeFile(name string, b []byte) error {
+	filename := dir.path(name)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0644)
+}
+
+// memOutput collects the generated files in memory.
+type memOutput map[string][]byte
+
+func (m memOutput) path(name string) string {
+	return name
+}
+
+func (m memOutput) writeFile(name string, b []byte) error {
+	m[name] = b
+	return nil
+}
+
+func (m memOutput) streamDir() (string, error) {
+	return "", errors.New("the memory-bounded mode cannot generate the package in memory")
+}
+
+// stagedOutput collects the generated files in memory,
+// and the data files that the memory-bounded mode streams in a staging directory in the output directory,
+// until commit replaces the files generated previously with them.
+type stagedOutput struct {
+	memOutput
+
+	// out is the output directory, and stage is the staging directory in it, or empty if it is not created.
+	out, stage string
+}
+
+func (s *stagedOutput) path(name string) string {
+	return filepath.Join(s.out, filepath.FromSlash(name))
+}
+
+func (s *stagedOutput) streamDir() (string, error) {
+	if s.stage != "" {
+		return s.stage, nil
+	}
+	if err := os.MkdirAll(s.out, 0755); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(s.out, "assets-life-stage-")
+	if err != nil {
+		return "", err
+	}
+	s.stage = dir
+	return dir, nil
+}
+
+// commit removes the files generated previously from the output directory, and writes the generated files into it.
+func (s *stagedOutput) commit() error {
+	out := dirOutput(s.out)
+	if err := out.reset(); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(s.memOutput))
+	for name := range s.memOutput {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := out.writeFile(name, s.memOutput[name]); err != nil {
+			return err
+		}
+	}
+	if s.stage != "" {
+		if err := os.Rename(s.stage, filepath.Join(s.out, dataDir)); err != nil {
+			return err
+		}
+		s.stage = ""
+	}
+	return nil
+}
+
+// cleanup removes the staging directory if it is not committed.
+func (s *stagedOutput) cleanup() {
+	if s.stage != "" {
+		os.RemoveAll(s.stage)
+	}
+}
+
+// moduleImportPath returns the import path of the package in the directory dir,
+// which is detected from the enclosing go.mod.
+// It returns empty if dir is not in a module.
+func moduleImportPath(dir string) (string, error) {
+	for root := dir; ; {
+		filename := filepath.Join(root, "go.mod")
+		b, err := os.ReadFile(filename)
+		if err == nil {
+			mod := modulePath(b)
+			if mod == "" {
+				return "", fmt.Errorf("%s: no module declaration", filename)
+			}
+			rel, err := filepath.Rel(root, dir)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(mod, filepath.ToSlash(rel)), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return "", nil
+		}
+		root = parent
+	}
+}
+
+// modulePath returns the module path declared in the content of go.mod, or empty if it is not declared.
+func modulePath(mod []byte) string {
+	for _, line := range strings.Split(string(mod), "\n") {
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+		if mod, err := strconv.Unquote(fields[1]); err == nil {
+			return mod
+		}
+		return fields[1]
+	}
+	return ""
+}
+
+// countFiles returns the number of the regular files in files.
+func countFiles(files []templateFile) int {
+	var n int
+	for _, f := range files {
+		if !f.Mode.IsDir() {
+			n++
+		}
+	}
+	return n
+}
+
+// limits returns the limits of -max-files and -max-depth.
+func (opts *options) limits() limits {
+	return limits{maxFiles: opts.maxFiles, maxDepth: opts.maxDepth}
+}
+
+// readAssets collects the assets in the input directory or archive in.
+func (opts *options) readAssets(in string) ([]*asset, error) {
+	var assets []*asset
+	if opts.maxMemory > 0 {
+		// the contents are read when they are written, see storeStreaming.
+		if isArchive(in) {
+			return nil, usageErrorf("-max-memory cannot be used with an archive")
+		}
+		entries, err := listDir(opts.log, in, opts.skipUnreadable, opts.limits())
+		if err != nil {
+			return nil, err
+		}
+		assets, err = lazyAssets(in, entries)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		src, err := opts.sourceOf(in)
+		if err != nil {
+			return nil, err
+		}
+		files, err := src.Files()
+		if err != nil {
+			return nil, err
+		}
+		assets, err = assetsOf(files)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if opts.exportIgnore {
+		if isArchive(in) {
+			return nil, &UsageError{errors.New("-export-ignore cannot be used with an archive")}
+		}
+		assets, err = filterExportIgnore(assets, in, opts.gitRef)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.remote != "" {
+		remote, err := readRemoteManifest(opts.log, opts.remote, opts.cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		// the remote files override the local files.
+		assets = append(assets, remote...)
+	}
+	if err := opts.limits().checkAssets(assets); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// sourceOf returns the source of the input in, which is the source of WithSource if in is the input of the options.
+func (opts *options) sourceOf(in string) (Source, error) {
+	switch {
+	case opts.source != nil && in == opts.in:
+		return opts.source, nil
+	case isArchive(in):
+		if opts.filesFrom != "" || opts.gitRef != "" {
+			return nil, &UsageError{errors.New("-files-from and -git-ref cannot be used with an archive")}
+		}
+		return &archiveSource{filename: in, log: opts.log}, nil
+	case opts.gitRef != "":
+		return &gitSource{dir: in, ref: opts.gitRef}, nil
+	case opts.filesFrom == "":
+		return &dirSource{dir: in, skipUnreadable: opts.skipUnreadable, limits: opts.limits(), log: opts.log}, nil
+	default:
+		return &listSource{list: opts.filesFrom, dir: in, skipUnreadable: opts.skipUnreadable, log: opts.log}, nil
+	}
+}
+
+// config is the configuration file given by the -config option.
+type config struct {
+	// Variants maps the names of the variants to their asset sets.
+	// The names are used as the build tags that select the variants.
+	Variants map[string]*variantConfig `json:"variants"`
+
+	// Platforms is the list of the rules that embed files only on specific platforms.
+	Platforms []*platformRule `json:"platforms"`
+
+	// Images is the list of the rules that add the resized variants and the alternates of the images.
+	Images []*imageRule `json:"images"`
+
+	// Markdown is the rule that renders the Markdown files to HTML.
+	Markdown *markdownRule `json:"markdown"`
+
+	// Templates is the rule that executes the text files as templates with the build-time variables.
+	Templates *templateRule `json:"templates"`
+
+	// Budgets is the list of the byte-size budgets of the groups of the files, e.g. "/js/** <= 500KB".
+	Budgets []string `json:"budgets"`
+
+	// Robots is the rule that generates /robots.txt.
+	Robots *robotsRule `json:"robots"`
+
+	// Security is the rule that generates /.well-known/security.txt.
+	Security *securityRule `json:"security"`
+
+	// budgets is Budgets parsed by readConfig.
+	budgets []*budget
+}
+
+// budget is the limit of the total size of the files that match the pattern.
+type budget struct {
+	// rule is the budget in the configuration file, e.g. "/js/** <= 500KB".
+	rule string
+
+	// pattern is the glob pattern of the files, e.g. "/js/**".
+	pattern string
+
+	// limit is the maximum total size of the files in bytes.
+	limit int64
+}
+
+// parseBudget parses the budget rule in the form of "PATTERN <= SIZE", e.g. "/js/** <= 500KB".
+func parseBudget(rule string) (*budget, error) {
+	i := strings.Index(rule, "<=")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid budget %q, it must be PATTERN <= SIZE", rule)
+	}
+	pattern := strings.TrimSpace(rule[:i])
+	if pattern == "" {
+		return nil, fmt.Errorf("invalid budget %q, the pattern is empty", rule)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid budget %q: %v", rule, err)
+	}
+	limit, err := parseByteSize(strings.TrimSpace(rule[i+len("<="):]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid budget %q: %v", rule, err)
+	}
+	return &budget{rule: rule, pattern: pattern, limit: limit}, nil
+}
+
+// parseByteSize parses the size in bytes with the optional unit, e.g. "500KB".
+// The units are the powers of 1024: KB, MB and GB, or KiB, MiB and GiB.
+func parseByteSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+		{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+		{"B", 1},
+	}
+	unit := int64(1)
+	num := s
+	for _, u := range units {
+		if strings.HasSuffix(strings.ToUpper(s), strings.ToUpper(u.suffix)) {
+			unit = u.size
+			num = strings.TrimSpace(s[:len(s)-len(u.suffix)])
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// checkBudgets returns an error with the breakdown of the largest files if the files in assets exceed any of budgets.
+// variant is the name of the variant of assets, or empty.
+func checkBudgets(log *Logger, assets []*asset, budgets []*budget, variant string) error {
+	var msgs []string
+	for _, b := range budgets {
+		type entry struct {
+			name string
+			size int64
+		}
+		var entries []entry
+		var total int64
+		for _, a := range assets {
+			if a.mode.IsDir() || !matchGlob(b.pattern, a.name) {
+				continue
+			}
+			size, err := a.size()
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry{a.name, size})
+			total += size
+		}
+		if total <= b.limit {
+			log.Infof("budget %s: %s of %s", b.rule, formatBytes(total), formatBytes(b.limit))
+			continue
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].size > entries[j].size })
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "budget %s is exceeded by %s: %s in %d files", b.rule, formatBytes(total-b.limit), formatBytes(total), len(entries))
+		const top = 10
+		for i, e := range entries {
+			if i == top {
+				fmt.Fprintf(&buf, "\n  ... and %d more files", len(entries)-top)
+				break
+			}
+			fmt.Fprintf(&buf, "\n  %10s %s", formatBytes(e.size), e.name)
+		}
+		msgs = append(msgs, buf.String())
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	if variant != "" {
+		return validationErrorf("variant %s: %s", variant, strings.Join(msgs, "\n"))
+	}
+	return validationErrorf("%s", strings.Join(msgs, "\n"))
+}
+
+// robotsRule is the rule that generates /robots.txt.
+type robotsRule struct {
+	// Allow is the list of the paths that the crawlers may fetch, e.g. "/".
+	Allow []string `json:"allow"`
+
+	// Disallow is the list of the paths that the crawlers must not fetch, e.g. "/admin/".
+	// If both Allow and Disallow are empty, all paths are allowed.
+	Disallow []string `json:"disallow"`
+
+	// Sitemaps is the list of the absolute URLs of the sitemaps.
+	Sitemaps []string `json:"sitemaps"`
+}
+
+// securityRule is the rule that generates /.well-known/security.txt of RFC 9116.
+type securityRule struct {
+	// Contact is the list of the URIs to report the vulnerabilities, e.g. "mailto:security@example.com". It is required.
+	Contact []string `json:"contact"`
+
+	// Expires is the date and time in RFC 3339 after which the file is stale. It is required.
+	Expires string `json:"expires"`
+
+	// Encryption is the list of the URIs of the keys to encrypt the reports.
+	Encryption []string `json:"encryption"`
+
+	// Acknowledgments is the list of the URIs of the pages that thank the reporters.
+	Acknowledgments []string `json:"acknowledgments"`
+
+	// PreferredLanguages is the list of the language tags of the reports, e.g. "en".
+	PreferredLanguages []string `json:"preferredLanguages"`
+
+	// Canonical is the list of the URIs where the file is located.
+	Canonical []string `json:"canonical"`
+
+	// Policy is the list of the URIs of the vulnerability disclosure policies.
+	Policy []string `json:"policy"`
+
+	// Hiring is the list of the URIs of the security-related job positions.
+	Hiring []string `json:"hiring"`
+
+	// expires is Expires parsed by readConfig.
+	expires time.Time
+}
+
+// validate reports the first error of the paths and the URLs of the rule.
+func (rule *robotsRule) validate() error {
+	for _, p := range append(rule.Allow[:len(rule.Allow):len(rule.Allow)], rule.Disallow...) {
+		if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "\r\n") {
+			return fmt.Errorf("robots has invalid path %q, it must start with /", p)
+		}
+	}
+	for _, s := range rule.Sitemaps {
+		if u, err := url.Parse(s); err != nil || !u.IsAbs() || strings.ContainsAny(s, "\r\n") {
+			return fmt.Errorf("robots has invalid sitemap %q, it must be an absolute URL", s)
+		}
+	}
+	return nil
+}
+
+// validate reports the first error of the fields of the rule, and parses Expires.
+func (rule *securityRule) validate() error {
+	if len(rule.Contact) == 0 {
+		return errors.New("security must have contact")
+	}
+	if rule.Expires == "" {
+		return errors.New("security must have expires")
+	}
+	t, err := time.Parse(time.RFC3339, rule.Expires)
+	if err != nil {
+		return fmt.Errorf("security has invalid expires %q, it must be in RFC 3339", rule.Expires)
+	}
+	rule.expires = t
+	fields := [][]string{rule.Contact, rule.Encryption, rule.Acknowledgments, rule.Canonical, rule.Policy, rule.Hiring}
+	for _, uris := range fields {
+		for _, s := range uris {
+			if u, err := url.Parse(s); err != nil || !u.IsAbs() || strings.ContainsAny(s, "\r\n") {
+				return fmt.Errorf("security has invalid URI %q, it must be absolute, e.g. mailto: or https:", s)
+			}
+		}
+	}
+	for _, lang := range rule.PreferredLanguages {
+		if lang == "" || strings.ContainsAny(lang, ", \r\n") {
+			return fmt.Errorf("security has invalid language %q", lang)
+		}
+	}
+	return nil
+}
+
+// robotsTxt returns the content of /robots.txt for all user agents.
+func (rule *robotsRule) robotsTxt() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("User-agent: *\n")
+	if len(rule.Allow) == 0 && len(rule.Disallow) == 0 {
+		buf.WriteString("Disallow:\n")
+	}
+	for _, p := range rule.Allow {
+		fmt.Fprintf(&buf, "Allow: %s\n", p)
+	}
+	for _, p := range rule.Disallow {
+		fmt.Fprintf(&buf, "Disallow: %s\n", p)
+	}
+	if len(rule.Sitemaps) > 0 {
+		buf.WriteString("\n")
+	}
+	for _, s := range rule.Sitemaps {
+		fmt.Fprintf(&buf, "Sitemap: %s\n", s)
+	}
+	return buf.Bytes()
+}
+
+// securityTxt returns the content of /.well-known/security.txt, with the fields in the order of RFC 9116.
+func (rule *securityRule) securityTxt() []byte {
+	var buf bytes.Buffer
+	fields := []struct {
+		name   string
+		values []string
+	}{
+		{"Contact", rule.Contact},
+		{"Expires", []string{rule.expires.UTC().Format(time.RFC3339)}},
+		{"Encryption", rule.Encryption},
+		{"Acknowledgments", rule.Acknowledgments},
+		{"Preferred-Languages", nil},
+		{"Canonical", rule.Canonical},
+		{"Policy", rule.Policy},
+		{"Hiring", rule.Hiring},
+	}
+	if len(rule.PreferredLanguages) > 0 {
+		// unlike the other fields, Preferred-Languages must appear at most once.
+		fields[4].values = []string{strings.Join(rule.PreferredLanguages, ", ")}
+	}
+	for _, f := range fields {
+		for _, v := range f.values {
+			fmt.Fprintf(&buf, "%s: %s\n", f.name, v)
+		}
+	}
+	return buf.Bytes()
+}
+
+// addWellKnown adds /robots.txt and /.well-known/security.txt generated by the rules of cfg to assets.
+// The parent directory /.well-known is added by newFileTable if it is missing.
+// now is the time of the generation, to warn of the expired security.txt.
+func addWellKnown(log *Logger, assets []*asset, cfg *config, now time.Time) ([]*asset, error) {
+	var generated []*asset
+	if cfg.Robots != nil {
+		generated = append(generated, &asset{
+			name:    "/robots.txt",
+			mode:    0644,
+			content: cfg.Robots.robotsTxt(),
+		})
+	}
+	if cfg.Security != nil {
+		if !cfg.Security.expires.After(now) {
+			log.Warnf("security.txt expired at %s", cfg.Security.expires.Format(time.RFC3339))
+		}
+		generated = append(generated, &asset{
+			name:    "/.well-known/security.txt",
+			mode:    0644,
+			content: cfg.Security.securityTxt(),
+		})
+	}
+	for _, a := range assets {
+		for _, g := range generated {
+			if a.name == g.name {
+				return nil, fmt.Errorf("%s conflicts with the generated file", g.name)
+			}
+		}
+	}
+	return append(assets[:len(assets):len(assets)], generated...), nil
+}
+
+// templateRule is a rule that executes the text files as text/template.
+type templateRule struct {
+	// Include is the list of the glob patterns of the files.
+	Include []string `json:"include"`
+
+	// Vars is the default values of the variables, overridden by the -var options.
+	Vars map[string]string `json:"vars"`
+}
+
+// markdownRule is a rule that renders the Markdown files to HTML.
+type markdownRule struct {
+	// Include is the list of the glob patterns of the Markdown files.
+	// If it is empty, all files with the extension ".md" are rendered.
+	Include []string `json:"include"`
+
+	// Template is the path to the html/template file that wraps the rendered HTML, relative to the configuration file.
+	// If it is empty, the minimal HTML document is used.
+	Template string `json:"template"`
+}
+
+// imageRule is a rule that transforms the images.
+type imageRule struct {
+	// Include is the list of the glob patterns of the images.
+	Include []string `json:"include"`
+
+	// Widths is the list of the widths of the resized variants.
+	Widths []int `json:"widths"`
+
+	// Formats is the list of the formats of the alternates, "webp" or "avif".
+	Formats []string `json:"formats"`
+}
+
+// variantConfig is the asset set of a variant.
+type variantConfig struct {
+	// Input is the input directory or archive of the variant, relative to the configuration file.
+	// If it is empty, INPUT_DIR is used.
+	Input string `json:"input"`
+
+	// Include is the list of the glob patterns of the files to embed.
+	// If it is empty, all files are embedded.
+	Include []string `json:"include"`
+
+	// Exclude is the list of the glob patterns of the files not to embed.
+	Exclude []string `json:"exclude"`
+}
+
+// platformRule is a rule that embeds files only on specific platforms.
+type platformRule struct {
+	// Include is the list of the glob patterns of the files.
+	Include []string `json:"include"`
+
+	// Only is the list of the platforms where the files are embedded, in the form of "GOOS" or "GOOS/GOARCH".
+	Only []string `json:"only"`
+}
+
+// readConfig reads the configuration file.
+// The relative paths in the file are resolved from the directory of the file.
+func readConfig(filename string) (*config, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cfg config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, validationErrorf("failed to parse %s: %w", filename, err)
+	}
+	for name, v := range cfg.Variants {
+		if !isValidBuildTag(name) {
+			return nil, validationErrorf("%s: invalid variant name %q, it must be a valid build tag", filename, name)
+		}
+		if v == nil {
+			return nil, validationErrorf("%s: variant %q is null", filename, name)
+		}
+		if v.Input != "" && !filepath.IsAbs(v.Input) {
+			v.Input = filepath.Join(filepath.Dir(filename), filepath.FromSlash(v.Input))
+		}
+	}
+	for i, rule := range cfg.Platforms {
+		if rule == nil || len(rule.Include) == 0 || len(rule.Only) == 0 {
+			return nil, validationErrorf("%s: platform rule #%d must have both include and only", filename, i)
+		}
+		for _, s := range rule.Only {
+			if _, err := parsePlatform(s); err != nil {
+				return nil, validationErrorf("%s: %v", filename, err)
+			}
+		}
+	}
+	for i, rule := range cfg.Images {
+		if rule == nil || len(rule.Include) == 0 {
+			return nil, validationErrorf("%s: image rule #%d must have include", filename, i)
+		}
+		for _, w := range rule.Widths {
+			if w <= 0 {
+				return nil, validationErrorf("%s: image rule #%d has invalid width %d", filename, i, w)
+			}
+		}
+		for _, format := range rule.Formats {
+			if _, ok := imageEncoders[format]; !ok {
+				return nil, validationErrorf("%s: image rule #%d has unknown format %q", filename, i, format)
+			}
+		}
+	}
+	if rule := cfg.Templates; rule != nil && len(rule.Include) == 0 {
+		return nil, validationErrorf("%s: templates must have include", filename)
+	}
+	for _, rule := range cfg.Budgets {
+		b, err := parseBudget(rule)
+		if err != nil {
+			return nil, validationErrorf("%s: %v", filename, err)
+		}
+		cfg.budgets = append(cfg.budgets, b)
+	}
+	if cfg.Robots != nil {
+		if err := cfg.Robots.validate(); err != nil {
+			return nil, validationErrorf("%s: %v", filename, err)
+		}
+	}
+	if cfg.Security != nil {
+		if err := cfg.Security.validate(); err != nil {
+			return nil, validationErrorf("%s: %v", filename, err)
+		}
+	}
+	if md := cfg.Markdown; md != nil {
+		if md.Template != "" && !filepath.IsAbs(md.Template) {
+			md.Template = filepath.Join(filepath.Dir(filename), filepath.FromSlash(md.Template))
+		}
+	}
+	return &cfg, nil
+}
+
+// isValidBuildTag reports whether tag can be used as a build tag.
+func isValidBuildTag(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// shard is a generated file that has the file table for a build configuration.
+type shard struct {
+	// variant is the name of the variant, or empty for the input directory.
+	variant string
+
+	// tags is the build tags in the file name of the shard, e.g. "staging" and "windows".
+	tags []string
+
+	// constraint is the build constraint expression, or empty if the shard is always built.
+	constraint string
+
+	// assets is the assets embedded in the shard.
+	assets []*asset
+}
+
+// selected reports whether the shard is built on the current platform with the build tag of variant.
+func (sh *shard) selected(variant string) (bool, error) {
+	if sh.constraint == "" {
+		return true, nil
+	}
+	expr, err := constraint.Parse("//go:build " + sh.constraint)
+	if err != nil {
+		return false, err
+	}
+	return expr.Eval(func(tag string) bool {
+		return tag == runtime.GOOS || tag == runtime.GOARCH || (variant != "" && tag == variant)
+	}), nil
+}
+
+// filename returns the name of the generated file, e.g. filesystem-staging-windows.go.
+func (sh *shard) filename() string {
+	var buf strings.Builder
+	buf.WriteString("filesystem")
+	for _, tag := range sh.tags {
+		// underscores in the file name are special for the go command, e.g. filesystem_windows.go.
+		buf.WriteString("-" + strings.Replace(tag, "_", "-", -1))
+	}
+	buf.WriteString(".go")
+	return buf.String()
+}
+
+// shards collects the assets and splits them into the shards guarded by the build constraints.
+// The shards are mutually exclusive, so exactly one of them is built if at most one variant tag is set.
+func (opts *options) shards(cfg *config) ([]*shard, error) {
+	if cfg == nil {
+		cfg = &config{}
+	}
+	var variants []string
+	for name := range cfg.Variants {
+		variants = append(variants, name)
+	}
+	sort.Strings(variants)
+	if opts.variant != "" && cfg.Variants[opts.variant] == nil {
+		return nil, validationErrorf("variant %q is not defined", opts.variant)
+	}
+	if opts.sourceMaps == "debug" && cfg.Variants["debug"] != nil {
+		return nil, errors.New("the variant debug conflicts with the build tag of -source-maps debug")
+	}
+	if opts.filesFrom == "-" && len(variants) > 0 {
+		return nil, errors.New("-files-from - cannot be used with variants, because stdin can be read only once")
+	}
+
+	// the default variant is built when no variant tags are set.
+	var others []string
+	for _, name := range variants {
+		if name != opts.variant {
+			others = append(others, "!"+name)
+		}
+	}
+	if opts.variant == "" {
+		variants = append([]string{""}, variants...)
+	}
+
+	var shards []*shard
+	for _, name := range variants {
+		sh := &shard{
+			variant: name,
+		}
+		if name == opts.variant {
+			sh.constraint = strings.Join(others, " && ")
+		} else {
+			sh.tags = []string{name}
+			sh.constraint = name
+		}
+
+		in := opts.in
+		var v variantConfig
+		if name != "" {
+			v = *cfg.Variants[name]
+			if v.Input != "" {
+				in = v.Input
+			}
+		}
+		assets, err := opts.readAssets(in)
+		if err != nil {
+			return nil, err
+		}
+		sh.assets = filterAssets(assets, v.Include, append(append([]string(nil), opts.exclude...), v.Exclude...))
+		warnArtifacts(opts.log, sh.assets, opts.allowArtifacts)
+		split, err := splitPlatforms(sh, cfg.Platforms)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, split...)
+	}
+	return splitSourceMaps(shards, opts.sourceMaps), nil
+}
+
+// sourceMapURLPattern matches the sourceMappingURL comments of JavaScript and CSS.
+var sourceMapURLPattern = regexp.MustCompile(`(?m)^[ \t]*(?://[#@][ \t]*sourceMappingURL=[^\r\n]*|/\*[#@][ \t]*sourceMappingURL=[^*]*\*/)[ \t]*(?:\r?\n|$)`)
+
+// stripSourceMaps removes the source maps and the sourceMappingURL comments from assets.
+func stripSourceMaps(assets []*asset) []*asset {
+	ret := make([]*asset, 0, len(assets))
+	for _, a := range assets {
+		if a.mode.IsDir() {
+			ret = append(ret, a)
+			continue
+		}
+		switch path.Ext(a.name) {
+		case ".map":
+			continue
+		case ".js", ".mjs", ".css":
+			if sourceMapURLPattern.Match(a.content) {
+				// the assets are shared by the shards, so the stripped files are copied.
+				a = &asset{
+					name:    a.name,
+					mode:    a.mode,
+					modTime: a.modTime,
+					content: sourceMapURLPattern.ReplaceAll(a.content, nil),
+				}
+			}
+		}
+		ret = append(ret, a)
+	}
+	return ret
+}
+
+// splitSourceMaps applies the policy of the -source-maps option to the shards.
+// "strip" removes the source maps, and "debug" splits each shard into the one with the source maps for the debug build tag
+// and the one without them for the other builds.
+func splitSourceMaps(shards []*shard, policy string) []*shard {
+	switch policy {
+	case "strip":
+		for _, sh := range shards {
+			sh.assets = stripSourceMaps(sh.assets)
+		}
+		return shards
+	case "debug":
+		and := func(a, b string) string {
+			if a == "" {
+				return b
+			}
+			return a + " && " + b
+		}
+		ret := make([]*shard, 0, 2*len(shards))
+		for _, sh := range shards {
+			ret = append(ret, &shard{
+				variant:    sh.variant,
+				tags:       append(append([]string{}, sh.tags...), "debug"),
+				constraint: and(sh.constraint, "debug"),
+				assets:     sh.assets,
+			}, &shard{
+				variant:    sh.variant,
+				tags:       sh.tags,
+				constraint: and(sh.constraint, "!debug"),
+				assets:     stripSourceMaps(sh.assets),
+			})
+		}
+		return ret
+	}
+	return shards
+}
+
+// platform is a target platform of the platform rules.
+type platform struct {
+	goos   string
+	goarch string // empty for all architectures
+}
+
+// parsePlatform parses the platform in the form of "GOOS" or "GOOS/GOARCH".
+func parsePlatform(s string) (platform, error) {
+	var p platform
+	if idx := strings.IndexByte(s, '/'); idx >= 0 {
+		p.goos, p.goarch = s[:idx], s[idx+1:]
+		if !isValidBuildTag(p.goarch) {
+			return p, fmt.Errorf("invalid platform: %q", s)
+		}
+	} else {
+		p.goos = s
+	}
+	if !isValidBuildTag(p.goos) {
+		return p, fmt.Errorf("invalid platform: %q", s)
+	}
+	return p, nil
+}
+
+// expr returns the build constraint expression that selects the platform.
+func (p platform) expr() string {
+	if p.goarch == "" {
+		return p.goos
+	}
+	return "(" + p.goos + " && " + p.goarch + ")"
+}
+
+// implies reports whether q is satisfied on the platform p.
+func (p platform) implies(q platform) bool {
+	return p.goos == q.goos && (q.goarch == "" || p.goarch == q.goarch)
+}
+
+// splitPlatforms splits the shard by the platform rules.
+// The files that match the rules are embedded only in the shards of the platforms of the rules,
+// and the other files are embedded in all shards.
+func splitPlatforms(sh *shard, rules []*platformRule) ([]*shard, error) {
+	if len(rules) == 0 {
+		return []*shard{sh}, nil
+	}
+
+	// list the platforms in the rules.
+	// the specific platforms, e.g. linux/amd64, go first to take precedence over the generic ones, e.g. linux.
+	only := make([][]platform, len(rules))
+	seen := map[platform]bool{}
+	var platforms []platform
+	for i, rule := range rules {
+		for _, s := range rule.Only {
+			p, err := parsePlatform(s)
+			if err != nil {
+				return nil, err
+			}
+			only[i] = append(only[i], p)
+			if !seen[p] {
+				seen[p] = true
+				platforms = append(platforms, p)
+			}
+		}
+	}
+	sort.Slice(platforms, func(i, j int) bool {
+		pi, pj := platforms[i], platforms[j]
+		if (pi.goarch != "") != (pj.goarch != "") {
+			return pi.goarch != ""
+		}
+		if pi.goos != pj.goos {
+			return pi.goos < pj.goos
+		}
+		return pi.goarch < pj.goarch
+	})
+
+	// embedded reports whether the asset is embedded on the platform p.
+	// p is nil for the other platforms.
+	embedded := func(a *asset, p *platform) bool {
+		matched := false
+		for i, rule := range rules {
+			if !matchAny(rule.Include, a.name) {
+				continue
+			}
+			matched = true
+			for _, q := range only[i] {
+				if p != nil && p.implies(q) {
+					return true
+				}
+			}
+		}
+		return !matched
+	}
+	and := func(exprs ...string) string {
+		var ret []string
+		for _, expr := range exprs {
+			if expr != "" {
+				ret = append(ret, expr)
+			}
+		}
+		return strings.Join(ret, " && ")
+	}
+
+	var shards []*shard
+	var others []string
+	for i := range platforms {
+		p := &platforms[i]
+		exprs := []string{sh.constraint, p.expr()}
+		for _, q := range platforms[:i] {
+			if q.goos == p.goos && q.goarch != "" && p.goarch == "" {
+				exprs = append(exprs, "!"+q.expr())
+			}
+		}
+		others = append(others, "!"+p.expr())
+
+		tags := append(append([]string{}, sh.tags...), p.goos)
+		if p.goarch != "" {
+			tags = append(tags, p.goarch)
+		}
+		split := &shard{
+			variant:    sh.variant,
+			tags:       tags,
+			constraint: and(exprs...),
+		}
+		for _, a := range sh.assets {
+			if embedded(a, p) {
+				split.assets = append(split.assets, a)
+			}
+		}
+		shards = append(shards, split)
+	}
+
+	split := &shard{
+		variant:    sh.variant,
+		tags:       sh.tags,
+		constraint: and(append([]string{sh.constraint}, others...)...),
+	}
+	for _, a := range sh.assets {
+		if embedded(a, nil) {
+			split.assets = append(split.assets, a)
+		}
+	}
+	return append(shards, split), nil
+}
+
+// buildConstraint returns the build constraint lines of the expression in the //go:build syntax,
+// with the equivalent // +build lines for Go 1.16.
+// It returns an empty string if expr is empty.
+func buildConstraint(expr string) (string, error) {
+	if expr == "" {
+		return "", nil
+	}
+	x, err := constraint.Parse("//go:build " + expr)
+	if err != nil {
+		return "", err
+	}
+	lines := []string{"//go:build " + x.String()}
+	plus, err := constraint.PlusBuildLines(x)
+	if err != nil {
+		return "", err
+	}
+	lines = append(lines, plus...)
+	return strings.Join(lines, "\n"), nil
+}
+
+// shardMeta is the metadata of the assets of a shard found by process.
+type shardMeta struct {
+	// charsets maps the names of the files converted to UTF-8 to their original charsets.
+	charsets map[string]string
+
+	// licenses is the licenses of the third-party files.
+	licenses []templateLicense
+
+	// fingerprints maps the names of the fingerprinted files to their names with the hashes.
+	fingerprints map[string]string
+}
+
+// process applies the transforms of the options to the assets of sh, e.g. the hooks and fingerprinting.
+func (opts *options) process(sh *shard, cfg *config) (*shardMeta, error) {
+	if opts.maxMemory > 0 && cfg != nil && (cfg.Templates != nil || cfg.Markdown != nil || len(cfg.Images) > 0) {
+		return nil, validationErrorf("-max-memory cannot be used with the rules of the templates, markdown and images, which read the contents")
+	}
+	meta := &shardMeta{}
+	var err error
+	sh.assets, err = gzipSources(sh.assets, opts.gzipSources)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.charsets) > 0 {
+		sh.assets, meta.charsets = convertCharsets(opts.log, sh.assets, opts.charsets)
+	}
+	if opts.normalizeEOL != "" || opts.stripBOM {
+		sh.assets = normalizeText(sh.assets, opts.normalizeEOL, opts.stripBOM)
+	}
+	if cfg != nil && cfg.Templates != nil {
+		sh.assets, err = expandTemplates(sh.assets, cfg.Templates, opts.vars)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.stripMetadata {
+		sh.assets, err = stripMetadata(sh.assets)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg != nil && cfg.Markdown != nil {
+		sh.assets, err = renderMarkdownAssets(sh.assets, cfg.Markdown)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg != nil && len(cfg.Images) > 0 {
+		sh.assets, err = transformImages(sh.assets, cfg.Images)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.htmlBase != "" || len(opts.htmlMeta) > 0 || opts.htmlInject != "" {
+		rw := &htmlRewrite{base: opts.htmlBase, meta: opts.htmlMeta, log: opts.log}
+		if opts.htmlInject != "" {
+			b, err := os.ReadFile(opts.htmlInject)
+			if err != nil {
+				return nil, err
+			}
+			rw.inject = string(b)
+		}
+		sh.assets, err = applyHooks(sh.assets, []hook{{transform: rw.transform}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sh.assets, err = applyHooks(sh.assets, opts.hooks)
+	if err != nil {
+		return nil, err
+	}
+	if opts.notices {
+		meta.licenses = findLicenses(sh.assets)
+	}
+	if opts.fingerprint {
+		sh.assets, meta.fingerprints = fingerprintAssets(sh.assets, opts.hash)
+	}
+	if opts.notices {
+		sh.assets, err = addNotices(sh.assets, meta.licenses)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg != nil && (cfg.Robots != nil || cfg.Security != nil) {
+		sh.assets, err = addWellKnown(opts.log, sh.assets, cfg, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.precache || opts.serviceWorker {
+		sh.assets, err = addPrecache(sh.assets, opts.serviceWorker, opts.hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.compression == Gzip {
+		sh.assets, err = compressAssets(sh.assets)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := checkNames(sh.assets); err != nil {
+		return nil, err
+	}
+	if cfg != nil && len(cfg.budgets) > 0 {
+		if err := checkBudgets(opts.log, sh.assets, cfg.budgets, sh.variant); err != nil {
+			return nil, err
+		}
+	}
+	return meta, nil
+}
+
+// checkNames returns an error if any name of assets cannot be opened in the generated package,
+// e.g. the names with backslashes, which are valid on Unix but rejected as the path traversals.
+func checkNames(assets []*asset) error {
+	for _, a := range assets {
+		if a.name != "/" && (!strings.HasPrefix(a.name, "/") || !fs.ValidPath(a.name[1:]) || strings.ContainsAny(a.name, "\\\x00")) {
+			return validationErrorf("%q cannot be embedded, the names must be clean absolute paths without backslashes and NUL bytes", a.name)
+		}
+	}
+	return nil
+}
+
+// store stores the contents of the files in data by the backend of opts,
+// and writes the data files of the backend into out.
+func store(opts *options, out output, filename string, data *templateData) error {
+	if opts.maxMemory > 0 {
+		dir, err := out.streamDir()
+		if err != nil {
+			return err
+		}
+		return storeStreaming(opts, dir, data)
+	}
+	contents := make([]string, len(data.Files))
+	for i, f := range data.Files {
+		contents[i] = f.Content
+	}
+	s, err := opts.backend.Store(filename, contents)
+	if err != nil {
+		return fmt.Errorf("backend %s: %w", opts.backend.Name(), err)
+	}
+	if len(s.Exprs) != len(contents) {
+		return fmt.Errorf("backend %s: %d expressions for %d files", opts.backend.Name(), len(s.Exprs), len(contents))
+	}
+	for i := range data.Files {
+		data.Files[i].Expr = s.Exprs[i]
+		data.Files[i].Lazy = s.Lazy && s.Exprs[i] != `""`
+	}
+	data.Imports = s.Imports
+	data.Decls = s.Decls
+	for name, b := range s.Files {
+		if err := out.writeFile(dataDir+"/"+name, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeShards removes the shards and the adapters generated previously,
+// because the shards of the removed variants and the adapters without their frameworks break the build.
+func removeShards(dir string) error {
+	var matches []string
+	for _, pattern := range []string{"filesystem-*.go", "adapter-*.go", benchFilename} {
+		m, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return err
+		}
+		matches = append(matches, m...)
+	}
+	for _, filename := range matches {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(b, []byte("// Code generated by go run ")) {
+			// it is not generated by assets-life.
+			continue
+		}
+		if err := os.Remove(filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterAssets returns the assets that match any of include and don't match any of exclude.
+// If include is empty, all assets match it.
+func filterAssets(assets []*asset, include, exclude []string) []*asset {
+	if len(include) == 0 && len(exclude) == 0 {
+		return assets
+	}
+	var ret []*asset
+	for _, a := range assets {
+		if len(include) > 0 && !matchAny(include, a.name) {
+			continue
+		}
+		if matchAny(exclude, a.name) {
+			continue
+		}
+		ret = append(ret, a)
+	}
+	return ret
+}
+
+// matchAny reports whether the name matches any of patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether the slash-separated absolute path name matches the glob pattern.
+// A pattern without slashes matches the base name in any directory, e.g. "*.map".
+// A pattern with slashes matches the path from the root, e.g. "/js/*.js", and "**" matches zero or more directories.
+// A pattern that matches a directory also matches all files in it.
+func matchGlob(pattern, name string) bool {
+	elems := strings.Split(strings.Trim(name, "/"), "/")
+	if !strings.Contains(pattern, "/") {
+		for _, elem := range elems {
+			if ok, _ := path.Match(pattern, elem); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matchElems(strings.Split(strings.Trim(pattern, "/"), "/"), elems)
+}
+
+func matchElems(pattern, elems []string) bool {
+	if len(pattern) == 0 {
+		// the pattern matches the directory, so it also matches the files in it.
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(elems); i++ {
+			if matchElems(pattern[1:], elems[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elems) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+		return false
+	}
+	return matchElems(pattern[1:], elems[1:])
+}
+
+// artifactPatterns is the patterns of the build artifacts and the junk files, which are rarely meant to be embedded.
+var artifactPatterns = []struct {
+	pattern string
+	reason  string
+}{
+	{"node_modules", "the dependencies of npm"},
+	{"*.psd", "a Photoshop document"},
+	{"*.log", "a log file"},
+	{".DS_Store", "the metadata of macOS Finder"},
+	{"Thumbs.db", "the thumbnail cache of Windows"},
+	{"core", "a core dump"},
+	{"core.[0-9]*", "a core dump"},
+}
+
+// artifact is a build artifact found by findArtifacts.
+type artifact struct {
+	// name is the name of the file or the directory, e.g. "/node_modules".
+	name string
+
+	// pattern is the pattern in artifactPatterns that matches name.
+	pattern string
+
+	// reason describes what the artifact looks like.
+	reason string
+
+	// files is the number of the files in the artifact, and size is their total size.
+	files int
+	size  int64
+}
+
+// findArtifacts finds the assets that look like the build artifacts, excluding the ones that match the allowed patterns.
+// The files in a matched directory, e.g. node_modules, are reported as one artifact of the directory.
+func findArtifacts(assets []*asset, allowed []string) []*artifact {
+	found := map[string]*artifact{}
+	var names []string
+	for _, a := range assets {
+		if a.mode.IsDir() || matchAny(allowed, a.name) {
+			continue
+		}
+		elems := strings.Split(strings.Trim(a.name, "/"), "/")
+	elems:
+		for i, elem := range elems {
+			for _, p := range artifactPatterns {
+				if ok, _ := path.Match(p.pattern, elem); !ok {
+					continue
+				}
+				name := "/" + strings.Join(elems[:i+1], "/")
+				art, ok := found[name]
+				if !ok {
+					art = &artifact{name: name, pattern: p.pattern, reason: p.reason}
+					found[name] = art
+					names = append(names, name)
+				}
+				art.files++
+				art.size += int64(len(a.content))
+				break elems
+			}
+		}
+	}
+	sort.Strings(names)
+	ret := make([]*artifact, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, found[name])
+	}
+	return ret
+}
+
+// warnArtifacts warns of the assets that look like the build artifacts.
+// They fail the generation with -strict.
+func warnArtifacts(log *Logger, assets []*asset, allowed []string) {
+	for _, art := range findArtifacts(assets, allowed) {
+		if art.files > 1 {
+			log.Warnf("%s looks like %s (%d files, %s), exclude it or allow it with -allow-artifacts %s",
+				art.name, art.reason, art.files, formatBytes(art.size), art.pattern)
+		} else {
+			log.Warnf("%s looks like %s (%s), exclude it or allow it with -allow-artifacts %s",
+				art.name, art.reason, formatBytes(art.size), art.pattern)
+		}
+	}
+}
+
+// asset is a file or a directory to be embedded.
+type asset struct {
+	// name is the slash-separated absolute path in the generated file system, e.g. "/index.html".
+	name string
+
+	// mode is the mode of the source file.
+	mode os.FileMode
+
+	// modTime is the modification time of the source file, or zero if it is unknown, e.g. in git.
+	modTime time.Time
+
+	// content is the content of the file. It is nil for directories.
+	content []byte
+
+	// path is the path of the file whose content is read when it is written in the memory-bounded mode of -max-memory.
+	// content is nil if path is set.
+	path string
+}
+
+// size returns the size of the content, which is read from the file in the memory-bounded mode of -max-memory.
+func (a *asset) size() (int64, error) {
+	if a.path == "" {
+		return int64(len(a.content)), nil
+	}
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// limits is the guards against the runaway trees, e.g. the loops of the bind mounts.
+// The zero values mean no limits.
+type limits struct {
+	// maxFiles is the maximum number of the files, excluding the directories.
+	maxFiles int
+
+	// maxDepth is the maximum depth of the files, e.g. "/a/b/c" is at the depth 3.
+	maxDepth int
+}
+
+// check returns an error if the file name is deeper than the limit,
+// or n, the number of the files so far, exceeds the limit.
+func (l limits) check(name string, n int) error {
+	if l.maxDepth > 0 && name != "/" {
+		if depth := strings.Count(strings.Trim(name, "/"), "/") + 1; depth > l.maxDepth {
+			return validationErrorf("%s: the depth %d exceeds the limit of -max-depth %d", name, depth, l.maxDepth)
+		}
+	}
+	if l.maxFiles > 0 && n > l.maxFiles {
+		return validationErrorf("the number of the files exceeds the limit of -max-files %d", l.maxFiles)
+	}
+	return nil
+}
+
+// checkAssets returns an error if the assets exceed the limits.
+func (l limits) checkAssets(assets []*asset) error {
+	var n int
+	for _, a := range assets {
+		if !a.mode.IsDir() {
+			n++
+		}
+		if err := l.check(a.name, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkDir collects the assets in the directory root, excluding hidden files.
+// If skipUnreadable is true, the files that cannot be read because of the permissions are skipped with warnings.
+// The walk stops as soon as the files exceed lim, before their contents are read.
+func walkDir(log *Logger, root string, skipUnreadable bool, lim limits) ([]*asset, error) {
+	// list the files first to know the total size for the progress.
+	entries, err := listDir(log, root, skipUnreadable, lim)
+	if err != nil {
+		return nil, err
+	}
+	return readEntries(log, root, entries, skipUnreadable)
+}
+
+// listDir lists the files in the directory root for walkDir, without reading their contents.
+func listDir(log *Logger, root string, skipUnreadable bool, lim limits) ([]fileEntry, error) {
+	var entries []fileEntry
+	var n int
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if skipUnreadable && errors.Is(err, fs.ErrPermission) && path != root {
+				log.Warnf("skip unreadable file: %v", err)
+				return nil
+			}
+			return err
+		}
+
+		// ignore hidden files
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			n++
+		}
+		if err := lim.check("/"+filepath.ToSlash(rel), n); err != nil {
+			return err
+		}
+
+		entries = append(entries, fileEntry{path: path, info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// lazyAssets returns the assets of entries in the directory root without reading their contents,
+// for the memory-bounded mode of -max-memory.
+func lazyAssets(root string, entries []fileEntry) ([]*asset, error) {
+	assets := make([]*asset, 0, len(entries))
+	for _, e := range entries {
+		a, err := newAsset(root, e.path, e.info, true)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, a)
+	}
+	return assets, nil
+}
+
+// fileEntry is a file to read.
+type fileEntry struct {
+	path string
+	info os.FileInfo
+}
+
+// readEntries reads the assets of entries in the directory root, reporting the progress.
+func readEntries(log *Logger, root string, entries []fileEntry, skipUnreadable bool) ([]*asset, error) {
+	var size int64
+	for _, e := range entries {
+		if !e.info.IsDir() {
+			size += e.info.Size()
+		}
+	}
+	p := newProgress(log, "reading", len(entries), size)
+	defer p.done()
+
+	assets := make([]*asset, 0, len(entries))
+	for _, e := range entries {
+		a, err := newAsset(root, e.path, e.info, false)
+		if err != nil {
+			if skipUnreadable && errors.Is(err, fs.ErrPermission) {
+				log.Warnf("skip unreadable file: %v", err)
+				continue
+			}
+			return nil, err
+		}
+		p.add(int64(len(a.content)))
+		assets = append(assets, a)
+	}
+	return assets, nil
+}
+
+// readFileList collects the assets listed in r.
+// r is a newline-separated list of paths relative to root, e.g. the output of git ls-files.
+// Unlike walkDir, the listed directories are not walked, and hidden files are not ignored.
+func readFileList(log *Logger, r io.Reader, root string, skipUnreadable bool) ([]*asset, error) {
+	var entries []fileEntry
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		path := line
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			if skipUnreadable && errors.Is(err, fs.ErrPermission) {
+				log.Warnf("skip unreadable file: %v", err)
+				continue
+			}
+			return nil, err
+		}
+		entries = append(entries, fileEntry{path: path, info: info})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	assets, err := readEntries(log, root, entries, skipUnreadable)
+	if err != nil {
+		return nil, err
+	}
+	if len(assets) == 0 {
+		return nil, validationErrorf("no files are listed")
+	}
+	return assets, nil
+}
+
+// readGitTree collects the assets in the directory root at the git revision ref, excluding hidden files.
+// The contents are read from the git object database, so the changes in the working tree are ignored.
+func readGitTree(root, ref string) ([]*asset, error) {
+	// list the files in the tree.
+	// the paths are relative to root because root is the working directory.
+	cmd := exec.Command("git", "ls-tree", "-r", "-z", ref, "--", ".")
+	cmd.Dir = root
+	cmd.Stderr = os.Stderr
+	list, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the files in %s: %v", ref, err)
+	}
+
+	var assets []*asset
+	var objects bytes.Buffer
+LIST:
+	for _, line := range strings.Split(string(list), "\x00") {
+		if line == "" {
+			continue
+		}
+		// the format is "<mode> SP <type> SP <object> TAB <file>"
+		idx := strings.IndexByte(line, '\t')
+		if idx < 0 {
+			return nil, fmt.Errorf("unexpected output of git ls-tree: %q", line)
+		}
+		meta, name := strings.Fields(line[:idx]), line[idx+1:]
+		if len(meta) != 3 {
+			return nil, fmt.Errorf("unexpected output of git ls-tree: %q", line)
+		}
+
+		// ignore hidden files
+		for _, elem := range strings.Split(name, "/") {
+			if strings.HasPrefix(elem, ".") {
+				continue LIST
+			}
+		}
+
+		a := &asset{
+			name: path.Clean("/" + name),
+		}
+		switch meta[0] {
+		case "100644":
+			a.mode = 0644
+		case "100755":
+			a.mode = 0755
+		default:
+			return nil, fmt.Errorf("unsupported file type: %s, mode %s", name, meta[0])
+		}
+		assets = append(assets, a)
+		objects.WriteString(meta[2] + "\n")
+	}
+
+	// read the contents of the files.
+	cmd = exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = root
+	cmd.Stdin = &objects
+	cmd.Stderr = os.Stderr
+	contents, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the files in %s: %v", ref, err)
+	}
+	for _, a := range assets {
+		// the format is "<object> SP <type> SP <size> LF <contents> LF"
+		idx := bytes.IndexByte(contents, '\n')
+		if idx < 0 {
+			return nil, errors.New("unexpected EOF of git cat-file")
+		}
+		meta := strings.Fields(string(contents[:idx]))
+		if len(meta) != 3 {
+			return nil, fmt.Errorf("unexpected output of git cat-file: %q", contents[:idx])
+		}
+		size, err := strconv.Atoi(meta[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output of git cat-file: %q", contents[:idx])
+		}
+		contents = contents[idx+1:]
+		if len(contents) < size+1 {
+			return nil, errors.New("unexpected EOF of git cat-file")
+		}
+		a.content = contents[:size]
+		contents = contents[size+1:]
+	}
+	return assets, nil
+}
+
+// isArchive reports whether filename is an archive file that readArchive supports.
+func isArchive(filename string) bool {
+	info, err := os.Stat(filename)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	name := strings.ToLower(filename)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz", ".alb"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// readArchive collects the assets in the zip or tar archive or the bundle, excluding hidden files.
+// The directory structure in the archive is preserved.
+func readArchive(log *Logger, filename string) ([]*asset, error) {
+	name := strings.ToLower(filename)
+	if strings.HasSuffix(name, ".zip") {
+		return readZip(log, filename)
+	}
+	if strings.HasSuffix(name, ".alb") {
+		return readBundleFile(filename)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return readTar(log, r)
+}
+
+func readZip(log *Logger, filename string) ([]*asset, error) {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var size int64
+	for _, f := range r.File {
+		size += int64(f.UncompressedSize64)
+	}
+	p := newProgress(log, "reading", len(r.File), size)
+	defer p.done()
+
+	var assets []*asset
+	for _, f := range r.File {
+		a, err := newArchiveAsset(f.Name, f.Mode())
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			continue
+		}
+		a.modTime = f.Modified
+		if !a.mode.IsDir() {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			a.content, err = io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		p.add(int64(len(a.content)))
+		assets = append(assets, a)
+	}
+	return assets, nil
+}
+
+func readTar(log *Logger, r io.Reader) ([]*asset, error) {
+	// the total size of a tar archive is unknown until reading it to the end.
+	p := newProgress(log, "reading", 0, 0)
+	defer p.done()
+
+	var assets []*asset
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		a, err := newArchiveAsset(h.Name, h.FileInfo().Mode())
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			continue
+		}
+		a.modTime = h.ModTime
+		if !a.mode.IsDir() {
+			a.content, err = io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+		}
+		p.add(int64(len(a.content)))
+		assets = append(assets, a)
+	}
+	return assets, nil
+}
+
+// bundleMagic is the header of the bundles, "ALB" and the version of the format.
+// It must be the same as bundleMagic of the generated package, which documents the format.
+const bundleMagic = "ALB\x02"
+
+// maxBundleSize is the maximum size in bytes of the payload of a bundle, and of the files in the payload after inflating.
+// It must be the same as maxBundleSize of the generated package.
+const maxBundleSize = 1 << 30
+
+// WriteBundle writes files into the bundle filename, which LoadBundle of the generated packages loads,
+// signed by the Ed25519 private key in PEM at signKey, e.g. generated by "openssl genpkey -algorithm ed25519".
+// The bundle is not signed if signKey is empty, and LoadBundle rejects it.
+func WriteBundle(filename string, files []*File, signKey string) error {
+	var key ed25519.PrivateKey
+	if signKey != "" {
+		var err error
+		key, err = readSignKey(signKey)
+		if err != nil {
+			return err
+		}
+	}
+	assets, err := assetsOf(files)
+	if err != nil {
+		return err
+	}
+	return writeBundleFile(filename, assets, key)
+}
+
+// writeBundleFile writes assets into the bundle filename, signed by key if it is not nil.
+// The bundle is written into a temporary file and renamed, so LoadBundle never reads a partial bundle.
+func writeBundleFile(filename string, assets []*asset, key ed25519.PrivateKey) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".tmp-*.alb")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeBundle(tmp, assets, key); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// writeBundle writes assets into w in the format of the bundles, signed by key if it is not nil.
+// The signature covers the compressed payload, which has the names, the modes and the contents of the files and the directories.
+func writeBundle(w io.Writer, assets []*asset, key ed25519.PrivateKey) error {
+	sorted := make([]*asset, len(assets))
+	copy(sorted, assets)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+	var raw bytes.Buffer
+	pw := bufio.NewWriter(&raw)
+	for _, a := range sorted {
+		if a.name == "/" {
+			continue
+		}
+		writeBundleBytes(pw, []byte(a.name))
+		writeBundleUvarint(pw, uint64(a.mode&(os.ModeDir|os.ModePerm)))
+		writeBundleBytes(pw, a.content)
+	}
+	writeBundleBytes(pw, nil)
+	if err := pw.Flush(); err != nil {
+		return err
+	}
+	if raw.Len() > maxBundleSize {
+		return fmt.Errorf("the files in the bundle are larger than %d bytes", maxBundleSize)
+	}
+
+	var payload bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&payload, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := raw.WriteTo(zw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if payload.Len() > maxBundleSize {
+		return fmt.Errorf("the bundle is larger than %d bytes", maxBundleSize)
+	}
+	var sig []byte
+	if key != nil {
+		sig = ed25519.Sign(key, payload.Bytes())
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(bundleMagic)
+	writeBundleBytes(bw, sig)
+	writeBundleBytes(bw, payload.Bytes())
+	return bw.Flush()
+}
+
+func writeBundleUvarint(w *bufio.Writer, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func writeBundleBytes(w *bufio.Writer, b []byte) {
+	writeBundleUvarint(w, uint64(len(b)))
+	w.Write(b)
+}
+
+// readBundleFile collects the assets in the bundle filename.
+// The signature is not verified, because the generator trusts its input.
+func readBundleFile(filename string) ([]*asset, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	assets, _, err := readBundle(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return assets, nil
+}
+
+// readBundle reads the assets and the signature of the bundle from r.
+func readBundle(r io.Reader) ([]*asset, []byte, error) {
+	var magic [len(bundleMagic)]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, nil, err
+	}
+	if string(magic[:3]) != bundleMagic[:3] {
+		return nil, nil, errors.New("not a bundle")
+	}
+	if magic[3] != bundleMagic[3] {
+		return nil, nil, fmt.Errorf("unsupported version of the bundle: %d", magic[3])
+	}
+	br := bufio.NewReader(r)
+	sig, err := readBundleBytes(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n > maxBundleSize {
+		return nil, nil, fmt.Errorf("the bundle is larger than %d bytes", maxBundleSize)
+	}
+	zr, err := gzip.NewReader(io.LimitReader(br, int64(n)))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zr.Close()
+	lr := &io.LimitedReader{R: zr, N: maxBundleSize + 1}
+	zbr := bufio.NewReader(lr)
+
+	assets, err := readBundleAssets(zbr)
+	if err == nil {
+		// read to the end, so the gzip reader verifies the checksum.
+		if _, err = zbr.ReadByte(); err == nil {
+			err = errors.New("unexpected data after the files")
+		} else if err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		if lr.N <= 0 {
+			return nil, nil, fmt.Errorf("the files in the bundle are larger than %d bytes", maxBundleSize)
+		}
+		return nil, nil, err
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		if err == nil {
+			err = errors.New("unexpected data after the payload")
+		}
+		return nil, nil, err
+	}
+	return assets, sig, nil
+}
+
+// readBundleAssets reads the assets in the inflated payload of a bundle up to the empty name.
+func readBundleAssets(br *bufio.Reader) ([]*asset, error) {
+	var assets []*asset
+	for {
+		name, err := readBundleBytes(br)
+		if err != nil {
+			return nil, err
+		}
+		if len(name) == 0 {
+			return assets, nil
+		}
+		mode, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		content, err := readBundleBytes(br)
+		if err != nil {
+			return nil, err
+		}
+		if string(name) != path.Clean("/"+string(name)) {
+			return nil, fmt.Errorf("invalid name: %q", name)
+		}
+		a, err := newArchiveAsset(string(name), os.FileMode(mode)&(os.ModeDir|os.ModePerm))
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			continue
+		}
+		if !a.mode.IsDir() {
+			a.content = content
+		}
+		assets = append(assets, a)
+	}
+}
+
+// readBundleBytes reads the length in uvarint and the bytes of the length.
+func readBundleBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	// the buffer grows as the bytes are read, so the broken length does not allocate the memory at once.
+	var buf bytes.Buffer
+	m, err := io.CopyN(&buf, r, int64(n))
+	if err == io.EOF || err == nil && uint64(m) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newArchiveAsset returns the asset of the archive entry.
+// It returns nil if the entry should be ignored.
+func newArchiveAsset(name string, mode os.FileMode) (*asset, error) {
+	if (mode&os.ModeType)|os.ModeDir != os.ModeDir {
+		return nil, fmt.Errorf("unsupported file type: %s, mode %s", name, mode)
+	}
+	name = path.Clean("/" + strings.TrimPrefix(name, "./"))
+
+	// ignore hidden files
+	for _, elem := range strings.Split(name, "/") {
+		if strings.HasPrefix(elem, ".") {
+			return nil, nil
+		}
+	}
+	return &asset{
+		name: name,
+		mode: mode,
+	}, nil
+}
+
+// filterExportIgnore removes the assets that have the export-ignore attribute in the directory root.
+// If ref is not empty, the attributes are read from the git revision ref.
+func filterExportIgnore(assets []*asset, root, ref string) ([]*asset, error) {
+	// the attribute of a directory applies to all files in it,
+	// so check the parent directories too.
+	var paths []string
+	seen := map[string]bool{}
+	for _, a := range assets {
+		for name := a.name; name != "/" && !seen[name]; name = path.Dir(name) {
+			seen[name] = true
+			paths = append(paths, name[1:])
+		}
+	}
+	if len(paths) == 0 {
+		return assets, nil
+	}
+
+	args := []string{"check-attr", "-z", "--stdin"}
+	if ref != "" {
+		args = append(args, "--source", ref)
+	}
+	args = append(args, "export-ignore")
+	cmd := exec.Command("git", args...)
+	cmd.Dir = root
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the export-ignore attributes: %v", err)
+	}
+
+	// the format is "<path> NUL <attribute> NUL <info> NUL"
+	ignored := map[string]bool{}
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		if fields[i+2] == "set" {
+			ignored["/"+fields[i]] = true
+		}
+	}
+
+	ret := assets[:0]
+LOOP:
+	for _, a := range assets {
+		for name := a.name; name != "/"; name = path.Dir(name) {
+			if ignored[name] {
+				continue LOOP
+			}
+		}
+		ret = append(ret, a)
+	}
+	return ret, nil
+}
+
+// readRemoteManifest downloads the remote files listed in the manifest.
+// Each line of the manifest is "URL NAME [sha256:HEX]", e.g.
+//
+//     https://cdn.example.com/lib.js /vendor/lib.js sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+//
+// Empty lines and lines starting with "#" are ignored.
+// The files pinned by the checksums are cached in cacheDir, and they are not downloaded again.
+func readRemoteManifest(log *Logger, manifest, cacheDir string) ([]*asset, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if cacheDir == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		cacheDir = filepath.Join(dir, "assets-life")
+	}
+
+	var assets []*asset
+	s := bufio.NewScanner(f)
+	var lineno int
+	for s.Scan() {
+		lineno++
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: want \"URL NAME [sha256:HEX]\", got %q", manifest, lineno, line)
+		}
+		var sum string
+		if len(fields) == 3 {
+			if !strings.HasPrefix(fields[2], "sha256:") {
+				return nil, fmt.Errorf("%s:%d: unsupported checksum: %s", manifest, lineno, fields[2])
+			}
+			sum = strings.ToLower(strings.TrimPrefix(fields[2], "sha256:"))
+		}
+		content, err := fetchRemote(log, fields[0], sum, cacheDir)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", manifest, lineno, err)
+		}
+		assets = append(assets, &asset{
+			name:    path.Clean("/" + fields[1]),
+			mode:    0644,
+			content: content,
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// fetchRemote downloads the content of the url.
+// If sum is not empty, the content is verified with it and cached in cacheDir.
+func fetchRemote(log *Logger, url, sum, cacheDir string) ([]byte, error) {
+	cache := filepath.Join(cacheDir, "sha256", sum)
+	if sum != "" {
+		if b, err := os.ReadFile(cache); err == nil && sha256Hex(b) == sum {
+			return b, nil
+		}
+	}
+
+	client := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	got := sha256Hex(b)
+	if sum == "" {
+		log.Warnf("%s is not pinned, its checksum is sha256:%s", url, got)
+		return b, nil
+	}
+	if got != sum {
+		return nil, fmt.Errorf("checksum mismatch of %s: want sha256:%s, got sha256:%s", url, sum, got)
+	}
+
+	// save the content into the cache.
+	if err := os.MkdirAll(filepath.Dir(cache), 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(cache), sum+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), cache); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashFuncs is the hash algorithms of the -hash option, for the fingerprints, the revisions of the precache manifest,
+// and the hashes of the contents in the generated package.
+// crc32 is the fastest for the huge trees, sha256 and blake3 have the strongest guarantee against the collisions,
+// and blake3 is faster than sha256 without the hardware acceleration.
+var hashFuncs = map[string]func() hash.Hash{
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"blake3": newBlake3,
+}
+
+// checkHash returns the error if the hash algorithm alg is not supported.
+func checkHash(alg string) error {
+	if _, ok := hashFuncs[alg]; ok {
+		return nil
+	}
+	return fmt.Errorf("unknown hash algorithm: %q, use crc32, sha1, sha256 or blake3", alg)
+}
+
+// digestHex returns the first n hex digits of the hash of b by the algorithm alg, or all digits if the hash is shorter.
+// An empty alg is sha256.
+func digestHex(alg string, b []byte, n int) string {
+	sum := hashHex(alg, b)
+	if len(sum) > n {
+		sum = sum[:n]
+	}
+	return sum
+}
+
+// hashHex returns the hash of b by the algorithm alg in hex. An empty alg is sha256.
+func hashHex(alg string, b []byte) string {
+	h := newHash(alg)
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newHash returns the hash of the algorithm alg. An empty alg is sha256.
+func newHash(alg string) hash.Hash {
+	newHash, ok := hashFuncs[alg]
+	if !ok {
+		newHash = sha256.New
+	}
+	return newHash()
+}
+
+// blake3 is the BLAKE3 hash of -hash blake3, which is not in the standard library.
+// It implements only the default mode with the 256-bit output, see https://github.com/BLAKE3-team/BLAKE3-specs.
+// The generated package has the same implementation for -hash blake3.
+type blake3 struct {
+	cv       [8]uint32   // the chaining value of the current chunk
+	chunk    uint64      // the index of the current chunk
+	blocks   int         // the number of the compressed blocks of the current chunk
+	block    [64]byte    // the current block, padded with zeros
+	blockLen int         // the length of the current block
+	stack    [][8]uint32 // the chaining values of the completed subtrees
+}
+
+const (
+	blake3ChunkStart = 1 << 0
+	blake3ChunkEnd   = 1 << 1
+	blake3Parent     = 1 << 2
+	blake3Root       = 1 << 3
+)
+
+var blake3IV = [8]uint32{0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19}
+
+var blake3Permutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+func newBlake3() hash.Hash {
+	return &blake3{cv: blake3IV}
+}
+
+func (h *blake3) Size() int      { return 32 }
+func (h *blake3) BlockSize() int { return 64 }
+
+func (h *blake3) Reset() {
+	*h = blake3{cv: blake3IV, stack: h.stack[:0]}
+}
+
+func (h *blake3) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if h.blockLen == len(h.block) {
+			// the block is compressed only when more input follows, because the last block has the end flag.
+			if h.blocks == 15 {
+				h.pushChunk()
+			} else {
+				out := blake3Compress(h.cv, &h.block, h.chunk, uint32(h.blockLen), h.flags())
+				copy(h.cv[:], out[:8])
+				h.blocks++
+			}
+			h.block = [64]byte{}
+			h.blockLen = 0
+		}
+		m := copy(h.block[h.blockLen:], p)
+		h.blockLen += m
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// flags returns the flags of the current block of the chunk.
+func (h *blake3) flags() uint32 {
+	if h.blocks == 0 {
+		return blake3ChunkStart
+	}
+	return 0
+}
+
+// pushChunk completes the current chunk, and merges the completed subtrees of the same size.
+func (h *blake3) pushChunk() {
+	out := blake3Compress(h.cv, &h.block, h.chunk, uint32(h.blockLen), h.flags()|blake3ChunkEnd)
+	var cv [8]uint32
+	copy(cv[:], out[:8])
+	for total := h.chunk + 1; total&1 == 0; total >>= 1 {
+		cv = blake3ParentCV(h.stack[len(h.stack)-1], cv)
+		h.stack = h.stack[:len(h.stack)-1]
+	}
+	h.stack = append(h.stack, cv)
+	h.chunk++
+	h.cv = blake3IV
+	h.blocks = 0
+}
+
+// Sum appends the hash to b. It doesn't change the state of h.
+func (h *blake3) Sum(b []byte) []byte {
+	cv, block, counter, blockLen, flags := h.cv, h.block, h.chunk, uint32(h.blockLen), h.flags()|blake3ChunkEnd
+	for i := len(h.stack) - 1; i >= 0; i-- {
+		// the current node is the right child of the parent.
+		out := blake3Compress(cv, &block, counter, blockLen, flags)
+		var right [8]uint32
+		copy(right[:], out[:8])
+		block = blake3ParentBlock(h.stack[i], right)
+		cv, counter, blockLen, flags = blake3IV, 0, 64, blake3Parent
+	}
+	out := blake3Compress(cv, &block, counter, blockLen, flags|blake3Root)
+	var sum [32]byte
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(sum[4*i:], out[i])
+	}
+	return append(b, sum[:]...)
+}
+
+// blake3ParentBlock returns the block of the parent node of the chaining values of the children.
+func blake3ParentBlock(left, right [8]uint32) [64]byte {
+	var block [64]byte
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(block[4*i:], left[i])
+		binary.LittleEndian.PutUint32(block[32+4*i:], right[i])
+	}
+	return block
+}
+
+// blake3ParentCV returns the chaining value of the parent node of the chaining values of the children.
+func blake3ParentCV(left, right [8]uint32) [8]uint32 {
+	block := blake3ParentBlock(left, right)
+	out := blake3Compress(blake3IV, &block, 0, 64, blake3Parent)
+	var cv [8]uint32
+	copy(cv[:], out[:8])
+	return cv
+}
+
+// blake3Compress is the compression function of BLAKE3.
+func blake3Compress(cv [8]uint32, block *[64]byte, counter uint64, blockLen, flags uint32) [16]uint32 {
+	var m [16]uint32
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint32(block[4*i:])
+	}
+	s := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		blake3IV[0], blake3IV[1], blake3IV[2], blake3IV[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	for r := 0; r < 7; r++ {
+		blake3G(&s, 0, 4, 8, 12, m[0], m[1])
+		blake3G(&s, 1, 5, 9, 13, m[2], m[3])
+		blake3G(&s, 2, 6, 10, 14, m[4], m[5])
+		blake3G(&s, 3, 7, 11, 15, m[6], m[7])
+		blake3G(&s, 0, 5, 10, 15, m[8], m[9])
+		blake3G(&s, 1, 6, 11, 12, m[10], m[11])
+		blake3G(&s, 2, 7, 8, 13, m[12], m[13])
+		blake3G(&s, 3, 4, 9, 14, m[14], m[15])
+		var permuted [16]uint32
+		for i, j := range blake3Permutation {
+			permuted[i] = m[j]
+		}
+		m = permuted
+	}
+	for i := 0; i < 8; i++ {
+		s[i] ^= s[i+8]
+		s[i+8] ^= cv[i]
+	}
+	return s
+}
+
+// blake3G is the quarter-round of BLAKE3.
+func blake3G(s *[16]uint32, a, b, c, d int, x, y uint32) {
+	s[a] += s[b] + x
+	s[d] = bits.RotateLeft32(s[d]^s[a], -16)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -12)
+	s[a] += s[b] + y
+	s[d] = bits.RotateLeft32(s[d]^s[a], -8)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -7)
+}
+
+// newAsset reads the file at filename in the directory root.
+func newAsset(root, filename string, info os.FileInfo, lazy bool) (*asset, error) {
+	if (info.Mode()&os.ModeType)|os.ModeDir != os.ModeDir {
+		return nil, fmt.Errorf("unsupported file type: %s, mode %s", filename, info.Mode())
+	}
+	rel, err := filepath.Rel(root, filename)
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return nil, validationErrorf("%s is outside of %s", filename, root)
+	}
+	a := &asset{
+		name:    path.Clean("/" + rel),
+		mode:    info.Mode(),
+		modTime: info.ModTime(),
+	}
+	switch {
+	case info.IsDir():
+	case lazy:
+		a.path = filename
+	default:
+		a.content, err = os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+var (
+	htmlHeadEndPattern = regexp.MustCompile(`(?i)</head\s*>`)
+	htmlTagPattern     = regexp.MustCompile(`(?is)<(link|script)\b([^>]*)>`)
+	htmlAttrPattern    = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+)
+
+// findPreloads sets Preload of the HTML files in files.
+// The critical resources are the stylesheets and the scripts without async in the head,
+// and the module scripts are preloaded with modulepreload.
+// The resources that are not embedded are ignored.
+func findPreloads(files []templateFile) {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.Name
+	}
+	exists := func(name string) bool {
+		i := sort.SearchStrings(names, name)
+		return i < len(names) && names[i] == name && !files[i].Mode.IsDir()
+	}
+
+	for i := range files {
+		f := &files[i]
+		if f.Mode.IsDir() || !strings.EqualFold(path.Ext(f.Name), ".html") && !strings.EqualFold(path.Ext(f.Name), ".htm") {
+			continue
+		}
+		head := f.Content
+		if loc := htmlHeadEndPattern.FindStringIndex(head); loc != nil {
+			head = head[:loc[0]]
+		}
+		for _, m := range htmlTagPattern.FindAllStringSubmatch(head, -1) {
+			attrs := map[string]string{}
+			for _, a := range htmlAttrPattern.FindAllStringSubmatch(m[2], -1) {
+				attrs[strings.ToLower(a[1])] = strings.Trim(a[2], `"'`)
+			}
+			var url, rel string
+			switch strings.ToLower(m[1]) {
+			case "link":
+				if !strings.EqualFold(attrs["rel"], "stylesheet") {
+					continue
+				}
+				url, rel = attrs["href"], "preload; as=style"
+			case "script":
+				if _, ok := attrs["async"]; ok {
+					continue
+				}
+				url, rel = attrs["src"], "preload; as=script"
+				if strings.EqualFold(attrs["type"], "module") {
+					// the module scripts are fetched in the CORS mode, so preload doesn't match them.
+					rel = "modulepreload"
+				}
+			}
+			if url == "" || strings.Contains(url, "//") || strings.Contains(url, ":") || strings.ContainsAny(url, "<>") {
+				// ignore the external resources.
+				continue
+			}
+			name := url
+			if idx := strings.IndexAny(name, "?#"); idx >= 0 {
+				name = name[:idx]
+			}
+			if !strings.HasPrefix(name, "/") {
+				name = path.Join(path.Dir(f.Name), name)
+			}
+			if !exists(path.Clean(name)) {
+				continue
+			}
+			f.Preload = append(f.Preload, "<"+url+">; rel="+rel)
+		}
+	}
+}
+
+var (
+	cssURLPattern     = regexp.MustCompile(`(url\(\s*['"]?)([^'")]+)`)
+	cssImportPattern  = regexp.MustCompile(`(@import\s+['"])([^'"]+)`)
+	htmlAnyTagPattern = regexp.MustCompile(`<[a-zA-Z][^>]*>`)
+	htmlURLPattern    = regexp.MustCompile(`(?i)(\s(?:src|href)\s*=\s*['"]?)([^\s'">]+)`)
+)
+
+// isHTML reports whether the file name is an HTML file.
+func isHTML(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".html" || ext == ".htm"
+}
+
+var (
+	htmlHeadStartPattern = regexp.MustCompile(`(?is)<head\b[^>]*>`)
+	htmlBasePattern      = regexp.MustCompile(`(?is)<base\b[^>]*>`)
+	htmlMetaPattern      = regexp.MustCompile(`(?is)<meta\b([^>]*)>`)
+)
+
+// htmlRewrite is the elements that the -html-base, -html-meta and -html-inject options set into the HTML files,
+// so one tree is specialized for each deployment.
+type htmlRewrite struct {
+	// base is the href of the base element, or empty.
+	base string
+
+	// meta maps the names of the meta elements to their contents.
+	meta map[string]string
+
+	// inject is the snippet inserted before </head>, or empty.
+	inject string
+
+	// log reports the HTML files without the head element.
+	log *Logger
+}
+
+// transform sets the elements into the HTML file.
+// The existing base element and the meta elements with the same names are replaced,
+// and the others are inserted at the start of the head element.
+// The files without the head element are kept as they are, with a warning.
+func (rw *htmlRewrite) transform(f *File) error {
+	if !isHTML(f.Name) {
+		return nil
+	}
+	content := string(f.Content)
+	start := htmlHeadStartPattern.FindStringIndex(content)
+	end := htmlHeadEndPattern.FindStringIndex(content)
+	if start == nil || end == nil || end[0] < start[1] {
+		rw.log.Warnf("%s: no head element to rewrite", f.Name)
+		return nil
+	}
+	head := content[start[1]:end[0]]
+
+	var insert strings.Builder
+	if rw.base != "" {
+		elem := fmt.Sprintf(`<base href="%s">`, html.EscapeString(rw.base))
+		if loc := htmlBasePattern.FindStringIndex(head); loc != nil {
+			head = head[:loc[0]] + elem + head[loc[1]:]
+		} else {
+			insert.WriteString(elem)
+		}
+	}
+	names := make([]string, 0, len(rw.meta))
+	for name := range rw.meta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		elem := fmt.Sprintf(`<meta name="%s" content="%s">`, html.EscapeString(name), html.EscapeString(rw.meta[name]))
+		replaced := false
+		head = htmlMetaPattern.ReplaceAllStringFunc(head, func(tag string) string {
+			m := htmlMetaPattern.FindStringSubmatch(tag)
+			for _, a := range htmlAttrPattern.FindAllStringSubmatch(m[1], -1) {
+				if strings.EqualFold(a[1], "name") && strings.EqualFold(html.UnescapeString(strings.Trim(a[2], `"'`)), name) {
+					replaced = true
+					return elem
+				}
+			}
+			return tag
+		})
+		if !replaced {
+			insert.WriteString(elem)
+		}
+	}
+	head = insert.String() + head + rw.inject
+	f.Content = []byte(content[:start[1]] + head + content[end[0]:])
+	return nil
+}
+
+// gzipSources applies the policy of the -gzip-sources option to the pre-compressed files in assets, e.g. app.js.gz.
+// "decompress" replaces them with the decompressed files, and "encoded" keeps them as the gzip variants
+// and adds the decompressed files if they are missing, for the clients that do not accept gzip.
+// The archives, e.g. .tar.gz, are kept as they are.
+func gzipSources(assets []*asset, policy string) ([]*asset, error) {
+	if policy != "decompress" && policy != "encoded" {
+		return assets, nil
+	}
+	index := make(map[string]bool, len(assets))
+	for _, a := range assets {
+		index[a.name] = true
+	}
+	ret := make([]*asset, 0, len(assets))
+	for _, a := range assets {
+		if a.mode.IsDir() || !strings.HasSuffix(a.name, ".gz") || strings.HasSuffix(a.name, ".tar.gz") {
+			ret = append(ret, a)
+			continue
+		}
+		if policy == "encoded" {
+			ret = append(ret, a)
+		}
+		name := strings.TrimSuffix(a.name, ".gz")
+		if index[name] {
+			continue
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(a.content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", a.name, err)
+		}
+		content, err := io.ReadAll(gz)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", a.name, err)
+		}
+		ret = append(ret, &asset{
+			name:    name,
+			mode:    a.mode,
+			modTime: a.modTime,
+			content: content,
+		})
+	}
+	return ret, nil
+}
+
+// compressAssets adds the gzip variants, e.g. app.js.gz, of the text files in assets for -compression gzip.
+// The variants are added only if they are smaller than the files and are not in assets yet,
+// and they are compressed without the names and the times, so that they are reproducible.
+func compressAssets(assets []*asset) ([]*asset, error) {
+	index := make(map[string]bool, len(assets))
+	for _, a := range assets {
+		index[a.name] = true
+	}
+	ret := make([]*asset, 0, len(assets))
+	for _, a := range assets {
+		ret = append(ret, a)
+		if a.mode.IsDir() || index[a.name+".gz"] || !isText(a.name, a.content) {
+			continue
+		}
+		var buf bytes.Buffer
+		gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := gz.Write(a.content); err != nil {
+			return nil, fmt.Errorf("%s: %v", a.name, err)
+		}
+		if err := gz.Close(); err != nil {
+			return nil, fmt.Errorf("%s: %v", a.name, err)
+		}
+		if buf.Len() >= len(a.content) {
+			continue
+		}
+		ret = append(ret, &asset{
+			name:    a.name + ".gz",
+			mode:    a.mode,
+			modTime: a.modTime,
+			content: buf.Bytes(),
+		})
+	}
+	return ret, nil
+}
+
+// normalizeText normalizes the line endings of the text files in assets to eol, "lf" or "crlf", if it is not empty,
+// and removes the UTF-8 byte order marks if stripBOM is true.
+// The embedded bytes, and so the ETags, become the same on Windows and on the other platforms.
+func normalizeText(assets []*asset, eol string, stripBOM bool) []*asset {
+	// the transform never fails, and it doesn't rename the files.
+	ret, _ := applyHooks(assets, []hook{{transform: normalizeTextHook(eol, stripBOM)}})
+	return ret
+}
+
+// normalizeTextHook returns the transform that normalizes the line endings of the text file to eol,
+// and removes the UTF-8 byte order mark if stripBOM is true.
+func normalizeTextHook(eol string, stripBOM bool) func(f *File) error {
+	return func(f *File) error {
+		if !isText(f.Name, f.Content) {
+			return nil
+		}
+		content := f.Content
+		if stripBOM {
+			content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+		}
+		switch eol {
+		case "lf":
+			content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		case "crlf":
+			content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+			content = bytes.ReplaceAll(content, []byte("\n"), []byte("\r\n"))
+		}
+		f.Content = content
+		return nil
+	}
+}
+
+// ContentType returns the content type of the file name as the generated handlers serve it,
+// detected from the content if the extension is unknown, e.g. for the uploads of the files to the storages.
+func ContentType(name string, content []byte) string {
+	if typ := mime.TypeByExtension(path.Ext(name)); typ != "" {
+		return typ
+	}
+	return http.DetectContentType(content)
+}
+
+// isText reports whether the file name is a text file, judging from the content type and the content.
+func isText(name string, content []byte) bool {
+	if bytes.IndexByte(content, 0) >= 0 {
+		return false
+	}
+	typ, _, err := mime.ParseMediaType(ContentType(name, content))
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(typ, "text/"):
+		return true
+	case strings.HasSuffix(typ, "+json"), strings.HasSuffix(typ, "+xml"):
+		return true
+	}
+	switch typ {
+	case "application/javascript", "application/json", "application/xml", "application/x-javascript":
+		return true
+	}
+	return false
+}
+
+// charsetConverter is the command that converts the text from the charset "{charset}" to UTF-8, reading stdin.
+var charsetConverter = []string{"iconv", "-f", "{charset}", "-t", "UTF-8"}
+
+// convertCharsets converts the text files in assets that are not valid UTF-8 to UTF-8.
+// The charset of each file is the first of charsets that the converter accepts,
+// so the strict charsets, e.g. shift_jis and euc-jp, should go before the permissive ones, e.g. iso-8859-1.
+// It returns the names of the converted files mapped to their original charsets.
+func convertCharsets(log *Logger, assets []*asset, charsets []string) ([]*asset, map[string]string) {
+	ret := make([]*asset, 0, len(assets))
+	original := map[string]string{}
+	for _, a := range assets {
+		if a.mode.IsDir() || utf8.Valid(a.content) || !isText(a.name, a.content) {
+			ret = append(ret, a)
+			continue
+		}
+		converted := false
+		for _, charset := range charsets {
+			content, err := convertCharset(a.content, charset)
+			if err != nil {
+				continue
+			}
+			// the assets are shared by the shards of the platforms, so the converted files are copied.
+			ret = append(ret, &asset{
+				name:    a.name,
+				mode:    a.mode,
+				modTime: a.modTime,
+				content: content,
+			})
+			original[a.name] = charset
+			converted = true
+			break
+		}
+		if !converted {
+			log.Warnf("%s is not valid UTF-8 nor any of %s", a.name, strings.Join(charsets, ", "))
+			ret = append(ret, a)
+		}
+	}
+	return ret, original
+}
+
+// convertCharset converts content from charset to UTF-8 with charsetConverter.
+func convertCharset(content []byte, charset string) ([]byte, error) {
+	args := make([]string, len(charsetConverter))
+	for i, arg := range charsetConverter {
+		args[i] = strings.ReplaceAll(arg, "{charset}", charset)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", args[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if !utf8.Valid(stdout.Bytes()) {
+		return nil, fmt.Errorf("%s: the output is not valid UTF-8", args[0])
+	}
+	return stdout.Bytes(), nil
+}
+
+// expandTemplates executes the text files that match rule as text/template with the variables,
+// which are the variables of rule overridden by vars.
+// The templates can also read the environment variables with the env function.
+func expandTemplates(assets []*asset, rule *templateRule, vars map[string]string) ([]*asset, error) {
+	data := make(map[string]string, len(rule.Vars)+len(vars))
+	for k, v := range rule.Vars {
+		data[k] = v
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+	funcs := template.FuncMap{
+		"env": func(name string) (string, error) {
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+			return v, nil
+		},
+	}
+	// the assets are shared by the shards of the platforms, so the executed files are copied.
+	ret := make([]*asset, 0, len(assets))
+	for _, a := range assets {
+		if a.mode.IsDir() || !matchAny(rule.Include, a.name) {
+			ret = append(ret, a)
+			continue
+		}
+		tmpl, err := template.New(a.name).Funcs(funcs).Option("missingkey=error").Parse(string(a.content))
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		ret = append(ret, &asset{
+			name:    a.name,
+			mode:    a.mode,
+			modTime: a.modTime,
+			content: buf.Bytes(),
+		})
+	}
+	return ret, nil
+}
+
+// defaultMarkdownTemplate is the wrapper of the HTML rendered from Markdown, used if the markdown rule has no template.
+const defaultMarkdownTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+{{.Content}}
+</body>
+</html>
+`
+
+// markdownPage is the data passed to the wrapper template of the markdown rule.
+type markdownPage struct {
+	// Path is the name of the rendered file, e.g. "/docs/index.html".
+	Path string
+
+	// Title is the text of the first heading.
+	Title string
+
+	// Content is the HTML rendered from the Markdown file.
+	Content htmltemplate.HTML
+}
+
+// renderMarkdownAssets renders the Markdown files that match rule to HTML, replacing ".md" with ".html".
+func renderMarkdownAssets(assets []*asset, rule *markdownRule) ([]*asset, error) {
+	src := defaultMarkdownTemplate
+	if rule.Template != "" {
+		b, err := os.ReadFile(rule.Template)
+		if err != nil {
+			return nil, err
+		}
+		src = string(b)
+	}
+	tmpl, err := htmltemplate.New("markdown").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*asset, 0, len(assets))
+	for _, a := range assets {
+		if a.mode.IsDir() || !strings.EqualFold(path.Ext(a.name), ".md") ||
+			len(rule.Include) > 0 && !matchAny(rule.Include, a.name) {
+			ret = append(ret, a)
+			continue
+		}
+		content, title := renderMarkdown(string(a.content))
+		page := markdownPage{
+			Path:    strings.TrimSuffix(a.name, path.Ext(a.name)) + ".html",
+			Title:   title,
+			Content: htmltemplate.HTML(content),
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, page); err != nil {
+			return nil, fmt.Errorf("%s: %v", a.name, err)
+		}
+		ret = append(ret, &asset{
+			name:    page.Path,
+			mode:    a.mode,
+			modTime: a.modTime,
+			content: buf.Bytes(),
+		})
+	}
+	return ret, nil
+}
+
+var (
+	mdHeading   = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+	mdRule      = regexp.MustCompile(`^ {0,3}(-( *-){2,}|\*( *\*){2,}|_( *_){2,}) *$`)
+	mdBullet    = regexp.MustCompile(`^ {0,3}[-*+][ \t]+`)
+	mdNumber    = regexp.MustCompile(`^ {0,3}[0-9]{1,9}[.)][ \t]+`)
+	mdFence     = regexp.MustCompile("^ {0,3}(```+|~~~+)[ \t]*([^ \t`]*)")
+	mdQuote     = regexp.MustCompile(`^ {0,3}> ?`)
+	mdLink      = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^ )]*)(?: &#34;(.*?)&#34;)?\)`)
+	mdStrong    = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdEmphasis  = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	mdTitleTags = regexp.MustCompile(`<[^>]*>`)
+)
+
+// renderMarkdown renders the subset of Markdown to HTML, and returns it with the text of the first heading.
+// It supports the headings, the paragraphs, the fenced code blocks, the lists, the block quotes, the horizontal rules,
+// and the code spans, the emphases, the links and the images in the lines.
+// The raw HTML is escaped.
+func renderMarkdown(src string) (string, string) {
+	var r markdownRenderer
+	r.render(strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n"))
+	return r.buf.String(), r.title
+}
+
+// markdownRenderer renders the blocks of Markdown.
+type markdownRenderer struct {
+	buf   strings.Builder
+	title string
+}
+
+func (r *markdownRenderer) render(lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case mdFence.MatchString(line):
+			m := mdFence.FindStringSubmatch(line)
+			fence := strings.TrimLeft(m[0], " ")[:len(m[1])]
+			if m[2] != "" {
+				fmt.Fprintf(&r.buf, "<pre><code class=\"language-%s\">", html.EscapeString(m[2]))
+			} else {
+				r.buf.WriteString("<pre><code>")
+			}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimLeft(lines[i], " "), fence); i++ {
+				r.buf.WriteString(html.EscapeString(lines[i]))
+				r.buf.WriteString("\n")
+			}
+			r.buf.WriteString("</code></pre>\n")
+			i++
+		case mdHeading.MatchString(line):
+			m := mdHeading.FindStringSubmatch(line)
+			text := renderInline(m[2])
+			if r.title == "" {
+				r.title = html.UnescapeString(mdTitleTags.ReplaceAllString(text, ""))
+			}
+			fmt.Fprintf(&r.buf, "<h%d>%s</h%d>\n", len(m[1]), text, len(m[1]))
+			i++
+		case mdRule.MatchString(line):
+			r.buf.WriteString("<hr>\n")
+			i++
+		case mdQuote.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && mdQuote.MatchString(lines[i]); i++ {
+				quoted = append(quoted, mdQuote.ReplaceAllString(lines[i], ""))
+			}
+			r.buf.WriteString("<blockquote>\n")
+			r.render(quoted)
+			r.buf.WriteString("</blockquote>\n")
+		case mdBullet.MatchString(line):
+			i = r.renderList(lines, i, mdBullet, "ul")
+		case mdNumber.MatchString(line):
+			i = r.renderList(lines, i, mdNumber, "ol")
+		default:
+			var para []string
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && !isMarkdownBlock(lines[i]); i++ {
+				para = append(para, strings.TrimSpace(lines[i]))
+			}
+			r.buf.WriteString("<p>")
+			r.buf.WriteString(renderInline(strings.Join(para, "\n")))
+			r.buf.WriteString("</p>\n")
+		}
+	}
+}
+
+// renderList renders the list that starts at lines[i], and returns the index of the line after the list.
+// The indented lines belong to the previous item.
+func (r *markdownRenderer) renderList(lines []string, i int, marker *regexp.Regexp, tag string) int {
+	fmt.Fprintf(&r.buf, "<%s>\n", tag)
+	for i < len(lines) && marker.MatchString(lines[i]) {
+		item := []string{marker.ReplaceAllString(lines[i], "")}
+		for i++; i < len(lines) && (strings.HasPrefix(lines[i], "  ") || strings.HasPrefix(lines[i], "\t")); i++ {
+			item = append(item, strings.TrimLeft(lines[i], " \t"))
+		}
+		r.buf.WriteString("<li>")
+		if len(item) == 1 {
+			r.buf.WriteString(renderInline(item[0]))
+		} else {
+			// the nested blocks, e.g. the sub lists.
+			r.buf.WriteString("\n")
+			r.render(item)
+		}
+		r.buf.WriteString("</li>\n")
+	}
+	fmt.Fprintf(&r.buf, "</%s>\n", tag)
+	return i
+}
+
+// isMarkdownBlock reports whether line starts a block other than the paragraphs.
+func isMarkdownBlock(line string) bool {
+	return mdFence.MatchString(line) || mdHeading.MatchString(line) || mdRule.MatchString(line) ||
+		mdQuote.MatchString(line) || mdBullet.MatchString(line) || mdNumber.MatchString(line)
+}
+
+// renderInline renders the code spans, the emphases, the links and the images in s.
+// The links to the relative Markdown files are replaced with the rendered HTML files.
+func renderInline(s string) string {
+	var buf strings.Builder
+	for {
+		start := strings.IndexByte(s, '`')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start+1:], '`')
+		if end < 0 {
+			break
+		}
+		buf.WriteString(renderText(s[:start]))
+		buf.WriteString("<code>")
+		buf.WriteString(html.EscapeString(s[start+1 : start+1+end]))
+		buf.WriteString("</code>")
+		s = s[start+end+2:]
+	}
+	buf.WriteString(renderText(s))
+	return buf.String()
+}
+
+// renderText renders the emphases, the links and the images in s, which has no code spans.
+// mdLink matches the escaped text, so the quotes of the titles are "&#34;".
+func renderText(s string) string {
+	s = html.EscapeString(s)
+	s = mdLink.ReplaceAllStringFunc(s, func(m string) string {
+		sub := mdLink.FindStringSubmatch(m)
+		href := markdownLinkTarget(html.UnescapeString(sub[3]))
+		title := ""
+		if sub[4] != "" {
+			title = ` title="` + sub[4] + `"`
+		}
+		if sub[1] == "!" {
+			return `<img src="` + html.EscapeString(href) + `" alt="` + sub[2] + `"` + title + `>`
+		}
+		return `<a href="` + html.EscapeString(href) + `"` + title + `>` + sub[2] + `</a>`
+	})
+	s = mdStrong.ReplaceAllString(s, "<strong>$1$2</strong>")
+	s = mdEmphasis.ReplaceAllString(s, "<em>$1$2</em>")
+	return strings.ReplaceAll(s, "  \n", "<br>\n")
+}
+
+// markdownLinkTarget replaces the extension of the link to a relative Markdown file with ".html".
+// The javascript URLs are dropped.
+func markdownLinkTarget(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if strings.EqualFold(u.Scheme, "javascript") {
+		return ""
+	}
+	if u.Scheme == "" && u.Host == "" && strings.EqualFold(path.Ext(u.Path), ".md") {
+		u.Path = strings.TrimSuffix(u.Path, path.Ext(u.Path)) + ".html"
+		return u.String()
+	}
+	return href
+}
+
+// stripMetadata removes the metadata, e.g. EXIF with the GPS location, from the JPEG and PNG images in assets.
+func stripMetadata(assets []*asset) ([]*asset, error) {
+	for _, a := range assets {
+		if a.mode.IsDir() {
+			continue
+		}
+		var err error
+		switch strings.ToLower(path.Ext(a.name)) {
+		case ".jpg", ".jpeg":
+			a.content, err = stripJPEGMetadata(a.content)
+		case ".png":
+			a.content, err = stripPNGMetadata(a.content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", a.name, err)
+		}
+	}
+	return assets, nil
+}
+
+// stripJPEGMetadata removes the APPn segments except JFIF (APP0), ICC profiles (APP2) and Adobe (APP14),
+// and the comments from the JPEG image b.
+// The segments after the start of scan are kept as they are.
+func stripJPEGMetadata(b []byte) ([]byte, error) {
+	if len(b) < 2 || b[0] != 0xff || b[1] != 0xd8 {
+		return nil, errors.New("not a JPEG image")
+	}
+	ret := append([]byte{}, b[:2]...)
+	for i := 2; ; {
+		if i+4 > len(b) || b[i] != 0xff {
+			return nil, errors.New("invalid JPEG segment")
+		}
+		marker := b[i+1]
+		if marker == 0xff {
+			// fill bytes
+			i++
+			continue
+		}
+		size := int(b[i+2])<<8 | int(b[i+3])
+		if size < 2 || i+2+size > len(b) {
+			return nil, errors.New("invalid JPEG segment")
+		}
+		if marker == 0xda { // start of scan
+			return append(ret, b[i:]...), nil
+		}
+		strip := marker == 0xfe || // comment
+			0xe1 <= marker && marker <= 0xef && marker != 0xe2 && marker != 0xee
+		if !strip {
+			ret = append(ret, b[i:i+2+size]...)
+		}
+		i += 2 + size
+	}
+}
+
+// pngMetadataChunks is the chunks of PNG removed by stripPNGMetadata.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// stripPNGMetadata removes the chunks of the metadata from the PNG image b.
+func stripPNGMetadata(b []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(b, []byte(signature)) {
+		return nil, errors.New("not a PNG image")
+	}
+	ret := append([]byte{}, signature...)
+	for i := len(signature); i < len(b); {
+		if i+8 > len(b) {
+			return nil, errors.New("invalid PNG chunk")
+		}
+		size := int(b[i])<<24 | int(b[i+1])<<16 | int(b[i+2])<<8 | int(b[i+3])
+		end := i + 12 + size // length, type, data and CRC
+		if size < 0 || end > len(b) || end < i {
+			return nil, errors.New("invalid PNG chunk")
+		}
+		if !pngMetadataChunks[string(b[i+4:i+8])] {
+			ret = append(ret, b[i:end]...)
+		}
+		i = end
+	}
+	return ret, nil
+}
+
+// imageEncoders is the commands that convert the images to the formats of the image rules.
+// "{in}" and "{out}" are replaced with the paths of the input and the output files.
+var imageEncoders = map[string][]string{
+	"webp": {"cwebp", "-quiet", "{in}", "-o", "{out}"},
+	"avif": {"avifenc", "{in}", "{out}"},
+}
+
+// transformImages adds the resized variants and the alternates in the other formats of the images that match rules.
+// The variant of the width w of "/img/photo.jpg" is "/img/photo-{w}w.jpg",
+// and the alternates are the names with the extensions of the formats, e.g. "/img/photo.jpg.webp".
+func transformImages(assets []*asset, rules []*imageRule) ([]*asset, error) {
+	ret := make([]*asset, 0, len(assets))
+	for _, a := range assets {
+		ret = append(ret, a)
+		if a.mode.IsDir() {
+			continue
+		}
+		var rule *imageRule
+		for _, r := range rules {
+			if matchAny(r.Include, a.name) {
+				rule = r
+				break
+			}
+		}
+		if rule == nil {
+			continue
+		}
+
+		images := []*asset{a}
+		if len(rule.Widths) > 0 {
+			resized, err := resizeImage(a, rule.Widths)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", a.name, err)
+			}
+			images = append(images, resized...)
+			ret = append(ret, resized...)
+		}
+		for _, format := range rule.Formats {
+			for _, img := range images {
+				content, err := encodeImage(img, format)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", img.name, err)
+				}
+				ret = append(ret, &asset{
+					name:    img.name + "." + format,
+					mode:    img.mode,
+					modTime: img.modTime,
+					content: content,
+				})
+			}
+		}
+	}
+	return ret, nil
+}
+
+// resizeImage returns the variants of the image a scaled down to widths, keeping the aspect ratio.
+// The widths that are not less than the width of a are skipped.
+func resizeImage(a *asset, widths []int) ([]*asset, error) {
+	src, format, err := image.Decode(bytes.NewReader(a.content))
+	if err != nil {
+		return nil, err
+	}
+	ext := path.Ext(a.name)
+	var ret []*asset
+	for _, w := range widths {
+		if w >= src.Bounds().Dx() {
+			continue
+		}
+		dst := scaleImage(src, w)
+		var buf bytes.Buffer
+		switch format {
+		case "jpeg":
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		case "png":
+			err = png.Encode(&buf, dst)
+		case "gif":
+			err = gif.Encode(&buf, dst, nil)
+		default:
+			err = fmt.Errorf("unsupported image format: %s", format)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &asset{
+			name:    strings.TrimSuffix(a.name, ext) + "-" + strconv.Itoa(w) + "w" + ext,
+			mode:    a.mode,
+			modTime: a.modTime,
+			content: buf.Bytes(),
+		})
+	}
+	return ret, nil
+}
+
+// scaleImage scales down src to the width w with the box filter, which averages the source pixels of each pixel.
+func scaleImage(src image.Image, w int) *image.RGBA {
+	b := src.Bounds()
+	h := (b.Dy()*w + b.Dx()/2) / b.Dx()
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			// the colors are averaged with the premultiplied alpha, so the transparent pixels don't leak their colors.
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// encodeImage converts the image a to format by the command in imageEncoders.
+func encodeImage(a *asset, format string) ([]byte, error) {
+	tmp, err := os.MkdirTemp("", "assets-life-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	in := filepath.Join(tmp, "in"+path.Ext(a.name))
+	out := filepath.Join(tmp, "out."+format)
+	if err := os.WriteFile(in, a.content, 0600); err != nil {
+		return nil, err
+	}
+	args := make([]string, len(imageEncoders[format]))
+	for i, arg := range imageEncoders[format] {
+		args[i] = strings.NewReplacer("{in}", in, "{out}", out).Replace(arg)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to convert the image to %s by %s: %v", format, args[0], err)
+	}
+	return os.ReadFile(out)
+}
+
+// fingerprintAssets renames the files except HTML to the names with the hashes of the contents,
+// and rewrites the references in HTML and CSS to the new names.
+// The hashes are computed by the algorithm alg of the -hash option.
+// It returns the renamed assets and the map from the old names to the new names.
+func fingerprintAssets(assets []*asset, alg string) ([]*asset, map[string]string) {
+	index := map[string]*asset{}
+	for _, a := range assets {
+		index[a.name] = a
+	}
+	fingerprints := map[string]string{}
+	visiting := map[string]bool{}
+
+	// refs returns the patterns that match the references in the file name, or nil if it has no references.
+	refs := func(name string) []*regexp.Regexp {
+		switch {
+		case isHTML(name):
+			return []*regexp.Regexp{htmlURLPattern}
+		case strings.EqualFold(path.Ext(name), ".css"):
+			return []*regexp.Regexp{cssURLPattern, cssImportPattern}
+		}
+		return nil
+	}
+
+	// rewrite rewrites the references in the content of a.
+	// The files that a refers to are fingerprinted first, because the hash of a depends on their names.
+	var fingerprint func(a *asset)
+	rewrite := func(a *asset) {
+		content := string(a.content)
+		replace := func(pattern *regexp.Regexp, s string) string {
+			return pattern.ReplaceAllStringFunc(s, func(m string) string {
+				sub := pattern.FindStringSubmatch(m)
+				ref := resolveReference(a.name, sub[2])
+				target, ok := index[ref]
+				if !ok || target.mode.IsDir() || isHTML(ref) {
+					return m
+				}
+				fingerprint(target)
+				fingerprinted, ok := fingerprints[ref]
+				if !ok {
+					// it is in a cycle of the references.
+					return m
+				}
+				return sub[1] + replaceBase(sub[2], path.Base(fingerprinted))
+			})
+		}
+		for _, pattern := range refs(a.name) {
+			if pattern == htmlURLPattern {
+				content = htmlAnyTagPattern.ReplaceAllStringFunc(content, func(tag string) string {
+					return replace(pattern, tag)
+				})
+			} else {
+				content = replace(pattern, content)
+			}
+		}
+		a.content = []byte(content)
+	}
+	fingerprint = func(a *asset) {
+		if _, ok := fingerprints[a.name]; ok || visiting[a.name] {
+			return
+		}
+		visiting[a.name] = true
+		rewrite(a)
+		ext := path.Ext(a.name)
+		fingerprints[a.name] = strings.TrimSuffix(a.name, ext) + "." + digestHex(alg, a.content, 8) + ext
+	}
+
+	for _, a := range assets {
+		if a.mode.IsDir() {
+			continue
+		}
+		if isHTML(a.name) {
+			rewrite(a)
+		} else {
+			fingerprint(a)
+		}
+	}
+	for _, a := range assets {
+		if fingerprinted, ok := fingerprints[a.name]; ok {
+			a.name = fingerprinted
+		}
+	}
+	return assets, fingerprints
+}
+
+// resolveReference returns the name of the file that the reference ref in the file name refers to,
+// or empty if ref is external.
+func resolveReference(name, ref string) string {
+	if idx := strings.IndexAny(ref, "?#"); idx >= 0 {
+		ref = ref[:idx]
+	}
+	if ref == "" || strings.Contains(ref, "//") || strings.Contains(ref, ":") {
+		return ""
+	}
+	if !strings.HasPrefix(ref, "/") {
+		ref = path.Join(path.Dir(name), ref)
+	}
+	return path.Clean(ref)
+}
+
+// replaceBase replaces the last element of the path in the reference ref with base, keeping the query and the fragment.
+func replaceBase(ref, base string) string {
+	var suffix string
+	if idx := strings.IndexAny(ref, "?#"); idx >= 0 {
+		ref, suffix = ref[:idx], ref[idx:]
+	}
+	return ref[:strings.LastIndex(ref, "/")+1] + base + suffix
+}
+
+// precacheEntry is an entry of precache-manifest.json, in the format of Workbox.
+type precacheEntry struct {
+	URL      string `json:"url"`
+	Revision string `json:"revision"`
+}
+
+// addPrecache adds /precache-manifest.json to assets, and /sw.js if serviceWorker is true.
+// The URLs in the manifest are relative to the root, so they work under any prefix.
+// The revisions are the hashes by the algorithm alg of the -hash option.
+func addPrecache(assets []*asset, serviceWorker bool, alg string) ([]*asset, error) {
+	generated := []string{"/precache-manifest.json"}
+	if serviceWorker {
+		generated = append(generated, "/sw.js")
+	}
+	manifest := []precacheEntry{}
+	for _, a := range assets {
+		for _, name := range generated {
+			if a.name == name {
+				return nil, fmt.Errorf("%s conflicts with the generated file", name)
+			}
+		}
+		if a.mode.IsDir() {
+			continue
+		}
+		manifest = append(manifest, precacheEntry{
+			URL:      a.name[1:],
+			Revision: digestHex(alg, a.content, 16),
+		})
+	}
+	sort.Slice(manifest, func(i, j int) bool { return manifest[i].URL < manifest[j].URL })
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, '\n')
+
+	ret := append(assets[:len(assets):len(assets)], &asset{
+		name:    "/precache-manifest.json",
+		mode:    0644,
+		content: b,
+	})
+	if serviceWorker {
+		sw := new(bytes.Buffer)
+		if err := template.Must(template.New("sw.js").Parse(serviceWorkerTemplate)).Execute(sw, map[string]string{
+			"Version":  digestHex(alg, b, 16),
+			"Manifest": strings.TrimSpace(string(b)),
+		}); err != nil {
+			return nil, err
+		}
+		ret = append(ret, &asset{
+			name:    "/sw.js",
+			mode:    0644,
+			content: sw.Bytes(),
+		})
+	}
+	return ret, nil
+}
+
+// templateLicense is a license or a notice of the third-party files found by the -notices option.
+type templateLicense struct {
+	// Name is the name of the file that has the license, e.g. "/vendor/jquery/LICENSE.txt".
+	Name string
+
+	// Text is the text of the license.
+	Text string
+}
+
+var (
+	// licenseFilePattern matches the names of the license files, e.g. LICENSE, LICENSE-MIT.txt, COPYING and NOTICE.md.
+	licenseFilePattern = regexp.MustCompile(`(?i)^(licen[cs]e|copying|notice)([-._][-.\w]*)?$`)
+
+	// bannerPattern matches the banner comment at the beginning of JavaScript and CSS,
+	// which starts with "/*!" or has "@license" or "@preserve".
+	bannerPattern = regexp.MustCompile(`^\s*(/\*![\s\S]*?\*/|/\*[\s\S]*?@(?:license|preserve)[\s\S]*?\*/)`)
+)
+
+// findLicenses finds the license files and the banner comments of JavaScript and CSS in assets, sorted by name.
+func findLicenses(assets []*asset) []templateLicense {
+	var licenses []templateLicense
+	for _, a := range assets {
+		if a.mode.IsDir() {
+			continue
+		}
+		var text string
+		switch ext := path.Ext(a.name); {
+		case licenseFilePattern.MatchString(path.Base(a.name)):
+			text = string(a.content)
+		case ext == ".js" || ext == ".mjs" || ext == ".css":
+			content := bytes.TrimPrefix(a.content, []byte("\xef\xbb\xbf"))
+			if m := bannerPattern.FindSubmatch(content); m != nil {
+				text = string(m[1])
+			}
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			licenses = append(licenses, templateLicense{
+				Name: a.name,
+				Text: text,
+			})
+		}
+	}
+	sort.Slice(licenses, func(i, j int) bool { return licenses[i].Name < licenses[j].Name })
+	return licenses
+}
+
+// addNotices adds /NOTICES, the consolidated text of licenses, to assets.
+func addNotices(assets []*asset, licenses []templateLicense) ([]*asset, error) {
+	var buf bytes.Buffer
+	buf.WriteString("This file contains the licenses and the notices of the third-party files.\n")
+	for _, l := range licenses {
+		fmt.Fprintf(&buf, "\n%s\n%s\n\n%s\n", l.Name, strings.Repeat("-", utf8.RuneCountInString(l.Name)), l.Text)
+	}
+	for _, a := range assets {
+		if a.name == "/NOTICES" {
+			return nil, errors.New("/NOTICES conflicts with the generated file")
+		}
+	}
+	return append(assets[:len(assets):len(assets)], &asset{
+		name:    "/NOTICES",
+		mode:    0644,
+		content: buf.Bytes(),
+	}), nil
+}
+
+// readSignKey reads the Ed25519 private key in PEM, e.g. generated by "openssl genpkey -algorithm ed25519".
+func readSignKey(filename string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s: no PRIVATE KEY block in PEM", filename)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %T is not an Ed25519 key", filename, key)
+	}
+	return priv, nil
+}
+
+// manifest returns the manifest of files that the -sign-key option signs,
+// the lines of the SHA-256 hashes of the contents in hex and the names sorted by name, in the format of sha256sum.
+// It is SHA-256 whatever the -hash option is, because the contents of crc32 and sha1 can be forged with the signature kept.
+// It must be the same as Manifest of the generated package.
+func manifest(files []templateFile) []byte {
+	var buf bytes.Buffer
+	for _, f := range files {
+		if f.Mode.IsDir() {
+			continue
+		}
+		sum := f.sum
+		if sum == "" {
+			sum = hashHex("sha256", []byte(f.Content))
+		}
+		buf.WriteString(sum)
+		buf.WriteString("  ")
+		buf.WriteString(f.Name)
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// contentHashes returns the names of the files in files mapped to the hashes of their contents by the algorithm alg,
+// and the hashes mapped to the first names of the files that have them.
+// It returns *ValidationError if the different contents have the same hash, e.g. by crc32,
+// because ByHash and ContentAddressable would serve one of them for the other.
+func contentHashes(files []templateFile, alg string) (map[string]string, map[string]string, error) {
+	hashes := map[string]string{}
+	names := map[string]string{}
+	first := map[string]int{}
+	for i, f := range files {
+		if f.Mode.IsDir() {
+			continue
+		}
+		hash := f.hash
+		if hash == "" {
+			hash = hashHex(alg, []byte(f.Content))
+		}
+		hashes[f.Name] = hash
+		j, ok := first[hash]
+		if !ok {
+			first[hash] = i
+			names[hash] = f.Name
+			continue
+		}
+		if !sameContent(&files[i], &files[j]) {
+			return nil, nil, validationErrorf("%s and %s have the same %s hash %s, use -hash sha256 or blake3", files[j].Name, f.Name, alg, hash)
+		}
+	}
+	return hashes, names, nil
+}
+
+// sameContent reports whether the files a and b have the same content.
+// The contents written in the memory-bounded mode of -max-memory are compared by their SHA-256 hashes.
+func sameContent(a, b *templateFile) bool {
+	if a.sum != "" || b.sum != "" {
+		return a.sum == b.sum
+	}
+	return a.Content == b.Content
+}
+
+// internContents finds the files that have the same content, e.g. copies of a file,
+// and sets their Alias to the name of the shared constant, so the content is written once.
+func internContents(files []templateFile) []templateContent {
+	contents := make([]string, len(files))
+	for i, f := range files {
+		contents[i] = f.Content
+	}
+	names, shared := sharedContents(contents)
+	for i := range files {
+		files[i].Alias = names[i]
+	}
+	return shared
+}
+
+// sharedContents finds the contents that appear more than once,
+// and returns the names of their constants by index, empty for the others, and the shared contents.
+func sharedContents(contents []string) ([]string, []templateContent) {
+	count := map[string]int{}
+	for _, content := range contents {
+		if content != "" {
+			count[content]++
+		}
+	}
+	var shared []templateContent
+	names := make([]string, len(contents))
+	byContent := map[string]string{}
+	for i, content := range contents {
+		if count[content] < 2 {
+			continue
+		}
+		name, ok := byContent[content]
+		if !ok {
+			name = fmt.Sprintf("content%d", len(shared))
+			byContent[content] = name
+			shared = append(shared, templateContent{
+				Name:    name,
+				Content: content,
+			})
+		}
+		names[i] = name
+	}
+	return names, shared
+}
+
+// newFileTable builds the file table from assets.
+// The parent directories missing from assets are added, and the table is sorted by name.
+// If preserveMode is false, the modes are normalized to 0755 | os.ModeDir, 0755 or 0644.
+// If preserveMTime is true, the modification times of the assets are recorded.
+// If dirsFirst is true, the directories are linked before the files in each directory.
+func newFileTable(assets []*asset, preserveMode, preserveMTime, dirsFirst bool) []templateFile {
+	index := map[string]*asset{}
+	for _, a := range assets {
+		index[a.name] = a
+	}
+	for _, a := range assets {
+		for dir := path.Dir(a.name); index[dir] == nil; dir = path.Dir(dir) {
+			index[dir] = &asset{
+				name: dir,
+				mode: 0755 | os.ModeDir,
+			}
+		}
+	}
+	if _, ok := index["/"]; !ok {
+		index["/"] = &asset{
+			name: "/",
+			mode: 0755 | os.ModeDir,
+		}
+	}
+
+	names := make([]string, 0, len(index))
+	for name := range index {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := make([]templateFile, len(names))
+	for i, name := range names {
+		a := index[name]
+		f := &files[i]
+		f.Name = name
+		f.Next = -1
+		f.Child = -1
+		switch {
+		case preserveMode:
+			f.Mode = a.mode & (os.ModeDir | os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		case a.mode.IsDir(): // directory
+			f.Mode = 0755 | os.ModeDir
+		case a.mode&0100 != 0: // executable file
+			f.Mode = 0755
+		default:
+			f.Mode = 0644
+		}
+		if preserveMTime && !a.modTime.IsZero() {
+			f.ModTime = a.modTime.UnixNano()
+		}
+		f.Content = string(a.content)
+		f.path = a.path
+	}
+
+	// the children are linked in the order of the names, or the directories first if dirsFirst is true.
+	order := make([]int, len(names))
+	for i := range order {
+		order[i] = i
+	}
+	if dirsFirst {
+		sort.SliceStable(order, func(i, j int) bool {
+			return files[order[i]].Mode.IsDir() && !files[order[j]].Mode.IsDir()
+		})
+	}
+	last := map[string]int{} // the index of the last child found, for each directory
+	for _, i := range order {
+		name := names[i]
+		if name == "/" {
+			continue
+		}
+
+		// link to the siblings
+		dir := path.Dir(name)
+		if j, ok := last[dir]; ok {
+			files[j].Next = i
+		} else {
+			files[sort.SearchStrings(names, dir)].Child = i
+		}
+		last[dir] = i
+	}
+	return files
+}
+
+// source is the source code of the generator itself.
+// It is embedded into the generated package so that the package can be re-generated by go generate.
+//
+//go:embed assets-life.go
+var source []byte
+
+// buildIgnore is the build constraint that excludes the embedded generator from the generated package.
+const buildIgnore = "//go:build ignore\n// +build ignore\n\n"
+
+// mainFunc is main of the generator embedded into the generated package, which go generate runs.
+const mainFunc = `func main() {
+	log := NewLogger(os.Stderr)
+	b, err := ParseArgs(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:], WithLogger(log))
+	if err == nil {
+		err = b.Build(context.Background())
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+`
+
+// selfSource returns the source code of the generator with the build constraint.
+func selfSource() []byte {
+	// normalize line feed marks, git may convert them on checkout.
+	source := bytes.ReplaceAll(source, []byte("\r\n"), []byte("\n"))
+
+	// the generator embedded into the generated package is a command.
+	source = bytes.Replace(source, []byte("\npackage assetslife\n"), []byte("\npackage main\n"), 1)
+	if !bytes.Contains(source, []byte("\n"+mainFunc)) {
+		imports := bytes.Index(source, []byte("\nimport (\n"))
+		idx := imports + bytes.Index(source[imports:], []byte("\n)\n\n")) + len("\n)\n\n")
+		ret := make([]byte, 0, len(source)+len(mainFunc))
+		ret = append(ret, source[:idx]...)
+		ret = append(ret, mainFunc...)
+		ret = append(ret, source[idx:]...)
+		source = ret
+	}
+	if bytes.Contains(source, []byte("\n"+buildIgnore)) {
+		// it is already embedded into a generated package.
+		return source
+	}
+
+	// the first generation does not have the build constraint for "go get".
+	// insert it just after the copyright header.
+	idx := bytes.Index(source, []byte("\n\n")) + 2
+	ret := make([]byte, 0, len(source)+len(buildIgnore))
+	ret = append(ret, source[:idx]...)
+	ret = append(ret, buildIgnore...)
+	ret = append(ret, source[idx:]...)
+	return ret
+}
+
+// formatSource checks that src is valid Go source code, and formats it in the canonical gofmt style.
+func formatSource(filename string, src []byte) ([]byte, error) {
+	if err := parseSource(filename, src); err != nil {
+		return nil, err
+	}
+	return format.Source(src)
+}
+
+// parseSource parses src as Go source code.
+// The returned error reports every syntax error with the offending line, for debugging the generator.
+func parseSource(filename string, src []byte) error {
+	fset := token.NewFileSet()
+	_, err := parser.ParseFile(fset, filename, src, parser.AllErrors)
+	if err == nil {
+		return nil
+	}
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		return err
+	}
+	lines := bytes.Split(src, []byte("\n"))
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "generated %s is invalid Go source code:", filename)
+	for _, e := range list {
+		fmt.Fprintf(&buf, "\n%s", e)
+		if n := e.Pos.Line; n > 0 && n <= len(lines) {
+			fmt.Fprintf(&buf, "\n\t%s", bytes.TrimSpace(lines[n-1]))
+		}
+	}
+	return errors.New(buf.String())
+}
@@ -0,0 +1,1366 @@
+package assetslife
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test(t *testing.T) {
+	b, err := os.ReadFile("../test/file/assets-life.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// normalize line feed marks
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+
+	if string(selfSource()) != string(b) {
+		t.Error("do not match", string(b))
+	}
+}
+
+func TestTransformImages(t *testing.T) {
+	// copy the images instead of converting them, because the encoders may not be installed.
+	defer func(encoders map[string][]string) { imageEncoders = encoders }(imageEncoders)
+	imageEncoders = map[string][]string{"webp": {"cp", "{in}", "{out}"}}
+
+	content, err := os.ReadFile("../testdata/images/photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assets := []*asset{
+		{name: "/img", mode: 0755 | os.ModeDir},
+		{name: "/img/photo.png", mode: 0644, content: content},
+		{name: "/index.html", mode: 0644, content: []byte("<html></html>")},
+	}
+	got, err := transformImages(assets, []*imageRule{
+		{Include: []string{"*.png"}, Widths: []int{16, 64}, Formats: []string{"webp"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, a := range got {
+		names = append(names, a.name)
+	}
+	want := "/img /img/photo.png /img/photo-16w.png /img/photo.png.webp /img/photo-16w.png.webp /index.html"
+	if strings.Join(names, " ") != want {
+		t.Errorf("want %s, got %s", want, strings.Join(names, " "))
+	}
+	if !bytes.Equal(got[3].content, content) {
+		t.Error("the alternate is not converted by the encoder")
+	}
+}
+
+func TestStripMetadata(t *testing.T) {
+	exif := []byte("\xff\xe1\x00\x10Exif\x00\x00GPS-data")
+	comment := []byte("\xff\xfe\x00\x09comment")
+	// the image data after the start of scan must be kept, even if it contains the bytes like the segments.
+	scan := []byte("\xff\xda\x00\x04\x00\x00\xff\xe1\xff\xd9")
+	jpg := append(append(append([]byte("\xff\xd8\xff\xe0\x00\x04\x00\x00"), exif...), comment...), scan...)
+	chunk := func(typ, data string) string {
+		b := []byte{0, 0, 0, byte(len(data))}
+		return string(b) + typ + data + "\x00\x00\x00\x00"
+	}
+	png := "\x89PNG\r\n\x1a\n" + chunk("IHDR", "header") + chunk("tEXt", "Author\x00me") + chunk("eXIf", "GPS-data") + chunk("IEND", "")
+	assets := []*asset{
+		{name: "/img", mode: 0755 | os.ModeDir},
+		{name: "/img/photo.JPG", mode: 0644, content: jpg},
+		{name: "/img/photo.png", mode: 0644, content: []byte(png)},
+		{name: "/exif.txt", mode: 0644, content: exif},
+	}
+	got, err := stripMetadata(assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append([]byte("\xff\xd8\xff\xe0\x00\x04\x00\x00"), scan...); !bytes.Equal(got[1].content, want) {
+		t.Errorf("want %q, got %q", want, got[1].content)
+	}
+	if want := "\x89PNG\r\n\x1a\n" + chunk("IHDR", "header") + chunk("IEND", ""); string(got[2].content) != want {
+		t.Errorf("want %q, got %q", want, got[2].content)
+	}
+	if !bytes.Equal(got[3].content, exif) {
+		t.Errorf("the file other than images is modified: %q", got[3].content)
+	}
+
+	if _, err := stripMetadata([]*asset{{name: "/broken.png", mode: 0644, content: []byte("text")}}); err == nil {
+		t.Error("want an error for the broken image")
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	src := "# Title *one*\n" +
+		"\n" +
+		"A paragraph with `<code>` and _emphasis_,\n" +
+		"[a link](javascript:alert(1)) and ![an image](img/a.png \"A\").\n" +
+		"\n" +
+		"- item <1>\n" +
+		"- item 2\n" +
+		"  1. sub item\n" +
+		"\n" +
+		"> quoted\n" +
+		"> text\n" +
+		"\n" +
+		"---\n" +
+		"## Second\n"
+	want := "<h1>Title <em>one</em></h1>\n" +
+		"<p>A paragraph with <code>&lt;code&gt;</code> and <em>emphasis</em>,\n" +
+		"<a href=\"\">a link</a>) and <img src=\"img/a.png\" alt=\"an image\" title=\"A\">.</p>\n" +
+		"<ul>\n" +
+		"<li>item &lt;1&gt;</li>\n" +
+		"<li>\n<p>item 2</p>\n<ol>\n<li>sub item</li>\n</ol>\n</li>\n" +
+		"</ul>\n" +
+		"<blockquote>\n<p>quoted\ntext</p>\n</blockquote>\n" +
+		"<hr>\n" +
+		"<h2>Second</h2>\n"
+	got, title := renderMarkdown(src)
+	if got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if title != "Title one" {
+		t.Errorf("want %q, got %q", "Title one", title)
+	}
+}
+
+func TestExpandTemplates(t *testing.T) {
+	defer os.Unsetenv("ASSETS_LIFE_TEST_ENV")
+	os.Setenv("ASSETS_LIFE_TEST_ENV", "from env")
+
+	config := &asset{name: "/config.js", mode: 0644, content: []byte(`{{.A}} {{.B}} {{env "ASSETS_LIFE_TEST_ENV"}}`)}
+	rule := &templateRule{Include: []string{"*.js"}, Vars: map[string]string{"A": "a", "B": "b"}}
+	got, err := expandTemplates([]*asset{config}, rule, map[string]string{"B": "overridden"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a overridden from env"; string(got[0].content) != want {
+		t.Errorf("want %q, got %q", want, got[0].content)
+	}
+	if got[0] == config {
+		t.Error("the asset shared by the shards is modified")
+	}
+
+	for _, src := range []string{`{{.Undefined}}`, `{{env "ASSETS_LIFE_TEST_UNDEFINED"}}`} {
+		a := &asset{name: "/config.js", mode: 0644, content: []byte(src)}
+		if _, err := expandTemplates([]*asset{a}, rule, nil); err == nil {
+			t.Errorf("%s: want an error, got nil", src)
+		}
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x00")
+	assets := []*asset{
+		{name: "/css", mode: 0755 | os.ModeDir},
+		{name: "/css/app.css", mode: 0644, content: []byte("\xef\xbb\xbfbody {\r\n}\r\n")},
+		{name: "/index.html", mode: 0644, content: []byte("<p>\n</p>\r\n")},
+		{name: "/img.png", mode: 0644, content: png},
+	}
+	tests := []struct {
+		eol      string
+		stripBOM bool
+		css      string
+		html     string
+	}{
+		{"lf", false, "\xef\xbb\xbfbody {\n}\n", "<p>\n</p>\n"},
+		{"crlf", false, "\xef\xbb\xbfbody {\r\n}\r\n", "<p>\r\n</p>\r\n"},
+		{"", true, "body {\r\n}\r\n", "<p>\n</p>\r\n"},
+	}
+	for _, tt := range tests {
+		got := normalizeText(assets, tt.eol, tt.stripBOM)
+		if string(got[1].content) != tt.css {
+			t.Errorf("%s, %v: want %q, got %q", tt.eol, tt.stripBOM, tt.css, got[1].content)
+		}
+		if string(got[2].content) != tt.html {
+			t.Errorf("%s, %v: want %q, got %q", tt.eol, tt.stripBOM, tt.html, got[2].content)
+		}
+		if !bytes.Equal(got[3].content, png) {
+			t.Errorf("%s, %v: the binary file is modified: %q", tt.eol, tt.stripBOM, got[3].content)
+		}
+	}
+	if string(assets[1].content) != "\xef\xbb\xbfbody {\r\n}\r\n" {
+		t.Error("the asset shared by the shards is modified")
+	}
+}
+
+func TestHTMLRewrite(t *testing.T) {
+	rw := &htmlRewrite{
+		base:   "/app/",
+		meta:   map[string]string{"version": "1.2.3", "env": `"prod"`},
+		inject: "<script>track()</script>",
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{
+			"<html><head><title>t</title></head><body></body></html>",
+			`<html><head><base href="/app/"><meta name="env" content="&#34;prod&#34;"><meta name="version" content="1.2.3"><title>t</title><script>track()</script></head><body></body></html>`,
+		},
+		// the existing elements are replaced.
+		{
+			`<HEAD lang="en"><Base HREF="/"><meta name='Version' content="0.0.1"><meta charset="utf-8"></HEAD>`,
+			`<HEAD lang="en"><meta name="env" content="&#34;prod&#34;"><base href="/app/"><meta name="version" content="1.2.3"><meta charset="utf-8"><script>track()</script></HEAD>`,
+		},
+		// the fragments without the head element are kept.
+		{"<p>fragment</p>", "<p>fragment</p>"},
+	}
+	for _, tt := range tests {
+		f := &File{Name: "/index.html", Content: []byte(tt.in)}
+		if err := rw.transform(f); err != nil {
+			t.Fatal(err)
+		}
+		if string(f.Content) != tt.want {
+			t.Errorf("%s:\nwant %s\ngot  %s", tt.in, tt.want, f.Content)
+		}
+	}
+
+	f := &File{Name: "/app.js", Content: []byte("<head></head>")}
+	if err := rw.transform(f); err != nil {
+		t.Fatal(err)
+	}
+	if string(f.Content) != "<head></head>" {
+		t.Errorf("the file that is not HTML is modified: %s", f.Content)
+	}
+}
+
+func TestConvertCharsets(t *testing.T) {
+	if _, err := exec.LookPath("iconv"); err != nil {
+		t.Skip("iconv is not installed")
+	}
+
+	// "テスト" in Shift_JIS, which is not valid UTF-8.
+	sjis := []byte("\x83\x65\x83\x58\x83\x67\n")
+	assets := []*asset{
+		{name: "/docs", mode: 0755 | os.ModeDir},
+		{name: "/docs/legacy.txt", mode: 0644, content: sjis},
+		{name: "/docs/utf8.txt", mode: 0644, content: []byte("テスト\n")},
+		{name: "/img.png", mode: 0644, content: []byte("\x89PNG\r\n\x1a\n\x00\x83\x65")},
+	}
+	got, charsets := convertCharsets(nil, assets, []string{"utf-16", "shift_jis"})
+	if string(got[1].content) != "テスト\n" {
+		t.Errorf("want %q, got %q", "テスト\n", got[1].content)
+	}
+	if string(got[2].content) != "テスト\n" {
+		t.Errorf("want %q, got %q", "テスト\n", got[2].content)
+	}
+	if got[3] != assets[3] {
+		t.Error("the binary file is converted")
+	}
+	if want := map[string]string{"/docs/legacy.txt": "shift_jis"}; fmt.Sprint(charsets) != fmt.Sprint(want) {
+		t.Errorf("want %v, got %v", want, charsets)
+	}
+	if !bytes.Equal(assets[1].content, sjis) {
+		t.Error("the asset shared by the shards is modified")
+	}
+}
+
+func TestStripSourceMaps(t *testing.T) {
+	assets := []*asset{
+		{name: "/js", mode: 0755 | os.ModeDir},
+		{name: "/js/app.js", mode: 0644, content: []byte("a();\r\n//@ sourceMappingURL=app.js.map\r\n")},
+		{name: "/js/app.js.map", mode: 0644, content: []byte("{}")},
+		{name: "/js/lib.js", mode: 0644, content: []byte("var s = '//# sourceMappingURL=x.map';\n")},
+		{name: "/style.css", mode: 0644, content: []byte("body {}\n/*# sourceMappingURL=style.css.map */")},
+	}
+	got := stripSourceMaps(assets)
+	var names []string
+	for _, a := range got {
+		names = append(names, a.name)
+	}
+	if want := "/js /js/app.js /js/lib.js /style.css"; strings.Join(names, " ") != want {
+		t.Errorf("want %s, got %s", want, strings.Join(names, " "))
+	}
+	for i, want := range []string{"", "a();\r\n", "var s = '//# sourceMappingURL=x.map';\n", "body {}\n"} {
+		if string(got[i].content) != want {
+			t.Errorf("%s: want %q, got %q", got[i].name, want, got[i].content)
+		}
+	}
+}
+
+func TestGzipSourcesDecompress(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	io.WriteString(w, "console.log(\"app\");\n")
+	w.Close()
+	assets := []*asset{
+		{name: "/app.js.gz", mode: 0644, content: gz.Bytes()},
+		{name: "/dist.tar.gz", mode: 0644, content: []byte("archive")},
+		{name: "/style.css", mode: 0644, content: []byte("body {}\n")},
+		{name: "/style.css.gz", mode: 0644, content: gz.Bytes()},
+	}
+	got, err := gzipSources(assets, "decompress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, a := range got {
+		names = append(names, a.name)
+	}
+	if want := "/app.js /dist.tar.gz /style.css"; strings.Join(names, " ") != want {
+		t.Errorf("want %s, got %s", want, strings.Join(names, " "))
+	}
+	if string(got[0].content) != "console.log(\"app\");\n" {
+		t.Errorf("unexpected content: %q", got[0].content)
+	}
+	if string(got[2].content) != "body {}\n" {
+		t.Errorf("the existing file is replaced: %q", got[2].content)
+	}
+
+	broken := []*asset{{name: "/broken.js.gz", mode: 0644, content: []byte("not gzip")}}
+	if _, err := gzipSources(broken, "decompress"); err == nil {
+		t.Error("want an error, got nil")
+	}
+}
+
+func TestFingerprintHash(t *testing.T) {
+	tests := []struct {
+		alg  string
+		want string
+	}{
+		{"crc32", "/app.6f674d7b.css"},
+		{"sha1", "/app.fbcb02f7.css"},
+		{"sha256", "/app.a06fd750.css"},
+		{"blake3", "/app.35fe6828.css"},
+	}
+	for _, tt := range tests {
+		if err := checkHash(tt.alg); err != nil {
+			t.Error(err)
+		}
+		assets := []*asset{{name: "/app.css", mode: 0644, content: []byte("body {}\n")}}
+		_, fingerprints := fingerprintAssets(assets, tt.alg)
+		if got := fingerprints["/app.css"]; got != tt.want {
+			t.Errorf("%s: want %s, got %s", tt.alg, tt.want, got)
+		}
+	}
+
+	// the revisions are truncated to 16 digits, or the whole crc32.
+	if got := digestHex("crc32", []byte("body {}\n"), 16); got != "6f674d7b" {
+		t.Errorf("want 6f674d7b, got %s", got)
+	}
+	if err := checkHash("md5"); err == nil {
+		t.Error("want an error, got nil")
+	}
+}
+
+func TestContentHashes(t *testing.T) {
+	// "plumless" and "buckeroo" have the same CRC-32.
+	files := []templateFile{
+		{Name: "/", Mode: 0755 | os.ModeDir},
+		{Name: "/a.txt", Mode: 0644, Content: "plumless"},
+		{Name: "/b.txt", Mode: 0644, Content: "buckeroo"},
+		{Name: "/c.txt", Mode: 0644, Content: "plumless"},
+	}
+	_, _, err := contentHashes(files, "crc32")
+	if ExitCode(err) != ExitValidation || !strings.Contains(err.Error(), "/a.txt and /b.txt have the same crc32 hash 4ddb0c25") {
+		t.Errorf("want the collision, got %v", err)
+	}
+
+	// the same contents have the same hash.
+	hashes, names, err := contentHashes([]templateFile{files[0], files[1], files[3]}, "crc32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashes["/a.txt"] != "4ddb0c25" || hashes["/c.txt"] != "4ddb0c25" || names["4ddb0c25"] != "/a.txt" {
+		t.Errorf("unexpected hashes: %v, %v", hashes, names)
+	}
+	if _, _, err := contentHashes(files, "sha256"); err != nil {
+		t.Error(err)
+	}
+
+	// the manifest has the SHA-256 hashes.
+	want := sha256Hex([]byte("plumless")) + "  /a.txt\n" + sha256Hex([]byte("buckeroo")) + "  /b.txt\n" + sha256Hex([]byte("plumless")) + "  /c.txt\n"
+	if got := string(manifest(files)); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestBlake3(t *testing.T) {
+	// the test vectors of https://github.com/BLAKE3-team/BLAKE3/blob/master/test_vectors/test_vectors.json,
+	// the inputs are the bytes 0, 1, ..., 250, 0, 1, ... of the length.
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{1, "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+		{1023, "10108970eeda3eb932baac1428c7a2163b0e924c9a9e25b35bba72b28f70bd11"},
+		{1024, "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+		{1025, "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+		{2048, "e776b6028c7cd22a4d0ba182a8bf62205d2ef576467e838ed6f2529b85fba24a"},
+		{2049, "5f4d72f40d7a5f82b15ca2b2e44b1de3c2ef86c426c95c1af0b6879522563030"},
+		{102400, "bc3e3d41a1146b069abffad3c0d44860cf664390afce4d9661f7902e7943e085"},
+	}
+	for _, tt := range tests {
+		b := make([]byte, tt.n)
+		for i := range b {
+			b[i] = byte(i % 251)
+		}
+		if got := hashHex("blake3", b); got != tt.want {
+			t.Errorf("%d: want %s, got %s", tt.n, tt.want, got)
+		}
+
+		// the result doesn't depend on the sizes of the writes.
+		h := newBlake3()
+		for rest := b; len(rest) > 0; {
+			n := 97
+			if n > len(rest) {
+				n = len(rest)
+			}
+			h.Write(rest[:n])
+			rest = rest[n:]
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != tt.want {
+			t.Errorf("%d in pieces: want %s, got %s", tt.n, tt.want, got)
+		}
+		h.Reset()
+		h.Write(b)
+		if got := hex.EncodeToString(h.Sum(nil)); got != tt.want {
+			t.Errorf("%d after Reset: want %s, got %s", tt.n, tt.want, got)
+		}
+	}
+}
+
+func TestReadGeneratedFiles(t *testing.T) {
+	for _, dir := range []string{"../test/intern", "../test/deep"} {
+		files, err := readGeneratedFiles(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in := "../testdata/intern"
+		if dir == "../test/deep" {
+			in = "../testdata/deep"
+		}
+		assets, err := walkDir(nil, in, false, limits{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{}
+		for _, a := range assets {
+			if !a.mode.IsDir() {
+				want[a.name] = string(a.content)
+			}
+		}
+		if !reflect.DeepEqual(files, want) {
+			t.Errorf("%s: want %v, got %v", dir, want, files)
+		}
+	}
+}
+
+func TestFindArtifacts(t *testing.T) {
+	assets := []*asset{
+		{name: "/", mode: 0755 | os.ModeDir},
+		{name: "/index.html", mode: 0644, content: []byte("<html></html>")},
+		{name: "/node_modules", mode: 0755 | os.ModeDir},
+		{name: "/node_modules/a/index.js", mode: 0644, content: []byte("a")},
+		{name: "/node_modules/b/index.js", mode: 0644, content: []byte("bb")},
+		{name: "/logs/error.log", mode: 0644, content: []byte("error")},
+		{name: "/img/.DS_Store", mode: 0644, content: []byte("x")},
+		{name: "/core.1234", mode: 0644, content: []byte("core")},
+		{name: "/design.psd", mode: 0644, content: []byte("psd")},
+		{name: "/core.css", mode: 0644, content: []byte("body {}")},
+	}
+	var got []string
+	for _, art := range findArtifacts(assets, []string{"*.psd"}) {
+		got = append(got, fmt.Sprintf("%s %s %d %d", art.name, art.pattern, art.files, art.size))
+	}
+	want := []string{
+		"/core.1234 core.[0-9]* 1 4",
+		"/img/.DS_Store .DS_Store 1 1",
+		"/logs/error.log *.log 1 5",
+		"/node_modules node_modules 2 3",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	// testdata/deep has /a and /aa/bb/c.
+	if _, err := walkDir(nil, "../testdata/deep", false, limits{maxFiles: 2, maxDepth: 3}); err != nil {
+		t.Error(err)
+	}
+	if _, err := walkDir(nil, "../testdata/deep", false, limits{maxFiles: 1}); err == nil || !strings.Contains(err.Error(), "-max-files 1") {
+		t.Errorf("want the error of -max-files, got %v", err)
+	}
+	if _, err := walkDir(nil, "../testdata/deep", false, limits{maxDepth: 2}); err == nil || !strings.Contains(err.Error(), "/aa/bb/c: the depth 3") {
+		t.Errorf("want the error of -max-depth, got %v", err)
+	}
+
+	assets := []*asset{
+		{name: "/", mode: 0755 | os.ModeDir},
+		{name: "/a/b", mode: 0644},
+	}
+	if err := (limits{maxDepth: 1}).checkAssets(assets); err == nil {
+		t.Error("want an error, got nil")
+	}
+}
+
+func TestBudgets(t *testing.T) {
+	for rule, want := range map[string]int64{
+		"/js/** <= 500KB": 500 << 10,
+		"*.png<=1.5MB":    3 << 19,
+		"/a <= 10":        10,
+		"/a <= 2 gib":     2 << 30,
+	} {
+		b, err := parseBudget(rule)
+		if err != nil {
+			t.Errorf("%s: %v", rule, err)
+			continue
+		}
+		if b.limit != want {
+			t.Errorf("%s: want %d, got %d", rule, want, b.limit)
+		}
+	}
+	for _, rule := range []string{"/js/**", " <= 1KB", "/js/** <= lots", "/js/** <= -1KB", "[ <= 1KB"} {
+		if _, err := parseBudget(rule); err == nil {
+			t.Errorf("%s: want an error, got nil", rule)
+		}
+	}
+
+	assets := []*asset{
+		{name: "/js", mode: 0755 | os.ModeDir},
+		{name: "/js/app.js", mode: 0644, content: make([]byte, 600)},
+		{name: "/js/vendor.js", mode: 0644, content: make([]byte, 900)},
+		{name: "/index.html", mode: 0644, content: make([]byte, 2000)},
+	}
+	budgets := []*budget{
+		{rule: "/js/** <= 2KB", pattern: "/js/**", limit: 2048},
+		{rule: "*.html <= 2KB", pattern: "*.html", limit: 2048},
+	}
+	if err := checkBudgets(nil, assets, budgets, ""); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+	budgets[0] = &budget{rule: "/js/** <= 1000", pattern: "/js/**", limit: 1000}
+	err := checkBudgets(nil, assets, budgets, "prod")
+	if ExitCode(err) != ExitValidation {
+		t.Fatalf("want a validation error, got %v", err)
+	}
+	// the breakdown lists the largest files first.
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "variant prod: budget /js/** <= 1000 is exceeded by 500 B: 1.5 KiB in 2 files") ||
+		strings.Index(msg, "/js/vendor.js") > strings.Index(msg, "/js/app.js") || strings.Contains(msg, "index.html") {
+		t.Errorf("unexpected error: %s", msg)
+	}
+}
+
+func TestWellKnown(t *testing.T) {
+	filename := t.TempDir() + "/config.json"
+	if err := os.WriteFile(filename, []byte(`{
+		"robots": {"disallow": ["/admin/"], "sitemaps": ["https://example.com/sitemap.xml"]},
+		"security": {
+			"contact": ["mailto:security@example.com", "https://example.com/security"],
+			"expires": "2030-01-01T09:00:00+09:00",
+			"preferredLanguages": ["en", "ja"]
+		}
+	}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := readConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assets := []*asset{
+		{name: "/", mode: 0755 | os.ModeDir},
+		{name: "/index.html", mode: 0644, content: []byte("<html></html>")},
+	}
+	assets, err = addWellKnown(nil, assets, cfg, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow: /admin/\n\nSitemap: https://example.com/sitemap.xml\n",
+		"/.well-known/security.txt": "Contact: mailto:security@example.com\nContact: https://example.com/security\n" +
+			"Expires: 2030-01-01T00:00:00Z\nPreferred-Languages: en, ja\n",
+	}
+	for _, a := range assets {
+		if w, ok := want[a.name]; ok {
+			if string(a.content) != w {
+				t.Errorf("%s: want %q, got %q", a.name, w, a.content)
+			}
+			delete(want, a.name)
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("missing files: %v", want)
+	}
+
+	// the file in the input directory conflicts with the generated one.
+	if _, err := addWellKnown(nil, assets, cfg, time.Time{}); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Errorf("want a conflict, got %v", err)
+	}
+
+	// the empty rule allows all paths.
+	if got, want := string((&robotsRule{}).robotsTxt()), "User-agent: *\nDisallow:\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	for _, tt := range []string{
+		`{"robots": {"disallow": ["admin/"]}}`,
+		`{"robots": {"sitemaps": ["/sitemap.xml"]}}`,
+		`{"security": {"expires": "2030-01-01T00:00:00Z"}}`,
+		`{"security": {"contact": ["mailto:security@example.com"]}}`,
+		`{"security": {"contact": ["mailto:security@example.com"], "expires": "2030-01-01"}}`,
+		`{"security": {"contact": ["security@example.com"], "expires": "2030-01-01T00:00:00Z"}}`,
+	} {
+		if err := os.WriteFile(filename, []byte(tt), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readConfig(filename); ExitCode(err) != ExitValidation {
+			t.Errorf("%s: want a validation error, got %v", tt, err)
+		}
+	}
+}
+
+func TestCheckNames(t *testing.T) {
+	assets := []*asset{
+		{name: "/", mode: 0755 | os.ModeDir},
+		{name: "/.well-known/security.txt", mode: 0644},
+		{name: "/a b/c.txt", mode: 0644},
+	}
+	if err := checkNames(assets); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+	for _, name := range []string{"/a\\b.txt", "/a\x00.txt", "/a/../b.txt", "a.txt", "/a/"} {
+		err := checkNames([]*asset{{name: name, mode: 0644}})
+		if ExitCode(err) != ExitValidation {
+			t.Errorf("%q: want a validation error, got %v", name, err)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	_, ioErr := os.ReadFile("../testdata/missing")
+	_, configErr := readConfig("../testdata/images.json.missing")
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("unknown"), ExitFailure},
+		{&UsageError{errors.New("usage")}, ExitUsage},
+		{ioErr, ExitIO},
+		{fmt.Errorf("wrapped: %w", ioErr), ExitIO},
+		{configErr, ExitIO},
+		{validationErrorf("invalid"), ExitValidation},
+		{&WarningsError{Warnings: 2}, ExitWarnings},
+		{(limits{maxFiles: 1}).check("/a", 2), ExitValidation},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("%v: want %d, got %d", tt.err, tt.want, got)
+		}
+	}
+
+	// the invalid configuration file.
+	filename := t.TempDir() + "/config.json"
+	if err := os.WriteFile(filename, []byte(`{"variants": {"in valid": {}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readConfig(filename); ExitCode(err) != ExitValidation {
+		t.Errorf("want %d, got %d: %v", ExitValidation, ExitCode(err), err)
+	}
+
+	// the listed file outside of the input directory.
+	_, err := readFileList(nil, strings.NewReader("../filesfrom.txt\n"), "../testdata/deep", false)
+	if ExitCode(err) != ExitValidation || !strings.Contains(err.Error(), "is outside of") {
+		t.Errorf("want %d, got %d: %v", ExitValidation, ExitCode(err), err)
+	}
+}
+
+// unreadableDir returns the directory that has a readable file, /a.txt, an unreadable file, /secret.txt,
+// and an unreadable directory, /private.
+// It skips the test if the files are readable anyway, e.g. by root.
+func unreadableDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0000); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "private"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "private", "b.txt"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "private"), 0000); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		// t.TempDir removes the directory after the cleanups registered before it.
+		os.Chmod(filepath.Join(dir, "private"), 0755)
+	})
+	if _, err := os.ReadFile(filepath.Join(dir, "secret.txt")); err == nil {
+		t.Skip("the unreadable files are readable, e.g. by root")
+	}
+	return dir
+}
+
+func TestSkipUnreadable(t *testing.T) {
+	dir := unreadableDir(t)
+
+	_, err := walkDir(nil, dir, false, limits{})
+	if !errors.Is(err, fs.ErrPermission) || ExitCode(err) != ExitIO {
+		t.Errorf("want the permission error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	log := &Logger{out: &buf, format: "text"}
+	assets, err := walkDir(log, dir, true, limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, a := range assets {
+		names = append(names, a.name)
+	}
+	if got, want := strings.Join(names, " "), "/ /a.txt"; got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+	if got := log.Warnings(); got != 2 {
+		t.Errorf("want 2 warnings, got %d: %s", got, buf.String())
+	}
+	for _, name := range []string{"private", "secret.txt"} {
+		if !strings.Contains(buf.String(), "warn: skip unreadable file: ") || !strings.Contains(buf.String(), name) {
+			t.Errorf("no warning of %s: %s", name, buf.String())
+		}
+	}
+}
+
+func TestSkipUnreadableStrict(t *testing.T) {
+	dir := unreadableDir(t)
+	out := filepath.Join(t.TempDir(), "public")
+	build := func(args ...string) (string, error) {
+		t.Helper()
+		var buf bytes.Buffer
+		b, err := ParseArgs(flag.NewFlagSet("test", flag.ContinueOnError), append(args, dir, out), WithLogOutput(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf.String(), b.Build(context.Background())
+	}
+
+	if _, err := build(); ExitCode(err) != ExitIO {
+		t.Errorf("want the exit status %d, got %v", ExitIO, err)
+	}
+
+	stderr, err := build("-skip-unreadable")
+	if err != nil || !strings.Contains(stderr, "skip unreadable file") {
+		t.Errorf("want no error with the warnings, got %v: %s", err, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(out, "filesystem.go")); err != nil {
+		t.Error(err)
+	}
+
+	_, err = build("-skip-unreadable", "-strict")
+	if ExitCode(err) != ExitWarnings {
+		t.Errorf("want the exit status %d, got %v", ExitWarnings, err)
+	}
+	if err == nil || err.Error() != "2 warning(s) occurred" {
+		t.Errorf("want the error of the warnings, got %v", err)
+	}
+}
+
+// parseJSONLog parses the log messages of -log-format json, and checks that each has only time, level and msg.
+func parseJSONLog(t *testing.T, log string) []map[string]string {
+	t.Helper()
+	var entries []map[string]string
+	for _, line := range strings.Split(strings.TrimSuffix(log, "\n"), "\n") {
+		var entry map[string]string
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		if len(entry) != 3 || entry["level"] == "" || entry["msg"] == "" {
+			t.Errorf("%q: want time, level and msg", line)
+		}
+		if _, err := time.Parse(time.RFC3339, entry["time"]); err != nil {
+			t.Errorf("%q: invalid time: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLogFormatJSON(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewLogger(&buf)
+	log.format = "json"
+	log.Infof("reading %s", "public")
+	log.Warnf("%s looks like %s", "/debug.log", "a log file")
+	log.logf(levelError, "1 warning(s) occurred")
+
+	entries := parseJSONLog(t, buf.String())
+	want := []struct{ level, msg string }{
+		{"info", "reading public"},
+		{"warn", "/debug.log looks like a log file"},
+		{"error", "1 warning(s) occurred"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("want %d messages, got %d: %s", len(want), len(entries), buf.String())
+	}
+	for i, w := range want {
+		if entries[i]["level"] != w.level || entries[i]["msg"] != w.msg {
+			t.Errorf("%d: want %s %q, got %s %q", i, w.level, w.msg, entries[i]["level"], entries[i]["msg"])
+		}
+	}
+
+	// the command writes all the messages in JSON, e.g. the warnings of -strict.
+	dir := t.TempDir()
+	for name, content := range map[string]string{"index.html": "<h1>Hello</h1>", "debug.log": "log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf.Reset()
+	b, err := ParseArgs(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-log-format", "json", "-strict", dir, filepath.Join(t.TempDir(), "public")}, WithLogOutput(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings *WarningsError
+	if err := b.Build(context.Background()); !errors.As(err, &warnings) || warnings.Warnings != 1 {
+		t.Errorf("want a warning, got %v", err)
+	}
+	levels := map[string]int{}
+	for _, entry := range parseJSONLog(t, buf.String()) {
+		levels[entry["level"]]++
+	}
+	if levels["info"] == 0 || levels["warn"] != 1 {
+		t.Errorf("unexpected levels %v: %s", levels, buf.String())
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	log := &Logger{out: &buf, format: "text", showStatus: true}
+	p := newProgress(log, "reading", 2, 3072)
+	p.last = time.Time{} // the interval has passed.
+	p.add(1024)
+	if got := buf.String(); !strings.HasPrefix(got, "\r\x1b[Kreading: 1/2 files, 1.0 KiB/3.0 KiB, ETA ") {
+		t.Errorf("unexpected progress: %q", got)
+	}
+
+	// the progress is not updated within the interval.
+	buf.Reset()
+	p.add(1024)
+	if buf.Len() != 0 {
+		t.Errorf("unexpected progress: %q", buf.String())
+	}
+
+	// the log messages clear the status line.
+	p.last = time.Time{}
+	p.add(1024)
+	buf.Reset()
+	log.Warnf("warning")
+	if got, want := buf.String(), "\r\x1b[Kwarn: warning\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	p.last = time.Time{}
+	p.add(0)
+	buf.Reset()
+	p.done()
+	if got, want := buf.String(), "\r\x1b[K"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	// the total is unknown.
+	buf.Reset()
+	p = newProgress(log, "writing", 0, 0)
+	p.last = time.Time{}
+	p.add(100)
+	if got, want := buf.String(), "\r\x1b[Kwriting: 1 files, 100 B"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	// -q and the logs out of the terminal, e.g. of the library, don't show the progress.
+	for _, log := range []*Logger{
+		{out: &buf, format: "text", showStatus: true, quiet: true},
+		NewLogger(&buf),
+		nil,
+	} {
+		buf.Reset()
+		p := newProgress(log, "reading", 1, 1)
+		p.last = time.Time{}
+		p.add(1)
+		p.done()
+		if buf.Len() != 0 {
+			t.Errorf("unexpected progress: %q", buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := New("../testdata/deep", filepath.Join(t.TempDir(), "public"), WithLogOutput(&buf)).Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "\x1b[K") {
+		t.Errorf("the library shows the progress: %q", buf.String())
+	}
+}
+
+func TestApplyHooks(t *testing.T) {
+	assets := []*asset{
+		{name: "/", mode: 0755 | os.ModeDir},
+		{name: "/a.txt", mode: 0644, content: []byte("a")},
+		{name: "/b.map", mode: 0644, content: []byte("b")},
+		{name: "/c.txt", mode: 0644, content: []byte("c")},
+	}
+	var seen []string
+	hooks := []hook{
+		{filter: func(f *File) bool { return !strings.HasSuffix(f.Name, ".map") }},
+		{transform: func(f *File) error {
+			if f.Name == "/c.txt" {
+				f.Name = "/docs/c.txt"
+				f.Content = []byte("C")
+			}
+			return nil
+		}},
+		{onFile: func(f *File) { seen = append(seen, f.Name) }},
+	}
+	got, err := applyHooks(assets, hooks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, a := range got {
+		names = append(names, a.name+":"+string(a.content))
+	}
+	if want := "/: /a.txt:a /docs/c.txt:C"; strings.Join(names, " ") != want {
+		t.Errorf("want %s, got %s", want, strings.Join(names, " "))
+	}
+	if want := "/a.txt /docs/c.txt"; strings.Join(seen, " ") != want {
+		t.Errorf("want %s, got %s", want, strings.Join(seen, " "))
+	}
+	if got[1] != assets[1] {
+		t.Error("the unchanged asset is copied")
+	}
+	if string(assets[3].content) != "c" {
+		t.Error("the original asset is modified")
+	}
+
+	// the invalid names and the errors.
+	for _, name := range []string{"docs/c.txt", "/docs/../c.txt", "/", "/a.txt"} {
+		rename := []hook{{transform: func(f *File) error {
+			if f.Name == "/c.txt" {
+				f.Name = name
+			}
+			return nil
+		}}}
+		if _, err := applyHooks(assets, rename); ExitCode(err) != ExitValidation {
+			t.Errorf("%q: want a validation error, got %v", name, err)
+		}
+	}
+	failure := []hook{{transform: func(f *File) error { return errors.New("failure") }}}
+	if _, err := applyHooks(assets, failure); err == nil || !strings.Contains(err.Error(), "/a.txt: failure") {
+		t.Errorf("want the error of the transform, got %v", err)
+	}
+}
+
+func TestSources(t *testing.T) {
+	opts := &options{}
+	readNames := func(in string) string {
+		t.Helper()
+		assets, err := opts.readAssets(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, a := range assets {
+			names = append(names, a.name)
+		}
+		return strings.Join(names, " ")
+	}
+	if got, want := readNames("../testdata/deep"), "/ /a /aa /aa/bb /aa/bb/c"; got != want {
+		t.Errorf("directory: want %s, got %s", want, got)
+	}
+	if _, ok := mustSource(t, opts, "../testdata/archive.zip").(*archiveSource); !ok {
+		t.Error("the archive is not read by archiveSource")
+	}
+
+	// the source of WithSource replaces the input.
+	opts.source = MapSource(map[string]string{"b.txt": "b", "/a/a.txt": "a"})
+	if got, want := readNames(""), "/a/a.txt /b.txt"; got != want {
+		t.Errorf("map: want %s, got %s", want, got)
+	}
+	if got, want := readNames("../testdata/deep"), "/ /a /aa /aa/bb /aa/bb/c"; got != want {
+		t.Errorf("the input of the variant: want %s, got %s", want, got)
+	}
+
+	opts.source = MapSource(map[string]string{"../a.txt": "a"})
+	if _, err := opts.readAssets(""); ExitCode(err) != ExitValidation {
+		t.Errorf("want a validation error, got %v", err)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	key, err := readSignKey("../testdata/sign.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assets, err := (&options{}).readAssets("../testdata/deep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "assets.alb")
+	if err := writeBundleFile(filename, assets, key); err != nil {
+		t.Fatal(err)
+	}
+
+	// the bundle is read as an archive.
+	if !isArchive(filename) {
+		t.Error("the bundle is not an archive")
+	}
+	got, err := (&options{}).readAssets(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, a := range got {
+		names = append(names, a.name)
+	}
+	if got, want := strings.Join(names, " "), "/a /aa /aa/bb /aa/bb/c"; got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	// the names outside of the directory are refused.
+	evil := filepath.Join(t.TempDir(), "evil.alb")
+	if err := writeBundleFile(evil, []*asset{{name: "/../evil", mode: 0644}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PackageSource(evil).Files(); err == nil {
+		t.Error("want an error of the name, got nil")
+	}
+
+	// the signature is of the compressed payload that follows it, the same as the generated package verifies.
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sig, err := readBundle(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(bytes.NewReader(b[len(bundleMagic):]))
+	if _, err := readBundleBytes(br); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := readBundleBytes(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(key.Public().(ed25519.PublicKey), payload, sig) {
+		t.Error("the signature is invalid")
+	}
+
+	// the readers reject the other versions.
+	b[3] = 1
+	if _, _, err := readBundle(bytes.NewReader(b)); err == nil || !strings.Contains(err.Error(), "unsupported version") {
+		t.Errorf("want an error of the version, got %v", err)
+	}
+}
+
+func mustSource(t *testing.T, opts *options, in string) Source {
+	t.Helper()
+	src, err := opts.sourceOf(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+var update = flag.Bool("update", false, "update the golden files in testdata/golden and the assetsfs package")
+
+func TestGolden(t *testing.T) {
+	src := MapSource(map[string]string{
+		"/index.html":   "<!DOCTYPE html>\n<link rel=\"stylesheet\" href=\"/css/app.css\">\n",
+		"/css/app.css":  "body { color: red; }\n",
+		"/css/copy.css": "body { color: red; }\n",
+		"/js/app.js":    "console.log(\"hello\");\n",
+		"/robots.txt":   "",
+	})
+	cases := []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"nonet", []Option{WithNoNet()}},
+		{"standalone", []Option{WithStandalone()}},
+		{"fingerprint", []Option{WithFingerprint()}},
+		{"backend-embed", []Option{WithBackend(backends["embed"])}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			files, err := Generate(context.Background(), src, append([]Option{WithPackageName("golden")}, c.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join("..", "testdata", "golden", c.name), files)
+		})
+	}
+}
+
+// checkGolden compares the generated Go files with the golden files in dir, or updates them with the -update flag.
+// The generator itself, assets-life.go, the runtime of -standalone, which TestAssetsfs checks, and the data files are not compared.
+func checkGolden(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	var names []string
+	for name := range files {
+		if strings.HasSuffix(name, ".go") && name != "assets-life.go" && name != "filesystem-runtime.go" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if *update {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(dir, name+".golden"), files[name], 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+
+	golden, err := filepath.Glob(filepath.Join(dir, "*.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(golden) != len(names) {
+		t.Errorf("want %d files, got %v; run make golden to update the golden files", len(golden), names)
+	}
+	for _, name := range names {
+		want, err := os.ReadFile(filepath.Join(dir, name+".golden"))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !bytes.Equal(want, files[name]) {
+			t.Errorf("%s differs from the golden file; run make golden to update it\n%s", name, files[name])
+		}
+	}
+}
+
+func TestAssetsfs(t *testing.T) {
+	filename := filepath.Join("..", "assetsfs", "assetsfs.go")
+	if *update {
+		if err := os.WriteFile(filename, assetsfsSource(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, assetsfsSource()) {
+		t.Errorf("%s differs from runtimeSource; run make golden to update it", filename)
+	}
+
+	// the standalone packages have the same runtime.
+	files, err := Generate(context.Background(), MapSource(map[string]string{"/index.html": "hello"}), WithPackageName("standalone"), WithStandalone())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(files["filesystem-runtime.go"], []byte("package standalone\n\n"+runtimeSource)) {
+		t.Error("filesystem-runtime.go differs from runtimeSource")
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	dir := t.TempDir()
+	generate := func(name string, opts *options) []byte {
+		t.Helper()
+		opts.in = "../testdata/intern"
+		opts.out = filepath.Join(dir, name)
+		opts.name = "assets"
+		if err := opts.validate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := build(context.Background(), opts); err != nil {
+			t.Fatal(err)
+		}
+		src, err := os.ReadFile(filepath.Join(opts.out, "filesystem.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the directives differ.
+		return src[bytes.Index(src, []byte("\npackage ")):]
+	}
+
+	// the memory-bounded mode generates the same package as -backend embed.
+	bounded := generate("bounded", &options{maxMemory: 1})
+	embedded := generate("embedded", &options{backend: embedBackend{}})
+	if !bytes.Equal(embedded, bounded) {
+		t.Errorf("the generated packages differ:\n%s\n%s", embedded, bounded)
+	}
+	a, err := filepath.Glob(filepath.Join(dir, "bounded", dataDir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := filepath.Glob(filepath.Join(dir, "embedded", dataDir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 2 || len(a) != len(b) || filepath.Base(a[0]) != filepath.Base(b[0]) {
+		t.Errorf("the data files differ: %v and %v", a, b)
+	}
+
+	for _, opts := range []*options{
+		{maxMemory: 1, fingerprint: true},
+		{maxMemory: 1, backend: stringBackend{}},
+		{maxMemory: -1},
+	} {
+		opts.in = "../testdata/intern"
+		opts.out = filepath.Join(dir, "invalid")
+		if err := opts.validate(); ExitCode(err) != ExitUsage {
+			t.Errorf("want a usage error, got %v", err)
+		}
+	}
+}
+
+func TestBuild_KeepPrevious(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "assets")
+	build := func(opts *options) error {
+		t.Helper()
+		opts.in = "../testdata/intern"
+		opts.out = out
+		opts.name = "assets"
+		if err := opts.validate(); err != nil {
+			t.Fatal(err)
+		}
+		return build(context.Background(), opts)
+	}
+	if err := build(&options{maxMemory: 1}); err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(filepath.Join(out, "filesystem.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := filepath.Glob(filepath.Join(out, dataDir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		t.Fatal("no data files")
+	}
+	// the staging directory is renamed into the data directory.
+	if stages, _ := filepath.Glob(filepath.Join(out, "assets-life-stage-*")); len(stages) != 0 {
+		t.Errorf("the staging directories are left: %v", stages)
+	}
+
+	// the template generates the invalid source, so the generation fails after the files are rendered.
+	tmpl := filepath.Join(dir, "invalid.tmpl")
+	if err := os.WriteFile(tmpl, []byte("package {{.Package}}\n\nvar Files = map[string]string{\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := build(&options{template: tmpl, backend: embedBackend{}}); err == nil {
+		t.Fatal("want an error, got nil")
+	}
+
+	// the package generated previously is kept.
+	got, err := os.ReadFile(filepath.Join(out, "filesystem.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, src) {
+		t.Error("filesystem.go is modified")
+	}
+	for _, name := range data {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("the data file is removed: %v", err)
+		}
+	}
+}
+
+// TestMaxMemory_Stress generates the tree of ASSETS_LIFE_STRESS_GB gigabytes in the memory-bounded mode,
+// and checks that the heap stays small. It is skipped unless the variable is set, because it writes the tree twice to the disk.
+func TestMaxMemory_Stress(t *testing.T) {
+	gb, _ := strconv.Atoi(os.Getenv("ASSETS_LIFE_STRESS_GB"))
+	if gb <= 0 {
+		t.Skip("set ASSETS_LIFE_STRESS_GB to run the stress test")
+	}
+
+	// the files are sparse except their unique headers, so the tree is created quickly.
+	const fileSize = 256 << 20
+	in := filepath.Join(t.TempDir(), "in")
+	for i := 0; i < gb<<30/fileSize; i++ {
+		name := filepath.Join(in, fmt.Sprintf("dir%d", i%8), fmt.Sprintf("file%d.bin", i))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Truncate(name, fileSize); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const maxMemory = 4
+	done := make(chan struct{})
+	peak := make(chan uint64)
+	go func() {
+		var max uint64
+		var stats runtime.MemStats
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > max {
+				max = stats.HeapInuse
+			}
+			select {
+			case <-done:
+				peak <- max
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	opts := &options{
+		in:        in,
+		out:       filepath.Join(t.TempDir(), "out"),
+		maxMemory: maxMemory,
+	}
+	if err := opts.validate(); err != nil {
+		t.Fatal(err)
+	}
+	err := build(context.Background(), opts)
+	close(done)
+	max := <-peak
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("the peak of the heap: %s", formatBytes(int64(max)))
+
+	// the heap has the buffer, the table of the names and the runtime, but not the contents.
+	if limit := uint64(maxMemory<<20 + 64<<20); max > limit {
+		t.Errorf("the peak of the heap %s exceeds %s", formatBytes(int64(max)), formatBytes(int64(limit)))
+	}
+}
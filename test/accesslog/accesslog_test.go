@@ -0,0 +1,51 @@
+package accesslog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	mux := http.NewServeMux()
+	Mount(mux, "/static/", AccessLog(&buf))
+
+	req := httptest.NewRequest(http.MethodGet, "/static/data/a.json?q=1", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.SetBasicAuth("alice", "secret")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/static/missing.txt", nil)
+	req.RemoteAddr = "[2001:db8::1]:1234"
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	pattern := regexp.MustCompile(`^192\.0\.2\.1 - alice \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [-+]\d{4}\] "GET /static/data/a\.json\?q=1 HTTP/1\.1" 200 3
+2001:db8::1 - - \[[^]]+\] "GET /static/missing\.txt HTTP/1\.1" 404 19
+$`)
+	if !pattern.MatchString(buf.String()) {
+		t.Errorf("unexpected logs: %q", buf.String())
+	}
+}
+
+func TestAccessLogFunc(t *testing.T) {
+	var entries []*AccessLogEntry
+	h := Handler(AccessLogFunc(func(e *AccessLogEntry) {
+		entries = append(entries, e)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodHead, "/data/a.json", nil))
+	SetAccessLog(false)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/data/a.json", nil))
+	SetAccessLog(true)
+
+	if len(entries) != 1 {
+		t.Fatalf("want 1 entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Method != http.MethodHead || e.Path != "/data/a.json" || e.Status != http.StatusOK || e.Bytes != 0 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
@@ -0,0 +1,270 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-chi/chi/v5"
+	"github.com/gofiber/fiber/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/afero"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func testServe(t *testing.T, h http.Handler) {
+	t.Helper()
+
+	// a file in the prefix
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/file.txt", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	// a missing file
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/missing.txt", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestChi(t *testing.T) {
+	r := chi.NewRouter()
+	ChiMount(r, "/static/")
+	testServe(t, r)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static", nil))
+	if rec.Code != http.StatusMovedPermanently {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusMovedPermanently, rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/static/" {
+		t.Errorf("unexpected location: want %q, got %q", "/static/", loc)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	e := echo.New()
+	e.GET("/static/*", EchoHandler())
+	testServe(t, e)
+}
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/static/*filepath", GinHandler())
+	testServe(t, r)
+}
+
+func TestFiber(t *testing.T) {
+	app := fiber.New()
+	app.Use("/static", FiberHandler())
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/static/file.txt", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if _, err := ioutil.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/static/missing.txt", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestPrometheus(t *testing.T) {
+	c := NewPrometheusCollector("public")
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	h := Handler(Metrics(c))
+	for _, p := range []string{"/file.txt", "/file.txt", "/missing.txt"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+
+	if got := testutil.ToFloat64(c.requests.WithLabelValues("/file.txt", "200")); got != 2 {
+		t.Errorf("unexpected requests to /file.txt: want 2, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.requests.WithLabelValues("", "404")); got != 1 {
+		t.Errorf("unexpected requests to the missing files: want 1, got %v", got)
+	}
+
+	// the requests that are not served by the files are not labeled with the paths.
+	h = Handler(Metrics(c), Authorize("/file.txt", func(r *http.Request, name string) bool { return false }))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/file.txt", nil))
+	if got := testutil.ToFloat64(c.requests.WithLabelValues("", "403")); got != 1 {
+		t.Errorf("unexpected forbidden requests: want 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.requests.WithLabelValues("", "405")); got != 1 {
+		t.Errorf("unexpected requests of the methods not allowed: want 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(c.requests.WithLabelValues("/file.txt", "403")); got != 0 {
+		t.Errorf("the forbidden requests are labeled with the path: %v", got)
+	}
+	if n, err := testutil.GatherAndCount(reg); err != nil || n == 0 {
+		t.Errorf("failed to gather: %d, %v", n, err)
+	}
+}
+
+func TestOTel(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	defer tp.Shutdown(context.Background())
+
+	h := Handler(OTelTracing(tp.Tracer("test")))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+	if name := spans[0].Name(); name != "assets /file.txt" {
+		t.Errorf("unexpected name: %q", name)
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if got := attrs["http.response.status_code"].AsInt64(); got != http.StatusOK {
+		t.Errorf("unexpected status: %d", got)
+	}
+	if got := attrs["assets.encoding"].AsString(); got != "identity" {
+		t.Errorf("unexpected encoding: %q", got)
+	}
+	if got := attrs["assets.cache_hit"].AsBool(); got {
+		t.Error("want cache miss, got hit")
+	}
+}
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	h := Handler(SlogAccessLog(slog.New(slog.NewJSONHandler(&buf, nil))))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/file.txt", nil))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "access" || entry["path"] != "/file.txt" || entry["status"] != float64(http.StatusOK) {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
+
+func TestAfero(t *testing.T) {
+	fs := AferoFs()
+
+	b, err := afero.ReadFile(fs, "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 0 {
+		t.Errorf("unexpected content: %q", b)
+	}
+
+	var names []string
+	err = afero.Walk(fs, "/", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		names = append(names, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "/" || names[1] != "/file.txt" {
+		t.Errorf("unexpected files: %v", names)
+	}
+
+	if _, err := fs.Stat("/missing.txt"); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+	if err := afero.WriteFile(fs, "/new.txt", []byte("new"), 0644); !os.IsPermission(err) {
+		t.Errorf("want permission error, got %v", err)
+	}
+	if err := fs.Remove("/file.txt"); !os.IsPermission(err) {
+		t.Errorf("want permission error, got %v", err)
+	}
+}
+
+func TestExtractToAfero(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	if err := ExtractToAfero(fs, "/fixtures"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := fs.Stat("/fixtures/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := AferoFs().Stat("/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != want.Mode() {
+		t.Errorf("unexpected mode: want %s, got %s", want.Mode(), info.Mode())
+	}
+}
+
+func TestBilly(t *testing.T) {
+	fs := BillyFilesystem()
+
+	b, err := util.ReadFile(fs, "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 0 {
+		t.Errorf("unexpected content: %q", b)
+	}
+
+	infos, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != "file.txt" {
+		t.Errorf("unexpected files: %v", infos)
+	}
+
+	if _, err := fs.Stat("/missing.txt"); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+	if err := util.WriteFile(fs, "/new.txt", []byte("new"), 0644); err != billy.ErrReadOnly {
+		t.Errorf("want read-only error, got %v", err)
+	}
+
+	// the chroot cannot be escaped.
+	sub, err := fs.Chroot("/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Stat("../file.txt"); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+}
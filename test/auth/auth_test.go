@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	h := Handler(
+		Authorize("/private.txt", func(r *http.Request, name string) bool {
+			return r.Header.Get("X-Token") == "token"
+		}),
+	)
+
+	cases := []struct {
+		path  string
+		token string
+		want  int
+	}{
+		{"/public.txt", "", http.StatusOK},
+		{"/private.txt", "", http.StatusForbidden},
+		{"/private.txt", "wrong", http.StatusForbidden},
+		{"/private.txt", "token", http.StatusOK},
+		{"/./private.txt", "", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.token != "" {
+			req.Header.Set("X-Token", c.token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s with %q: unexpected status: want %d, got %d", c.path, c.token, c.want, rec.Code)
+		}
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	h := Handler(BasicAuth("/admin/**", "admin", map[string]string{"alice": "secret"}))
+
+	cases := []struct {
+		path     string
+		user     string
+		password string
+		want     int
+	}{
+		{"/public.txt", "", "", http.StatusOK},
+		{"/admin/secret.txt", "", "", http.StatusUnauthorized},
+		{"/admin/", "", "", http.StatusUnauthorized},
+		{"/admin", "", "", http.StatusUnauthorized},
+		{"/admin/secret.txt", "alice", "wrong", http.StatusUnauthorized},
+		{"/admin/secret.txt", "bob", "secret", http.StatusUnauthorized},
+		{"/admin/secret.txt", "alice", "secret", http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.user != "" {
+			req.SetBasicAuth(c.user, c.password)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s as %q: unexpected status: want %d, got %d", c.path, c.user, c.want, rec.Code)
+		}
+		if c.want == http.StatusUnauthorized {
+			if got, want := rec.Header().Get("WWW-Authenticate"), `Basic realm="admin", charset="UTF-8"`; got != want {
+				t.Errorf("%s: unexpected WWW-Authenticate: want %q, got %q", c.path, want, got)
+			}
+		}
+	}
+}
+
+func TestAuthorize_Rewrites(t *testing.T) {
+	deny := func(r *http.Request, name string) bool { return false }
+	cases := []struct {
+		name    string
+		options []Option
+		path    string
+		lang    string
+	}{
+		{"clean URL", []Option{CleanURLs(), Authorize("/about.html", deny)}, "/about", ""},
+		{"index", []Option{Authorize("/docs/index.html", deny)}, "/docs/", ""},
+		{"index with the trailing slash policy", []Option{TrailingSlash(SlashStrip), Authorize("/docs/index.html", deny)}, "/docs", ""},
+		{"language", []Option{Languages("en", "en", "ja"), Authorize("/ja/about.html", deny)}, "/about.html", "ja"},
+		{"language and clean URL", []Option{Languages("en", "en", "ja"), CleanURLs(), Authorize("/ja/about.html", deny)}, "/about", "ja"},
+		{"language prefix and clean URL", []Option{Languages("en", "en", "ja"), CleanURLs(), Authorize("/ja/about.html", deny)}, "/ja/about", ""},
+		{"default language", []Option{Languages("en", "en", "ja"), Authorize("/en/about.html", deny)}, "/about.html", "en"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.lang != "" {
+			req.Header.Set("Accept-Language", c.lang)
+		}
+		rec := httptest.NewRecorder()
+		Handler(c.options...).ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: %s: want %d, got %d", c.name, c.path, http.StatusForbidden, rec.Code)
+		}
+	}
+
+	// the other files are served.
+	req := httptest.NewRequest(http.MethodGet, "/about", nil)
+	req.Header.Set("Accept-Language", "en")
+	rec := httptest.NewRecorder()
+	Handler(Languages("en", "en", "ja"), CleanURLs(), Authorize("/ja/about.html", deny)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestBasicAuth_ContentAddressable(t *testing.T) {
+	h := Handler(ContentAddressable(), BasicAuth("/admin/**", "admin", map[string]string{"alice": "secret"}))
+	hash, ok := Hash("/admin/secret.txt")
+	if !ok {
+		t.Fatal("/admin/secret.txt has no hash")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/_cas/"+hash, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("want %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/_cas/"+hash, nil)
+	req.SetBasicAuth("alice", "secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	// the public files are served by their hashes.
+	hash, _ = Hash("/public.txt")
+	req = httptest.NewRequest(http.MethodGet, "/_cas/"+hash, nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("want %d, got %d", http.StatusOK, rec.Code)
+	}
+}
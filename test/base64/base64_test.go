@@ -0,0 +1,84 @@
+package base64
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestContent(t *testing.T) {
+	b, err := ioutil.ReadFile("../../testdata/intern/app.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/app.css", "/copy/app.css"} {
+		f, err := Root.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(b) {
+			t.Errorf("%s: want %q, got %q", name, b, got)
+		}
+	}
+
+	// the contents are written in base64.
+	src, err := ioutil.ReadFile("filesystem.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(src), "color: red") {
+		t.Error("the content is written as the string literal")
+	}
+}
+
+func TestLazy(t *testing.T) {
+	i, ok := assets.files.lookup("/other.txt")
+	if !ok {
+		t.Fatal("/other.txt is not embedded")
+	}
+	f := &files[i]
+	if f.Lazy == nil {
+		t.Fatal("the content is not decoded lazily")
+	}
+	if f.Lazy.content != "" {
+		t.Fatal("the content is decoded before the first access")
+	}
+
+	// listing the directory doesn't decode the content.
+	dir, err := Root.Open("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	fis, err := dir.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range fis {
+		if fi.Name() == "other.txt" && fi.Size() != int64(len("other\n")) {
+			t.Errorf("unexpected size: %d", fi.Size())
+		}
+	}
+	if f.Lazy.content != "" {
+		t.Error("the content is decoded by Readdir")
+	}
+
+	// opening the file decodes the content.
+	file, err := Root.Open("/other.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "other\n" || f.Lazy.content != "other\n" {
+		t.Errorf("unexpected content: %q", got)
+	}
+}
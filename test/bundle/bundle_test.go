@@ -0,0 +1,250 @@
+package bundle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// bundlePayload returns the compressed payload of the files, whose modes are 0644 unless they are in modes.
+func bundlePayload(t *testing.T, files map[string]string, modes map[string]fs.FileMode) []byte {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, name := range names {
+		mode, ok := modes[name]
+		if !ok {
+			mode = 0644
+		}
+		writeBundleString(zw, name)
+		writeBundleUvarint(zw, uint64(mode))
+		writeBundleString(zw, files[name])
+	}
+	writeBundleString(zw, "")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeBundle returns the bundle of the payload and the signature.
+func encodeBundle(payload, sig []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("ALB\x02")
+	writeBundleString(&buf, string(sig))
+	writeBundleString(&buf, string(payload))
+	return buf.Bytes()
+}
+
+// writeBundle writes the bundle of the files signed by key, or unsigned if key is nil.
+func writeBundle(t *testing.T, files map[string]string, key ed25519.PrivateKey) []byte {
+	t.Helper()
+	payload := bundlePayload(t, files, nil)
+	var sig []byte
+	if key != nil {
+		sig = ed25519.Sign(key, payload)
+	}
+	return encodeBundle(payload, sig)
+}
+
+func writeBundleUvarint(w io.Writer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func writeBundleString(w io.Writer, s string) {
+	writeBundleUvarint(w, uint64(len(s)))
+	io.WriteString(w, s)
+}
+
+func readFile(t *testing.T, fsys http.FileSystem, name string) string {
+	t.Helper()
+	f, err := fsys.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestLoadBundle(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetBundleKey(pub)
+	defer SetBundleKey(nil)
+	defer UnloadBundle()
+
+	b := writeBundle(t, map[string]string{
+		"/a":        "updated",
+		"/new/file": "new",
+	}, key)
+	if err := LoadBundle(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, Root, "/a"); got != "updated" {
+		t.Errorf("unexpected content: %q", got)
+	}
+	if got := readFile(t, Root, "/new/file"); got != "new" {
+		t.Errorf("unexpected content: %q", got)
+	}
+	// the files that are not in the bundle fall back to the embedded files.
+	if got := readFile(t, Root, "/aa/bb/c"); got != "" {
+		t.Errorf("unexpected content: %q", got)
+	}
+
+	// the directories list the files in the bundle.
+	dir, err := Root.Open("/new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	fis, err := dir.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 || fis[0].Name() != "file" {
+		t.Errorf("unexpected entries: %v", fis)
+	}
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/new/file", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "new" {
+		t.Errorf("unexpected response: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// the broken bundles keep the files loaded before.
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, b := range map[string][]byte{
+		"unsigned":     writeBundle(t, map[string]string{"/a": "unsigned"}, nil),
+		"other key":    writeBundle(t, map[string]string{"/a": "other"}, otherKey),
+		"truncated":    b[:len(b)-8],
+		"not a bundle": []byte("not a bundle"),
+		"invalid name": writeBundle(t, map[string]string{"/../a": "escaped"}, key),
+		// the signature covers the modes and the directories.
+		"other mode": encodeBundle(
+			bundlePayload(t, map[string]string{"/a": "updated"}, map[string]fs.FileMode{"/a": 0755}),
+			ed25519.Sign(key, bundlePayload(t, map[string]string{"/a": "updated"}, nil)),
+		),
+		"other directory": encodeBundle(
+			bundlePayload(t, map[string]string{"/a": "updated", "/dir": ""}, map[string]fs.FileMode{"/dir": fs.ModeDir | 0755}),
+			ed25519.Sign(key, bundlePayload(t, map[string]string{"/a": "updated"}, nil)),
+		),
+		"data after the payload": append(writeBundle(t, map[string]string{"/a": "trailing"}, key), 0),
+	} {
+		if err := LoadBundle(bytes.NewReader(b)); err == nil {
+			t.Errorf("%s: want an error, got nil", name)
+		}
+	}
+	if got := readFile(t, Root, "/a"); got != "updated" {
+		t.Errorf("unexpected content: %q", got)
+	}
+
+	UnloadBundle()
+	if got := readFile(t, Root, "/a"); got != "" {
+		t.Errorf("unexpected content: %q", got)
+	}
+	if _, err := Root.Open("/new/file"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("want not exist, got %v", err)
+	}
+}
+
+func TestLoadBundle_TooLarge(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetBundleKey(pub)
+	defer SetBundleKey(nil)
+	defer UnloadBundle()
+	defer func(size int64) { maxBundleSize = size }(maxBundleSize)
+	maxBundleSize = 1024
+
+	// the files compress well, so the payload is smaller than the limit but the inflated files are not.
+	b := writeBundle(t, map[string]string{"/a": strings.Repeat("a", 4096)}, key)
+	if len(b) > 1024 {
+		t.Fatalf("the bundle is too large for the test: %d bytes", len(b))
+	}
+	if err := LoadBundle(bytes.NewReader(b)); err == nil || !strings.Contains(err.Error(), "larger than 1024 bytes") {
+		t.Errorf("want the error of the size, got %v", err)
+	}
+
+	// the length of the payload is checked before reading it.
+	b = encodeBundle([]byte(strings.Repeat("x", 2048)), nil)
+	if err := LoadBundle(bytes.NewReader(b)); err == nil || !strings.Contains(err.Error(), "larger than 1024 bytes") {
+		t.Errorf("want the error of the size, got %v", err)
+	}
+}
+
+func TestLoadBundle_NoKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := writeBundle(t, map[string]string{"/a": "updated"}, key)
+	if err := LoadBundle(bytes.NewReader(b)); err == nil {
+		t.Error("want an error, got nil")
+	}
+}
+
+// assets.alb is the bundle of testdata/intern generated by the bundle subcommand with testdata/sign.key.
+func TestLoadBundle_Subcommand(t *testing.T) {
+	b, err := os.ReadFile("../../testdata/sign.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatal("no PEM block")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetBundleKey(pub.(ed25519.PublicKey))
+	defer SetBundleKey(nil)
+	defer UnloadBundle()
+
+	f, err := os.Open("assets.alb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := LoadBundle(f); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, Root, "/copy/app.css"); got != "body { color: red; }\n" {
+		t.Errorf("unexpected content: %q", got)
+	}
+	if got := readFile(t, Root, "/other.txt"); got != "other\n" {
+		t.Errorf("unexpected content: %q", got)
+	}
+}
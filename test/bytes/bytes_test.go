@@ -0,0 +1,87 @@
+package bytes
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestBytes(t *testing.T) {
+	f, err := Root.Open("/large.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Bytes("/large.txt"); string(got) != string(want) {
+		t.Errorf("unexpected content: %d bytes", len(got))
+	}
+	if allocs := testing.AllocsPerRun(100, func() { Bytes("/large.txt") }); allocs != 0 {
+		t.Errorf("Bytes must not copy the content: %f allocs", allocs)
+	}
+
+	if got := Bytes("/"); got != nil {
+		t.Errorf("want nil for the directory, got %q", got)
+	}
+	if got := Bytes("/not-found.txt"); got != nil {
+		t.Errorf("want nil for the missing file, got %q", got)
+	}
+	if got := Bytes("/../large.txt"); got != nil {
+		t.Errorf("want nil for the invalid name, got %q", got)
+	}
+}
+
+func TestBytesHook(t *testing.T) {
+	o := NewOverlay()
+	if err := o.WriteFile("/hooked.txt", []byte("hooked")); err != nil {
+		t.Fatal(err)
+	}
+	SetOpenHook(func(name string) (http.File, bool) {
+		if name != "/hooked.txt" {
+			return nil, false
+		}
+		f, err := o.Open(name)
+		return f, err == nil
+	})
+	defer SetOpenHook(nil)
+
+	// Bytes and Open return the same content.
+	f, err := Root.Open("/hooked.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Bytes("/hooked.txt"); string(got) != string(want) || string(got) != "hooked" {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got := Bytes("/large.txt"); len(got) == 0 {
+		t.Error("the file not hooked is not found")
+	}
+}
+
+func TestOverlayBytes(t *testing.T) {
+	o := NewOverlay()
+	if err := o.WriteFile("/large.txt", []byte("overlaid")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(o.Bytes("/large.txt")), "overlaid"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got := Bytes("/large.txt"); string(got) == "overlaid" {
+		t.Error("the overlay changed the embedded file")
+	}
+	if err := o.Remove("/large.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got := o.Bytes("/large.txt"); got != nil {
+		t.Errorf("want nil for the removed file, got %q", got)
+	}
+}
@@ -0,0 +1,146 @@
+package cas
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// appCSS is the SHA-256 hash of /app.css and /copy/app.css.
+const appCSS = "9767e91e9d4b0334e59a1d389e9801bc6a2c5c4a5500a3c2c7915687965b2c16"
+
+func TestByHash(t *testing.T) {
+	for _, name := range []string{"/app.css", "/copy/app.css"} {
+		hash, ok := Hash(name)
+		if !ok || hash != appCSS {
+			t.Errorf("%s: want %s, got %s", name, appCSS, hash)
+		}
+	}
+	if _, ok := Hash("/copy"); ok {
+		t.Error("the directory has the hash")
+	}
+
+	f, err := ByHash(appCSS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "body { color: red; }\n" {
+		t.Errorf("unexpected content: %q", b)
+	}
+
+	if _, err := ByHash("0000"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("want fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestContentURL(t *testing.T) {
+	defer func(u string) { BaseURL = u }(BaseURL)
+	BaseURL = "https://cdn.example.com/"
+	if got, want := ContentURL("/copy/app.css"), "https://cdn.example.com/_cas/"+appCSS; got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+	if got, want := ContentURL("/missing.css"), "https://cdn.example.com/missing.css"; got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestContentAddressable(t *testing.T) {
+	h := Handler(ContentAddressable())
+	req := httptest.NewRequest(http.MethodGet, "/_cas/"+appCSS, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/css; charset=utf-8" {
+		t.Errorf("unexpected content type: %s", got)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+		t.Errorf("unexpected cache control: %s", got)
+	}
+	if rec.Body.String() != "body { color: red; }\n" {
+		t.Errorf("unexpected body: %q", rec.Body.String())
+	}
+
+	// the clients revalidate with the ETag.
+	req = httptest.NewRequest(http.MethodGet, "/_cas/"+appCSS, nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("want %d, got %d", http.StatusNotModified, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/_cas/0000", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("want %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	// the handler without the option does not serve /_cas/.
+	req = httptest.NewRequest(http.MethodGet, "/_cas/"+appCSS, nil)
+	rec = httptest.NewRecorder()
+	Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("want %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestDebug(t *testing.T) {
+	h := Handler(Debug("", func(r *http.Request) bool {
+		return r.Header.Get("X-Debug") == "secret"
+	}))
+
+	// the inventory is hidden from the requests that are not allowed.
+	req := httptest.NewRequest(http.MethodGet, "/_assets/debug", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("want %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/_assets/debug", nil)
+	req.Header.Set("X-Debug", "secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+		t.Errorf("unexpected content type: %s", got)
+	}
+	var info DebugInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.APIVersion != APIVersion || info.SourceHash != SourceHash() || info.Signed || info.BundleHash != "" {
+		t.Errorf("unexpected metadata: %+v", info)
+	}
+	var found bool
+	var total int64
+	for _, f := range info.Files {
+		total += f.Size
+		if f.Name == "/copy/app.css" {
+			found = true
+			if f.Hash != appCSS || f.Size != int64(len("body { color: red; }\n")) {
+				t.Errorf("unexpected file: %+v", f)
+			}
+		}
+	}
+	if !found {
+		t.Error("/copy/app.css is not listed")
+	}
+	if info.TotalBytes != total {
+		t.Errorf("want %d, got %d", total, info.TotalBytes)
+	}
+}
@@ -0,0 +1,93 @@
+package cleanurls
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCleanURLs(t *testing.T) {
+	h := Handler(CleanURLs())
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+		body     string
+	}{
+		{"/about", http.StatusOK, "", "<p>about</p>\n"},
+		{"/about?q=1", http.StatusOK, "", "<p>about</p>\n"},
+		{"/about.html", http.StatusMovedPermanently, "about", ""},
+		{"/about.html?q=1", http.StatusMovedPermanently, "about?q=1", ""},
+		{"/docs/", http.StatusOK, "", "<p>docs</p>\n"},
+		{"/docs", http.StatusMovedPermanently, "docs/", ""},
+		{"/docs/index.html", http.StatusMovedPermanently, "./", ""},
+		{"/docs/guide", http.StatusOK, "", "<p>guide</p>\n"},
+		{"/docs/guide.html", http.StatusMovedPermanently, "guide", ""},
+		{"/hello.txt", http.StatusOK, "", "hello\n"},
+		{"/hello", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: unexpected status: want %d, got %d", tt.path, tt.status, rec.Code)
+		}
+		if loc := rec.Header().Get("Location"); loc != tt.location {
+			t.Errorf("%s: unexpected location: want %q, got %q", tt.path, tt.location, loc)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: unexpected body: want %q, got %q", tt.path, tt.body, rec.Body.String())
+		}
+	}
+}
+
+func TestWithoutCleanURLs(t *testing.T) {
+	h := Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/about", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	tests := []struct {
+		opts     []Option
+		path     string
+		status   int
+		location string
+		body     string
+	}{
+		// add
+		{[]Option{TrailingSlash(SlashAdd)}, "/hello.txt", http.StatusMovedPermanently, "hello.txt/", ""},
+		{[]Option{TrailingSlash(SlashAdd)}, "/hello.txt/", http.StatusOK, "", "hello\n"},
+		{[]Option{TrailingSlash(SlashAdd)}, "/docs", http.StatusMovedPermanently, "docs/", ""},
+		{[]Option{TrailingSlash(SlashAdd)}, "/docs/", http.StatusOK, "", "<p>docs</p>\n"},
+		{[]Option{TrailingSlash(SlashAdd), CleanURLs()}, "/about", http.StatusMovedPermanently, "about/", ""},
+		{[]Option{TrailingSlash(SlashAdd), CleanURLs()}, "/about/", http.StatusOK, "", "<p>about</p>\n"},
+
+		// strip
+		{[]Option{TrailingSlash(SlashStrip)}, "/docs/", http.StatusMovedPermanently, "../docs", ""},
+		{[]Option{TrailingSlash(SlashStrip)}, "/docs", http.StatusOK, "", "<p>docs</p>\n"},
+		{[]Option{TrailingSlash(SlashStrip)}, "/hello.txt/", http.StatusMovedPermanently, "../hello.txt", ""},
+		{[]Option{TrailingSlash(SlashStrip)}, "/hello.txt", http.StatusOK, "", "hello\n"},
+		{[]Option{TrailingSlash(SlashStrip)}, "/", http.StatusOK, "", ""},
+		{[]Option{TrailingSlash(SlashStrip), CleanURLs()}, "/docs/guide/", http.StatusMovedPermanently, "../guide", ""},
+		{[]Option{TrailingSlash(SlashStrip), CleanURLs()}, "/docs/guide", http.StatusOK, "", "<p>guide</p>\n"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		Handler(tt.opts...).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: unexpected status: want %d, got %d", tt.path, tt.status, rec.Code)
+		}
+		if loc := rec.Header().Get("Location"); loc != tt.location {
+			t.Errorf("%s: unexpected location: want %q, got %q", tt.path, tt.location, loc)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: unexpected body: want %q, got %q", tt.path, tt.body, rec.Body.String())
+		}
+	}
+}
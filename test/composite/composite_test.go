@@ -0,0 +1,57 @@
+package composite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shogo82148/assets-life/test/intern"
+)
+
+func TestComposite(t *testing.T) {
+	h := Composite(map[string]http.FileSystem{
+		"/":       Root,
+		"/static": intern.Root,
+		"/css/":   intern.Root,
+	}, CSPNonce("default-src 'self'"))
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/a.txt", http.StatusOK, "a\n"},
+		{"/b/x.txt", http.StatusOK, "x\n"},
+		{"/static/other.txt", http.StatusOK, "other\n"},
+		{"/css/copy/app.css", http.StatusOK, "body { color: red; }\n"},
+		{"/static/a.txt", http.StatusNotFound, "404 page not found\n"},
+		{"/not-found.txt", http.StatusNotFound, "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("%s: unexpected response: %d, %q", tt.path, rec.Code, rec.Body.String())
+		}
+	}
+
+	// the prefix without the trailing slash is redirected to the directory.
+	req := httptest.NewRequest(http.MethodGet, "/static", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if loc := rec.Header().Get("Location"); rec.Code != http.StatusMovedPermanently || loc != "static/" {
+		t.Errorf("unexpected redirect: %d, %q", rec.Code, loc)
+	}
+}
+
+func TestAPIVersion(t *testing.T) {
+	for _, fsys := range []interface{}{Root, RootWithFallback("."), NewOverlay(), intern.Root} {
+		if got := APIVersionOf(fsys); got != APIVersion {
+			t.Errorf("%T: want %d, got %d", fsys, APIVersion, got)
+		}
+	}
+	if got := APIVersionOf(http.Dir(".")); got != 0 {
+		t.Errorf("want 0, got %d", got)
+	}
+}
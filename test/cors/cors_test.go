@@ -0,0 +1,58 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	h := Handler(
+		CORS("/fonts/**", CORSConfig{Origins: []string{"*"}}),
+		CORS("/data/*.json", CORSConfig{
+			Origins:       []string{"https://example.com"},
+			AllowHeaders:  []string{"X-Requested-With"},
+			ExposeHeaders: []string{"Content-Length"},
+			MaxAge:        time.Hour,
+		}),
+	)
+
+	tests := []struct {
+		method      string
+		path        string
+		origin      string
+		status      int
+		allowOrigin string
+		maxAge      string
+	}{
+		{http.MethodGet, "/fonts/a.woff2", "https://example.net", http.StatusOK, "*", ""},
+		{http.MethodGet, "/data/a.json", "https://example.com", http.StatusOK, "https://example.com", ""},
+		{http.MethodGet, "/data/a.json", "https://example.net", http.StatusOK, "", ""},
+		{http.MethodGet, "/data/a.json", "", http.StatusOK, "", ""},
+		{http.MethodGet, "/index.html", "https://example.com", http.StatusMovedPermanently, "", ""},
+		{http.MethodOptions, "/data/a.json", "https://example.com", http.StatusNoContent, "https://example.com", "3600"},
+		{http.MethodOptions, "/data/a.json", "https://example.net", http.StatusNoContent, "", ""},
+		{http.MethodOptions, "/", "https://example.com", http.StatusNoContent, "", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s from %q: unexpected status: want %d, got %d", tt.method, tt.path, tt.origin, tt.status, rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+			t.Errorf("%s %s from %q: unexpected allowed origin: want %q, got %q", tt.method, tt.path, tt.origin, tt.allowOrigin, got)
+		}
+		if got := rec.Header().Get("Access-Control-Max-Age"); got != tt.maxAge {
+			t.Errorf("%s %s from %q: unexpected max age: want %q, got %q", tt.method, tt.path, tt.origin, tt.maxAge, got)
+		}
+	}
+}
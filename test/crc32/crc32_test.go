@@ -0,0 +1,75 @@
+package crc32
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// the CRC-32 hash of "body { color: red; }\n", and the SHA-256 hashes of it and "other\n".
+const (
+	appHash  = "a8e7618a"
+	appSum   = "9767e91e9d4b0334e59a1d389e9801bc6a2c5c4a5500a3c2c7915687965b2c16"
+	otherSum = "7e4fa2eb8c7ac089739d5defc4489fad68a100d92082ca35c6b40a4524821f87"
+)
+
+func TestHash(t *testing.T) {
+	if got, _ := Hash("/app.css"); got != appHash {
+		t.Errorf("want %s, got %s", appHash, got)
+	}
+}
+
+func TestInsecureHash(t *testing.T) {
+	// the files are not served by the hashes that can be forged.
+	if _, err := ByHash(appHash); !errors.Is(err, ErrInsecureHash) {
+		t.Errorf("want ErrInsecureHash, got %v", err)
+	}
+	if got, want := ContentURL("/app.css"), URL("/app.css"); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+	defer func() {
+		if err := recover(); err != ErrInsecureHash {
+			t.Errorf("want the panic of ErrInsecureHash, got %v", err)
+		}
+	}()
+	ContentAddressable()
+}
+
+func TestManifest(t *testing.T) {
+	// the manifest is signed, so it has the SHA-256 hashes whatever HashAlgorithm is.
+	want := appSum + "  /app.css\n" + appSum + "  /copy/app.css\n" + otherSum + "  /other.txt\n"
+	if got := Manifest(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	b, err := os.ReadFile("../../testdata/sign.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatal("no PEM block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignature(key.(ed25519.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestETag(t *testing.T) {
+	h := Handler()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/app.css", nil))
+	if got, want := rec.Header().Get("ETag"), strconv.Quote(appHash); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,50 @@
+package csp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestCSPNonce(t *testing.T) {
+	h := Handler(CSPNonce("script-src 'nonce-{nonce}'"))
+	pattern := regexp.MustCompile(`<script nonce="([^"]+)">`)
+
+	var nonces []string
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected status: want %d, got %d", http.StatusOK, rec.Code)
+		}
+		m := pattern.FindStringSubmatch(rec.Body.String())
+		if m == nil || m[1] == NoncePlaceholder {
+			t.Fatalf("the nonce is not injected: %s", rec.Body.String())
+		}
+		nonce := m[1]
+		if got, want := rec.Header().Get("Content-Security-Policy"), "script-src 'nonce-"+nonce+"'"; got != want {
+			t.Errorf("unexpected policy: want %q, got %q", want, got)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("unexpected cache control: %q", got)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+			t.Errorf("unexpected content type: %q", got)
+		}
+		nonces = append(nonces, nonce)
+	}
+	if nonces[0] == nonces[1] {
+		t.Errorf("the nonce is reused: %q", nonces[0])
+	}
+
+	// the files except HTML are served as they are.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/style.css", nil))
+	if got := rec.Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("unexpected policy for CSS: %q", got)
+	}
+	if rec.Body.String() != "body {}\n" {
+		t.Errorf("unexpected body: %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,45 @@
+package dirsfirst
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func readdir(t *testing.T, fsys http.FileSystem, name string) []string {
+	t.Helper()
+	dir, err := fsys.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	fis, err := dir.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	return names
+}
+
+func TestDirsFirst(t *testing.T) {
+	want := []string{"b", "d", "a.txt", "c.txt"}
+	if got := readdir(t, Root, "/"); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// the overlay keeps the order.
+	o := NewOverlay()
+	if err := o.WriteFile("/e/z.txt", []byte("z")); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.WriteFile("/0.txt", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"b", "d", "e", "0.txt", "a.txt", "c.txt"}
+	if got := readdir(t, o, "/"); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,58 @@
+package embedded
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContent(t *testing.T) {
+	b, err := ioutil.ReadFile("../../testdata/intern/app.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/app.css", "/copy/app.css"} {
+		f, err := Root.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(b) {
+			t.Errorf("%s: want %q, got %q", name, b, got)
+		}
+	}
+
+	src, err := ioutil.ReadFile("filesystem.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(src), "color: red") {
+		t.Error("the content is written as the string literal")
+	}
+}
+
+func TestDataFiles(t *testing.T) {
+	// the data files are named by the hashes, and the shared content is written once.
+	files, err := filepath.Glob("assets-life-data/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("want 2 data files, got %v", files)
+	}
+	b, err := ioutil.ReadFile("../../testdata/intern/app.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(b)
+	if _, err := ioutil.ReadFile(filepath.Join("assets-life-data", hex.EncodeToString(sum[:]))); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,52 @@
+package emptydir
+
+import (
+	"io"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	for _, name := range []string{"/empty", "/hidden_only"} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := Root.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stat, err := dir.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !stat.IsDir() {
+				t.Error("want directory, but it is not")
+			}
+
+			fis, err := dir.Readdir(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fis == nil || len(fis) != 0 {
+				t.Errorf("want empty slice, got %#v", fis)
+			}
+
+			fis, err = dir.Readdir(1)
+			if err != io.EOF {
+				t.Errorf("want io.EOF, got %v", err)
+			}
+			if len(fis) != 0 {
+				t.Errorf("want empty, got %d entries", len(fis))
+			}
+		})
+	}
+
+	root, err := Root.Open("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fis, err := root.Readdir(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 2 {
+		t.Errorf("want %d, got %d", 2, len(fis))
+	}
+}
@@ -0,0 +1,18 @@
+package exportignore
+
+import (
+	"os"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	if _, err := Root.Open("/keep.txt"); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{"/ignored.txt", "/ignored_dir", "/ignored_dir/file.txt"} {
+		_, err := Root.Open(name)
+		if !os.IsNotExist(err) {
+			t.Errorf("%s will be not exist, but %v", name, err)
+		}
+	}
+}
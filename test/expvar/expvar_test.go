@@ -0,0 +1,36 @@
+package expvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpvar(t *testing.T) {
+	hits := expvar.Get("assets.hits").(*expvar.Int)
+	bytes := expvar.Get("assets.bytes").(*expvar.Int)
+	hits0, bytes0 := hits.Value(), bytes.Value()
+
+	// the counters need no options.
+	h := Handler()
+	for _, target := range []string{"/app.css", "/missing.css"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	if got := hits.Value() - hits0; got != 1 {
+		t.Errorf("want 1 hit, got %d", got)
+	}
+	if got := bytes.Value() - bytes0; got < int64(len("body { color: red; }\n")) {
+		t.Errorf("too few bytes: %d", got)
+	}
+
+	var hashes map[string]string
+	if err := json.Unmarshal([]byte(expvar.Get("assets.source_hash").String()), &hashes); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hashes["expvar"], SourceHash(); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,114 @@
+package extract
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestExtractTo(t *testing.T) {
+	dir := t.TempDir()
+	if err := ExtractTo(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "aa", "bb", "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "" {
+		t.Errorf("unexpected content: want empty, got %q", string(b))
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	stat, err := os.Stat(filepath.Join(dir, "aa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode() != 0755|os.ModeDir {
+		t.Errorf("unexpected mode: want %s, got %s", 0755|os.ModeDir, stat.Mode())
+	}
+	stat, err = os.Stat(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode() != 0644 {
+		t.Errorf("unexpected mode: want %s, got %s", os.FileMode(0644), stat.Mode())
+	}
+}
+
+func TestExtractTo_ModTime(t *testing.T) {
+	dir := t.TempDir()
+	if err := ExtractTo(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// the modification times of the source files are restored, including the directories.
+	for _, name := range []string{"a", "aa", filepath.Join("aa", "bb"), filepath.Join("aa", "bb", "c")} {
+		src, err := os.Stat(filepath.Join("..", "..", "testdata", "deep", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !dst.ModTime().Equal(src.ModTime()) {
+			t.Errorf("%s: want %s, got %s", name, src.ModTime(), dst.ModTime())
+		}
+	}
+
+	// the file system reports them too.
+	f, err := Root.Open("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src, err := os.Stat(filepath.Join("..", "..", "testdata", "deep", "a")); err != nil || !stat.ModTime().Equal(src.ModTime()) {
+		t.Errorf("unexpected modification time: %s, %v", stat.ModTime(), err)
+	}
+}
+
+func TestExtractTo_Symlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links requires the privilege on windows")
+	}
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "aa")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExtractTo(dir); err == nil {
+		t.Error("want error, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "bb")); !os.IsNotExist(err) {
+		t.Errorf("the file is written outside of the target directory: %v", err)
+	}
+}
+
+func TestSeedT(t *testing.T) {
+	var dir string
+	t.Run("seed", func(t *testing.T) {
+		dir = SeedT(t)
+		b, err := ioutil.ReadFile(filepath.Join(dir, "aa", "bb", "c"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "" {
+			t.Errorf("unexpected content: want empty, got %q", string(b))
+		}
+	})
+
+	// the directory is removed after the test.
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package fallback
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRootWithFallback(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "patch.txt"), []byte("patch"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs := RootWithFallback(dir)
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		// the embedded file is preferred.
+		{"/file.txt", ""},
+		// the file that is not embedded is opened from the disk.
+		{"/patch.txt", "patch"},
+	}
+	for _, tt := range tests {
+		f, err := fs.Open(tt.name)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		b, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(b) != tt.content {
+			t.Errorf("%s: want %q, got %q", tt.name, tt.content, string(b))
+		}
+	}
+
+	if _, err := fs.Open("/missing.txt"); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+}
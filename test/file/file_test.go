@@ -1,9 +1,14 @@
 package file
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -49,3 +54,36 @@ func TestHiddenFile(t *testing.T) {
 		t.Errorf("hidden file will be not exist, but %v", err)
 	}
 }
+
+func TestDoc(t *testing.T) {
+	b, err := ioutil.ReadFile("doc.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"github.com/shogo82148/assets-life/test/file"`
+	if !strings.Contains(string(b), want) {
+		t.Errorf("the import path %s is not documented: %s", want, string(b))
+	}
+}
+
+func TestOpenContext(t *testing.T) {
+	f, err := OpenContext(context.Background(), "/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := OpenContext(ctx, "/file.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("want %v, got %v", context.Canceled, err)
+	}
+
+	// the handler opens the files with the context of the request.
+	req := httptest.NewRequest(http.MethodGet, "/file.txt", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, req)
+	if rec.Code == http.StatusOK {
+		t.Error("want error for the canceled request")
+	}
+}
@@ -0,0 +1,45 @@
+package filesfrom
+
+import (
+	"os"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	t.Run("listed file", func(t *testing.T) {
+		f, err := Root.Open("/aa/bb/c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.Name() != "c" {
+			t.Errorf("unexpected name: want %q, got %q", "c", stat.Name())
+		}
+	})
+
+	t.Run("not listed file", func(t *testing.T) {
+		_, err := Root.Open("/a")
+		if !os.IsNotExist(err) {
+			t.Errorf("not listed file will be not exist, but %v", err)
+		}
+	})
+
+	t.Run("parent directories", func(t *testing.T) {
+		for _, name := range []string{"/", "/aa", "/aa/bb"} {
+			dir, err := Root.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fis, err := dir.Readdir(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fis) != 1 {
+				t.Errorf("%s: want %d, got %d", name, 1, len(fis))
+			}
+		}
+	})
+}
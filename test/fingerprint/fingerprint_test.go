@@ -0,0 +1,116 @@
+package fingerprint
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	f, err := Root.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFingerprint(t *testing.T) {
+	pattern := regexp.MustCompile(`^/css/app\.[0-9a-f]{8}\.css$`)
+	if name := Fingerprint("/css/app.css"); !pattern.MatchString(name) {
+		t.Errorf("unexpected name: %q", name)
+	}
+	if name := Fingerprint("/index.html"); name != "/index.html" {
+		t.Errorf("HTML files must not be fingerprinted, but %q", name)
+	}
+	if _, err := Root.Open("/css/app.css"); !os.IsNotExist(err) {
+		t.Errorf("the original name must not exist, but %v", err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	app := path.Base(Fingerprint("/css/app.css"))
+	base := path.Base(Fingerprint("/css/base.css"))
+	logo := path.Base(Fingerprint("/img/logo.png"))
+
+	want := `<!doctype html>
+<link rel="stylesheet" href="css/` + app + `">
+<link rel="stylesheet" href="https://cdn.example.com/lib.css">
+<img src='/img/` + logo + `?v=1' alt="logo">
+<a href="page.html">page href="css/app.css"</a>
+`
+	if got := readFile(t, "/index.html"); got != want {
+		t.Errorf("unexpected index.html: want %q, got %q", want, got)
+	}
+
+	want = `@import "` + base + `";
+body { background: url(../img/` + logo + `); }
+`
+	if got := readFile(t, Fingerprint("/css/app.css")); got != want {
+		t.Errorf("unexpected app.css: want %q, got %q", want, got)
+	}
+
+	want = `h1 { background: url("/img/` + logo + `#icon"); }
+`
+	if got := readFile(t, Fingerprint("/css/base.css")); got != want {
+		t.Errorf("unexpected base.css: want %q, got %q", want, got)
+	}
+}
+
+func TestURL(t *testing.T) {
+	defer func() { BaseURL = "" }()
+	if got, want := URL("/css/app.css"), Fingerprint("/css/app.css"); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	BaseURL = "https://cdn.example.com/3f9a2c/"
+	if got, want := URL("/css/app.css"), "https://cdn.example.com/3f9a2c"+Fingerprint("/css/app.css"); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	tmpl := template.Must(template.New("page").Funcs(TemplateFuncs()).Parse(`<link href="{{asset "/css/app.css"}}">`))
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<link href="https://cdn.example.com/3f9a2c` + Fingerprint("/css/app.css") + `">`; b.String() != want {
+		t.Errorf("want %q, got %q", want, b.String())
+	}
+}
+
+func TestSys(t *testing.T) {
+	f, err := Root.Open(Fingerprint("/css/app.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, ok := fi.Sys().(*FileMeta)
+	if !ok {
+		t.Fatalf("want *FileMeta, got %T", fi.Sys())
+	}
+	if meta.Source != "/css/app.css" {
+		t.Errorf("unexpected source: %q", meta.Source)
+	}
+	if hash, _ := Hash(Fingerprint("/css/app.css")); meta.Hash != hash || hash == "" {
+		t.Errorf("unexpected hash: want %q, got %q", hash, meta.Hash)
+	}
+	if meta.ContentType != "text/css; charset=utf-8" {
+		t.Errorf("unexpected content type: %q", meta.ContentType)
+	}
+	if meta.CompressedSize != -1 {
+		t.Errorf("unexpected compressed size: %d", meta.CompressedSize)
+	}
+}
@@ -0,0 +1,45 @@
+package gitref
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	for _, name := range []string{"/a", "/aa/bb/c"} {
+		f, err := Root.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.IsDir() {
+			t.Errorf("%s: want not directory, but it is", name)
+		}
+		if stat.Mode() != 0644 {
+			t.Errorf("%s: unexpected mode: want %s, got %s", name, os.FileMode(0644), stat.Mode())
+		}
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "" {
+			t.Errorf("%s: unexpected content: want empty, got %v", name, string(b))
+		}
+	}
+
+	dir, err := Root.Open("/aa/bb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := dir.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.IsDir() {
+		t.Error("want directory, but it is not")
+	}
+}
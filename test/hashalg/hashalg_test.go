@@ -0,0 +1,144 @@
+package hashalg
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// the BLAKE3 hashes of "body { color: red; }\n" and "other\n".
+const (
+	appHash   = "e187838a214a59c96903f5da42dbfa2dd91b36388dbccfecbcc222ed3752ce9b"
+	otherHash = "c0d6c8281a3879ca493d73b4b2372662b69803fda485c67b6ee1bbafe82dd9a5"
+)
+
+// the SHA-256 hashes of "body { color: red; }\n" and "other\n".
+const (
+	appSum   = "9767e91e9d4b0334e59a1d389e9801bc6a2c5c4a5500a3c2c7915687965b2c16"
+	otherSum = "7e4fa2eb8c7ac089739d5defc4489fad68a100d92082ca35c6b40a4524821f87"
+)
+
+func TestHash(t *testing.T) {
+	if HashAlgorithm != "blake3" {
+		t.Errorf("want blake3, got %s", HashAlgorithm)
+	}
+	if got, _ := Hash(Fingerprint("/other.txt")); got != otherHash {
+		t.Errorf("want %s, got %s", otherHash, got)
+	}
+
+	// the fingerprints are the hashes of the same algorithm.
+	if got, want := Fingerprint("/app.css"), "/app."+appHash[:8]+".css"; got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	f, err := ByHash(otherHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "other\n" {
+		t.Errorf("unexpected content: %q", b)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	// the manifest is signed, so it has the SHA-256 hashes whatever HashAlgorithm is.
+	want := appSum + "  /app." + appHash[:8] + ".css\n" +
+		appSum + "  /copy/app." + appHash[:8] + ".css\n" +
+		otherSum + "  /other." + otherHash[:8] + ".txt\n"
+	if got := Manifest(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := SourceHash(), assets.hashHex([]byte(want)); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	b, err := os.ReadFile("../../testdata/sign.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatal("no PEM block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignature(key.(ed25519.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileMeta(t *testing.T) {
+	f, err := Root.Open(Fingerprint("/other.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta, ok := fi.Sys().(*FileMeta); !ok || meta.Hash != otherHash {
+		t.Errorf("unexpected metadata: %#v", fi.Sys())
+	}
+
+	// the files written by Overlay have the hashes of the same algorithm.
+	o := NewOverlay()
+	if err := o.WriteFile("/new.txt", []byte("other\n")); err != nil {
+		t.Fatal(err)
+	}
+	f, err = o.Open("/new.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err = f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta, ok := fi.Sys().(*FileMeta); !ok || meta.Hash != otherHash {
+		t.Errorf("unexpected metadata: %#v", fi.Sys())
+	}
+}
+
+func TestETag(t *testing.T) {
+	ts := httptest.NewServer(Handler())
+	defer ts.Close()
+
+	name := Fingerprint("/other.txt")
+	resp, err := http.Get(ts.URL + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if want := strconv.Quote(otherHash); etag != want {
+		t.Errorf("want %s, got %s", want, etag)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("want %d, got %d", http.StatusNotModified, resp.StatusCode)
+	}
+}
@@ -0,0 +1,66 @@
+package images
+
+import (
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	f, err := Root.Open("/photo-32w.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 32 || b.Dy() != 16 {
+		t.Errorf("unexpected size: %v", b)
+	}
+
+	// the image is not enlarged.
+	if _, err := Root.Open("/photo-128w.png"); err == nil {
+		t.Error("want an error, got nil")
+	}
+}
+
+func TestNegotiateImages(t *testing.T) {
+	h := Handler(NegotiateImages())
+	tests := []struct {
+		accept string
+		typ    string
+		body   string
+	}{
+		{"image/avif,image/webp,*/*", "image/avif", "fake avif\n"},
+		{"image/avif;q=0,image/webp", "image/webp", "fake webp\n"},
+		{"image/*,*/*;q=0.8", "image/png", ""},
+		{"", "image/png", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/photo.png", nil)
+		req.Header.Set("Accept", tt.accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if typ := rec.Header().Get("Content-Type"); typ != tt.typ {
+			t.Errorf("%q: want %q, got %q", tt.accept, tt.typ, typ)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%q: unexpected body: %q", tt.accept, rec.Body.String())
+		}
+		if vary := rec.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("%q: unexpected Vary: %q", tt.accept, vary)
+		}
+	}
+
+	// the resized variants don't have the alternates.
+	req := httptest.NewRequest(http.MethodGet, "/photo-32w.png", nil)
+	req.Header.Set("Accept", "image/avif")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if typ := rec.Header().Get("Content-Type"); typ != "image/png" {
+		t.Errorf("want image/png, got %q", typ)
+	}
+}
@@ -0,0 +1,51 @@
+package intern
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIntern(t *testing.T) {
+	for _, name := range []string{"/app.css", "/copy/app.css"} {
+		f, err := Root.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "body { color: red; }\n" {
+			t.Errorf("%s: unexpected content: %q", name, b)
+		}
+	}
+
+	// the same content is written once.
+	src, err := ioutil.ReadFile("filesystem.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(src), `"body { color: red; }\n"`); n != 1 {
+		t.Errorf("the shared content is written %d times", n)
+	}
+}
+
+func TestStats(t *testing.T) {
+	want := AssetStats{
+		Files:         3,
+		Dirs:          2,
+		TotalBytes:    48,
+		EmbeddedBytes: 27,
+		Largest: []FileStat{
+			{Name: "/app.css", Size: 21},
+			{Name: "/copy/app.css", Size: 21},
+			{Name: "/other.txt", Size: 6},
+		},
+	}
+	if got := Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v, got %#v", want, got)
+	}
+}
@@ -0,0 +1,26 @@
+package assets
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestInternal(t *testing.T) {
+	if _, err := Root.Open("/file.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile("doc.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"github.com/shogo82148/assets-life/test/internal/assets"`
+	if !strings.Contains(string(b), want) {
+		t.Errorf("the import path %s is not documented: %s", want, string(b))
+	}
+	want = "imported only from the packages in github.com/shogo82148/assets-life/test."
+	if !strings.Contains(string(b), want) {
+		t.Errorf("the internal package is not documented: %s", string(b))
+	}
+}
@@ -0,0 +1,50 @@
+//go:build js && wasm
+// +build js,wasm
+
+package js
+
+import (
+	"os"
+	"syscall/js"
+	"testing"
+)
+
+func TestReadJS(t *testing.T) {
+	arr, err := ReadJS("/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !arr.InstanceOf(js.Global().Get("Uint8Array")) {
+		t.Errorf("want Uint8Array, got %s", arr.Type())
+	}
+	if arr.Length() != 0 {
+		t.Errorf("unexpected length: %d", arr.Length())
+	}
+
+	if _, err := ReadJS("/missing.txt"); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+	if _, err := ReadJS("/"); err == nil {
+		t.Error("want error for reading a directory, got nil")
+	}
+}
+
+func TestExposeJS(t *testing.T) {
+	ExposeJS("assets")
+	assets := js.Global().Get("assets")
+
+	if v := assets.Call("read", "/file.txt"); !v.InstanceOf(js.Global().Get("Uint8Array")) {
+		t.Errorf("want Uint8Array, got %s", v.Type())
+	}
+	if v := assets.Call("read", "/missing.txt"); !v.IsNull() {
+		t.Errorf("want null, got %s", v.Type())
+	}
+
+	resp := assets.Call("response", "/file.txt")
+	if !resp.InstanceOf(js.Global().Get("Response")) {
+		t.Fatalf("want Response, got %s", resp.Type())
+	}
+	if got := resp.Get("headers").Call("get", "Content-Type").String(); got != "text/plain; charset=utf-8" {
+		t.Errorf("unexpected Content-Type: %q", got)
+	}
+}
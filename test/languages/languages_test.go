@@ -0,0 +1,50 @@
+package languages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLanguages(t *testing.T) {
+	h := Handler(Languages("en", "ja", "pt-BR"))
+
+	tests := []struct {
+		path           string
+		acceptLanguage string
+		status         int
+		lang           string
+		body           string
+	}{
+		{"/help.html", "", http.StatusOK, "en", "help\n"},
+		{"/help.html", "ja", http.StatusOK, "ja", "ヘルプ\n"},
+		{"/help.html", "ja-JP, en;q=0.5", http.StatusOK, "ja", "ヘルプ\n"},
+		{"/help.html", "fr, ja;q=0.8, en;q=0.9", http.StatusOK, "en", "help\n"},
+		{"/help.html", "pt-br", http.StatusOK, "pt-BR", "ajuda\n"},
+		{"/help.html", "pt-PT", http.StatusOK, "pt-BR", "ajuda\n"},
+		{"/help.html", "fr", http.StatusOK, "en", "help\n"},
+		{"/help.html", "ja;q=0, *", http.StatusOK, "en", "help\n"},
+		{"/english.html", "ja", http.StatusOK, "en", "only in english\n"},
+		{"/", "en", http.StatusOK, "en", "en\n"},
+		{"/ja/help.html", "en", http.StatusOK, "", "ヘルプ\n"},
+		{"/favicon.ico", "ja", http.StatusOK, "", "favicon\n"},
+		{"/missing.html", "ja", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s %q: unexpected status: want %d, got %d", tt.path, tt.acceptLanguage, tt.status, rec.Code)
+		}
+		if lang := rec.Header().Get("Content-Language"); lang != tt.lang {
+			t.Errorf("%s %q: unexpected language: want %q, got %q", tt.path, tt.acceptLanguage, tt.lang, lang)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s %q: unexpected body: want %q, got %q", tt.path, tt.acceptLanguage, tt.body, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package lazy
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+// decoded returns the names of the files whose contents are decoded.
+func decoded(t *testing.T) []string {
+	t.Helper()
+	names := []string{}
+	for i := range files {
+		f := &files[i]
+		if f.FileMode.IsDir() {
+			continue
+		}
+		if f.Lazy == nil {
+			t.Fatalf("%s: the content is not decoded lazily", f.Path)
+		}
+		if f.Lazy.content != "" {
+			names = append(names, f.Path)
+		}
+	}
+	return names
+}
+
+func TestPreload(t *testing.T) {
+	// nothing is decoded on the initialization, nor by Stats.
+	Stats()
+	if got := decoded(t); len(got) != 0 {
+		t.Fatalf("decoded before the first access: %v", got)
+	}
+
+	if err := Preload("/copy"); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded(t); len(got) != 1 || got[0] != "/copy/app.css" {
+		t.Errorf("unexpected decoded files: %v", got)
+	}
+
+	if err := Preload("/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("want fs.ErrNotExist, got %v", err)
+	}
+
+	// the first access decodes the file.
+	f, err := Root.Open("/other.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if got := decoded(t); len(got) != 2 || got[1] != "/other.txt" {
+		t.Errorf("unexpected decoded files: %v", got)
+	}
+
+	// no paths preload all the files.
+	if err := Preload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded(t); len(got) != 3 {
+		t.Errorf("unexpected decoded files: %v", got)
+	}
+}
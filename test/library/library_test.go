@@ -0,0 +1,369 @@
+package library
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/shogo82148/assets-life/assetslife"
+)
+
+func TestBuild(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "public")
+	b := assetslife.New("../../testdata/deep", out, assetslife.WithPackageName("assets"), assetslife.WithExclude("bb"))
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := os.ReadFile(filepath.Join(out, "filesystem.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "\npackage assets\n") {
+		t.Error("unexpected package name")
+	}
+	if !strings.Contains(string(src), `-exclude bb`) {
+		t.Error("the directive doesn't have -exclude")
+	}
+	if !strings.Contains(string(src), `"/a"`) || strings.Contains(string(src), `"/aa/bb/c"`) {
+		t.Error("the excluded files are embedded")
+	}
+
+	// the generator embedded into the package is the command.
+	self, err := os.ReadFile(filepath.Join(out, "assets-life.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(self), "\npackage main\n") {
+		t.Error("the embedded generator is not package main")
+	}
+	if !strings.Contains(string(self), "\nfunc main() {\n") {
+		t.Error("the embedded generator doesn't have main")
+	}
+}
+
+func TestBuild_Warnings(t *testing.T) {
+	// the Builders count their own warnings, even if they run concurrently.
+	sources := []map[string]string{
+		{"index.html": "<h1>Hello</h1>"},
+		{"index.html": "<h1>Hello</h1>", "debug.log": "log", "core": "dump"},
+	}
+	builders := make([]*assetslife.Builder, len(sources))
+	logs := make([]*bytes.Buffer, len(sources))
+	var wg sync.WaitGroup
+	errs := make([]error, len(sources))
+	for i, src := range sources {
+		logs[i] = new(bytes.Buffer)
+		out := filepath.Join(t.TempDir(), "public")
+		builders[i] = assetslife.New("", out, assetslife.WithSource(assetslife.MapSource(src)), assetslife.WithLogOutput(logs[i]), assetslife.WithQuiet())
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = builders[i].Build(context.Background())
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+	if got := builders[0].Warnings(); got != 0 {
+		t.Errorf("want no warnings, got %d: %s", got, logs[0])
+	}
+	if got := builders[1].Warnings(); got != 2 {
+		t.Errorf("want 2 warnings, got %d: %s", got, logs[1])
+	}
+	if !strings.Contains(logs[1].String(), "debug.log looks like a log file") {
+		t.Errorf("the warning is not written: %s", logs[1])
+	}
+}
+
+func TestBuild_Compression(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "public")
+	css := strings.Repeat("body { color: red; }\n", 100)
+	src := assetslife.MapSource(map[string]string{
+		"app.css":   css,
+		"small.txt": "a",
+		"image.png": "\x89PNG\r\n\x1a\n",
+	})
+	if err := assetslife.New("", out, assetslife.WithSource(src), assetslife.WithCompression(assetslife.Gzip)).Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(out, "filesystem.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `-compression gzip`) {
+		t.Error("the directive doesn't have -compression")
+	}
+	if !strings.Contains(string(b), "GzipEncoded:   true") {
+		t.Error("the handler doesn't serve the gzip variants")
+	}
+
+	fsys, err := assetslife.BuildFS(src, assetslife.WithCompression(assetslife.Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := fs.ReadFile(fsys, "app.css.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != css {
+		t.Error("the gzip variant has the different content")
+	}
+	// the variants are added only for the text files that they make smaller.
+	for _, name := range []string{"small.txt.gz", "image.png.gz"} {
+		if _, err := fs.Stat(fsys, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s is added: %v", name, err)
+		}
+	}
+
+	err = assetslife.New("", out, assetslife.WithSource(src), assetslife.WithCompression("brotli")).Build(context.Background())
+	var usageErr *assetslife.UsageError
+	if !errors.As(err, &usageErr) {
+		t.Errorf("want UsageError, got %v", err)
+	}
+}
+
+func TestBuild_Hooks(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "public")
+	var files []string
+	b := assetslife.New("../../testdata/intern", out,
+		assetslife.WithFilter(func(f *assetslife.File) bool {
+			return f.Name != "/other.txt"
+		}),
+		assetslife.WithTransform(func(f *assetslife.File) error {
+			f.Name = strings.Replace(f.Name, "/copy/", "/vendor/", 1)
+			f.Content = []byte(strings.ToUpper(string(f.Content)))
+			return nil
+		}),
+		assetslife.WithOnFile(func(f *assetslife.File) {
+			files = append(files, f.Name)
+		}),
+	)
+	if err := b.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(files, " "), "/app.css /vendor/app.css"; got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+	src, err := os.ReadFile(filepath.Join(out, "filesystem.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), `"/vendor/app.css"`) || strings.Contains(string(src), `"/other.txt"`) {
+		t.Error("the hooks are not applied")
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "public")
+	err := assetslife.New("../../testdata/deep", out, assetslife.WithNoNet(), assetslife.WithTemplate("custom.tmpl")).Build(context.Background())
+	var usageErr *assetslife.UsageError
+	if !errors.As(err, &usageErr) {
+		t.Errorf("want UsageError, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := assetslife.New("../../testdata/deep", out).Build(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestBuild_InvalidTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "invalid.tmpl")
+	if err := os.WriteFile(tmpl, []byte("package {{.Package}}\n\nvar Files = map[string]string{\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "public")
+	err := assetslife.New("../../testdata/deep", out, assetslife.WithTemplate(tmpl)).Build(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "generated filesystem.go is invalid Go source code") ||
+		!strings.Contains(err.Error(), "var Files = map[string]string{") {
+		t.Errorf("want the syntax error with the offending line, got %v", err)
+	}
+
+	// the invalid source is not written.
+	if _, err := os.Stat(filepath.Join(out, "filesystem.go")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("want fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestBuild_Source(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "public")
+	src := assetslife.MapSource(map[string]string{
+		"index.html":  "<h1>Hello</h1>",
+		"css/app.css": "body {}",
+	})
+	if err := assetslife.New("", out, assetslife.WithSource(src)).Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(out, "filesystem.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{`"/"`, `"/css"`, `"/css/app.css"`, `"/index.html"`} {
+		if !strings.Contains(string(b), name) {
+			t.Errorf("%s is not embedded", name)
+		}
+	}
+
+	err = assetslife.New("", out, assetslife.WithSource(src), assetslife.WithGitRef("HEAD")).Build(context.Background())
+	var usageErr *assetslife.UsageError
+	if !errors.As(err, &usageErr) {
+		t.Errorf("want UsageError, got %v", err)
+	}
+}
+
+func TestBuildFS(t *testing.T) {
+	src := assetslife.MapSource(map[string]string{
+		"index.html":  "<h1>Hello</h1>",
+		"css/app.css": "body {}",
+		"app.js.map":  "{}",
+	})
+	fsys, err := assetslife.BuildFS(src,
+		assetslife.WithExclude("*.map"),
+		assetslife.WithTransform(func(f *assetslife.File) error {
+			f.Content = []byte(strings.ToUpper(string(f.Content)))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "index.html", "css/app.css"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := fs.ReadFile(fsys, "index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "<H1>HELLO</H1>" {
+		t.Errorf("unexpected content: %q", b)
+	}
+	if _, err := fs.Stat(fsys, "app.js.map"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the excluded file exists: %v", err)
+	}
+}
+
+func TestBuildFS_Variant(t *testing.T) {
+	fsys, err := assetslife.BuildFS(assetslife.DirSource("../../testdata/file"),
+		assetslife.WithConfig("../../testdata/variants.json"),
+		assetslife.WithVariant("staging"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the staging variant has its own input.
+	if err := fstest.TestFS(fsys, "a", "aa/bb/c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(fsys, "file.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the file of the default variant exists: %v", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	src := assetslife.MapSource(map[string]string{"index.html": "<h1>Hello</h1>"})
+	files, err := assetslife.Generate(context.Background(), src, assetslife.WithPackageName("public"), assetslife.WithNoNet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"assets-life.go", "doc.go", "filesystem.go", "filesystem-http.go"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("%s is not generated", name)
+		}
+	}
+	if !strings.Contains(string(files["filesystem.go"]), `"<h1>Hello</h1>"`) {
+		t.Error("the file is not embedded")
+	}
+
+	// nothing is written to the disk.
+	if _, err := os.Stat("filesystem.go"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("filesystem.go is written: %v", err)
+	}
+}
+
+func TestBuild_CheckCompile(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "public")
+	if err := assetslife.New("../../testdata/deep", out, assetslife.WithStandalone(), assetslife.WithCheckCompile("vet")).Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "filesystem.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the package that doesn't compile is not written.
+	tmpl := filepath.Join(dir, "broken.tmpl")
+	if err := os.WriteFile(tmpl, []byte("package {{.Package}}\n\nvar Broken int = \"string\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken")
+	err := assetslife.New("../../testdata/deep", broken, assetslife.WithTemplate(tmpl), assetslife.WithCheckCompile("build")).Build(context.Background())
+	var validationErr *assetslife.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("want ValidationError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "filesystem.go:3") {
+		t.Errorf("the error doesn't have the message of go build: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(broken, "filesystem.go")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the broken package is written: %v", err)
+	}
+	entries, err := os.ReadDir(broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("the temporary directory is left: %v", entries)
+	}
+
+	// the temporary directory is outside of the module, so the package that imports the runtime cannot be compiled.
+	err = assetslife.New("../../testdata/deep", filepath.Join(dir, "runtime"), assetslife.WithCheckCompile("vet")).Build(context.Background())
+	if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), "-standalone") {
+		t.Errorf("want ValidationError that suggests -standalone, got %v", err)
+	}
+
+	err = assetslife.New("../../testdata/deep", out, assetslife.WithCheckCompile("test")).Build(context.Background())
+	var usageErr *assetslife.UsageError
+	if !errors.As(err, &usageErr) {
+		t.Errorf("want UsageError, got %v", err)
+	}
+}
+
+func TestBuild_Hash(t *testing.T) {
+	// the generated hashes of each algorithm compile.
+	for _, alg := range []string{"crc32", "sha1", "sha256", "blake3"} {
+		out := filepath.Join(t.TempDir(), "public")
+		if err := assetslife.New("../../testdata/intern", out, assetslife.WithHash(alg), assetslife.WithStandalone(), assetslife.WithCheckCompile("vet")).Build(context.Background()); err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		b, err := os.ReadFile(filepath.Join(out, "filesystem.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), `const HashAlgorithm = "`+alg+`"`) {
+			t.Errorf("%s: the package doesn't have HashAlgorithm", alg)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package listing
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestJSONListing(t *testing.T) {
+	h := Handler(JSONListing())
+
+	req := httptest.NewRequest(http.MethodGet, "/?format=json", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("unexpected content type: %q", ct)
+	}
+	var got []ListingEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []ListingEntry{
+		{Name: "a.txt", Size: 2, Type: "file"},
+		{Name: "b", Type: "dir"},
+		{Name: "c.txt", Size: 2, Type: "file"},
+		{Name: "d", Type: "dir"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if !strings.Contains(rec.Body.String(), `"mtime":`) {
+		t.Errorf("the listing doesn't have mtime: %s", rec.Body.String())
+	}
+
+	// the query is kept by the redirect to the directory.
+	req = httptest.NewRequest(http.MethodGet, "/b?format=json", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if loc := rec.Header().Get("Location"); rec.Code != http.StatusMovedPermanently || loc != "b/?format=json" {
+		t.Errorf("unexpected redirect: %d, %q", rec.Code, loc)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/not-found/?format=json", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unexpected status: %d", rec.Code)
+	}
+}
+
+func TestWithoutJSONListing(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?format=json", nil)
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("unexpected content type: %q", ct)
+	}
+}
+
+func TestListingTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("listing").Parse(
+		`<h1>{{.Path}}</h1>{{range .Entries}}<a href="{{.Name}}">{{.Name}}</a>{{end}}`,
+	))
+	h := Handler(ListingTemplate(tmpl))
+
+	req := httptest.NewRequest(http.MethodGet, "/b/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("unexpected content type: %q", ct)
+	}
+	want := `<h1>/b</h1><a href="x.txt">x.txt</a>`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	// the files are served as they are.
+	req = httptest.NewRequest(http.MethodGet, "/b/x.txt", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Body.String(); got != "x\n" {
+		t.Errorf("unexpected body: %q", got)
+	}
+}
+
+func TestHeadAndOptions(t *testing.T) {
+	tmpl := template.Must(template.New("listing").Parse(`{{range .Entries}}{{.Name}} {{end}}`))
+	tests := []struct {
+		h      http.Handler
+		target string
+	}{
+		{Handler(), "/a.txt"},
+		{Handler(JSONListing()), "/?format=json"},
+		{Handler(ListingTemplate(tmpl)), "/"},
+		{Handler(CSPNonce("script-src 'nonce-{nonce}'")), "/a.txt"},
+	}
+	for _, tt := range tests {
+		get := httptest.NewRecorder()
+		tt.h.ServeHTTP(get, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		head := httptest.NewRecorder()
+		tt.h.ServeHTTP(head, httptest.NewRequest(http.MethodHead, tt.target, nil))
+
+		if get.Code != http.StatusOK || head.Code != http.StatusOK {
+			t.Errorf("%s: unexpected status: GET %d, HEAD %d", tt.target, get.Code, head.Code)
+		}
+		if head.Body.Len() != 0 {
+			t.Errorf("%s: want empty body, got %q", tt.target, head.Body.String())
+		}
+		if got, want := head.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+			t.Errorf("%s: unexpected content length: want %s, got %s", tt.target, want, got)
+		}
+		if got, want := head.Header().Get("Content-Type"), get.Header().Get("Content-Type"); got != want {
+			t.Errorf("%s: unexpected content type: want %s, got %s", tt.target, want, got)
+		}
+	}
+
+	// the error pages have no bodies either.
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/missing.txt", nil))
+	if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+		t.Errorf("unexpected response: %d, %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/a.txt", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if got := rec.Header().Get("Allow"); got != "GET, HEAD, OPTIONS" {
+		t.Errorf("unexpected allow: %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/a.txt", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Errorf("unexpected response: %d, %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,39 @@
+package markdown
+
+import (
+	"io"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	f, err := Root.Open("/guide/setup.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<title>Setup &lt;guide&gt;</title>
+<main data-path="/guide/setup.html">
+<h1>Setup &lt;guide&gt;</h1>
+<p>Install the <strong>generator</strong>, then see <a href="usage.html#flags">usage</a>.</p>
+<pre><code class="language-sh">go run assets-life.go
+</code></pre>
+</main>
+`
+	if string(b) != want {
+		t.Errorf("want %q, got %q", want, b)
+	}
+
+	// the Markdown file is replaced with the HTML file.
+	if _, err := Root.Open("/guide/setup.md"); err == nil {
+		t.Error("want an error, got nil")
+	}
+
+	// the Markdown files that do not match the rule are embedded as they are.
+	if _, err := Root.Open("/README.md"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type request struct {
+	name   string
+	status int
+	bytes  int64
+}
+
+type recorder struct {
+	requests []request
+}
+
+func (r *recorder) RecordRequest(name string, status int, bytes int64, latency time.Duration) {
+	if latency < 0 {
+		panic("negative latency")
+	}
+	r.requests = append(r.requests, request{name, status, bytes})
+}
+
+func TestMetrics(t *testing.T) {
+	rec := &recorder{}
+	h := Handler(Metrics(rec))
+
+	for _, p := range []string{"/data/a.json", "/missing.txt", "/fonts/a.woff2", "/data"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodHead, "/data/a.json", nil))
+
+	want := []request{
+		{"/data/a.json", http.StatusOK, 3},
+		{"", http.StatusNotFound, 19},
+		{"/fonts/a.woff2", http.StatusOK, 5},
+		{"", http.StatusMovedPermanently, 0},
+		{"/data/a.json", http.StatusOK, 0},
+	}
+	if len(rec.requests) != len(want) {
+		t.Fatalf("unexpected requests: want %v, got %v", want, rec.requests)
+	}
+	for i := range want {
+		if rec.requests[i] != want[i] {
+			t.Errorf("unexpected request #%d: want %v, got %v", i, want[i], rec.requests[i])
+		}
+	}
+}
+
+func TestMetrics_Names(t *testing.T) {
+	rec := &recorder{}
+	h := Handler(Metrics(rec), Authorize("/fonts/**", func(r *http.Request, name string) bool { return false }))
+
+	cases := []struct {
+		method string
+		path   string
+		name   string
+		status int
+	}{
+		{http.MethodGet, "/", "/index.html", http.StatusOK},
+		{http.MethodGet, "/data/", "/data", http.StatusOK},
+		{http.MethodGet, "/fonts/a.woff2", "", http.StatusForbidden},
+		{http.MethodPost, "/data/a.json", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/index.html", "", http.StatusMovedPermanently},
+		{http.MethodGet, "/missing/../../missing.txt", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		rec.requests = nil
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(c.method, c.path, nil))
+		if len(rec.requests) != 1 {
+			t.Fatalf("%s %s: unexpected requests: %v", c.method, c.path, rec.requests)
+		}
+		if got := rec.requests[0]; got.name != c.name || got.status != c.status {
+			t.Errorf("%s %s: want %q %d, got %q %d", c.method, c.path, c.name, c.status, got.name, got.status)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package mount
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	mux := http.NewServeMux()
+	Mount(mux, "/static/")
+
+	tests := []struct {
+		method   string
+		path     string
+		status   int
+		location string
+	}{
+		{http.MethodGet, "/static/", http.StatusOK, ""},
+		{http.MethodHead, "/static/", http.StatusOK, ""},
+		{http.MethodGet, "/static/sub_dir/", http.StatusOK, ""},
+		{http.MethodGet, "/static", http.StatusMovedPermanently, "/static/"},
+		{http.MethodGet, "/static/sub_dir", http.StatusMovedPermanently, "sub_dir/"},
+		{http.MethodGet, "/static/missing.html", http.StatusNotFound, ""},
+		{http.MethodGet, "/index.html", http.StatusNotFound, ""},
+		{http.MethodPost, "/static/", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: unexpected status: want %d, got %d", tt.method, tt.path, tt.status, rec.Code)
+		}
+		if loc := rec.Header().Get("Location"); loc != tt.location {
+			t.Errorf("%s %s: unexpected location: want %q, got %q", tt.method, tt.path, tt.location, loc)
+		}
+		if tt.method == http.MethodHead && rec.Body.Len() != 0 {
+			t.Errorf("%s %s: want empty body, got %q", tt.method, tt.path, rec.Body.String())
+		}
+	}
+}
+
+func TestMountRoot(t *testing.T) {
+	mux := http.NewServeMux()
+	Mount(mux, "/")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sub_dir/index.html", nil))
+	if rec.Code != http.StatusMovedPermanently {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusMovedPermanently, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sub_dir/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusOK, rec.Code)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !nonet
+// +build !nonet
+
+package nonet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoot(t *testing.T) {
+	rec := httptest.NewRecorder()
+	http.FileServer(Root).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/aa/bb/c", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusOK, rec.Code)
+	}
+}
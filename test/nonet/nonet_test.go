@@ -0,0 +1,33 @@
+package nonet
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	if err := fstest.TestFS(FS, "a", "aa/bb/c"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFS_Invalid(t *testing.T) {
+	for _, name := range []string{"/a", "../a", "aa/", ""} {
+		if _, err := FS.Open(name); err == nil {
+			t.Errorf("%q: want error, got nil", name)
+		}
+	}
+	if _, err := fs.Stat(FS, "missing"); err == nil {
+		t.Error("want error, got nil")
+	}
+}
+
+func TestAPIVersion(t *testing.T) {
+	if got := APIVersionOf(FS); got != APIVersion {
+		t.Errorf("want %d, got %d", APIVersion, got)
+	}
+	if got := APIVersionOf(fstest.MapFS{}); got != 0 {
+		t.Errorf("want 0, got %d", got)
+	}
+}
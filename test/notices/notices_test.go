@@ -0,0 +1,55 @@
+package notices
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestLicenses(t *testing.T) {
+	want := []License{
+		{Name: "/theme.css", Text: "/**\n * theme v2\n * @license Apache-2.0\n */"},
+		{Name: "/vendor/lib/LICENSE", Text: "MIT License\n\nCopyright (c) Example Authors"},
+		{Name: "/vendor/lib/lib.js", Text: "/*! lib v1.0.0 | (c) Example Authors | MIT */"},
+	}
+	if got := Licenses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestNotices(t *testing.T) {
+	f, err := Root.Open("/NOTICES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `This file contains the licenses and the notices of the third-party files.
+
+/theme.css
+----------
+
+/**
+ * theme v2
+ * @license Apache-2.0
+ */
+
+/vendor/lib/LICENSE
+-------------------
+
+MIT License
+
+Copyright (c) Example Authors
+
+/vendor/lib/lib.js
+------------------
+
+/*! lib v1.0.0 | (c) Example Authors | MIT */
+`
+	if string(b) != want {
+		t.Errorf("want %q, got %q", want, b)
+	}
+}
@@ -0,0 +1,207 @@
+package overlay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFile(t *testing.T, fs http.FileSystem, name string) string {
+	t.Helper()
+	f, err := fs.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func readDir(t *testing.T, fs http.FileSystem, name string) []string {
+	t.Helper()
+	f, err := fs.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	infos, err := f.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
+
+func TestOverlay(t *testing.T) {
+	o := NewOverlay()
+	if err := o.WriteFile("/a", []byte("replaced")); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.WriteFile("/aa/bb/d", []byte("added")); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.WriteFile("/aa/new/e", []byte("new dir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Remove("/aa/bb/c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, o, "/a"); got != "replaced" {
+		t.Errorf("/a: want %q, got %q", "replaced", got)
+	}
+	if got := readFile(t, o, "/aa/new/e"); got != "new dir" {
+		t.Errorf("/aa/new/e: want %q, got %q", "new dir", got)
+	}
+	if _, err := o.Open("/aa/bb/c"); !os.IsNotExist(err) {
+		t.Errorf("/aa/bb/c: want not exist error, got %v", err)
+	}
+	if got, want := readDir(t, o, "/aa"), []string{"bb", "new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("/aa: want %v, got %v", want, got)
+	}
+	if got, want := readDir(t, o, "/aa/bb"), []string{"d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("/aa/bb: want %v, got %v", want, got)
+	}
+
+	// the embedded files are not modified.
+	if got := readFile(t, Root, "/a"); got == "replaced" {
+		t.Error("Root is modified")
+	}
+	if _, err := Root.Open("/aa/bb/c"); err != nil {
+		t.Errorf("Root is modified: %v", err)
+	}
+	if _, err := NewOverlay().Open("/aa/bb/d"); !os.IsNotExist(err) {
+		t.Errorf("the overlays must be independent: %v", err)
+	}
+}
+
+func TestOverlayErrors(t *testing.T) {
+	o := NewOverlay()
+	if err := o.WriteFile("/aa", []byte("file")); err == nil {
+		t.Error("want error for writing a directory")
+	}
+	if err := o.WriteFile("/a/b", []byte("file")); err == nil {
+		t.Error("want error for writing under a file")
+	}
+	if err := o.Remove("/missing"); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+	if err := o.Remove("/"); err == nil {
+		t.Error("want error for removing the root")
+	}
+
+	// removing a directory removes the files in it.
+	if err := o.Remove("/aa"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Open("/aa/bb/c"); !os.IsNotExist(err) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+	if got, want := readDir(t, o, "/"), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("/: want %v, got %v", want, got)
+	}
+}
+
+func TestSetOpenHook(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a"), []byte("shadowed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	SetOpenHook(func(name string) (http.File, bool) {
+		if name != "/a" {
+			return nil, false
+		}
+		f, err := http.Dir(dir).Open(name)
+		return f, err == nil
+	})
+	defer SetOpenHook(nil)
+
+	if got := readFile(t, Root, "/a"); got != "shadowed" {
+		t.Errorf("unexpected content: %q", got)
+	}
+	if got := readFile(t, Root, "/aa/bb/c"); got != "" {
+		t.Errorf("unexpected content: %q", got)
+	}
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "shadowed" {
+		t.Errorf("unexpected response: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// the files in the overlays take precedence over the hook, which opens the other names.
+	o := NewOverlay()
+	if err := o.WriteFile("/a", []byte("overlay")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, o, "/a"); got != "overlay" {
+		t.Errorf("unexpected content: %q", got)
+	}
+	if err := o.Remove("/a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, o, "/a"); got != "shadowed" {
+		t.Errorf("unexpected content: %q", got)
+	}
+
+	// nil removes the hook.
+	SetOpenHook(nil)
+	if got := readFile(t, Root, "/a"); got != "" {
+		t.Errorf("unexpected content: %q", got)
+	}
+}
+
+func TestOverlay_Sys(t *testing.T) {
+	o := NewOverlay()
+	if err := o.WriteFile("/a", []byte("<html>replaced</html>")); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.WriteFile("/aa/new.json", []byte(`{"added": true}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name        string
+		content     string
+		contentType string
+	}{
+		{"/a", "<html>replaced</html>", "text/html; charset=utf-8"},
+		{"/aa/new.json", `{"added": true}`, "application/json"},
+	} {
+		f, err := o.Open(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi, err := f.Stat()
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, ok := fi.Sys().(*FileMeta)
+		if !ok {
+			t.Fatalf("%s: unexpected Sys: %#v", c.name, fi.Sys())
+		}
+		sum := sha256.Sum256([]byte(c.content))
+		if want := hex.EncodeToString(sum[:]); meta.Hash != want {
+			t.Errorf("%s: want hash %s, got %s", c.name, want, meta.Hash)
+		}
+		if meta.ContentType != c.contentType {
+			t.Errorf("%s: want content type %q, got %q", c.name, c.contentType, meta.ContentType)
+		}
+		if meta.Source != c.name {
+			t.Errorf("%s: want source %q, got %q", c.name, c.name, meta.Source)
+		}
+	}
+}
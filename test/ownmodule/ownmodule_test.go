@@ -0,0 +1,21 @@
+package ownmodule
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestOwnModule(t *testing.T) {
+	if _, err := Root.Open("/file.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "\nmodule example.com/ownmodule\n") {
+		t.Errorf("unexpected go.mod: %s", string(b))
+	}
+}
@@ -0,0 +1,24 @@
+package platform
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	want := map[string]bool{
+		"/aa": runtime.GOOS == "windows",
+		"/bb": (runtime.GOOS == "linux" && runtime.GOARCH == "amd64") || runtime.GOOS == "darwin",
+		"/cc": true,
+	}
+	for name, embedded := range want {
+		_, err := Root.Open(name)
+		if embedded && err != nil {
+			t.Errorf("%s: want embedded, got %v", name, err)
+		}
+		if !embedded && !os.IsNotExist(err) {
+			t.Errorf("%s: want not exist, got %v", name, err)
+		}
+	}
+}
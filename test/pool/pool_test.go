@@ -0,0 +1,59 @@
+package pool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	f, err := Root.Open("/large.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Handler()
+
+	// the pooled files must not be shared by the concurrent requests.
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				start, end := i*10, i*10+j*100
+				req := httptest.NewRequest(http.MethodGet, "/large.txt", nil)
+				req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Code != http.StatusPartialContent {
+					t.Errorf("unexpected status: %d", rec.Code)
+					return
+				}
+				if got := rec.Body.String(); got != string(want[start:end+1]) {
+					t.Errorf("unexpected body of bytes=%d-%d: %d bytes", start, end, len(got))
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// the file opened by Root is not pooled.
+	req := httptest.NewRequest(http.MethodGet, "/large.txt", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Body.String() != string(want) {
+		t.Errorf("unexpected body: %d bytes", rec.Body.Len())
+	}
+	if fi, err := f.Stat(); err != nil || fi.Name() != "large.txt" {
+		t.Errorf("the file is reused: %v, %v", fi, err)
+	}
+}
@@ -0,0 +1,50 @@
+package precache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	f, err := Root.Open("/precache-manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var manifest []struct {
+		URL      string `json:"url"`
+		Revision string `json:"revision"`
+	}
+	if err := json.NewDecoder(f).Decode(&manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 2 {
+		t.Fatalf("unexpected manifest: %v", manifest)
+	}
+	if manifest[0].URL != "a" || manifest[1].URL != "aa/bb/c" {
+		t.Errorf("unexpected urls: %v", manifest)
+	}
+	for _, entry := range manifest {
+		if len(entry.Revision) != 16 {
+			t.Errorf("unexpected revision of %s: %q", entry.URL, entry.Revision)
+		}
+	}
+}
+
+func TestServiceWorker(t *testing.T) {
+	f, err := Root.Open("/sw.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"url": "aa/bb/c"`) {
+		t.Errorf("the manifest is not embedded into sw.js: %s", string(b))
+	}
+}
@@ -0,0 +1,89 @@
+package precompressed
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGzipSources(t *testing.T) {
+	h := Handler()
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		encoding       string
+		typ            string
+		body           string
+	}{
+		{"/app.js", "gzip, deflate, br", "gzip", "text/javascript; charset=utf-8", "console.log(\"app\");\n"},
+		// the decompressed file is added for the clients that do not accept gzip.
+		{"/app.js", "", "", "text/javascript; charset=utf-8", "console.log(\"app\");\n"},
+		{"/app.js", "gzip;q=0", "", "text/javascript; charset=utf-8", "console.log(\"app\");\n"},
+		// the existing file is kept as the uncompressed one.
+		{"/style.css", "gzip", "gzip", "text/css; charset=utf-8", "body { color: red }\n"},
+		{"/style.css", "", "", "text/css; charset=utf-8", "body {}\n"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		res := rec.Result()
+		if enc := res.Header.Get("Content-Encoding"); enc != tt.encoding {
+			t.Errorf("%s, %q: want encoding %q, got %q", tt.path, tt.acceptEncoding, tt.encoding, enc)
+		}
+		if typ := res.Header.Get("Content-Type"); typ != tt.typ {
+			t.Errorf("%s, %q: want type %q, got %q", tt.path, tt.acceptEncoding, tt.typ, typ)
+		}
+		if vary := res.Header.Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("%s, %q: want Vary Accept-Encoding, got %q", tt.path, tt.acceptEncoding, vary)
+		}
+		var body io.Reader = res.Body
+		if tt.encoding == "gzip" {
+			gz, err := gzip.NewReader(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = gz
+		}
+		b, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.body {
+			t.Errorf("%s, %q: want %q, got %q", tt.path, tt.acceptEncoding, tt.body, b)
+		}
+	}
+}
+
+func TestSys(t *testing.T) {
+	f, err := Root.Open("/style.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := Root.Open("/style.css.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+	gzi, err := gz.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := fi.Sys().(*FileMeta)
+	if meta.CompressedSize != gzi.Size() {
+		t.Errorf("unexpected compressed size: want %d, got %d", gzi.Size(), meta.CompressedSize)
+	}
+	if meta.Source != "/style.css" {
+		t.Errorf("unexpected source: %q", meta.Source)
+	}
+}
@@ -0,0 +1,51 @@
+package preload
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPreload(t *testing.T) {
+	h := Handler(PreloadHeaders())
+
+	tests := []struct {
+		path  string
+		links []string
+	}{
+		{"/", []string{"<css/app.css>; rel=preload; as=style", "<js/app.js?v=1>; rel=preload; as=script"}},
+		{"/docs/page.html", []string{"<../css/app.css>; rel=preload; as=style", "</js/app.js>; rel=modulepreload"}},
+		{"/plain.html", nil},
+		{"/css/app.css", nil},
+		{"/index.html", nil}, // redirected to "./"
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if links := rec.Header().Values("Link"); !reflect.DeepEqual(links, tt.links) {
+			t.Errorf("%s: unexpected links: want %q, got %q", tt.path, tt.links, links)
+		}
+	}
+}
+
+func TestPreloadLinks(t *testing.T) {
+	want := []string{"<css/app.css>; rel=preload; as=style", "<js/app.js?v=1>; rel=preload; as=script"}
+	if links := PreloadLinks("/index.html"); !reflect.DeepEqual(links, want) {
+		t.Errorf("want %q, got %q", want, links)
+	}
+	if links := PreloadLinks("/plain.html"); links != nil {
+		t.Errorf("want nil, got %q", links)
+	}
+	if links := PreloadLinks("/not-found.html"); links != nil {
+		t.Errorf("want nil, got %q", links)
+	}
+}
+
+func TestWithoutPreload(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if links := rec.Header().Values("Link"); len(links) != 0 {
+		t.Errorf("want no links, got %q", links)
+	}
+}
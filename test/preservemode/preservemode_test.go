@@ -0,0 +1,34 @@
+package preservemode
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows doesn't support the permission bits")
+	}
+
+	tests := []struct {
+		name string
+		mode os.FileMode
+	}{
+		{"/private_dir", 0750 | os.ModeDir},
+		{"/private_dir/private.txt", 0640},
+	}
+	for _, tt := range tests {
+		f, err := Root.Open(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.Mode() != tt.mode {
+			t.Errorf("%s: unexpected mode: want %s, got %s", tt.name, tt.mode, stat.Mode())
+		}
+	}
+}
@@ -2,6 +2,10 @@ package readdir
 
 import (
 	"io"
+	"io/fs"
+	"net/http"
+	"sort"
+	"sync"
 	"testing"
 )
 
@@ -108,3 +112,194 @@ func Test(t *testing.T) {
 		}
 	})
 }
+
+func TestReaddir_Seek(t *testing.T) {
+	dir, err := Root.Open("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	if _, err := dir.Readdir(-1); err != nil {
+		t.Fatal(err)
+	}
+
+	// seeking to the start restarts Readdir.
+	if _, err := dir.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	fis, err := dir.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 3 {
+		t.Errorf("got: %d, expect: 3", len(fis))
+	}
+}
+
+// the embedded directories work as the directories on the disk.
+func TestReaddir_OSFile(t *testing.T) {
+	for name, fsys := range map[string]http.FileSystem{
+		"os":       http.Dir("../../testdata/readdir"),
+		"embedded": Root,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := fsys.Open("/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dir.Close()
+			if fis, err := dir.Readdir(2); err != nil || len(fis) != 2 {
+				t.Fatalf("dir.Readdir(2) = %d, %v", len(fis), err)
+			}
+
+			// seeking to the start restarts Readdir in the middle of the iteration.
+			if _, err := dir.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			fis, err := dir.Readdir(-1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := make([]string, 0, len(fis))
+			for _, fi := range fis {
+				names = append(names, fi.Name())
+			}
+			sort.Strings(names)
+			if len(names) != 3 || names[0] != "aa" || names[1] != "bb" || names[2] != "cc" {
+				t.Errorf("unexpected entries: %v", names)
+			}
+
+			// reading a file as a directory is an error.
+			f, err := fsys.Open("/aa")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.Readdir(-1); err == nil {
+				t.Error("want an error, got nil")
+			}
+		})
+	}
+}
+
+func TestReaddir_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				dir, err := Root.Open("/")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				fis, err := dir.Readdir(-1)
+				dir.Close()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(fis) != 3 {
+					t.Errorf("got: %d, expect: 3", len(fis))
+					return
+				}
+				for _, fi := range fis {
+					if fi.IsDir() {
+						continue
+					}
+					f, err := Root.Open("/" + fi.Name())
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if _, err := io.Copy(io.Discard, f); err != nil {
+						t.Error(err)
+					}
+					f.Close()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Readdir and ReadDir of the embedded directories follow the semantics of os.File.
+func TestReaddir_Conformance(t *testing.T) {
+	type step struct {
+		count int
+		n     int
+		err   error
+	}
+	steps := []step{
+		{2, 2, nil},
+		{2, 1, nil},
+		{2, 0, io.EOF},
+		{2, 0, io.EOF}, // subsequent calls return io.EOF again
+		{-1, 0, nil},
+		{0, 0, nil},
+	}
+	type dirReader interface {
+		ReadDir(count int) ([]fs.DirEntry, error)
+	}
+
+	for name, fsys := range map[string]http.FileSystem{
+		"os":       http.Dir("../../testdata/readdir"),
+		"embedded": Root,
+	} {
+		t.Run(name+"/Readdir", func(t *testing.T) {
+			dir, err := fsys.Open("/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dir.Close()
+			for _, s := range steps {
+				fis, err := dir.Readdir(s.count)
+				if len(fis) != s.n || err != s.err {
+					t.Errorf("dir.Readdir(%d) = %d, %v; want %d, %v", s.count, len(fis), err, s.n, s.err)
+				}
+				if fis == nil {
+					t.Errorf("dir.Readdir(%d) returns nil slice", s.count)
+				}
+			}
+		})
+
+		t.Run(name+"/ReadDir", func(t *testing.T) {
+			dir, err := fsys.Open("/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dir.Close()
+			r, ok := dir.(dirReader)
+			if !ok {
+				t.Fatal("want ReadDir, but it is not implemented")
+			}
+			for _, s := range steps {
+				entries, err := r.ReadDir(s.count)
+				if len(entries) != s.n || err != s.err {
+					t.Errorf("dir.ReadDir(%d) = %d, %v; want %d, %v", s.count, len(entries), err, s.n, s.err)
+				}
+			}
+		})
+	}
+
+	// the entries of ReadDir have the same information as Readdir.
+	dir, err := Root.Open("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	entries, err := dir.(dirReader).ReadDir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Name() != entry.Name() || info.IsDir() != entry.IsDir() || info.Mode().Type() != entry.Type() {
+			t.Errorf("%s: the entry and its info differ", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package remote
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	f, err := Root.Open("/vendor/lib.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "// lib.js\n" {
+		t.Errorf("unexpected content: want %q, got %q", "// lib.js\n", string(b))
+	}
+
+	// local files are also embedded.
+	if _, err := Root.Open("/file.txt"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,67 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"testing"
+)
+
+func readPublicKey(t *testing.T) ed25519.PublicKey {
+	t.Helper()
+	b, err := os.ReadFile("../../testdata/sign.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatal("no PEM block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key.(ed25519.PublicKey)
+}
+
+func TestVerifySignature(t *testing.T) {
+	pub := readPublicKey(t)
+	if err := VerifySignature(pub); err != nil {
+		t.Fatal(err)
+	}
+
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignature(other); err == nil {
+		t.Error("want an error for the other key, got nil")
+	}
+	if err := VerifySignature(pub[:8]); err == nil {
+		t.Error("want an error for the invalid key, got nil")
+	}
+}
+
+func TestVerifySignature_Modified(t *testing.T) {
+	pub := readPublicKey(t)
+	for i := range files {
+		if files[i].Path == "/other.txt" {
+			defer func(content string) { files[i].Content = content }(files[i].Content)
+			files[i].Content = "modified\n"
+		}
+	}
+	if err := VerifySignature(pub); err == nil {
+		t.Error("want an error for the modified file, got nil")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	want := "9767e91e9d4b0334e59a1d389e9801bc6a2c5c4a5500a3c2c7915687965b2c16  /app.css\n" +
+		"9767e91e9d4b0334e59a1d389e9801bc6a2c5c4a5500a3c2c7915687965b2c16  /copy/app.css\n" +
+		"7e4fa2eb8c7ac089739d5defc4489fad68a100d92082ca35c6b40a4524821f87  /other.txt\n"
+	if got := Manifest(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,49 @@
+package sourcemaps
+
+import (
+	"io"
+	"testing"
+)
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	f, err := Root.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSourceMaps(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		stripped  string
+		sourceMap string
+	}{
+		{"/app.js", "console.log(\"app\");\n", "/app.js.map"},
+		{"/style.css", "body {}\n", "/style.css.map"},
+	} {
+		got := readFile(t, tt.name)
+		_, err := Root.Open(tt.sourceMap)
+		if debug {
+			if got == tt.stripped {
+				t.Errorf("%s: the sourceMappingURL comment is removed in the debug build", tt.name)
+			}
+			if err != nil {
+				t.Errorf("%s: %v", tt.sourceMap, err)
+			}
+		} else {
+			if got != tt.stripped {
+				t.Errorf("%s: want %q, got %q", tt.name, tt.stripped, got)
+			}
+			if err == nil {
+				t.Errorf("%s: want an error, got nil", tt.sourceMap)
+			}
+		}
+	}
+}
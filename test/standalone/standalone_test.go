@@ -0,0 +1,69 @@
+package standalone
+
+import (
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestImports(t *testing.T) {
+	for _, name := range []string{"filesystem.go", "filesystem-runtime.go"} {
+		f, err := parser.ParseFile(token.NewFileSet(), name, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the packages of the standard library have no dots in their first elements.
+			if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+				t.Errorf("%s: want only the standard library, but it imports %s", name, path)
+			}
+		}
+	}
+}
+
+func TestRoot(t *testing.T) {
+	f, err := Root.Open("/aa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	list, err := f.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name() != "bb" || !list[0].IsDir() {
+		t.Errorf("unexpected entries: %v", list)
+	}
+
+	if _, err := Root.Open("/missing"); err == nil {
+		t.Error("want error, got nil")
+	}
+	if got := APIVersionOf(Root); got != APIVersion {
+		t.Errorf("unexpected api version: want %d, got %d", APIVersion, got)
+	}
+}
+
+func TestMount(t *testing.T) {
+	mux := http.NewServeMux()
+	Mount(mux, "/static/")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/aa/bb/c", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/static/a", strings.NewReader("")))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
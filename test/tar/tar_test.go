@@ -0,0 +1,44 @@
+package tar
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	files := map[string]string{
+		"/dist/index.html": "<h1>hello</h1>\n",
+		"/dist/js/app.js":  "console.log('hello');\n",
+	}
+	for name, content := range files {
+		f, err := Root.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("%s: unexpected content: want %q, got %q", name, content, string(b))
+		}
+	}
+
+	dir, err := Root.Open("/dist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fis, err := dir.Readdir(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 2 {
+		t.Errorf("want %d, got %d", 2, len(fis))
+	}
+
+	_, err = Root.Open("/dist/.hidden_file")
+	if !os.IsNotExist(err) {
+		t.Errorf("hidden file will be not exist, but %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package template
+
+import (
+	"bytes"
+	"go/format"
+	"os"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	if len(Files) != 1 {
+		t.Errorf("want %d, got %d", 1, len(Files))
+	}
+	content, ok := Files["/file.txt"]
+	if !ok {
+		t.Fatal("/file.txt is not found")
+	}
+	if content != "" {
+		t.Errorf("unexpected content: want empty, got %q", content)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	// the output of the custom template is formatted, even though the template is not.
+	src, err := os.ReadFile("filesystem.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, formatted) {
+		t.Errorf("filesystem.go is not formatted:\n%s", src)
+	}
+}
@@ -0,0 +1,34 @@
+package textvars
+
+import (
+	"io"
+	"testing"
+)
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	f, err := Root.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestTemplates(t *testing.T) {
+	// API_BASE is the default in the configuration file, and VERSION is overridden by -var.
+	want := "window.config = {\n  apiBase: \"/api\",\n  version: \"1.2.3\"\n};\n"
+	if got := readFile(t, "/config.js"); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	// the files that do not match the rule are embedded as they are.
+	want = "<p>{{not expanded}}</p>\n"
+	if got := readFile(t, "/index.html"); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
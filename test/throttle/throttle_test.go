@@ -0,0 +1,65 @@
+package throttle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func get(h http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/large.txt", nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestThrottleBytes(t *testing.T) {
+	// the file is 6060 bytes, so it takes 0.5 seconds after the burst of 4000 bytes.
+	h := Handler(Throttle(ThrottleConfig{BytesPerSecond: 4000}))
+	start := time.Now()
+	rec := get(h, "192.0.2.1:1234")
+	if rec.Code != http.StatusOK || rec.Body.Len() != 6060 {
+		t.Fatalf("unexpected response: %d, %d bytes", rec.Code, rec.Body.Len())
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("the bandwidth is not limited: %s", d)
+	}
+}
+
+func TestThrottleBytesPerIP(t *testing.T) {
+	h := Handler(Throttle(ThrottleConfig{BytesPerSecondPerIP: 6060}))
+
+	// the burst of each IP address is enough for the file.
+	start := time.Now()
+	get(h, "192.0.2.1:1234")
+	get(h, "192.0.2.2:1234")
+	if d := time.Since(start); d > 400*time.Millisecond {
+		t.Errorf("the IP addresses must have their own buckets: %s", d)
+	}
+
+	// the bucket of the IP address is empty.
+	start = time.Now()
+	get(h, "192.0.2.1:1234")
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("the bandwidth is not limited: %s", d)
+	}
+}
+
+func TestThrottleRequests(t *testing.T) {
+	h := Handler(Throttle(ThrottleConfig{RequestsPerSecondPerIP: 0.5, RequestBurstPerIP: 2}))
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rec := get(h, "192.0.2.1:1234")
+		if rec.Code != want {
+			t.Errorf("request #%d: unexpected status: want %d, got %d", i, want, rec.Code)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "2" {
+			t.Errorf("unexpected Retry-After: %q", rec.Header().Get("Retry-After"))
+		}
+	}
+	if rec := get(h, "192.0.2.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("unexpected status of another IP address: %d", rec.Code)
+	}
+}
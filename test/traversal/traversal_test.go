@@ -0,0 +1,152 @@
+package traversal
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// embedded is the names of the embedded files.
+var embedded = map[string]bool{
+	"/":        true,
+	"/a":       true,
+	"/aa":      true,
+	"/aa/bb":   true,
+	"/aa/bb/c": true,
+}
+
+// hostile is the names that try to escape the embedded files.
+var hostile = []string{
+	"",
+	"a",
+	"//a",
+	"/a/",
+	"/./a",
+	"/../a",
+	"/aa/../a",
+	"/aa/bb/../../a",
+	"/..",
+	"\\a",
+	"/..\\a",
+	"/aa\\bb\\c",
+	"/aa/bb/c\x00",
+	"/a\x00/../a",
+	"/%2e%2e/a",
+	"/..%2fa",
+}
+
+func TestOpen(t *testing.T) {
+	overlay := NewOverlay()
+	for _, name := range hostile {
+		if _, err := Root.Open(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Root.Open(%q): want fs.ErrNotExist, got %v", name, err)
+		}
+		if _, err := OpenContext(context.Background(), name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("OpenContext(%q): want fs.ErrNotExist, got %v", name, err)
+		}
+		if _, err := overlay.Open(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Overlay.Open(%q): want fs.ErrNotExist, got %v", name, err)
+		}
+	}
+	if err := overlay.WriteFile("/aa\\bb", []byte("x")); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Overlay.WriteFile: want fs.ErrInvalid, got %v", err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	// all names of up to 3 elements are opened, and only the embedded files are found.
+	elems := []string{"", ".", "..", "a", "aa", "bb", "c", "%2e%2e", "\\", "aa\\bb", "\x00"}
+	names := []string{""}
+	for i := 0; i < 3; i++ {
+		var next []string
+		for _, name := range names {
+			for _, elem := range elems {
+				next = append(next, name+"/"+elem)
+			}
+		}
+		names = append(names, next...)
+	}
+	for _, name := range names {
+		f, err := Root.Open(name)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Root.Open(%q): want fs.ErrNotExist, got %v", name, err)
+			}
+			continue
+		}
+		f.Close()
+		if !embedded[name] {
+			t.Errorf("Root.Open(%q): opened the file out of the embedded files", name)
+		}
+	}
+}
+
+func TestRootWithFallback(t *testing.T) {
+	dir := t.TempDir()
+	public := filepath.Join(dir, "public")
+	if err := os.Mkdir(public, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(public, "patch.txt"), []byte("patch"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fsys := RootWithFallback(public)
+	f, err := fsys.Open("/patch.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	for _, name := range append(hostile, "/../secret.txt", "../secret.txt", "/..\\secret.txt", "/patch.txt\x00") {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Open(%q): want fs.ErrNotExist, got %v", name, err)
+		}
+	}
+
+	h := Composite(map[string]http.FileSystem{"/static": fsys})
+	for _, target := range []string{
+		"/static/..%2fsecret.txt",
+		"/static/%2e%2e/secret.txt",
+		"/static/%2e%2e%2fsecret.txt",
+		"/static/..%5csecret.txt",
+		"/static/patch.txt%00",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusNotFound || strings.Contains(rec.Body.String(), "secret") {
+			t.Errorf("%s: unexpected response: %d, %q", target, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/aa/bb/c", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	for _, target := range []string{
+		"/%2e%2e/a",
+		"/aa/%2e%2e/a",
+		"/aa/..%2fa",
+		"/aa%5cbb%5cc",
+		"/a%00",
+		"/aa/bb/c%00.html",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: want %d, got %d", target, http.StatusNotFound, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package variant
+
+import "testing"
+
+func Test(t *testing.T) {
+	var want []string
+	switch Variant {
+	case "":
+		want = []string{"file.txt"}
+	case "staging":
+		want = []string{"a", "aa"}
+	case "prod":
+		want = []string{"aa", "cc"}
+	default:
+		t.Fatalf("unknown variant: %q", Variant)
+	}
+
+	dir, err := Root.Open("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fis, err := dir.Readdir(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != len(want) {
+		t.Fatalf("want %d, got %d", len(want), len(fis))
+	}
+	for i, fi := range fis {
+		if fi.Name() != want[i] {
+			t.Errorf("want %q, got %q", want[i], fi.Name())
+		}
+	}
+}
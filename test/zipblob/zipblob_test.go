@@ -0,0 +1,102 @@
+package zipblob
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestContent(t *testing.T) {
+	b, err := ioutil.ReadFile("../../testdata/intern/app.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/app.css", "/copy/app.css"} {
+		f, err := Root.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(b) {
+			t.Errorf("%s: want %q, got %q", name, b, got)
+		}
+	}
+
+	src, err := ioutil.ReadFile("filesystem.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(src), "color: red") {
+		t.Error("the content is written as the string literal")
+	}
+}
+
+func TestZipFile(t *testing.T) {
+	r, err := zip.OpenReader("assets-life-data/filesystem.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// the shared content is written once.
+	if len(r.File) != 2 {
+		t.Errorf("want 2 entries, got %d", len(r.File))
+	}
+	for _, f := range r.File {
+		if f.Method != zip.Deflate {
+			t.Errorf("%s: want deflate, got method %d", f.Name, f.Method)
+		}
+	}
+}
+
+func TestLazy(t *testing.T) {
+	i, ok := assets.files.lookup("/other.txt")
+	if !ok {
+		t.Fatal("/other.txt is not embedded")
+	}
+	f := &files[i]
+	if f.Lazy == nil {
+		t.Fatal("the content is not decoded lazily")
+	}
+	if f.Lazy.content != "" {
+		t.Fatal("the content is decoded before the first access")
+	}
+
+	// listing the directory doesn't decode the content.
+	dir, err := Root.Open("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	fis, err := dir.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range fis {
+		if fi.Name() == "other.txt" && fi.Size() != int64(len("other\n")) {
+			t.Errorf("unexpected size: %d", fi.Size())
+		}
+	}
+	if f.Lazy.content != "" {
+		t.Error("the content is decoded by Readdir")
+	}
+
+	// opening the file decodes the content.
+	file, err := Root.Open("/other.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "other\n" || f.Lazy.content != "other\n" {
+		t.Errorf("unexpected content: %q", got)
+	}
+}
@@ -0,0 +1,2 @@
+/* not a license */
+console.log("app");
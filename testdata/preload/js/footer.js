@@ -0,0 +1 @@
+// footer
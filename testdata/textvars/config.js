@@ -0,0 +1,4 @@
+window.config = {
+  apiBase: "{{.API_BASE}}",
+  version: "{{.VERSION}}"
+};